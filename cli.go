@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const usage = `Usage:
+  oapi-gen                      start the interactive menu
+  oapi-gen generate [flags] <spec>
+  oapi-gen mock [flags] <spec>
+  oapi-gen verify [flags] -base-url <url> <spec>
+  oapi-gen validate [flags] <spec>
+
+Commands:
+  generate   generate a server and/or clients from an OpenAPI spec
+  mock       serve a spec's operations from examples, without generating code
+  verify     check that a running server honours a spec
+  validate   check a spec against the OpenAPI meta-schema and lint rules
+
+Run 'oapi-gen <command> -h' for the flags of a command.
+`
+
+// runCommand runs oapi-gen non-interactively and returns the exit code
+func runCommand(args []string, stdout, stderr io.Writer) int {
+	switch args[0] {
+	case "generate":
+		return runGenerate(args[1:], stdout, stderr)
+	case "mock":
+		return runMock(args[1:], stdout, stderr)
+	case "verify":
+		return runVerify(args[1:], stdout, stderr)
+	case "validate":
+		return runValidate(args[1:], stdout, stderr)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+}
+
+// runGenerate writes the server into the output directory and, with -client,
+// a Go client package into its client subdirectory. -cli adds a command-line
+// client under cmd and -ts a TypeScript package in a directory of its own.
+func runGenerate(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("generate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	specPath := fs.String("spec", "", "path to the OpenAPI spec in JSON or YAML (or pass it as an argument)")
+	outputDir := fs.String("out", "generated", "output directory")
+	server := fs.Bool("server", true, "generate the server")
+	client := fs.Bool("client", false, "generate a Go client package in <out>/client")
+	cli := fs.Bool("cli", false, "generate a command-line client in <out>/cmd/<name of the API>")
+	tsDir := fs.String("ts", "", "generate a TypeScript types and fetch client package in this directory")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *specPath == "" && fs.NArg() > 0 {
+		*specPath = fs.Arg(0)
+	}
+	if *specPath == "" {
+		fmt.Fprintln(stderr, "generate: no spec given")
+		fs.Usage()
+		return 2
+	}
+	if !*server && !*client && !*cli && *tsDir == "" {
+		fmt.Fprintln(stderr, "generate: nothing to generate with -server=false and no -client, -cli or -ts")
+		return 2
+	}
+
+	spec, err := readOpenAPISpec(*specPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "generate: %v\n", err)
+		return 1
+	}
+	if err := os.MkdirAll(*outputDir, 0755); err != nil {
+		fmt.Fprintf(stderr, "generate: error creating output directory: %v\n", err)
+		return 1
+	}
+	if *server {
+		if err := generateCode(spec, *outputDir); err != nil {
+			fmt.Fprintf(stderr, "generate: error generating code: %v\n", err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "Server generated in %s\n", *outputDir)
+	}
+	if *client {
+		dir := filepath.Join(*outputDir, "client")
+		if err := generateClient(spec, dir); err != nil {
+			fmt.Fprintf(stderr, "generate: error generating client: %v\n", err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "Go client generated in %s\n", dir)
+	}
+	if *cli {
+		dir := filepath.Join(*outputDir, "cmd", specSlug(spec))
+		if err := generateCLIClient(spec, dir); err != nil {
+			fmt.Fprintf(stderr, "generate: error generating command-line client: %v\n", err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "Command-line client generated in %s\n", dir)
+	}
+	if *tsDir != "" {
+		if err := generateTypeScript(spec, *tsDir); err != nil {
+			fmt.Fprintf(stderr, "generate: error generating TypeScript: %v\n", err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "TypeScript package generated in %s\n", *tsDir)
+	}
+	return 0
+}
+
+// runMock serves the spec until interrupted, logging each request
+func runMock(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("mock", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	specPath := fs.String("spec", "", "path to the OpenAPI spec in JSON or YAML (or pass it as an argument)")
+	addr := fs.String("addr", ":8080", "address to listen on")
+	stateful := fs.Bool("stateful", false, "keep created resources in memory so they can be read, updated and deleted")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *specPath == "" && fs.NArg() > 0 {
+		*specPath = fs.Arg(0)
+	}
+	if *specPath == "" {
+		fmt.Fprintln(stderr, "mock: no spec given")
+		fs.Usage()
+		return 2
+	}
+
+	spec, err := readOpenAPISpec(*specPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "mock: %v\n", err)
+		return 1
+	}
+	logf := func(line string) {
+		fmt.Fprintf(stdout, "%s %s\n", time.Now().Format("15:04:05"), line)
+	}
+	server, listening, err := startMock(spec, *addr, *stateful, logf)
+	if err != nil {
+		fmt.Fprintf(stderr, "mock: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "Mock server for %s listening on %s\n", *specPath, listening)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	<-stop
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server.Shutdown(ctx)
+	return 0
+}
+
+// headerFlags collects repeated -header "Name: value" flags
+type headerFlags http.Header
+
+func (h headerFlags) String() string {
+	return ""
+}
+
+func (h headerFlags) Set(value string) error {
+	name, v, ok := strings.Cut(value, ":")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("want Name: value, got %q", value)
+	}
+	http.Header(h).Add(strings.TrimSpace(name), strings.TrimSpace(v))
+	return nil
+}
+
+// queryFlags collects repeated -query name=value flags
+type queryFlags url.Values
+
+func (q queryFlags) String() string {
+	return ""
+}
+
+func (q queryFlags) Set(value string) error {
+	name, v, ok := strings.Cut(value, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("want name=value, got %q", value)
+	}
+	url.Values(q).Add(name, v)
+	return nil
+}
+
+// runVerify checks every operation of the spec against a running server and
+// writes the results as JUnit XML and JSON. It fails when any operation
+// breaks the contract.
+func runVerify(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	specPath := fs.String("spec", "", "path to the OpenAPI spec in JSON or YAML (or pass it as an argument)")
+	baseURL := fs.String("base-url", "", "URL the server is reached at, e.g. http://localhost:8080")
+	junitFile := fs.String("junit", "verify-report.xml", "JUnit XML report file, empty for none")
+	jsonFile := fs.String("json", "verify-report.json", "JSON report file, empty for none")
+	timeout := fs.Duration("timeout", 10*time.Second, "timeout of each request")
+	header := headerFlags{}
+	fs.Var(header, "header", `header sent with every request, e.g. "Authorization: Bearer <token>" (repeatable)`)
+	query := queryFlags{}
+	fs.Var(query, "query", "query parameter sent with every request, e.g. api_key=<key> (repeatable)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *specPath == "" && fs.NArg() > 0 {
+		*specPath = fs.Arg(0)
+	}
+	if *specPath == "" || *baseURL == "" {
+		fmt.Fprintln(stderr, "verify: a spec and -base-url are required")
+		fs.Usage()
+		return 2
+	}
+
+	spec, err := readOpenAPISpec(*specPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "verify: %v\n", err)
+		return 1
+	}
+	v, err := newVerifier(spec, *baseURL, http.Header(header), url.Values(query), *timeout)
+	if err != nil {
+		fmt.Fprintf(stderr, "verify: %v\n", err)
+		return 1
+	}
+	report := v.run(func(r verifyResult) {
+		verdict := "PASS"
+		if !r.Passed() {
+			verdict = "FAIL"
+		}
+		fmt.Fprintf(stdout, "%s  %s %s (%s)", verdict, r.Method, r.Path, r.OperationID)
+		if r.Status != 0 {
+			fmt.Fprintf(stdout, " %d in %.1fms", r.Status, r.DurationMS)
+		}
+		fmt.Fprintln(stdout)
+		if r.Error != "" {
+			fmt.Fprintf(stdout, "      %s\n", r.Error)
+		}
+		for _, problem := range r.Problems {
+			fmt.Fprintf(stdout, "      %s\n", problem)
+		}
+	})
+	report.Spec = *specPath
+	fmt.Fprintf(stdout, "\n%d operations: %d passed, %d failed\n", len(report.Results), report.Passed, report.Failed)
+
+	if *junitFile != "" {
+		if err := writeJUnit(report, *junitFile); err != nil {
+			fmt.Fprintf(stderr, "verify: writing JUnit report: %v\n", err)
+			return 1
+		}
+	}
+	if *jsonFile != "" {
+		if err := writeJSONReport(report, *jsonFile); err != nil {
+			fmt.Fprintf(stderr, "verify: writing JSON report: %v\n", err)
+			return 1
+		}
+	}
+	if report.Failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// runValidate reports the problems the rules find in a spec, as text or
+// JSON. It fails when any of them is an error.
+func runValidate(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	specPath := fs.String("spec", "", "path to the OpenAPI spec in JSON or YAML (or pass it as an argument)")
+	rulesPath := fs.String("rules", "", "YAML or JSON ruleset file (default "+defaultRulesFile+" when it exists)")
+	format := fs.String("format", "text", "output format, text or json")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *specPath == "" && fs.NArg() > 0 {
+		*specPath = fs.Arg(0)
+	}
+	if *specPath == "" {
+		fmt.Fprintln(stderr, "validate: no spec given")
+		fs.Usage()
+		return 2
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(stderr, "validate: unknown format %q, want text or json\n", *format)
+		return 2
+	}
+
+	rules, err := loadRuleset(*rulesPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "validate: %v\n", err)
+		return 2
+	}
+	_, diagnostics, err := loadSpec(*specPath, rules)
+	if err != nil {
+		fmt.Fprintf(stderr, "validate: %v\n", err)
+		return 1
+	}
+	if *format == "json" {
+		report := struct {
+			Spec        string       `json:"spec"`
+			Errors      int          `json:"errors"`
+			Warnings    int          `json:"warnings"`
+			Diagnostics []diagnostic `json:"diagnostics"`
+		}{*specPath, countSeverity(diagnostics, "error"), countSeverity(diagnostics, "warning"), diagnostics}
+		if report.Diagnostics == nil {
+			report.Diagnostics = []diagnostic{}
+		}
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+	} else {
+		for _, d := range diagnostics {
+			fmt.Fprintln(stdout, d.format(*specPath))
+		}
+		fmt.Fprintf(stdout, "%s: %s\n", *specPath, summarizeDiagnostics(diagnostics))
+	}
+	if countSeverity(diagnostics, "error") > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,924 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// kebabCase turns an identifier such as listUsers or X-Request-Id into a
+// command or flag name such as list-users or x-request-id
+func kebabCase(name string) string {
+	name = regexp.MustCompile(`([a-z0-9])([A-Z])`).ReplaceAllString(name, "$1-$2")
+	name = regexp.MustCompile(`[^a-zA-Z0-9]+`).ReplaceAllString(name, "-")
+	return strings.Trim(strings.ToLower(name), "-")
+}
+
+// cliReservedFlags are the flags every generated command has
+var cliReservedFlags = map[string]bool{
+	"base-url": true, "profile": true, "output": true, "o": true, "body": true,
+	"content-type": true, "idempotency-key": true, "h": true, "help": true,
+}
+
+// cliParam is a flag of a generated command
+type cliParam struct {
+	Name     string
+	Flag     string
+	In       string // path, query, header, cookie, or body for fields of a JSON body
+	Type     string
+	Items    string // item type of arrays
+	Required bool
+}
+
+// cliParamType returns the type and, for arrays, the item type of a schema
+func cliParamType(schema map[string]interface{}, schemas map[string]Schema) (string, string) {
+	if ref, _ := schema["$ref"].(string); ref != "" {
+		if s, ok := findSchema(schemas, strings.TrimPrefix(ref, "#/components/schemas/")); ok {
+			schema = schemaMap(s)
+		}
+	}
+	t, _ := schema["type"].(string)
+	if t == "array" {
+		items, _ := schema["items"].(map[string]interface{})
+		itemType, _ := items["type"].(string)
+		if itemType == "" {
+			itemType = "object"
+		}
+		return t, itemType
+	}
+	if t == "" {
+		t = "object"
+	}
+	return t, ""
+}
+
+// bodyFields lists flags for the top-level properties of an operation's JSON
+// body, so simple bodies can be given without writing JSON
+func bodyFields(op operation, schemas map[string]Schema, taken map[string]bool) []cliParam {
+	reqBody, _ := op.Endpoint["requestBody"].(map[string]interface{})
+	content, _ := reqBody["content"].(map[string]interface{})
+	mediaType := jsonMediaType(content)
+	if mediaType == "" || mediaType == jsonPatchMediaType {
+		return nil
+	}
+	media, _ := content[mediaType].(map[string]interface{})
+	schema, _ := media["schema"].(map[string]interface{})
+	if _, ok := schema["properties"]; !ok && schema["$ref"] == nil && op.Method == "PATCH" {
+		// Merge patches without their own schema change fields of the document
+		// the operation returns
+		content, _ := operationSuccess(op).Response["content"].(map[string]interface{})
+		media, _ := content[jsonMediaType(content)].(map[string]interface{})
+		schema, _ = media["schema"].(map[string]interface{})
+	}
+	if ref, _ := schema["$ref"].(string); ref != "" {
+		s, ok := findSchema(schemas, strings.TrimPrefix(ref, "#/components/schemas/"))
+		if !ok {
+			return nil
+		}
+		schema = schemaMap(s)
+	}
+	properties, _ := schema["properties"].(map[string]interface{})
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var fields []cliParam
+	for _, name := range names {
+		prop, _ := properties[name].(map[string]interface{})
+		if prop["format"] == "binary" {
+			continue
+		}
+		t, items := cliParamType(prop, schemas)
+		flag := kebabCase(name)
+		if taken[flag] {
+			flag = "body-" + flag
+		}
+		taken[flag] = true
+		fields = append(fields, cliParam{Name: name, Flag: flag, In: "body", Type: t, Items: items})
+	}
+	return fields
+}
+
+// generateCLIClient writes a command-line client for the spec into dir
+func generateCLIClient(spec *OpenAPISpec, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := writeFile(filepath.Join(dir, "main.go"), generateCLIMainCode(spec)); err != nil {
+		return err
+	}
+	schemas, err := collectSchemas(spec)
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(dir, "operations.go"), generateCLIOperationsCode(spec, collectOperations(spec.Paths), schemas))
+}
+
+// generateCLIOperationsCode renders the table of commands and security
+// schemes the command-line client is driven by
+func generateCLIOperationsCode(spec *OpenAPISpec, ops []operation, schemas map[string]Schema) string {
+	var code strings.Builder
+	code.WriteString("package main\n\n")
+	code.WriteString("// operations lists a command per operation, grouped by its first tag\n")
+	code.WriteString("var operations = []operation{\n")
+	prefix := strings.ToUpper(strings.ReplaceAll(specSlug(spec), "-", "_"))
+	schemes := extractSecuritySchemes(spec.Components)
+	for _, op := range ops {
+		group := ""
+		if tags := routeTags(op); len(tags) > 0 {
+			group = kebabCase(tags[0])
+		}
+		summary, _ := op.Endpoint["summary"].(string)
+		code.WriteString(fmt.Sprintf("    {Group: %q, Name: %q, OperationID: %q, Method: %q, Path: %q, Summary: %q,\n",
+			group, kebabCase(op.OperationID), op.OperationID, op.Method, op.Path, strings.TrimSuffix(summary, ".")))
+
+		taken := map[string]bool{}
+		for flag := range cliReservedFlags {
+			taken[flag] = true
+		}
+		var params []cliParam
+		for _, p := range operationParameters(op, spec.Components) {
+			flag := kebabCase(p.Name)
+			if taken[flag] {
+				flag = "param-" + flag
+			}
+			if taken[flag] {
+				flag += "-" + p.In
+			}
+			taken[flag] = true
+			t, items := cliParamType(p.Schema, schemas)
+			params = append(params, cliParam{Name: p.Name, Flag: flag, In: p.In, Type: t, Items: items, Required: p.Required || p.In == "path"})
+		}
+		if body := requestBodyShape(op); body != nil {
+			reqBody, _ := op.Endpoint["requestBody"].(map[string]interface{})
+			required, _ := reqBody["required"].(bool)
+			code.WriteString(fmt.Sprintf("        BodyType: %q, BodyRequired: %t,\n", body.MediaType, required))
+			params = append(params, bodyFields(op, schemas, taken)...)
+		}
+		if len(params) > 0 {
+			code.WriteString("        Params: []param{\n")
+			for _, p := range params {
+				code.WriteString(fmt.Sprintf("            {Name: %q, Flag: %q, In: %q, Type: %q", p.Name, p.Flag, p.In, p.Type))
+				if p.Items != "" {
+					code.WriteString(fmt.Sprintf(", Items: %q", p.Items))
+				}
+				if p.Required {
+					code.WriteString(", Required: true")
+				}
+				code.WriteString("},\n")
+			}
+			code.WriteString("        },\n")
+		}
+		if resp := responseBodyShape(op); resp != nil {
+			code.WriteString(fmt.Sprintf("        Accept: %q,\n", resp.MediaType))
+		}
+		if reqs := operationSecurity(op, spec, schemes); len(reqs) > 0 {
+			alternatives := make([]string, 0, len(reqs))
+			for _, req := range reqs {
+				names := make([]string, 0, len(req))
+				for name := range req {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				alternatives = append(alternatives, strings.TrimPrefix(stringList(names), "[]string"))
+			}
+			code.WriteString(fmt.Sprintf("        Security: [][]string{%s},\n", strings.Join(alternatives, ", ")))
+		}
+		if idempotencyEnabled(op, spec.Extensions) {
+			code.WriteString("        Idempotent: true,\n")
+		}
+		code.WriteString("    },\n")
+	}
+	code.WriteString("}\n\n")
+
+	names := make([]string, 0, len(schemes))
+	for name := range schemes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	code.WriteString("// schemes lists the security schemes and the environment variables holding\n")
+	code.WriteString("// their credentials\n")
+	code.WriteString("var schemes = map[string]scheme{\n")
+	for _, name := range names {
+		s := schemes[name]
+		code.WriteString(fmt.Sprintf("    %q: {Type: %q, Scheme: %q, In: %q, Name: %q, Env: %q},\n", name, s.Type, s.Scheme, s.In, s.Name, prefix+"_"+envName(name)))
+	}
+	code.WriteString("}\n")
+	return code.String()
+}
+
+// generateCLIMainCode renders the runtime of the command-line client: flag
+// parsing, configuration, the request and output formatting
+func generateCLIMainCode(spec *OpenAPISpec) string {
+	slug := specSlug(spec)
+	title, _ := spec.Info["title"].(string)
+	if title == "" {
+		title = "the API"
+	}
+	// Generated servers mount the paths at the root, so only the scheme and
+	// host of the first server are kept
+	baseURL := "http://localhost:8080"
+	if len(spec.Servers) > 0 {
+		raw, _ := spec.Servers[0]["url"].(string)
+		if u, err := url.Parse(raw); err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" {
+			baseURL = u.Scheme + "://" + u.Host
+		}
+	}
+
+	var code strings.Builder
+	code.WriteString(fmt.Sprintf("// Command %s calls %s from the command line.\n", slug, title))
+	code.WriteString("package main\n\n")
+	code.WriteString("import (\n    \"bytes\"\n    \"crypto/rand\"\n    \"encoding/hex\"\n    \"encoding/json\"\n    \"errors\"\n    \"flag\"\n    \"fmt\"\n    \"io\"\n    \"net/http\"\n    \"net/url\"\n    \"os\"\n    \"path/filepath\"\n    \"regexp\"\n    \"sort\"\n    \"strconv\"\n    \"strings\"\n    \"text/tabwriter\"\n)\n\n")
+	code.WriteString("const (\n")
+	code.WriteString(fmt.Sprintf("    envPrefix      = %q // prefix of the environment variables read\n", strings.ToUpper(strings.ReplaceAll(slug, "-", "_"))))
+	code.WriteString(fmt.Sprintf("    configName     = %q // directory of config.json under the user config directory\n", slug))
+	code.WriteString(fmt.Sprintf("    defaultBaseURL = %q\n", baseURL))
+	code.WriteString(")\n\n")
+	code.WriteString(`// operation describes the command calling one operation
+type operation struct {
+    Group        string // first tag of the operation; empty for untagged operations
+    Name         string
+    OperationID  string
+    Method       string
+    Path         string
+    Summary      string
+    BodyType     string // media type of the request body; empty without one
+    BodyRequired bool
+    Params       []param
+    Accept       string
+    Security     [][]string // alternatives of schemes that must all be sent
+    Idempotent   bool       // POST honouring Idempotency-Key
+}
+
+// param is a flag of a command: a parameter, or a field of a JSON body
+type param struct {
+    Name     string
+    Flag     string
+    In       string // path, query, header, cookie or body
+    Type     string
+    Items    string // item type of arrays
+    Required bool
+}
+
+// scheme is a security scheme of the API
+type scheme struct {
+    Type   string
+    Scheme string
+    In     string
+    Name   string
+    Env    string // environment variable holding the credentials
+}
+
+`)
+	code.WriteString("// profile is an entry of the config file, which maps profile names to\n")
+	code.WriteString("// profiles. Credentials are keyed by scheme; HTTP basic ones are user:password.\n")
+	code.WriteString("type profile struct {\n")
+	code.WriteString("    BaseURL     string            `json:\"base_url\"`\n")
+	code.WriteString("    Credentials map[string]string `json:\"credentials\"`\n")
+	code.WriteString("}\n\n")
+	code.WriteString(`func main() {
+    os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes a command and returns the exit code: 1 when the request failed
+// and 2 for usage errors
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+    prog := filepath.Base(os.Args[0])
+    if len(args) == 0 {
+        printUsage(stderr, prog, "")
+        return 2
+    }
+    if args[0] == "-h" || args[0] == "-help" || args[0] == "--help" || args[0] == "help" {
+        printUsage(stdout, prog, "")
+        return 0
+    }
+    for _, op := range operations {
+        if op.Group == "" && op.Name == args[0] {
+            return runOperation(prog, op, args[1:], stdin, stdout, stderr)
+        }
+        if op.Group == args[0] && len(args) > 1 && op.Name == args[1] {
+            return runOperation(prog, op, args[2:], stdin, stdout, stderr)
+        }
+    }
+    for _, op := range operations {
+        if op.Group != "" && op.Group == args[0] {
+            if len(args) > 1 && args[1] != "-h" && args[1] != "-help" && args[1] != "--help" {
+                fmt.Fprintf(stderr, "unknown command %q in %s\n\n", args[1], args[0])
+            }
+            printUsage(stderr, prog, args[0])
+            return 2
+        }
+    }
+    fmt.Fprintf(stderr, "unknown command %q\n\n", args[0])
+    printUsage(stderr, prog, "")
+    return 2
+}
+
+// printUsage lists the commands, or those of one group
+func printUsage(w io.Writer, prog, group string) {
+    fmt.Fprintf(w, "Usage: %s [group] <command> [flags]\n\nCommands:\n", prog)
+    tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+    for _, op := range operations {
+        if group != "" && op.Group != group {
+            continue
+        }
+        fmt.Fprintf(tw, "  %s\t%s %s", strings.TrimSpace(op.Group+" "+op.Name), op.Method, op.Path)
+        if op.Summary != "" {
+            fmt.Fprintf(tw, "\t%s", op.Summary)
+        }
+        fmt.Fprintln(tw)
+    }
+    tw.Flush()
+    fmt.Fprintf(w, "\nEvery command takes -base-url, -profile and -output (json, table or yaml).\n")
+    fmt.Fprintf(w, "\nEnvironment:\n")
+    tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+    fmt.Fprintf(tw, "  %s_BASE_URL\tbase URL, default %s\n", envPrefix, defaultBaseURL)
+    fmt.Fprintf(tw, "  %s_PROFILE\tprofile of the config file, default \"default\"\n", envPrefix)
+    fmt.Fprintf(tw, "  %s_CONFIG\tconfig file, default %s\n", envPrefix, configPath())
+    names := make([]string, 0, len(schemes))
+    for name := range schemes {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    for _, name := range names {
+        s := schemes[name]
+        switch {
+        case s.Type == "apiKey":
+            fmt.Fprintf(tw, "  %s\tAPI key for %s\n", s.Env, name)
+        case s.Type == "http" && s.Scheme == "basic":
+            fmt.Fprintf(tw, "  %s\tuser:password for %s\n", s.Env, name)
+        default:
+            fmt.Fprintf(tw, "  %s\tbearer token for %s\n", s.Env, name)
+        }
+    }
+    tw.Flush()
+    fmt.Fprintf(w, "\nRun '%s <command> -h' for the flags of a command.\n", prog)
+}
+
+// paramValue collects the value of a parameter flag; array flags can be
+// repeated or take comma-separated values
+type paramValue struct {
+    param  param
+    values []string
+}
+
+func (v *paramValue) String() string {
+    return strings.Join(v.values, ",")
+}
+
+func (v *paramValue) Set(s string) error {
+    if v.param.Type == "array" {
+        v.values = append(v.values, strings.Split(s, ",")...)
+    } else {
+        v.values = []string{s}
+    }
+    return nil
+}
+
+func (v *paramValue) IsBoolFlag() bool {
+    return v.param.Type == "boolean"
+}
+
+// runOperation parses the flags of a command, sends its request and prints
+// the response
+func runOperation(prog string, op operation, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+    command := strings.Join(strings.Fields(prog+" "+op.Group+" "+op.Name), " ")
+    fs := flag.NewFlagSet(command, flag.ContinueOnError)
+    fs.SetOutput(stderr)
+    baseURL := fs.String("base-url", "", "base URL of the API (env "+envPrefix+"_BASE_URL)")
+    profileName := fs.String("profile", "", "profile of the config file (env "+envPrefix+"_PROFILE)")
+    output := fs.String("output", "json", "output format: json, table or yaml")
+    fs.StringVar(output, "o", "json", "shorthand for -output")
+    var body, contentType, idempotencyKey string
+    if op.BodyType != "" {
+        fs.StringVar(&body, "body", "", "request body: the text itself, @file to read a file, or - to read stdin")
+        fs.StringVar(&contentType, "content-type", op.BodyType, "media type of the request body")
+    }
+    if op.Idempotent {
+        fs.StringVar(&idempotencyKey, "idempotency-key", "", "Idempotency-Key to send; random by default, reuse one to retry safely")
+    }
+    values := make([]*paramValue, len(op.Params))
+    for i, p := range op.Params {
+        values[i] = &paramValue{param: p}
+        usage := p.In + " parameter " + p.Name
+        if p.In == "body" {
+            usage = "body field " + p.Name
+        }
+        usage += " (" + p.Type
+        if p.Items != "" {
+            usage += " of " + p.Items + ", repeatable"
+        }
+        if p.Required {
+            usage += ", required"
+        }
+        fs.Var(values[i], p.Flag, usage+")")
+    }
+    fs.Usage = func() {
+        fmt.Fprintf(stderr, "Usage: %s [flags]\n\n%s %s", command, op.Method, op.Path)
+        if op.Summary != "" {
+            fmt.Fprintf(stderr, ": %s", op.Summary)
+        }
+        fmt.Fprintf(stderr, "\n\nFlags:\n")
+        fs.PrintDefaults()
+    }
+    if err := fs.Parse(args); err != nil {
+        if err == flag.ErrHelp {
+            return 0
+        }
+        return 2
+    }
+    if fs.NArg() > 0 {
+        fmt.Fprintf(stderr, "unexpected argument %q\n", fs.Arg(0))
+        fs.Usage()
+        return 2
+    }
+    switch *output {
+    case "json", "table", "yaml":
+    default:
+        fmt.Fprintf(stderr, "unknown output format %q, use json, table or yaml\n", *output)
+        return 2
+    }
+    for _, v := range values {
+        if v.param.Required && len(v.values) == 0 {
+            fmt.Fprintf(stderr, "missing required flag -%s\n", v.param.Flag)
+            fs.Usage()
+            return 2
+        }
+    }
+
+    prof, err := loadProfile(*profileName)
+    if err != nil {
+        fmt.Fprintln(stderr, err)
+        return 2
+    }
+    base := firstNonEmpty(*baseURL, os.Getenv(envPrefix+"_BASE_URL"), prof.BaseURL, defaultBaseURL)
+    req, err := buildRequest(op, strings.TrimSuffix(base, "/"), values, body, contentType, stdin)
+    if err != nil {
+        fmt.Fprintln(stderr, err)
+        return 2
+    }
+    if op.Idempotent {
+        if idempotencyKey == "" {
+            buf := make([]byte, 16)
+            rand.Read(buf)
+            idempotencyKey = hex.EncodeToString(buf)
+        }
+        req.Header.Set("Idempotency-Key", idempotencyKey)
+    }
+    authorize(req, op.Security, prof)
+
+    resp, err := http.DefaultClient.Do(req)
+    if err != nil {
+        fmt.Fprintln(stderr, err)
+        return 1
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode < 200 || resp.StatusCode > 299 {
+        fmt.Fprintf(stderr, "%s\n", resp.Status)
+        io.Copy(stderr, resp.Body)
+        return 1
+    }
+    if !isJSON(resp.Header.Get("Content-Type")) {
+        if _, err := io.Copy(stdout, resp.Body); err != nil {
+            fmt.Fprintln(stderr, err)
+            return 1
+        }
+        return 0
+    }
+    data, err := io.ReadAll(resp.Body)
+    if err != nil {
+        fmt.Fprintln(stderr, err)
+        return 1
+    }
+    if len(bytes.TrimSpace(data)) == 0 {
+        return 0
+    }
+    if err := printResult(stdout, data, *output); err != nil {
+        fmt.Fprintln(stderr, err)
+        return 1
+    }
+    return 0
+}
+
+// buildRequest creates the request of an operation from the flag values
+func buildRequest(op operation, base string, values []*paramValue, body, contentType string, stdin io.Reader) (*http.Request, error) {
+    path := op.Path
+    query := url.Values{}
+    header := http.Header{}
+    var cookies []*http.Cookie
+    fields := map[string]interface{}{}
+    for _, v := range values {
+        if len(v.values) == 0 {
+            continue
+        }
+        p := v.param
+        switch p.In {
+        case "path":
+            path = strings.ReplaceAll(path, "{"+p.Name+"}", url.PathEscape(v.values[0]))
+        case "query":
+            for _, s := range v.values {
+                query.Add(p.Name, s)
+            }
+        case "header":
+            header.Set(p.Name, strings.Join(v.values, ","))
+        case "cookie":
+            cookies = append(cookies, &http.Cookie{Name: p.Name, Value: strings.Join(v.values, ",")})
+        case "body":
+            value, err := fieldValue(p, v.values)
+            if err != nil {
+                return nil, fmt.Errorf("-%s: %v", p.Flag, err)
+            }
+            fields[p.Name] = value
+        }
+    }
+
+    var reader io.Reader
+    if op.BodyType != "" {
+        data, err := readBody(body, stdin)
+        if err != nil {
+            return nil, err
+        }
+        if isJSON(contentType) && (data != nil || len(fields) > 0) {
+            if data, err = mergeFields(data, fields); err != nil {
+                return nil, err
+            }
+        }
+        if data == nil && op.BodyRequired {
+            return nil, errors.New("missing request body, pass -body or field flags")
+        }
+        if data != nil {
+            reader = bytes.NewReader(data)
+        }
+    }
+    u := base + path
+    if len(query) > 0 {
+        u += "?" + query.Encode()
+    }
+    req, err := http.NewRequest(op.Method, u, reader)
+    if err != nil {
+        return nil, err
+    }
+    req.Header = header
+    for _, c := range cookies {
+        req.AddCookie(c)
+    }
+    if reader != nil {
+        req.Header.Set("Content-Type", contentType)
+    }
+    if op.Accept != "" {
+        req.Header.Set("Accept", op.Accept)
+    }
+    return req, nil
+}
+
+// readBody reads the -body flag: the text itself, @file, or - for stdin
+func readBody(body string, stdin io.Reader) ([]byte, error) {
+    switch {
+    case body == "":
+        return nil, nil
+    case body == "-":
+        return io.ReadAll(stdin)
+    case strings.HasPrefix(body, "@"):
+        return os.ReadFile(body[1:])
+    }
+    return []byte(body), nil
+}
+
+// mergeFields sets body field flags on the JSON object of -body
+func mergeFields(data []byte, fields map[string]interface{}) ([]byte, error) {
+    object := map[string]interface{}{}
+    if data != nil {
+        var v interface{}
+        d := json.NewDecoder(bytes.NewReader(data))
+        d.UseNumber()
+        if err := d.Decode(&v); err != nil {
+            return nil, fmt.Errorf("-body is not valid JSON: %v", err)
+        }
+        if len(fields) == 0 {
+            return data, nil
+        }
+        m, ok := v.(map[string]interface{})
+        if !ok {
+            return nil, errors.New("field flags need -body to be a JSON object")
+        }
+        object = m
+    }
+    for name, value := range fields {
+        object[name] = value
+    }
+    return json.Marshal(object)
+}
+
+// fieldValue converts the value of a body field flag to its JSON type
+func fieldValue(p param, values []string) (interface{}, error) {
+    if p.Type == "array" {
+        items := make([]interface{}, len(values))
+        for i, s := range values {
+            item, err := scalarValue(p.Items, s)
+            if err != nil {
+                return nil, err
+            }
+            items[i] = item
+        }
+        return items, nil
+    }
+    return scalarValue(p.Type, values[0])
+}
+
+func scalarValue(t, s string) (interface{}, error) {
+    switch t {
+    case "integer":
+        return strconv.ParseInt(s, 10, 64)
+    case "number":
+        return strconv.ParseFloat(s, 64)
+    case "boolean":
+        return strconv.ParseBool(s)
+    case "string":
+        return s, nil
+    }
+    var v interface{}
+    if err := json.Unmarshal([]byte(s), &v); err != nil {
+        return nil, fmt.Errorf("expected JSON: %v", err)
+    }
+    return v, nil
+}
+
+// configPath returns where the config file is read from
+func configPath() string {
+    if path := os.Getenv(envPrefix + "_CONFIG"); path != "" {
+        return path
+    }
+    dir, err := os.UserConfigDir()
+    if err != nil {
+        return filepath.Join(".", configName+".json")
+    }
+    return filepath.Join(dir, configName, "config.json")
+}
+
+// loadProfile reads a profile of the config file. A missing file is only an
+// error when a profile was asked for by name.
+func loadProfile(name string) (profile, error) {
+    name = firstNonEmpty(name, os.Getenv(envPrefix+"_PROFILE"))
+    data, err := os.ReadFile(configPath())
+    if errors.Is(err, os.ErrNotExist) && name == "" {
+        return profile{}, nil
+    } else if err != nil {
+        return profile{}, err
+    }
+    var profiles map[string]profile
+    if err := json.Unmarshal(data, &profiles); err != nil {
+        return profile{}, fmt.Errorf("%s: %v", configPath(), err)
+    }
+    p, ok := profiles[firstNonEmpty(name, "default")]
+    if !ok && name != "" {
+        return profile{}, fmt.Errorf("%s: no profile %q", configPath(), name)
+    }
+    return p, nil
+}
+
+// authorize sends the credentials of the first security alternative that
+// has all its credentials set in the environment or the profile
+func authorize(req *http.Request, alternatives [][]string, prof profile) {
+    credential := func(name string) string {
+        return firstNonEmpty(os.Getenv(schemes[name].Env), prof.Credentials[name])
+    }
+    for _, names := range alternatives {
+        complete := true
+        for _, name := range names {
+            if credential(name) == "" {
+                complete = false
+            }
+        }
+        if !complete {
+            continue
+        }
+        for _, name := range names {
+            s, value := schemes[name], credential(name)
+            switch {
+            case s.Type == "apiKey" && s.In == "query":
+                q := req.URL.Query()
+                q.Set(s.Name, value)
+                req.URL.RawQuery = q.Encode()
+            case s.Type == "apiKey" && s.In == "cookie":
+                req.AddCookie(&http.Cookie{Name: s.Name, Value: value})
+            case s.Type == "apiKey":
+                req.Header.Set(s.Name, value)
+            case s.Type == "http" && s.Scheme == "basic":
+                user, password, _ := strings.Cut(value, ":")
+                req.SetBasicAuth(user, password)
+            default:
+                req.Header.Set("Authorization", "Bearer "+value)
+            }
+        }
+        return
+    }
+}
+
+func firstNonEmpty(values ...string) string {
+    for _, v := range values {
+        if v != "" {
+            return v
+        }
+    }
+    return ""
+}
+
+func isJSON(mediaType string) bool {
+    mediaType, _, _ = strings.Cut(mediaType, ";")
+    mediaType = strings.TrimSpace(mediaType)
+    return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// printResult writes a JSON response in the output format
+func printResult(w io.Writer, data []byte, format string) error {
+    var v interface{}
+    d := json.NewDecoder(bytes.NewReader(data))
+    d.UseNumber()
+    if err := d.Decode(&v); err != nil {
+        return fmt.Errorf("decoding response: %v", err)
+    }
+    switch format {
+    case "table":
+        return writeTable(w, v)
+    case "yaml":
+        var b strings.Builder
+        writeYAML(&b, v, "")
+        _, err := io.WriteString(w, b.String())
+        return err
+    }
+    out, err := json.MarshalIndent(v, "", "  ")
+    if err != nil {
+        return err
+    }
+    _, err = fmt.Fprintf(w, "%s\n", out)
+    return err
+}
+
+// writeTable prints a list of objects with a column per property, an object
+// as property and value rows, and anything else as it is
+func writeTable(w io.Writer, v interface{}) error {
+    tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+    switch v := v.(type) {
+    case []interface{}:
+        var columns []string
+        seen := map[string]bool{}
+        for _, item := range v {
+            object, ok := item.(map[string]interface{})
+            if !ok {
+                columns = nil
+                break
+            }
+            for key := range object {
+                if !seen[key] {
+                    seen[key] = true
+                    columns = append(columns, key)
+                }
+            }
+        }
+        if columns == nil {
+            for _, item := range v {
+                fmt.Fprintln(tw, cell(item))
+            }
+            break
+        }
+        sortColumns(columns)
+        fmt.Fprintln(tw, strings.ToUpper(strings.Join(columns, "\t")))
+        for _, item := range v {
+            object := item.(map[string]interface{})
+            cells := make([]string, len(columns))
+            for i, column := range columns {
+                cells[i] = cell(object[column])
+            }
+            fmt.Fprintln(tw, strings.Join(cells, "\t"))
+        }
+    case map[string]interface{}:
+        keys := make([]string, 0, len(v))
+        for key := range v {
+            keys = append(keys, key)
+        }
+        sortColumns(keys)
+        for _, key := range keys {
+            fmt.Fprintf(tw, "%s\t%s\n", strings.ToUpper(key), cell(v[key]))
+        }
+    default:
+        fmt.Fprintln(tw, cell(v))
+    }
+    return tw.Flush()
+}
+
+// sortColumns sorts property names with id first
+func sortColumns(columns []string) {
+    sort.Slice(columns, func(i, j int) bool {
+        if (columns[i] == "id") != (columns[j] == "id") {
+            return columns[i] == "id"
+        }
+        return columns[i] < columns[j]
+    })
+}
+
+// cell renders a value for a table, nested values as compact JSON
+func cell(v interface{}) string {
+    switch v := v.(type) {
+    case nil:
+        return ""
+    case string:
+        return strings.ReplaceAll(v, "\n", " ")
+    case json.Number:
+        return v.String()
+    case bool:
+        return strconv.FormatBool(v)
+    }
+    data, _ := json.Marshal(v)
+    return string(data)
+}
+
+// writeYAML renders a decoded JSON value as YAML
+func writeYAML(b *strings.Builder, v interface{}, indent string) {
+    switch v := v.(type) {
+    case map[string]interface{}:
+        if len(v) == 0 {
+            b.WriteString(indent + "{}\n")
+            return
+        }
+        keys := make([]string, 0, len(v))
+        for key := range v {
+            keys = append(keys, key)
+        }
+        sort.Strings(keys)
+        for _, key := range keys {
+            b.WriteString(indent + yamlScalar(key) + ":")
+            writeYAMLValue(b, v[key], indent)
+        }
+    case []interface{}:
+        if len(v) == 0 {
+            b.WriteString(indent + "[]\n")
+            return
+        }
+        for _, item := range v {
+            switch item.(type) {
+            case map[string]interface{}, []interface{}:
+                // Start the nested block on the line of the dash
+                var nested strings.Builder
+                writeYAML(&nested, item, indent+"  ")
+                b.WriteString(indent + "- " + strings.TrimPrefix(nested.String(), indent+"  "))
+            default:
+                b.WriteString(indent + "- " + yamlScalar(item) + "\n")
+            }
+        }
+    default:
+        b.WriteString(indent + yamlScalar(v) + "\n")
+    }
+}
+
+// writeYAMLValue writes the value of a key: scalars and empty collections on
+// the same line, others indented below
+func writeYAMLValue(b *strings.Builder, v interface{}, indent string) {
+    switch c := v.(type) {
+    case map[string]interface{}:
+        if len(c) > 0 {
+            b.WriteString("\n")
+            writeYAML(b, c, indent+"  ")
+            return
+        }
+    case []interface{}:
+        if len(c) > 0 {
+            b.WriteString("\n")
+            writeYAML(b, c, indent+"  ")
+            return
+        }
+    }
+    b.WriteString(" ")
+    writeYAML(b, v, "")
+}
+
+// plainYAML matches strings that YAML reads back as the same string unquoted
+var plainYAML = regexp.MustCompile(` + "`^[A-Za-z_][A-Za-z0-9_./-]*( [A-Za-z0-9_./-]+)*$`" + `)
+
+func yamlScalar(v interface{}) string {
+    switch v := v.(type) {
+    case nil:
+        return "null"
+    case bool:
+        return strconv.FormatBool(v)
+    case json.Number:
+        return v.String()
+    case string:
+        switch strings.ToLower(v) {
+        case "true", "false", "yes", "no", "on", "off", "null", "y", "n":
+            return strconv.Quote(v)
+        }
+        if plainYAML.MatchString(v) {
+            return v
+        }
+        return strconv.Quote(v)
+    }
+    return fmt.Sprint(v)
+}
+`)
+	return code.String()
+}
@@ -0,0 +1,790 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// goKeywords cannot be used as parameter names in generated code
+var goKeywords = map[string]bool{
+	"break": true, "case": true, "chan": true, "const": true, "continue": true, "default": true,
+	"defer": true, "else": true, "fallthrough": true, "for": true, "func": true, "go": true,
+	"goto": true, "if": true, "import": true, "interface": true, "map": true, "package": true,
+	"range": true, "return": true, "select": true, "struct": true, "switch": true, "type": true, "var": true,
+}
+
+// goParamName turns a parameter name into a Go identifier starting in lower
+// case, avoiding keywords and the names generated methods use themselves
+func goParamName(name string) string {
+	id := toGoIdentifier(name)
+	if id == "" {
+		return "param"
+	}
+	id = strings.ToLower(id[:1]) + id[1:]
+	switch {
+	case goKeywords[id], id == "ctx", id == "c", id == "params", id == "body", id == "contentType", id == "req", id == "result":
+		return id + "Param"
+	}
+	return id
+}
+
+// clientSchemaType resolves the Go type of a schema in the client package.
+// References name their model, arrays are typed by their items and inline
+// objects use the fallback model extracted for them.
+func clientSchemaType(schema map[string]interface{}, fallback string) string {
+	if ref, _ := schema["$ref"].(string); ref != "" {
+		return toGoIdentifier(strings.TrimPrefix(ref, "#/components/schemas/"))
+	}
+	switch schema["type"] {
+	case "array":
+		items, _ := schema["items"].(map[string]interface{})
+		return "[]" + clientSchemaType(items, "")
+	case "object", nil:
+		if fallback != "" {
+			return fallback
+		}
+	}
+	return propertyGoType(schema)
+}
+
+// clientParamType returns the Go type of a query, header or cookie parameter
+func clientParamType(p parameter) string {
+	if t, _ := p.Schema["type"].(string); t == "array" {
+		items, _ := p.Schema["items"].(map[string]interface{})
+		itemType, _ := items["type"].(string)
+		return "[]" + mapTypeToGo(itemType)
+	}
+	t, _ := p.Schema["type"].(string)
+	return mapTypeToGo(t)
+}
+
+// isStructType reports whether a client type names an object model, which
+// methods take and return by pointer
+func isStructType(schemas map[string]Schema, goType string) bool {
+	schema, ok := findSchema(schemas, goType)
+	return ok && (schema.Type == "object" || schema.Type == "")
+}
+
+// clientBody describes how a generated method sends its request body
+type clientBody struct {
+	GoType    string // JSON body type; empty for raw bodies
+	MediaType string // JSON media type, or the raw type offered by default
+	Raw       bool   // no JSON type declared: the caller passes an io.Reader
+}
+
+// requestBodyShape picks how a client method takes an operation's body
+func requestBodyShape(op operation) *clientBody {
+	reqBody, ok := op.Endpoint["requestBody"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	content, _ := reqBody["content"].(map[string]interface{})
+	if op.Method == "PATCH" {
+		// Patches only carry the fields to change, so they are not typed
+		types := patchMediaTypes(op)
+		mediaType := types[len(types)-1]
+		if len(content) == 0 {
+			mediaType = mergePatchMediaType
+		}
+		return &clientBody{GoType: "interface{}", MediaType: mediaType}
+	}
+	if mediaType := jsonMediaType(content); mediaType != "" {
+		media, _ := content[mediaType].(map[string]interface{})
+		schema, _ := media["schema"].(map[string]interface{})
+		return &clientBody{GoType: clientSchemaType(schema, op.HandlerName+"Request"), MediaType: mediaType}
+	}
+	types := requestMediaTypes(op)
+	body := &clientBody{Raw: true, MediaType: "application/octet-stream"}
+	if len(types) > 0 {
+		body.MediaType = types[0]
+	}
+	return body
+}
+
+// responseBodyShape picks what a client method returns on success: a decoded
+// JSON type, a raw stream for other media types, or nothing
+func responseBodyShape(op operation) *clientBody {
+	success := operationSuccess(op)
+	content, _ := success.Response["content"].(map[string]interface{})
+	if len(content) == 0 {
+		return nil
+	}
+	if mediaType := jsonMediaType(content); mediaType != "" {
+		media, _ := content[mediaType].(map[string]interface{})
+		schema, _ := media["schema"].(map[string]interface{})
+		if schema == nil {
+			return nil
+		}
+		return &clientBody{GoType: clientSchemaType(schema, fmt.Sprintf("%sResponse%s", op.HandlerName, success.Declared)), MediaType: mediaType}
+	}
+	types := mediaTypes(success.Response, true)
+	if len(types) == 0 {
+		return nil
+	}
+	return &clientBody{Raw: true, MediaType: types[0]}
+}
+
+// errorModels maps the error statuses an operation declares to the Go types
+// of their JSON bodies, as the cases of a switch on status
+func errorModels(op operation) []string {
+	responses, _ := op.Endpoint["responses"].(map[string]interface{})
+	statuses := make([]string, 0, len(responses))
+	for status := range responses {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	var exact, ranges []string
+	var fallback string
+	for _, status := range statuses {
+		resp, _ := responses[status].(map[string]interface{})
+		content, _ := resp["content"].(map[string]interface{})
+		mediaType := jsonMediaType(content)
+		if mediaType == "" || strings.HasPrefix(status, "2") {
+			continue
+		}
+		media, _ := content[mediaType].(map[string]interface{})
+		schema, _ := media["schema"].(map[string]interface{})
+		if schema == nil {
+			continue
+		}
+		goType := clientSchemaType(schema, fmt.Sprintf("%sResponse%s", op.HandlerName, status))
+		ret := fmt.Sprintf("return new(%s)", goType)
+		switch {
+		case status == "default":
+			fallback = "    default:\n        " + ret + "\n"
+		case len(status) == 3 && strings.HasSuffix(strings.ToUpper(status), "XX"):
+			ranges = append(ranges, fmt.Sprintf("    case status/100 == %c:\n        %s\n", status[0], ret))
+		default:
+			exact = append(exact, fmt.Sprintf("    case status == %s:\n        %s\n", status, ret))
+		}
+	}
+	cases := append(exact, ranges...)
+	if fallback != "" {
+		cases = append(cases, fallback)
+	}
+	return cases
+}
+
+// pathExpression renders Go code building the path of an operation from its
+// path parameters
+func pathExpression(op operation, names map[string]string) string {
+	var parts []string
+	literal := ""
+	for _, segment := range strings.Split(strings.TrimPrefix(op.Path, "/"), "/") {
+		literal += "/"
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			parts = append(parts, fmt.Sprintf("%q", literal), "pathValue("+names[strings.Trim(segment, "{}")]+")")
+			literal = ""
+			continue
+		}
+		literal += segment
+	}
+	if literal != "" || len(parts) == 0 {
+		parts = append(parts, fmt.Sprintf("%q", literal))
+	}
+	return strings.Join(parts, " + ")
+}
+
+// generateClient writes a Go client package for the spec into dir, with its
+// own copy of the models
+func generateClient(spec *OpenAPISpec, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	schemas, err := collectSchemas(spec)
+	if err != nil {
+		return err
+	}
+	if err := writeFile(filepath.Join(dir, "models.go"), generateStructs(schemas, "client")); err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(dir, "client.go"), generateClientCode(spec, collectOperations(spec.Paths), schemas))
+}
+
+// generateClientCode creates the client type, its options and one method per
+// operation
+func generateClientCode(spec *OpenAPISpec, ops []operation, schemas map[string]Schema) string {
+	var body strings.Builder
+	title, _ := spec.Info["title"].(string)
+	if title == "" {
+		title = "the"
+	}
+	body.WriteString(fmt.Sprintf("// Client calls %s API. Methods retry idempotent requests that fail with a\n", title))
+	body.WriteString(`// network error, 429, 502, 503 or 504, following Retry.
+type Client struct {
+    BaseURL     string
+    HTTPClient  *http.Client
+    Retry       RetryPolicy
+    credentials map[string]func(*http.Request)
+    editors     []RequestEditor
+}
+
+// RetryPolicy sets how often a request is attempted and how long to wait in
+// between: exponential backoff from MinBackoff up to MaxBackoff, with jitter,
+// or the server's Retry-After when longer
+type RetryPolicy struct {
+    MaxAttempts int
+    MinBackoff  time.Duration
+    MaxBackoff  time.Duration
+}
+
+// DefaultRetry is the retry policy of new clients
+var DefaultRetry = RetryPolicy{MaxAttempts: 3, MinBackoff: 100 * time.Millisecond, MaxBackoff: 2 * time.Second}
+
+// RequestEditor changes requests before they are sent, e.g. to add headers
+type RequestEditor func(*http.Request) error
+
+// Option configures a Client
+type Option func(*Client)
+
+// New returns a client for the API served at baseURL, e.g. http://localhost:8080
+func New(baseURL string, opts ...Option) (*Client, error) {
+    u, err := url.Parse(baseURL)
+    if err != nil || u.Scheme == "" || u.Host == "" {
+        return nil, fmt.Errorf("invalid base URL %q", baseURL)
+    }
+    c := &Client{
+        BaseURL:     strings.TrimSuffix(baseURL, "/"),
+        HTTPClient:  http.DefaultClient,
+        Retry:       DefaultRetry,
+        credentials: map[string]func(*http.Request){},
+    }
+    for _, opt := range opts {
+        opt(c)
+    }
+    return c, nil
+}
+
+// WithHTTPClient sends requests with hc instead of http.DefaultClient
+func WithHTTPClient(hc *http.Client) Option {
+    return func(c *Client) {
+        c.HTTPClient = hc
+    }
+}
+
+// WithTransport sends requests through rt, e.g. to add tracing or logging
+func WithTransport(rt http.RoundTripper) Option {
+    return func(c *Client) {
+        c.HTTPClient = &http.Client{Transport: rt}
+    }
+}
+
+// WithRetry replaces DefaultRetry; MaxAttempts 1 disables retries
+func WithRetry(policy RetryPolicy) Option {
+    return func(c *Client) {
+        c.Retry = policy
+    }
+}
+
+// WithRequestEditor runs fn on every request before it is sent
+func WithRequestEditor(fn RequestEditor) Option {
+    return func(c *Client) {
+        c.editors = append(c.editors, fn)
+    }
+}
+
+`)
+	writeClientAuthOptions(&body, extractSecuritySchemes(spec.Components))
+	writeClientRuntime(&body)
+
+	for _, op := range ops {
+		writeClientMethod(&body, op, spec, schemas)
+	}
+
+	var code strings.Builder
+	code.WriteString("// Package client is a generated Go client for " + strings.TrimSuffix(title, " API") + " API.\n")
+	code.WriteString("package client\n\n")
+	code.WriteString(importBlock(body.String(), "bytes", "context", "encoding/json", "errors", "fmt", "io", "math/rand/v2", "net/http", "net/url", "strconv", "strings", "time"))
+	code.WriteString(body.String())
+	return code.String()
+}
+
+// writeClientAuthOptions emits an option per security scheme storing the
+// credentials operations requiring that scheme send
+func writeClientAuthOptions(code *strings.Builder, schemes map[string]securityScheme) {
+	names := make([]string, 0, len(schemes))
+	for name := range schemes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s := schemes[name]
+		option := "With" + toGoIdentifier(name)
+		switch {
+		case s.Type == "apiKey":
+			code.WriteString(fmt.Sprintf("// %s sends key in the %s %s to operations requiring %s\n", option, s.Name, s.In, name))
+			code.WriteString(fmt.Sprintf("func %s(key string) Option {\n", option))
+			code.WriteString("    return func(c *Client) {\n")
+			code.WriteString(fmt.Sprintf("        c.credentials[%q] = func(r *http.Request) {\n", name))
+			switch s.In {
+			case "query":
+				code.WriteString("            q := r.URL.Query()\n")
+				code.WriteString(fmt.Sprintf("            q.Set(%q, key)\n", s.Name))
+				code.WriteString("            r.URL.RawQuery = q.Encode()\n")
+			case "cookie":
+				code.WriteString(fmt.Sprintf("            r.AddCookie(&http.Cookie{Name: %q, Value: key})\n", s.Name))
+			default:
+				code.WriteString(fmt.Sprintf("            r.Header.Set(%q, key)\n", s.Name))
+			}
+			code.WriteString("        }\n    }\n}\n\n")
+		case s.Type == "http" && s.Scheme == "basic":
+			code.WriteString(fmt.Sprintf("// %s sends HTTP basic credentials to operations requiring %s\n", option, name))
+			code.WriteString(fmt.Sprintf("func %s(user, password string) Option {\n", option))
+			code.WriteString("    return func(c *Client) {\n")
+			code.WriteString(fmt.Sprintf("        c.credentials[%q] = func(r *http.Request) {\n", name))
+			code.WriteString("            r.SetBasicAuth(user, password)\n")
+			code.WriteString("        }\n    }\n}\n\n")
+		default:
+			code.WriteString(fmt.Sprintf("// %s sends a bearer token to operations requiring %s\n", option, name))
+			code.WriteString(fmt.Sprintf("func %s(token string) Option {\n", option))
+			code.WriteString("    return func(c *Client) {\n")
+			code.WriteString(fmt.Sprintf("        c.credentials[%q] = func(r *http.Request) {\n", name))
+			code.WriteString("            r.Header.Set(\"Authorization\", \"Bearer \"+token)\n")
+			code.WriteString("        }\n    }\n}\n\n")
+		}
+	}
+}
+
+// writeClientRuntime emits the request, retry and error handling shared by the
+// operation methods
+func writeClientRuntime(code *strings.Builder) {
+	code.WriteString("// BlobRef references a file stored by the server\n")
+	code.WriteString("type BlobRef struct {\n")
+	code.WriteString("    Blob        string `json:\"blob\"`\n")
+	code.WriteString("    ContentType string `json:\"contentType,omitempty\"`\n")
+	code.WriteString("    Filename    string `json:\"filename,omitempty\"`\n")
+	code.WriteString("    Size        int64  `json:\"size\"`\n")
+	code.WriteString("    SHA256      string `json:\"sha256,omitempty\"`\n")
+	code.WriteString("}\n\n")
+	code.WriteString(`// APIError is returned for responses without a success status. Model holds
+// the body decoded into the type the spec declares for the status, if any.
+type APIError struct {
+    Operation  string
+    StatusCode int
+    Header     http.Header
+    Body       []byte
+    Model      interface{}
+}
+
+func (e *APIError) Error() string {
+    msg := strings.TrimSpace(string(e.Body))
+    if len(msg) > 200 {
+        msg = msg[:200] + "..."
+    }
+    return fmt.Sprintf("%s: %d %s: %s", e.Operation, e.StatusCode, http.StatusText(e.StatusCode), msg)
+}
+
+// request describes a call to an operation
+type request struct {
+    operation      string
+    method         string
+    path           string
+    query          url.Values
+    header         http.Header
+    cookies        []*http.Cookie
+    body           []byte    // encoded JSON body
+    reader         io.Reader // raw body, retried only when it is an io.Seeker
+    contentType    string
+    accept         string
+    security       [][]string // alternatives of schemes that must all be sent
+    idempotencyKey bool       // send an Idempotency-Key so the POST can be retried
+    errorModel     func(status int) interface{}
+}
+
+// pathValue escapes a path parameter
+func pathValue(v interface{}) string {
+    return url.PathEscape(fmt.Sprint(v))
+}
+
+// jsonBody encodes a request body
+func jsonBody(v interface{}) ([]byte, error) {
+    data, err := json.Marshal(v)
+    if err != nil {
+        return nil, fmt.Errorf("encoding request body: %w", err)
+    }
+    return data, nil
+}
+
+// do sends req and decodes a JSON success body into out, when given
+func (c *Client) do(ctx context.Context, req request, out interface{}) error {
+    resp, err := c.send(ctx, req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    if out == nil {
+        io.Copy(io.Discard, resp.Body)
+        return nil
+    }
+    data, err := io.ReadAll(resp.Body)
+    if err != nil {
+        return fmt.Errorf("%s: reading response: %w", req.operation, err)
+    }
+    if len(bytes.TrimSpace(data)) == 0 {
+        return nil
+    }
+    if err := json.Unmarshal(data, out); err != nil {
+        return fmt.Errorf("%s: decoding response: %w", req.operation, err)
+    }
+    return nil
+}
+
+// send performs req, retrying idempotent requests, and turns responses
+// without a success status into an *APIError
+func (c *Client) send(ctx context.Context, req request) (*http.Response, error) {
+    if req.idempotencyKey && (req.header == nil || req.header.Get("Idempotency-Key") == "") {
+        if req.header == nil {
+            req.header = http.Header{}
+        }
+        req.header.Set("Idempotency-Key", fmt.Sprintf("%016x%016x", rand.Uint64(), rand.Uint64()))
+    }
+    seeker, seekable := req.reader.(io.Seeker)
+    retryable := req.reader == nil || seekable
+    switch req.method {
+    case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+    default:
+        retryable = retryable && req.header.Get("Idempotency-Key") != ""
+    }
+    attempts := c.Retry.MaxAttempts
+    if attempts < 1 || !retryable {
+        attempts = 1
+    }
+    for attempt := 1; ; attempt++ {
+        if seekable && attempt > 1 {
+            if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+                return nil, err
+            }
+        }
+        httpReq, err := c.newRequest(ctx, req)
+        if err != nil {
+            return nil, err
+        }
+        resp, err := c.HTTPClient.Do(httpReq)
+        var wait time.Duration
+        switch {
+        case err != nil:
+            if ctx.Err() != nil || attempt >= attempts {
+                return nil, fmt.Errorf("%s: %w", req.operation, err)
+            }
+        case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+            return resp, nil
+        case attempt < attempts && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusBadGateway ||
+            resp.StatusCode == http.StatusServiceUnavailable || resp.StatusCode == http.StatusGatewayTimeout):
+            if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+                wait = time.Duration(seconds) * time.Second
+            }
+            io.Copy(io.Discard, resp.Body)
+            resp.Body.Close()
+        default:
+            return nil, c.apiError(req, resp)
+        }
+        if backoff := c.backoff(attempt); backoff > wait {
+            wait = backoff
+        }
+        select {
+        case <-ctx.Done():
+            return nil, fmt.Errorf("%s: %w", req.operation, ctx.Err())
+        case <-time.After(wait):
+        }
+    }
+}
+
+// backoff is the jittered exponential delay after a failed attempt
+func (c *Client) backoff(attempt int) time.Duration {
+    d := c.Retry.MinBackoff << (attempt - 1)
+    if d > c.Retry.MaxBackoff || d <= 0 {
+        d = c.Retry.MaxBackoff
+    }
+    if d <= 0 {
+        return 0
+    }
+    return d/2 + time.Duration(rand.Int64N(int64(d/2)+1))
+}
+
+func (c *Client) newRequest(ctx context.Context, req request) (*http.Request, error) {
+    var body io.Reader
+    if req.body != nil {
+        body = bytes.NewReader(req.body)
+    } else if req.reader != nil {
+        body = io.NopCloser(req.reader)
+    }
+    u := c.BaseURL + req.path
+    if len(req.query) > 0 {
+        u += "?" + req.query.Encode()
+    }
+    httpReq, err := http.NewRequestWithContext(ctx, req.method, u, body)
+    if err != nil {
+        return nil, fmt.Errorf("%s: %w", req.operation, err)
+    }
+    for name, values := range req.header {
+        httpReq.Header[name] = values
+    }
+    for _, cookie := range req.cookies {
+        httpReq.AddCookie(cookie)
+    }
+    if body != nil && req.contentType != "" {
+        httpReq.Header.Set("Content-Type", req.contentType)
+    }
+    if req.accept != "" {
+        httpReq.Header.Set("Accept", req.accept)
+    }
+    c.authorize(httpReq, req.security)
+    for _, edit := range c.editors {
+        if err := edit(httpReq); err != nil {
+            return nil, fmt.Errorf("%s: %w", req.operation, err)
+        }
+    }
+    return httpReq, nil
+}
+
+// authorize sends the credentials of the first alternative the client has
+// all credentials for. Without any, the request is sent as is.
+func (c *Client) authorize(r *http.Request, alternatives [][]string) {
+    for _, schemes := range alternatives {
+        complete := true
+        for _, scheme := range schemes {
+            if c.credentials[scheme] == nil {
+                complete = false
+            }
+        }
+        if complete {
+            for _, scheme := range schemes {
+                c.credentials[scheme](r)
+            }
+            return
+        }
+    }
+}
+
+// apiError reads an error response into an *APIError
+func (c *Client) apiError(req request, resp *http.Response) error {
+    defer resp.Body.Close()
+    data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+    apiErr := &APIError{Operation: req.operation, StatusCode: resp.StatusCode, Header: resp.Header, Body: data}
+    if req.errorModel != nil {
+        if model := req.errorModel(resp.StatusCode); model != nil && json.Unmarshal(data, model) == nil {
+            apiErr.Model = model
+        }
+    }
+    return apiErr
+}
+
+`)
+}
+
+// writeClientMethod emits the method calling one operation, with a params
+// struct for its query, header and cookie parameters when it has any
+func writeClientMethod(code *strings.Builder, op operation, spec *OpenAPISpec, schemas map[string]Schema) {
+	params := operationParameters(op, spec.Components)
+	names := map[string]string{}
+	var args []string
+	var optional []parameter
+	for _, p := range params {
+		if p.In != "path" {
+			optional = append(optional, p)
+			continue
+		}
+		names[p.Name] = goParamName(p.Name)
+		t, _ := p.Schema["type"].(string)
+		args = append(args, fmt.Sprintf("%s %s", names[p.Name], mapTypeToGo(t)))
+	}
+	paramsType := op.HandlerName + "Params"
+	if len(optional) > 0 {
+		code.WriteString(fmt.Sprintf("// %s holds the query, header and cookie parameters of %s\n", paramsType, op.HandlerName))
+		code.WriteString(fmt.Sprintf("type %s struct {\n", paramsType))
+		for _, p := range optional {
+			goType := clientParamType(p)
+			if !p.Required && !strings.HasPrefix(goType, "[]") {
+				goType = "*" + goType
+			}
+			code.WriteString(fmt.Sprintf("    %s %s // %s %s\n", toGoIdentifier(p.Name), goType, p.In, p.Name))
+		}
+		code.WriteString("}\n\n")
+	}
+
+	reqBody := requestBodyShape(op)
+	if reqBody != nil {
+		switch {
+		case reqBody.Raw:
+			args = append(args, "body io.Reader", "contentType string")
+		case isStructType(schemas, reqBody.GoType):
+			args = append(args, "body *"+reqBody.GoType)
+		default:
+			args = append(args, "body "+reqBody.GoType)
+		}
+	}
+	if len(optional) > 0 {
+		args = append(args, "params *"+paramsType)
+	}
+	resp := responseBodyShape(op)
+	result := "error"
+	switch {
+	case resp == nil:
+	case resp.Raw:
+		result = "(io.ReadCloser, error)"
+	case isStructType(schemas, resp.GoType):
+		result = fmt.Sprintf("(*%s, error)", resp.GoType)
+	default:
+		result = fmt.Sprintf("(%s, error)", resp.GoType)
+	}
+
+	summary, _ := op.Endpoint["summary"].(string)
+	comment := fmt.Sprintf("// %s calls %s %s", op.HandlerName, op.Method, op.Path)
+	if summary != "" {
+		comment += ": " + strings.TrimSuffix(summary, ".")
+	}
+	code.WriteString(comment + "\n")
+	if resp != nil && resp.Raw {
+		code.WriteString("// The caller closes the returned body.\n")
+	}
+	if len(optional) > 0 && !anyRequired(optional) {
+		code.WriteString("// params may be nil.\n")
+	}
+	code.WriteString(fmt.Sprintf("func (c *Client) %s(ctx context.Context", op.HandlerName))
+	for _, arg := range args {
+		code.WriteString(", " + arg)
+	}
+	code.WriteString(fmt.Sprintf(") %s {\n", result))
+
+	// Failures return the zero value of the result
+	fail := "return err"
+	switch {
+	case resp == nil:
+	case resp.Raw || isStructType(schemas, resp.GoType) || strings.HasPrefix(resp.GoType, "[]") || strings.HasPrefix(resp.GoType, "map[") || resp.GoType == "interface{}" || strings.HasPrefix(resp.GoType, "*"):
+		fail = "return nil, err"
+	default:
+		code.WriteString(fmt.Sprintf("    var zero %s\n", resp.GoType))
+		fail = "return zero, err"
+	}
+
+	code.WriteString(fmt.Sprintf("    req := request{operation: %q, method: %q, path: %s}\n", op.OperationID, op.Method, pathExpression(op, names)))
+	if reqs := operationSecurity(op, spec, extractSecuritySchemes(spec.Components)); len(reqs) > 0 {
+		alternatives := make([]string, 0, len(reqs))
+		for _, req := range reqs {
+			schemeNames := make([]string, 0, len(req))
+			for name := range req {
+				schemeNames = append(schemeNames, name)
+			}
+			sort.Strings(schemeNames)
+			alternatives = append(alternatives, stringList(schemeNames)[len("[]string"):])
+		}
+		code.WriteString(fmt.Sprintf("    req.security = [][]string{%s}\n", strings.Join(alternatives, ", ")))
+	}
+	if idempotencyEnabled(op, spec.Extensions) {
+		code.WriteString("    req.idempotencyKey = true\n")
+	}
+	if cases := errorModels(op); len(cases) > 0 {
+		code.WriteString("    req.errorModel = func(status int) interface{} {\n")
+		code.WriteString("        switch {\n")
+		for _, c := range cases {
+			code.WriteString(indentLines(c, "    "))
+		}
+		code.WriteString("        }\n")
+		if !strings.HasPrefix(cases[len(cases)-1], "    default:") {
+			code.WriteString("        return nil\n")
+		}
+		code.WriteString("    }\n")
+	}
+	if len(optional) > 0 {
+		writeClientParams(code, optional)
+	}
+	if reqBody != nil {
+		code.WriteString(fmt.Sprintf("    req.contentType = %q\n", reqBody.MediaType))
+		if reqBody.Raw {
+			code.WriteString("    if contentType != \"\" {\n")
+			code.WriteString("        req.contentType = contentType\n")
+			code.WriteString("    }\n")
+			code.WriteString("    req.reader = body\n")
+		} else {
+			code.WriteString("    data, err := jsonBody(body)\n")
+			code.WriteString("    if err != nil {\n")
+			code.WriteString(fmt.Sprintf("        %s\n", fail))
+			code.WriteString("    }\n")
+			code.WriteString("    req.body = data\n")
+		}
+	}
+	if resp != nil {
+		code.WriteString(fmt.Sprintf("    req.accept = %q\n", resp.MediaType))
+	}
+	switch {
+	case resp == nil:
+		code.WriteString("    return c.do(ctx, req, nil)\n")
+	case resp.Raw:
+		code.WriteString("    httpResp, err := c.send(ctx, req)\n")
+		code.WriteString("    if err != nil {\n")
+		code.WriteString("        return nil, err\n")
+		code.WriteString("    }\n")
+		code.WriteString("    return httpResp.Body, nil\n")
+	default:
+		code.WriteString(fmt.Sprintf("    var result %s\n", resp.GoType))
+		code.WriteString("    if err := c.do(ctx, req, &result); err != nil {\n")
+		code.WriteString(fmt.Sprintf("        %s\n", fail))
+		code.WriteString("    }\n")
+		if isStructType(schemas, resp.GoType) {
+			code.WriteString("    return &result, nil\n")
+		} else {
+			code.WriteString("    return result, nil\n")
+		}
+	}
+	code.WriteString("}\n\n")
+}
+
+// writeClientParams emits the encoding of a params struct into the query,
+// headers and cookies of a request
+func writeClientParams(code *strings.Builder, params []parameter) {
+	code.WriteString("    if params != nil {\n")
+	code.WriteString("        req.query = url.Values{}\n")
+	code.WriteString("        req.header = http.Header{}\n")
+	for _, p := range params {
+		field := "params." + toGoIdentifier(p.Name)
+		var set string
+		switch p.In {
+		case "query":
+			set = fmt.Sprintf("req.query.Add(%q, fmt.Sprint(%%s))", p.Name)
+		case "header":
+			set = fmt.Sprintf("req.header.Add(%q, fmt.Sprint(%%s))", p.Name)
+		case "cookie":
+			set = fmt.Sprintf("req.cookies = append(req.cookies, &http.Cookie{Name: %q, Value: fmt.Sprint(%%s)})", p.Name)
+		default:
+			continue
+		}
+		goType := clientParamType(p)
+		switch {
+		case strings.HasPrefix(goType, "[]"):
+			code.WriteString(fmt.Sprintf("        for _, v := range %s {\n", field))
+			code.WriteString("            " + fmt.Sprintf(set, "v") + "\n")
+			code.WriteString("        }\n")
+		case !p.Required:
+			code.WriteString(fmt.Sprintf("        if %s != nil {\n", field))
+			code.WriteString("            " + fmt.Sprintf(set, "*"+field) + "\n")
+			code.WriteString("        }\n")
+		default:
+			code.WriteString("        " + fmt.Sprintf(set, field) + "\n")
+		}
+	}
+	code.WriteString("    }\n")
+}
+
+// anyRequired reports whether a parameter must be sent
+func anyRequired(params []parameter) bool {
+	for _, p := range params {
+		if p.Required {
+			return true
+		}
+	}
+	return false
+}
+
+// indentLines prefixes every line of s
+func indentLines(s, prefix string) string {
+	lines := strings.SplitAfter(s, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "")
+}
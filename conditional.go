@@ -0,0 +1,77 @@
+package main
+
+import "strings"
+
+// generateConditionalCode creates the ETag helpers behind conditional requests.
+// Writes compare If-Match inside the BadgerDB transaction that performs them,
+// so a concurrent update either fails the precondition or the commit.
+func generateConditionalCode() string {
+	var code strings.Builder
+	code.WriteString("package main\n\n")
+	code.WriteString("import (\n    \"errors\"\n    \"fmt\"\n    \"net/http\"\n    \"strings\"\n)\n\n")
+	code.WriteString(`// errPreconditionFailed aborts a write whose If-Match header does not match
+var errPreconditionFailed = errors.New("precondition failed")
+
+// etagFor renders a record version as a strong entity tag
+func etagFor(version uint64) string {
+    return fmt.Sprintf("\"%x\"", version)
+}
+
+// etagMatches reports whether etag appears in an If-Match or If-None-Match
+// list. Weak comparison (used by If-None-Match) ignores the W/ prefix; strong
+// comparison never matches weak tags.
+func etagMatches(header, etag string, weak bool) bool {
+    for _, candidate := range strings.Split(header, ",") {
+        candidate = strings.TrimSpace(candidate)
+        if candidate == "*" {
+            return true
+        }
+        if strings.HasPrefix(candidate, "W/") {
+            if !weak {
+                continue
+            }
+            candidate = strings.TrimPrefix(candidate, "W/")
+        }
+        if candidate == etag {
+            return true
+        }
+    }
+    return false
+}
+
+// checkIfMatch verifies the stored version against the request's If-Match
+// header; exists is false when there is no current record
+func checkIfMatch(r *http.Request, version uint64, exists bool) error {
+    header := r.Header.Get("If-Match")
+    if header == "" {
+        return nil
+    }
+    if !exists || !etagMatches(header, etagFor(version), false) {
+        return errPreconditionFailed
+    }
+    return nil
+}
+
+// notModified sets the ETag header and answers 304 when it matches the
+// request's If-None-Match header
+func notModified(w http.ResponseWriter, r *http.Request, version uint64) bool {
+    etag := etagFor(version)
+    w.Header().Set("ETag", etag)
+    if header := r.Header.Get("If-None-Match"); header != "" && etagMatches(header, etag, true) {
+        w.WriteHeader(http.StatusNotModified)
+        return true
+    }
+    return false
+}
+
+// conflictStatus picks the status for a write that lost a transaction
+// conflict: conditional requests fail their precondition, others may retry
+func conflictStatus(r *http.Request) int {
+    if r.Header.Get("If-Match") != "" {
+        return http.StatusPreconditionFailed
+    }
+    return http.StatusConflict
+}
+`)
+	return code.String()
+}
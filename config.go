@@ -0,0 +1,328 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// envPrefix derives the environment variable prefix of a generated server
+// from the spec title, e.g. "Sample API" becomes "SAMPLE_API_"
+func envPrefix(spec *OpenAPISpec) string {
+	title, _ := spec.Info["title"].(string)
+	var b strings.Builder
+	for _, c := range strings.ToUpper(title) {
+		switch {
+		case (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9'):
+			b.WriteRune(c)
+		case b.Len() > 0 && !strings.HasSuffix(b.String(), "_"):
+			b.WriteByte('_')
+		}
+	}
+	prefix := strings.TrimSuffix(b.String(), "_")
+	if prefix == "" || (prefix[0] >= '0' && prefix[0] <= '9') {
+		prefix = "API" + strings.TrimPrefix("_"+prefix, "_")
+	}
+	return prefix + "_"
+}
+
+// defaultAddr takes the listen address from the port of the first servers
+// entry, falling back to :8080
+func defaultAddr(spec *OpenAPISpec) string {
+	if len(spec.Servers) > 0 {
+		raw, _ := spec.Servers[0]["url"].(string)
+		if u, err := url.Parse(raw); err == nil && u.Port() != "" {
+			return ":" + u.Port()
+		}
+	}
+	return ":8080"
+}
+
+// generateConfigCode creates the runtime configuration of the generated
+// server. Values come from defaults, then an optional YAML or JSON file, then
+// environment variables, then command-line flags.
+func generateConfigCode(spec *OpenAPISpec) string {
+	var code strings.Builder
+	code.WriteString("package main\n\n")
+	code.WriteString("import (\n    \"bytes\"\n    \"encoding/json\"\n    \"errors\"\n    \"flag\"\n    \"fmt\"\n    \"io\"\n    \"log/slog\"\n    \"os\"\n    \"path/filepath\"\n    \"strings\"\n    \"time\"\n    \"gopkg.in/yaml.v3\"\n)\n\n")
+	code.WriteString("// EnvPrefix prefixes the environment variables read by LoadConfig\n")
+	code.WriteString(fmt.Sprintf("const EnvPrefix = %q\n\n", envPrefix(spec)))
+	code.WriteString("// ServerConfig holds the runtime settings of the server. File keys use the\n")
+	code.WriteString("// json/yaml names, flags the same names with dashes, and environment\n")
+	code.WriteString("// variables the upper-case names after EnvPrefix (e.g. " + envPrefix(spec) + "DB_PATH).\n")
+	code.WriteString("type ServerConfig struct {\n")
+	for _, f := range []struct{ name, typ, key string }{
+		{"Addr", "string", "addr"},
+		{"TLSCert", "string", "tls_cert"},
+		{"TLSKey", "string", "tls_key"},
+		{"DBPath", "string", "db_path"},
+		{"DBInMemory", "bool", "db_in_memory"},
+		{"SyncWrites", "bool", "sync_writes"},
+		{"ValueLogFileSize", "int64", "value_log_file_size"},
+		{"BlobDir", "string", "blob_dir"},
+		{"LogLevel", "string", "log_level"},
+		{"LogFormat", "string", "log_format"},
+		{"TraceExporter", "string", "trace_exporter"},
+		{"OTLPEndpoint", "string", "otlp_endpoint"},
+		{"ShutdownTimeout", "configDuration", "shutdown_timeout"},
+		{"DrainDelay", "configDuration", "drain_delay"},
+		{"CORSOrigins", "configList", "cors_origins"},
+		{"CORSMethods", "configList", "cors_methods"},
+		{"CORSHeaders", "configList", "cors_headers"},
+		{"HealthPath", "string", "health_path"},
+		{"ReadyPath", "string", "ready_path"},
+		{"MetricsPath", "string", "metrics_path"},
+		{"SpecJSONPath", "string", "spec_json_path"},
+		{"SpecYAMLPath", "string", "spec_yaml_path"},
+		{"DocsPath", "string", "docs_path"},
+		{"RateLimitStore", "string", "rate_limit_store"},
+		{"TrustProxy", "bool", "trust_proxy"},
+		{"IdempotencyTTL", "configDuration", "idempotency_ttl"},
+	} {
+		code.WriteString(fmt.Sprintf("    %-16s %-14s `json:\"%s\" yaml:\"%s\"`\n", f.name, f.typ, f.key, f.key))
+	}
+	code.WriteString("}\n\n")
+	code.WriteString("// DefaultConfig returns the settings used when nothing overrides them\n")
+	code.WriteString("func DefaultConfig() ServerConfig {\n")
+	code.WriteString("    return ServerConfig{\n")
+	code.WriteString(fmt.Sprintf("        Addr:             %q,\n", defaultAddr(spec)))
+	code.WriteString("        DBPath:           \"./badger_db\",\n")
+	code.WriteString("        ValueLogFileSize: 1<<30 - 1,\n")
+	code.WriteString("        LogLevel:         \"info\",\n")
+	code.WriteString("        LogFormat:        \"text\",\n")
+	code.WriteString("        ShutdownTimeout:  configDuration(ShutdownTimeout),\n")
+	code.WriteString("        DrainDelay:       configDuration(DrainDelay),\n")
+	code.WriteString("        CORSOrigins:      configList(Cors.AllowOrigins),\n")
+	code.WriteString("        CORSMethods:      configList(Cors.AllowMethods),\n")
+	code.WriteString("        CORSHeaders:      configList(Cors.AllowHeaders),\n")
+	code.WriteString("        HealthPath:       HealthPath,\n")
+	code.WriteString("        ReadyPath:        ReadyPath,\n")
+	code.WriteString("        MetricsPath:      MetricsPath,\n")
+	code.WriteString("        SpecJSONPath:     SpecJSONPath,\n")
+	code.WriteString("        SpecYAMLPath:     SpecYAMLPath,\n")
+	code.WriteString("        DocsPath:         DocsPath,\n")
+	code.WriteString("        RateLimitStore:   RateLimitStore,\n")
+	code.WriteString("        IdempotencyTTL:   configDuration(IdempotencyTTL),\n")
+	code.WriteString("    }\n")
+	code.WriteString("}\n\n")
+	code.WriteString(`// flagSet binds every setting to a flag
+func (c *ServerConfig) flagSet() *flag.FlagSet {
+    fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+    fs.String("config", "", "YAML or JSON configuration file (env "+EnvPrefix+"CONFIG)")
+    fs.StringVar(&c.Addr, "addr", c.Addr, "listen address")
+    fs.StringVar(&c.TLSCert, "tls-cert", c.TLSCert, "TLS certificate file; serves HTTPS together with -tls-key")
+    fs.StringVar(&c.TLSKey, "tls-key", c.TLSKey, "TLS private key file")
+    fs.StringVar(&c.DBPath, "db-path", c.DBPath, "BadgerDB directory")
+    fs.BoolVar(&c.DBInMemory, "db-in-memory", c.DBInMemory, "keep BadgerDB in memory; data is lost on exit")
+    fs.BoolVar(&c.SyncWrites, "sync-writes", c.SyncWrites, "sync BadgerDB writes to disk before acknowledging them")
+    fs.Int64Var(&c.ValueLogFileSize, "value-log-file-size", c.ValueLogFileSize, "BadgerDB value log file size in bytes")
+    fs.StringVar(&c.BlobDir, "blob-dir", c.BlobDir, "directory storing uploaded files; empty stores them in BadgerDB")
+    fs.StringVar(&c.LogLevel, "log-level", c.LogLevel, "log level: debug, info, warn or error")
+    fs.StringVar(&c.LogFormat, "log-format", c.LogFormat, "log format: text or json")
+    fs.StringVar(&c.TraceExporter, "trace-exporter", c.TraceExporter, "OpenTelemetry span exporter: otlp or stdout; empty disables tracing")
+    fs.StringVar(&c.OTLPEndpoint, "otlp-endpoint", c.OTLPEndpoint, "OTLP/HTTP traces URL; defaults to OTEL_EXPORTER_OTLP_* settings")
+    fs.Var(&c.ShutdownTimeout, "shutdown-timeout", "time allowed for in-flight requests on shutdown")
+    fs.Var(&c.DrainDelay, "drain-delay", "time to report not ready before shutting down")
+    fs.Var(&c.CORSOrigins, "cors-origins", "comma-separated origins allowed by CORS, * for any")
+    fs.Var(&c.CORSMethods, "cors-methods", "comma-separated methods allowed by CORS")
+    fs.Var(&c.CORSHeaders, "cors-headers", "comma-separated request headers allowed by CORS")
+    fs.StringVar(&c.HealthPath, "health-path", c.HealthPath, "liveness endpoint path; empty disables it")
+    fs.StringVar(&c.ReadyPath, "ready-path", c.ReadyPath, "readiness endpoint path; empty disables it")
+    fs.StringVar(&c.MetricsPath, "metrics-path", c.MetricsPath, "Prometheus metrics endpoint path; empty disables it")
+    fs.StringVar(&c.SpecJSONPath, "spec-json-path", c.SpecJSONPath, "OpenAPI JSON endpoint path; empty disables it")
+    fs.StringVar(&c.SpecYAMLPath, "spec-yaml-path", c.SpecYAMLPath, "OpenAPI YAML endpoint path; empty disables it")
+    fs.StringVar(&c.DocsPath, "docs-path", c.DocsPath, "API explorer path; empty disables it")
+    fs.StringVar(&c.RateLimitStore, "rate-limit-store", c.RateLimitStore, "where rate limit buckets are kept: memory or badger")
+    fs.BoolVar(&c.TrustProxy, "trust-proxy", c.TrustProxy, "take client IPs from X-Forwarded-For, set by a reverse proxy")
+    fs.Var(&c.IdempotencyTTL, "idempotency-ttl", "how long responses to POSTs with an Idempotency-Key are kept for replay")
+    return fs
+}
+
+// LoadConfig reads the configuration from the file named by -config or
+// <EnvPrefix>CONFIG, the environment and args, in increasing precedence, and
+// validates it
+func LoadConfig(args []string) (ServerConfig, error) {
+    cfg := DefaultConfig()
+    fs := cfg.flagSet()
+    if err := fs.Parse(args); err != nil {
+        return cfg, err
+    }
+    path := fs.Lookup("config").Value.String()
+    if path == "" {
+        path = os.Getenv(EnvPrefix + "CONFIG")
+    }
+    if path != "" {
+        if err := cfg.loadFile(path); err != nil {
+            return cfg, err
+        }
+    }
+    var envErr error
+    fs.VisitAll(func(f *flag.Flag) {
+        name := EnvPrefix + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+        if value, ok := os.LookupEnv(name); ok && f.Name != "config" {
+            if err := f.Value.Set(value); err != nil {
+                envErr = errors.Join(envErr, fmt.Errorf("%s: %v", name, err))
+            }
+        }
+    })
+    if envErr != nil {
+        return cfg, envErr
+    }
+    // Parse again so flags override the file and environment
+    if err := fs.Parse(args); err != nil {
+        return cfg, err
+    }
+    return cfg, cfg.Validate()
+}
+
+func (c *ServerConfig) loadFile(path string) error {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return fmt.Errorf("config: %v", err)
+    }
+    // Unknown keys are rejected so typos do not silently fall back to defaults
+    switch strings.ToLower(filepath.Ext(path)) {
+    case ".yaml", ".yml":
+        dec := yaml.NewDecoder(bytes.NewReader(data))
+        dec.KnownFields(true)
+        err = dec.Decode(c)
+    default:
+        dec := json.NewDecoder(bytes.NewReader(data))
+        dec.DisallowUnknownFields()
+        err = dec.Decode(c)
+    }
+    if err != nil && err != io.EOF {
+        return fmt.Errorf("config %s: %v", path, err)
+    }
+    return nil
+}
+
+// Validate reports every invalid setting
+func (c ServerConfig) Validate() error {
+    var errs []error
+    if c.Addr == "" {
+        errs = append(errs, errors.New("addr must not be empty"))
+    }
+    if (c.TLSCert == "") != (c.TLSKey == "") {
+        errs = append(errs, errors.New("tls-cert and tls-key must be set together"))
+    }
+    for _, file := range []string{c.TLSCert, c.TLSKey} {
+        if file == "" {
+            continue
+        }
+        if _, err := os.Stat(file); err != nil {
+            errs = append(errs, fmt.Errorf("TLS file: %v", err))
+        }
+    }
+    if c.DBPath == "" && !c.DBInMemory {
+        errs = append(errs, errors.New("db-path must not be empty unless db-in-memory is set"))
+    }
+    if c.ValueLogFileSize < 1<<20 || c.ValueLogFileSize >= 2<<30 {
+        errs = append(errs, errors.New("value-log-file-size must be at least 1MB and below 2GB"))
+    }
+    var level slog.Level
+    if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+        errs = append(errs, fmt.Errorf("log-level %q must be debug, info, warn or error", c.LogLevel))
+    }
+    if c.LogFormat != "text" && c.LogFormat != "json" {
+        errs = append(errs, fmt.Errorf("log-format %q must be text or json", c.LogFormat))
+    }
+    if c.TraceExporter != "" && c.TraceExporter != "otlp" && c.TraceExporter != "stdout" {
+        errs = append(errs, fmt.Errorf("trace-exporter %q must be otlp or stdout", c.TraceExporter))
+    }
+    if c.RateLimitStore != "memory" && c.RateLimitStore != "badger" {
+        errs = append(errs, fmt.Errorf("rate-limit-store %q must be memory or badger", c.RateLimitStore))
+    }
+    if c.IdempotencyTTL <= 0 {
+        errs = append(errs, errors.New("idempotency-ttl must be positive"))
+    }
+    if c.ShutdownTimeout < 0 || c.DrainDelay < 0 {
+        errs = append(errs, errors.New("shutdown-timeout and drain-delay must not be negative"))
+    }
+    for _, origin := range c.CORSOrigins {
+        if origin != "*" && !strings.Contains(origin, "://") {
+            errs = append(errs, fmt.Errorf("CORS origin %q must be * or scheme://host", origin))
+        }
+    }
+    probes := map[string]bool{}
+    if c.DocsPath != "" && c.SpecJSONPath == "" {
+        errs = append(errs, errors.New("docs-path requires spec-json-path, which the API explorer loads"))
+    }
+    for _, path := range []string{c.HealthPath, c.ReadyPath, c.MetricsPath, c.SpecJSONPath, c.SpecYAMLPath, c.DocsPath} {
+        if path == "" {
+            continue
+        }
+        if !strings.HasPrefix(path, "/") || strings.ContainsAny(path, "{} ") {
+            errs = append(errs, fmt.Errorf("endpoint path %q must start with / and contain no wildcards", path))
+        }
+        if probes[path] {
+            errs = append(errs, fmt.Errorf("endpoint path %q is used twice", path))
+        }
+        probes[path] = true
+        for _, rt := range routes {
+            if strings.Fields(rt.Pattern)[1] == path {
+                errs = append(errs, fmt.Errorf("endpoint path %q clashes with route %s", path, rt.Pattern))
+            }
+        }
+    }
+    return errors.Join(errs...)
+}
+
+// Apply installs the settings that live in package variables
+func (c ServerConfig) Apply() {
+    ShutdownTimeout = time.Duration(c.ShutdownTimeout)
+    DrainDelay = time.Duration(c.DrainDelay)
+    var level slog.Level
+    level.UnmarshalText([]byte(c.LogLevel))
+    LogLevel.Set(level)
+    setupLogging(c.LogFormat)
+    Cors.AllowOrigins = c.CORSOrigins
+    Cors.AllowMethods = c.CORSMethods
+    Cors.AllowHeaders = c.CORSHeaders
+    HealthPath, ReadyPath, MetricsPath = c.HealthPath, c.ReadyPath, c.MetricsPath
+    SpecJSONPath, SpecYAMLPath, DocsPath = c.SpecJSONPath, c.SpecYAMLPath, c.DocsPath
+    BlobDir = c.BlobDir
+    RateLimitStore, TrustProxy = c.RateLimitStore, c.TrustProxy
+    IdempotencyTTL = time.Duration(c.IdempotencyTTL)
+}
+
+// configDuration is a time.Duration written as "30s" in files, flags and the environment
+type configDuration time.Duration
+
+func (d configDuration) String() string {
+    return time.Duration(d).String()
+}
+
+func (d *configDuration) Set(s string) error {
+    v, err := time.ParseDuration(s)
+    *d = configDuration(v)
+    return err
+}
+
+func (d configDuration) MarshalText() ([]byte, error) {
+    return []byte(d.String()), nil
+}
+
+func (d *configDuration) UnmarshalText(text []byte) error {
+    return d.Set(string(text))
+}
+
+// configList is a string list written comma-separated in flags and the environment
+type configList []string
+
+func (l configList) String() string {
+    return strings.Join(l, ",")
+}
+
+func (l *configList) Set(s string) error {
+    *l = nil
+    for _, item := range strings.Split(s, ",") {
+        if item = strings.TrimSpace(item); item != "" {
+            *l = append(*l, item)
+        }
+    }
+    return nil
+}
+`)
+	return code.String()
+}
@@ -0,0 +1,518 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// specJSON normalizes the source specification to indented JSON
+func specJSON(spec *OpenAPISpec) (string, error) {
+	data, err := json.MarshalIndent(spec.Raw, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
+
+// specYAML writes the source specification as block-style YAML with sorted
+// keys. Strings that could be read back as another type are double-quoted.
+func specYAML(spec *OpenAPISpec) string {
+	var b strings.Builder
+	writeYAMLMap(&b, spec.Raw, 0, "")
+	return b.String()
+}
+
+var plainYAMLScalar = regexp.MustCompile(`^[A-Za-z_/][A-Za-z0-9_./-]*$`)
+
+// yamlScalar renders a JSON scalar, quoting strings only when needed
+func yamlScalar(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(t)
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case string:
+		switch strings.ToLower(t) {
+		case "true", "false", "yes", "no", "on", "off", "null", "y", "n":
+			return strconv.Quote(t)
+		}
+		if plainYAMLScalar.MatchString(t) {
+			return t
+		}
+		quoted, _ := json.Marshal(t)
+		return string(quoted)
+	case map[string]interface{}:
+		return "{}"
+	case []interface{}:
+		return "[]"
+	}
+	return fmt.Sprintf("%q", fmt.Sprint(v))
+}
+
+// writeYAMLMap writes the keys of m at indent; first, when set, replaces the
+// indentation of the first key (e.g. "  - " for a map inside a list)
+func writeYAMLMap(b *strings.Builder, m map[string]interface{}, indent int, first string) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		prefix := strings.Repeat(" ", indent)
+		if i == 0 && first != "" {
+			prefix = first
+		}
+		b.WriteString(prefix + yamlScalar(k) + ":")
+		switch t := m[k].(type) {
+		case map[string]interface{}:
+			if len(t) == 0 {
+				b.WriteString(" {}\n")
+				continue
+			}
+			b.WriteString("\n")
+			writeYAMLMap(b, t, indent+2, "")
+		case []interface{}:
+			if len(t) == 0 {
+				b.WriteString(" []\n")
+				continue
+			}
+			b.WriteString("\n")
+			writeYAMLList(b, t, indent+2)
+		default:
+			b.WriteString(" " + yamlScalar(t) + "\n")
+		}
+	}
+}
+
+func writeYAMLList(b *strings.Builder, items []interface{}, indent int) {
+	pad := strings.Repeat(" ", indent)
+	for _, item := range items {
+		switch t := item.(type) {
+		case map[string]interface{}:
+			if len(t) > 0 {
+				writeYAMLMap(b, t, indent+2, pad+"- ")
+				continue
+			}
+		case []interface{}:
+			if len(t) > 0 {
+				b.WriteString(pad + "-\n")
+				writeYAMLList(b, t, indent+2)
+				continue
+			}
+		}
+		b.WriteString(pad + "- " + yamlScalar(item) + "\n")
+	}
+}
+
+// generateDocsCode creates the endpoints serving the embedded specification
+// and the API explorer page
+func generateDocsCode(spec *OpenAPISpec) string {
+	var code strings.Builder
+	code.WriteString("package main\n\n")
+	code.WriteString("import (\n    _ \"embed\"\n    \"encoding/json\"\n    \"net/http\"\n    \"strings\"\n)\n\n")
+	code.WriteString("// Paths of the specification and API explorer endpoints; an empty path disables the endpoint\n")
+	code.WriteString("var (\n")
+	code.WriteString(fmt.Sprintf("    SpecJSONPath = %q\n", probePath(spec, "/openapi.json")))
+	code.WriteString(fmt.Sprintf("    SpecYAMLPath = %q\n", probePath(spec, "/openapi.yaml")))
+	code.WriteString(fmt.Sprintf("    DocsPath     = %q\n", probePath(spec, "/docs")))
+	code.WriteString(")\n\n")
+	code.WriteString(`// The source specification, normalized to JSON and YAML, and the API explorer
+var (
+    //go:embed openapi.json
+    specJSON []byte
+    //go:embed openapi.yaml
+    specYAML []byte
+    //go:embed docs.html
+    docsPage string
+)
+
+// registerDocs adds the specification and API explorer endpoints to mux
+func registerDocs(mux *http.ServeMux) {
+    if SpecJSONPath != "" {
+        mux.HandleFunc("GET "+SpecJSONPath, serveSpec(specJSON, "application/json"))
+    }
+    if SpecYAMLPath != "" {
+        mux.HandleFunc("GET "+SpecYAMLPath, serveSpec(specYAML, "application/yaml"))
+    }
+    if DocsPath != "" {
+        mux.HandleFunc("GET "+DocsPath, serveDocs)
+    }
+}
+
+func serveSpec(doc []byte, contentType string) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", contentType)
+        w.Header().Set("Cache-Control", "no-cache")
+        w.Write(doc)
+    }
+}
+
+// serveDocs writes the API explorer, pointing it at the served specification.
+// The page loads nothing but the specification and the operations it calls.
+func serveDocs(w http.ResponseWriter, r *http.Request) {
+    config, _ := json.Marshal(map[string]string{"spec": SpecJSONPath, "yaml": SpecYAMLPath})
+    page := strings.Replace(docsPage, "{{CONFIG}}", string(config), 1)
+    w.Header().Set("Content-Type", "text/html; charset=utf-8")
+    w.Header().Set("Content-Security-Policy", "default-src 'self'; style-src 'unsafe-inline'; script-src 'unsafe-inline'")
+    w.Write([]byte(page))
+}
+`)
+	return code.String()
+}
+
+// docsHTML is the API explorer served by generated servers. It is a single
+// page with inline styles and script so it works offline and without a CDN.
+const docsHTML = `<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>API Explorer</title>
+<style>
+  body { font-family: system-ui, sans-serif; margin: 0; color: #1f2328; background: #f6f8fa; }
+  header { background: #24292f; color: #fff; padding: 16px 24px; }
+  header h1 { margin: 0 0 4px; font-size: 22px; }
+  header a { color: #9ecbff; margin-right: 12px; font-size: 14px; }
+  main { max-width: 1000px; margin: 0 auto; padding: 16px 24px; }
+  .muted { color: #656d76; }
+  .panel { background: #fff; border: 1px solid #d0d7de; border-radius: 6px; padding: 12px 16px; margin-bottom: 16px; }
+  .panel h2 { margin: 0 0 8px; font-size: 16px; }
+  #filter { width: 100%; box-sizing: border-box; padding: 8px; margin-bottom: 16px; border: 1px solid #d0d7de; border-radius: 6px; }
+  details.op { background: #fff; border: 1px solid #d0d7de; border-radius: 6px; margin-bottom: 8px; }
+  details.op > summary { cursor: pointer; padding: 8px 12px; list-style: none; display: flex; gap: 12px; align-items: center; }
+  details.op > div { padding: 0 12px 12px; border-top: 1px solid #d0d7de; }
+  .method { font-weight: 600; font-size: 12px; color: #fff; border-radius: 4px; padding: 2px 8px; min-width: 52px; text-align: center; }
+  .get { background: #1f6feb; } .post { background: #1a7f37; } .put { background: #9a6700; }
+  .patch { background: #8250df; } .delete { background: #cf222e; } .other { background: #656d76; }
+  .path { font-family: ui-monospace, monospace; }
+  .tag { font-size: 12px; background: #ddf4ff; color: #0969da; border-radius: 10px; padding: 1px 8px; }
+  table { border-collapse: collapse; width: 100%; margin: 8px 0; }
+  td, th { text-align: left; padding: 4px 8px; border-bottom: 1px solid #eaeef2; vertical-align: top; font-size: 14px; }
+  input[type=text], select, textarea { width: 100%; box-sizing: border-box; font-family: ui-monospace, monospace; font-size: 13px; padding: 4px; }
+  textarea { min-height: 120px; }
+  button { background: #1f883d; color: #fff; border: 0; border-radius: 6px; padding: 6px 16px; cursor: pointer; }
+  pre { background: #f6f8fa; border: 1px solid #d0d7de; border-radius: 6px; padding: 8px; overflow: auto; max-height: 400px; font-size: 13px; }
+  .status-ok { color: #1a7f37; } .status-err { color: #cf222e; }
+</style>
+</head>
+<body>
+<header>
+  <h1 id="title">API Explorer</h1>
+  <div id="description" class="muted"></div>
+  <div id="links"></div>
+</header>
+<main>
+  <div id="error" class="panel status-err" hidden></div>
+  <section id="auth" class="panel" hidden><h2>Authorization</h2></section>
+  <input id="filter" type="search" placeholder="Filter by path, operation or tag">
+  <div id="ops"></div>
+</main>
+<script id="config" type="application/json">{{CONFIG}}</script>
+<script>
+(function () {
+  "use strict";
+  var config = JSON.parse(document.getElementById("config").textContent);
+  var methods = ["get", "put", "post", "delete", "options", "head", "patch", "trace"];
+  var spec = null;
+  var credentials = {};
+
+  function el(tag, attrs, children) {
+    var node = document.createElement(tag);
+    Object.keys(attrs || {}).forEach(function (k) {
+      if (k === "text") { node.textContent = attrs[k]; }
+      else if (k.indexOf("on") === 0) { node.addEventListener(k.slice(2), attrs[k]); }
+      else { node.setAttribute(k, attrs[k]); }
+    });
+    (children || []).forEach(function (c) { if (c) { node.appendChild(c); } });
+    return node;
+  }
+
+  // resolve follows local $ref pointers such as #/components/schemas/User
+  function resolve(obj) {
+    var seen = 0;
+    while (obj && obj.$ref && seen++ < 32) {
+      var target = spec;
+      obj.$ref.replace(/^#\//, "").split("/").forEach(function (part) {
+        part = part.replace(/~1/g, "/").replace(/~0/g, "~");
+        target = target ? target[part] : undefined;
+      });
+      obj = target;
+    }
+    return obj || {};
+  }
+
+  // example builds a sample value for a schema, preferring declared examples
+  function example(schema, depth) {
+    schema = resolve(schema);
+    if (depth > 6) { return null; }
+    if (schema.example !== undefined) { return schema.example; }
+    if (schema.format === "binary") { return undefined; }
+    if (schema["default"] !== undefined) { return schema["default"]; }
+    if (schema["enum"] && schema["enum"].length) { return schema["enum"][0]; }
+    if (schema.allOf) {
+      var merged = {};
+      schema.allOf.forEach(function (s) { Object.assign(merged, example(s, depth + 1)); });
+      return merged;
+    }
+    if (schema.oneOf || schema.anyOf) { return example((schema.oneOf || schema.anyOf)[0], depth + 1); }
+    switch (schema.type) {
+      case "array": return [example(schema.items || {}, depth + 1)];
+      case "integer": return 0;
+      case "number": return 0;
+      case "boolean": return false;
+      case "string":
+        if (schema.format === "date-time") { return new Date().toISOString(); }
+        if (schema.format === "date") { return new Date().toISOString().slice(0, 10); }
+        if (schema.format === "email") { return "user@example.com"; }
+        if (schema.format === "uuid") { return "00000000-0000-0000-0000-000000000000"; }
+        return "string";
+    }
+    var obj = {};
+    Object.keys(schema.properties || {}).forEach(function (name) {
+      var prop = resolve(schema.properties[name]);
+      if (!prop.readOnly) { obj[name] = example(prop, depth + 1); }
+    });
+    return obj;
+  }
+
+  // encodeExample renders a sample value in a request media type
+  function encodeExample(value, mediaType) {
+    if (value === undefined) { return ""; }
+    if (typeof value === "string") { return value; }
+    if (mediaType === "application/x-www-form-urlencoded") {
+      var params = new URLSearchParams();
+      Object.keys(value || {}).forEach(function (k) {
+        [].concat(value[k]).forEach(function (v) {
+          if (v !== null && v !== undefined && typeof v !== "object") { params.append(k, v); }
+        });
+      });
+      return params.toString();
+    }
+    if (/[/+]xml$/.test(mediaType)) { return toXML("document", value, ""); }
+    return JSON.stringify(value, null, 2);
+  }
+
+  function toXML(name, value, indent) {
+    if (Array.isArray(value)) {
+      return value.map(function (v) { return toXML(name, v, indent); }).join("");
+    }
+    if (value === null || value === undefined) { return ""; }
+    if (typeof value === "object") {
+      var inner = Object.keys(value).map(function (k) { return toXML(k, value[k], indent + "  "); }).join("");
+      return indent + "<" + name + ">\n" + inner + indent + "</" + name + ">\n";
+    }
+    var text = String(value).replace(/&/g, "&amp;").replace(/</g, "&lt;");
+    return indent + "<" + name + ">" + text + "</" + name + ">\n";
+  }
+
+  // multipartBody sends the JSON fields of the textarea and the chosen files
+  function multipartBody(text, fileInputs) {
+    var form = new FormData(), fields = {};
+    try { fields = JSON.parse(text || "{}"); } catch (e) { /* send the files only */ }
+    Object.keys(fields).forEach(function (k) {
+      [].concat(fields[k]).forEach(function (v) {
+        if (v !== null && v !== undefined) { form.append(k, typeof v === "object" ? JSON.stringify(v) : v); }
+      });
+    });
+    fileInputs.forEach(function (input) {
+      Array.prototype.forEach.call(input.files, function (f) { form.append(input.name, f); });
+    });
+    return form;
+  }
+
+  function renderAuth() {
+    var schemes = (spec.components && spec.components.securitySchemes) || {};
+    var names = Object.keys(schemes);
+    if (!names.length) { return; }
+    var section = document.getElementById("auth");
+    var rows = names.map(function (name) {
+      var s = resolve(schemes[name]);
+      var hint = s.type === "apiKey" ? s["in"] + " " + s.name :
+        s.type === "http" && s.scheme === "basic" ? "user:password" : "bearer token";
+      var input = el("input", { type: "text", placeholder: hint, oninput: function () { credentials[name] = input.value; } });
+      return el("tr", {}, [el("td", { text: name }), el("td", { "class": "muted", text: s.type }), el("td", {}, [input])]);
+    });
+    section.appendChild(el("table", {}, rows));
+    section.hidden = false;
+  }
+
+  // authorize adds the credentials of the schemes an operation accepts
+  function authorize(op, headers, query) {
+    var requirements = op.security || spec.security || [];
+    var schemes = (spec.components && spec.components.securitySchemes) || {};
+    requirements.forEach(function (req) {
+      Object.keys(req).forEach(function (name) {
+        var value = credentials[name];
+        var s = resolve(schemes[name]);
+        if (!value) { return; }
+        if (s.type === "apiKey") {
+          if (s["in"] === "header") { headers[s.name] = value; }
+          if (s["in"] === "query") { query.set(s.name, value); }
+        } else if (s.type === "http" && s.scheme === "basic") {
+          headers.Authorization = "Basic " + btoa(value);
+        } else {
+          headers.Authorization = "Bearer " + value;
+        }
+      });
+    });
+  }
+
+  function renderOperation(path, method, op, shared) {
+    var params = (shared || []).concat(op.parameters || []).map(resolve).filter(function (p) { return p["in"] !== "cookie"; });
+    (path.match(/\{[^}]+\}/g) || []).forEach(function (segment) {
+      var name = segment.slice(1, -1);
+      var declared = params.some(function (p) { return p["in"] === "path" && p.name === name; });
+      if (!declared) { params.push({ name: name, "in": "path", required: true, schema: { type: "string" } }); }
+    });
+    var inputs = {};
+    var paramRows = params.map(function (p) {
+      var schema = resolve(p.schema);
+      var input = el("input", { type: "text", placeholder: schema.type || "" });
+      if (p.example !== undefined) { input.value = p.example; }
+      inputs[p["in"] + ":" + p.name] = input;
+      return el("tr", {}, [
+        el("td", { "class": "path", text: p.name + (p.required ? " *" : "") }),
+        el("td", { "class": "muted", text: p["in"] }),
+        el("td", { text: p.description || "" }),
+        el("td", {}, [input])
+      ]);
+    });
+
+    var body = op.requestBody ? resolve(op.requestBody) : null;
+    var bodyType = null, bodyInput = null, files = el("div", {}), fileInputs = [];
+    if (body && body.content) {
+      var types = Object.keys(body.content);
+      bodyType = el("select", {}, types.map(function (t) { return el("option", { text: t }); }));
+      bodyInput = el("textarea", {});
+      var fill = function () {
+        var media = body.content[bodyType.value] || {};
+        var value = media.example !== undefined ? media.example : example(media.schema || {}, 0);
+        bodyInput.value = encodeExample(value, bodyType.value);
+        fileInputs = [];
+        files.replaceChildren();
+        if (bodyType.value.indexOf("multipart/") === 0) {
+          var props = resolve(media.schema).properties || {};
+          Object.keys(props).forEach(function (name) {
+            var prop = resolve(props[name]);
+            if (prop.format === "binary" || resolve(prop.items).format === "binary") {
+              var input = el("input", { type: "file", name: name, multiple: "" });
+              fileInputs.push(input);
+              files.appendChild(el("p", {}, [el("span", { "class": "path", text: name + " " }), input]));
+            }
+          });
+        }
+      };
+      bodyType.addEventListener("change", fill);
+      fill();
+    }
+
+    var responseRows = Object.keys(op.responses || {}).map(function (code) {
+      var r = resolve(op.responses[code]);
+      return el("tr", {}, [el("td", { "class": "path", text: code }), el("td", { text: r.description || "" })]);
+    });
+
+    var result = el("div", {});
+    var send = function () {
+      var url = path, query = new URLSearchParams(), headers = {};
+      params.forEach(function (p) {
+        var value = inputs[p["in"] + ":" + p.name].value;
+        if (value === "") { return; }
+        if (p["in"] === "path") { url = url.replace("{" + p.name + "}", encodeURIComponent(value)); }
+        if (p["in"] === "query") { query.append(p.name, value); }
+        if (p["in"] === "header") { headers[p.name] = value; }
+      });
+      authorize(op, headers, query);
+      var init = { method: method.toUpperCase(), headers: headers };
+      if (bodyInput && ["get", "head"].indexOf(method) < 0) {
+        if (bodyType.value.indexOf("multipart/") === 0) {
+          // The browser sets the multipart Content-Type with its boundary
+          init.body = multipartBody(bodyInput.value, fileInputs);
+        } else {
+          headers["Content-Type"] = bodyType.value;
+          init.body = bodyInput.value;
+        }
+      }
+      if (query.toString()) { url += "?" + query.toString(); }
+      result.replaceChildren(el("p", { "class": "muted", text: init.method + " " + url + " ..." }));
+      var started = performance.now();
+      fetch(url, init).then(function (res) {
+        return res.text().then(function (text) {
+          var lines = [];
+          res.headers.forEach(function (v, k) { lines.push(k + ": " + v); });
+          try { text = JSON.stringify(JSON.parse(text), null, 2); } catch (e) { /* not JSON */ }
+          result.replaceChildren(
+            el("p", { "class": res.ok ? "status-ok" : "status-err",
+              text: res.status + " " + res.statusText + " in " + Math.round(performance.now() - started) + " ms" }),
+            el("pre", { text: lines.join("\n") }),
+            el("pre", { text: text || "(empty body)" }));
+        });
+      }).catch(function (err) {
+        result.replaceChildren(el("p", { "class": "status-err", text: String(err) }));
+      });
+    };
+
+    var known = ["get", "put", "post", "delete", "patch"].indexOf(method) >= 0;
+    var details = el("details", { "class": "op" }, [
+      el("summary", {}, [
+        el("span", { "class": "method " + (known ? method : "other"), text: method.toUpperCase() }),
+        el("span", { "class": "path", text: path }),
+        el("span", { "class": "muted", text: op.summary || op.operationId || "" })
+      ].concat((op.tags || []).map(function (t) { return el("span", { "class": "tag", text: t }); }))),
+      el("div", {}, [
+        op.description ? el("p", { text: op.description }) : null,
+        op.operationId ? el("p", { "class": "muted path", text: "operationId: " + op.operationId }) : null,
+        paramRows.length ? el("h4", { text: "Parameters" }) : null,
+        paramRows.length ? el("table", {}, paramRows) : null,
+        bodyInput ? el("h4", { text: "Request body" }) : null,
+        bodyType, bodyInput, files,
+        responseRows.length ? el("h4", { text: "Responses" }) : null,
+        responseRows.length ? el("table", {}, responseRows) : null,
+        el("p", {}, [el("button", { text: "Send request", onclick: send })]),
+        result
+      ])
+    ]);
+    details.dataset.search = [path, method, op.operationId || "", op.summary || "", (op.tags || []).join(" ")].join(" ").toLowerCase();
+    return details;
+  }
+
+  function render() {
+    var info = spec.info || {};
+    document.title = (info.title || "API") + " - API Explorer";
+    document.getElementById("title").textContent = (info.title || "API") + (info.version ? " " + info.version : "");
+    document.getElementById("description").textContent = info.description || "";
+    var links = document.getElementById("links");
+    links.appendChild(el("a", { href: config.spec, text: "openapi.json" }));
+    if (config.yaml) { links.appendChild(el("a", { href: config.yaml, text: "openapi.yaml" })); }
+    renderAuth();
+    var ops = document.getElementById("ops");
+    Object.keys(spec.paths || {}).sort().forEach(function (path) {
+      var item = spec.paths[path];
+      methods.forEach(function (method) {
+        if (item[method]) { ops.appendChild(renderOperation(path, method, item[method], item.parameters)); }
+      });
+    });
+    document.getElementById("filter").addEventListener("input", function (e) {
+      var q = e.target.value.toLowerCase();
+      Array.prototype.forEach.call(ops.children, function (d) { d.hidden = d.dataset.search.indexOf(q) < 0; });
+    });
+  }
+
+  fetch(config.spec).then(function (res) {
+    if (!res.ok) { throw new Error("loading " + config.spec + ": " + res.status); }
+    return res.json();
+  }).then(function (doc) { spec = doc; render(); }).catch(function (err) {
+    var box = document.getElementById("error");
+    box.textContent = String(err);
+    box.hidden = false;
+  });
+})();
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var (
+	modCache     = flag.String("modcache", filepath.Join("testdata", "modcache"), "module cache the generated projects compile against, with the network off")
+	fillModCache = flag.Bool("fill-modcache", false, "download the dependencies of the generated projects into -modcache first")
+)
+
+// goCommand runs the go tool in dir against the module cache, offline unless
+// online is set, and fails the test with its output when it fails
+func goCommand(t *testing.T, dir string, online bool, args ...string) {
+	t.Helper()
+	cache, err := filepath.Abs(*modCache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GOMODCACHE="+cache,
+		"GOFLAGS=-mod=mod",
+		"GOWORK=off",
+		"GOTOOLCHAIN=local",
+	)
+	if !online {
+		cmd.Env = append(cmd.Env, "GOPROXY=off", "GOSUMDB=off")
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+}
+
+// TestGeneratedProjectsBuild compiles, vets and tests the project generated
+// for every spec of the corpus, including its client and command-line client.
+// Running the generated handler and fuzz tests checks the server against its
+// own spec. Dependencies come from -modcache only, so broken imports or a
+// go.mod that no longer matches the code fail here without network access.
+// The cache is not committed; without it the test is skipped.
+func TestGeneratedProjectsBuild(t *testing.T) {
+	requireModCache(t)
+	for _, name := range corpus(t) {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			dir := t.TempDir()
+			generateProject(t, name, dir)
+			// The generated go.mod lists direct dependencies only, as users
+			// run go mod tidy after generating
+			goCommand(t, dir, *fillModCache, "mod", "tidy")
+			goCommand(t, dir, false, "build", "./...")
+			goCommand(t, dir, false, "vet", "./...")
+			goCommand(t, dir, false, "test", "./...")
+		})
+	}
+}
+
+// requireModCache skips tests that compile generated projects when they
+// cannot run offline
+func requireModCache(t *testing.T) {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("compiling generated projects is slow")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go tool not found")
+	}
+	if _, err := os.Stat(*modCache); os.IsNotExist(err) && !*fillModCache {
+		t.Skipf("no module cache in %s; run go test -run TestGeneratedProjectsBuild -fill-modcache once", *modCache)
+	}
+}
+
+// TestGeneratedRuntime checks the behavior of the generated runtime. Every
+// spec in testdata/runtime is generated and the test file of the same name
+// next to it is copied into the project and run there, against the
+// generated server.
+func TestGeneratedRuntime(t *testing.T) {
+	requireModCache(t)
+	specs, err := filepath.Glob(filepath.Join("testdata", "runtime", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range specs {
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			dir := t.TempDir()
+			spec, err := readOpenAPISpec(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := generateCode(spec, dir); err != nil {
+				t.Fatal(err)
+			}
+			test, err := os.ReadFile(filepath.Join("testdata", "runtime", name+"_test.go"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, "runtime_test.go"), test, 0644); err != nil {
+				t.Fatal(err)
+			}
+			goCommand(t, dir, *fillModCache, "mod", "tidy")
+			goCommand(t, dir, false, "test", "-run", "^TestRuntime", ".")
+		})
+	}
+}
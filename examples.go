@@ -0,0 +1,235 @@
+package main
+
+import (
+	"regexp/syntax"
+	"sort"
+	"strings"
+)
+
+// resolveRef follows a local $ref to the object it points at
+func resolveRef(spec *OpenAPISpec, v interface{}) interface{} {
+	for i := 0; i < 16; i++ {
+		obj, ok := v.(map[string]interface{})
+		ref, _ := obj["$ref"].(string)
+		if !ok || !strings.HasPrefix(ref, "#/") {
+			return v
+		}
+		v = spec.Raw
+		for _, token := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+			parent, _ := v.(map[string]interface{})
+			v = parent[token]
+		}
+	}
+	return v
+}
+
+// resolveSchema follows the $ref of a schema
+func resolveSchema(spec *OpenAPISpec, schema map[string]interface{}) map[string]interface{} {
+	resolved, _ := resolveRef(spec, schema).(map[string]interface{})
+	return resolved
+}
+
+// mediaExample picks the body of a media type: the named example, its example,
+// the first of its examples, else data synthesized from its schema
+func mediaExample(spec *OpenAPISpec, media map[string]interface{}, name string) interface{} {
+	examples, _ := media["examples"].(map[string]interface{})
+	if e, ok := resolveRef(spec, examples[name]).(map[string]interface{}); ok && name != "" {
+		return e["value"]
+	}
+	if v, ok := media["example"]; ok {
+		return v
+	}
+	names := make([]string, 0, len(examples))
+	for n := range examples {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		if e, ok := resolveRef(spec, examples[n]).(map[string]interface{}); ok {
+			if v, ok := e["value"]; ok {
+				return v
+			}
+		}
+	}
+	schema, _ := media["schema"].(map[string]interface{})
+	return synthesize(spec, schema, 0)
+}
+
+// synthesize makes up a value matching a schema, preferring its example,
+// default or first enum value
+func synthesize(spec *OpenAPISpec, schema map[string]interface{}, depth int) interface{} {
+	schema = resolveSchema(spec, schema)
+	if schema == nil || depth > 8 {
+		return nil
+	}
+	for _, key := range []string{"example", "default"} {
+		if v, ok := schema[key]; ok {
+			return v
+		}
+	}
+	if values, ok := schema["enum"].([]interface{}); ok && len(values) > 0 {
+		return values[0]
+	}
+	if all, ok := schema["allOf"].([]interface{}); ok {
+		merged := map[string]interface{}{}
+		for _, sub := range all {
+			s, _ := sub.(map[string]interface{})
+			if object, ok := synthesize(spec, s, depth+1).(map[string]interface{}); ok {
+				for k, v := range object {
+					merged[k] = v
+				}
+			}
+		}
+		return merged
+	}
+	for _, keyword := range []string{"oneOf", "anyOf"} {
+		if variants, ok := schema[keyword].([]interface{}); ok && len(variants) > 0 {
+			s, _ := variants[0].(map[string]interface{})
+			return synthesize(spec, s, depth+1)
+		}
+	}
+	t, _ := schema["type"].(string)
+	if types, ok := schema["type"].([]interface{}); ok && len(types) > 0 {
+		t, _ = types[0].(string)
+	}
+	switch t {
+	case "string":
+		switch schema["format"] {
+		case "date-time":
+			return "2024-01-01T12:00:00Z"
+		case "date":
+			return "2024-01-01"
+		case "email":
+			return "user@example.com"
+		case "uuid":
+			return "3fa85f64-5717-4562-b3fc-2c963f66afa6"
+		case "uri", "url":
+			return "https://example.com"
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			if s, ok := patternExample(pattern); ok {
+				return s
+			}
+		}
+		s := "string"
+		if min, ok := schema["minLength"].(float64); ok && int(min) > len(s) {
+			s += strings.Repeat("x", int(min)-len(s))
+		}
+		return s
+	case "integer", "number":
+		if min, ok := schema["minimum"].(float64); ok {
+			return min
+		}
+		return 0
+	case "boolean":
+		return true
+	case "array":
+		// Items cut off by the depth limit leave the array empty
+		items, _ := schema["items"].(map[string]interface{})
+		if item := synthesize(spec, items, depth+1); item != nil {
+			return []interface{}{item}
+		}
+		return []interface{}{}
+	case "null":
+		return nil
+	}
+	properties, _ := schema["properties"].(map[string]interface{})
+	if t == "object" || len(properties) > 0 {
+		// Below the third level only required properties are filled in, so
+		// recursive schemas end in valid values
+		required := map[string]bool{}
+		names, _ := schema["required"].([]interface{})
+		for _, name := range names {
+			if s, ok := name.(string); ok {
+				required[s] = true
+			}
+		}
+		object := map[string]interface{}{}
+		for name, raw := range properties {
+			if depth >= 3 && !required[name] {
+				continue
+			}
+			prop, _ := raw.(map[string]interface{})
+			if value := synthesize(spec, prop, depth+1); value != nil {
+				object[name] = value
+			}
+		}
+		return object
+	}
+	return nil
+}
+
+// patternExample makes up the shortest string a regular expression matches,
+// or reports false for expressions it cannot follow
+func patternExample(pattern string) (string, bool) {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return "", false
+	}
+	var b strings.Builder
+	if !writeMatch(&b, re.Simplify()) {
+		return "", false
+	}
+	return b.String(), true
+}
+
+// writeMatch appends the shortest match of a parsed expression, taking the
+// first alternative and preferring letters and digits from character classes
+func writeMatch(b *strings.Builder, re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpEmptyMatch, syntax.OpBeginLine, syntax.OpEndLine, syntax.OpBeginText, syntax.OpEndText,
+		syntax.OpWordBoundary, syntax.OpNoWordBoundary, syntax.OpStar, syntax.OpQuest:
+		return true
+	case syntax.OpLiteral:
+		b.WriteString(string(re.Rune))
+		return true
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		b.WriteByte('a')
+		return true
+	case syntax.OpCharClass:
+		c, ok := classRune(re.Rune)
+		if ok {
+			b.WriteRune(c)
+		}
+		return ok
+	case syntax.OpCapture, syntax.OpPlus, syntax.OpAlternate:
+		return writeMatch(b, re.Sub[0])
+	case syntax.OpRepeat:
+		for i := 0; i < re.Min; i++ {
+			if !writeMatch(b, re.Sub[0]) {
+				return false
+			}
+		}
+		return true
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			if !writeMatch(b, sub) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// classRune picks a printable rune from the ranges of a character class
+func classRune(ranges []rune) (rune, bool) {
+	for _, want := range []rune{'a', 'A', '0'} {
+		for i := 0; i+1 < len(ranges); i += 2 {
+			if ranges[i] <= want && want <= ranges[i+1] {
+				return want, true
+			}
+		}
+	}
+	for i := 0; i+1 < len(ranges); i += 2 {
+		lo, hi := ranges[i], ranges[i+1]
+		if lo < '!' {
+			lo = '!'
+		}
+		if lo <= hi && lo != 0x7f {
+			return lo, true
+		}
+	}
+	return 0, false
+}
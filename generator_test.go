@@ -0,0 +1,299 @@
+package main
+
+import (
+	"errors"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtractSchemas(t *testing.T) {
+	components := map[string]interface{}{
+		"schemas": map[string]interface{}{
+			"Pet": map[string]interface{}{
+				"type":     "object",
+				"required": []interface{}{"name"},
+				"properties": map[string]interface{}{
+					"name": map[string]interface{}{"type": "string"},
+					"tag":  map[string]interface{}{"$ref": "#/components/schemas/Tag"},
+				},
+			},
+			"Status": map[string]interface{}{
+				"type": "string",
+				"enum": []interface{}{"available", "sold"},
+			},
+			"Animal": map[string]interface{}{
+				"oneOf": []interface{}{
+					map[string]interface{}{"$ref": "#/components/schemas/Pet"},
+				},
+			},
+		},
+	}
+	schemas, err := extractSchemas(components)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schemas) != 3 {
+		t.Fatalf("got %d schemas, want 3", len(schemas))
+	}
+	pet := schemas["Pet"]
+	if pet.Type != "object" || !reflect.DeepEqual(pet.Required, []string{"name"}) || len(pet.Properties) != 2 {
+		t.Errorf("Pet = %+v", pet)
+	}
+	if status := schemas["Status"]; status.Type != "string" || len(status.Enum) != 2 {
+		t.Errorf("Status = %+v", status)
+	}
+	if animal := schemas["Animal"]; animal.Type != "" || len(animal.OneOf) != 1 {
+		t.Errorf("Animal = %+v", animal)
+	}
+}
+
+func TestExtractSchemasWithoutSchemas(t *testing.T) {
+	schemas, err := extractSchemas(map[string]interface{}{})
+	if err != nil || len(schemas) != 0 {
+		t.Errorf("got %v, %v, want no schemas", schemas, err)
+	}
+	if _, err := extractSchemas(map[string]interface{}{"schemas": []interface{}{}}); err == nil {
+		t.Error("schemas that are not an object should fail")
+	}
+}
+
+func TestExtractSchemasTypeArrays(t *testing.T) {
+	components := map[string]interface{}{
+		"schemas": map[string]interface{}{
+			"Note":  map[string]interface{}{"type": []interface{}{"object", "null"}},
+			"Label": map[string]interface{}{"type": []interface{}{"string"}},
+			"Value": map[string]interface{}{"type": []interface{}{"string", "integer"}},
+		},
+	}
+	schemas, err := extractSchemas(components)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if note := schemas["Note"]; note.Type != "object" || !note.Nullable {
+		t.Errorf("Note = %+v, want a nullable object", note)
+	}
+	if label := schemas["Label"]; label.Type != "string" || label.Nullable {
+		t.Errorf("Label = %+v, want a string", label)
+	}
+	if value := schemas["Value"]; value.Type != "" {
+		t.Errorf("Value = %+v, want no single type", value)
+	}
+}
+
+func TestExtractSchemasReportsPointer(t *testing.T) {
+	components := map[string]interface{}{
+		"schemas": map[string]interface{}{
+			"Pet": map[string]interface{}{"type": "object", "required": "name"},
+		},
+	}
+	_, err := extractSchemas(components)
+	var se *schemaError
+	if !errors.As(err, &se) || se.Pointer != "/components/schemas/Pet" {
+		t.Errorf("got %v, want an error at /components/schemas/Pet", err)
+	}
+}
+
+func TestExtractInlineSchemas(t *testing.T) {
+	object := map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"q": map[string]interface{}{"type": "string"}},
+	}
+	paths := map[string]interface{}{
+		"/search": map[string]interface{}{
+			"post": map[string]interface{}{
+				"operationId": "search",
+				"requestBody": map[string]interface{}{
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{"schema": object},
+					},
+				},
+				"responses": map[string]interface{}{
+					"200": map[string]interface{}{
+						"content": map[string]interface{}{
+							"application/json": map[string]interface{}{"schema": object},
+						},
+					},
+					"404": map[string]interface{}{
+						"content": map[string]interface{}{
+							"application/json": map[string]interface{}{
+								"schema": map[string]interface{}{"$ref": "#/components/schemas/Problem"},
+							},
+						},
+					},
+				},
+			},
+		},
+		"/upload": map[string]interface{}{
+			"put": map[string]interface{}{
+				"operationId": "upload",
+				"requestBody": map[string]interface{}{
+					"content": map[string]interface{}{
+						"application/octet-stream": map[string]interface{}{
+							"schema": map[string]interface{}{"type": "string", "format": "binary"},
+						},
+					},
+				},
+			},
+		},
+	}
+	schemas, err := extractInlineSchemas(paths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for name := range schemas {
+		names = append(names, name)
+	}
+	for _, name := range []string{"SearchRequest", "SearchResponse200", "UploadRequest"} {
+		if _, ok := schemas[name]; !ok {
+			t.Errorf("missing %s in %v", name, names)
+		}
+	}
+	if len(schemas) != 3 {
+		t.Errorf("got %v, want referenced schemas to be skipped", names)
+	}
+	if upload := schemas["UploadRequest"]; upload.Type != "string" || upload.Format != "binary" {
+		t.Errorf("UploadRequest = %+v", upload)
+	}
+}
+
+func TestGenerateStructs(t *testing.T) {
+	schemas := map[string]Schema{
+		"pet": {
+			Type:     "object",
+			Required: []string{"name"},
+			Properties: map[string]interface{}{
+				"name":   map[string]interface{}{"type": "string"},
+				"age":    map[string]interface{}{"type": "integer"},
+				"photo":  map[string]interface{}{"type": "string", "format": "binary"},
+				"extras": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string", "format": "binary"}},
+			},
+		},
+		"Note":   {Type: "string"},
+		"Animal": {OneOf: []interface{}{map[string]interface{}{"$ref": "#/components/schemas/pet"}}},
+		"puppy": {AllOf: []interface{}{
+			map[string]interface{}{"$ref": "#/components/schemas/pet"},
+			map[string]interface{}{"type": "object", "properties": map[string]interface{}{"breed": map[string]interface{}{"type": "string"}}},
+		}},
+	}
+	want := "package models\n\n" +
+		"// Auto-generated structs from OpenAPI spec\n\n" +
+		"type Animal interface{}\n\n" +
+		"type Note string\n\n" +
+		"type Pet struct {\n" +
+		"    Age int `json:\"age,omitempty\"`\n" +
+		"    Extras []*BlobRef `json:\"extras,omitempty\"`\n" +
+		"    Name string `json:\"name\"`\n" +
+		"    Photo *BlobRef `json:\"photo,omitempty\"`\n" +
+		"}\n\n" +
+		"type Puppy struct {\n" +
+		"    Age int `json:\"age,omitempty\"`\n" +
+		"    Breed string `json:\"breed,omitempty\"`\n" +
+		"    Extras []*BlobRef `json:\"extras,omitempty\"`\n" +
+		"    Name string `json:\"name\"`\n" +
+		"    Photo *BlobRef `json:\"photo,omitempty\"`\n" +
+		"}\n\n"
+	got := generateStructs(schemas, "models")
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	for i := 0; i < 10; i++ {
+		if again := generateStructs(schemas, "models"); again != got {
+			t.Fatal("output changes between runs")
+		}
+	}
+}
+
+func TestGenerateServerAndHandlers(t *testing.T) {
+	spec, err := readOpenAPISpec("testdata/specs/petstore.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	schemas, err := collectSchemas(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops := collectOperations(spec.Paths)
+	serverCode, handlerCode := generateServerAndHandlers(spec, ops, schemas)
+
+	fset := token.NewFileSet()
+	for name, code := range map[string]string{"server.go": serverCode, "handlers.go": handlerCode} {
+		if _, err := parser.ParseFile(fset, name, code, parser.AllErrors); err != nil {
+			t.Errorf("%s does not parse: %v", name, err)
+		}
+	}
+	for _, want := range []string{
+		`Pattern: "GET /pets", OperationID: "listPets"`,
+		`Pattern: "POST /pets", OperationID: "createPets"`,
+		`Pattern: "GET /pets/{petId}", OperationID: "showPetById"`,
+	} {
+		if !strings.Contains(serverCode, want) {
+			t.Errorf("server.go has no route %s", want)
+		}
+	}
+	for _, op := range ops {
+		if !strings.Contains(handlerCode, "func "+op.HandlerName+"(") {
+			t.Errorf("handlers.go has no handler for %s", op.OperationID)
+		}
+	}
+}
+
+func TestWriteEntityResponse(t *testing.T) {
+	op := operation{HandlerName: "GetPet", Endpoint: map[string]interface{}{
+		"responses": map[string]interface{}{
+			"200": map[string]interface{}{
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": map[string]interface{}{"$ref": "#/components/schemas/Pet"},
+					},
+				},
+			},
+		},
+	}}
+	success := successResponse(op, "200", "200")
+
+	var code strings.Builder
+	writeEntityResponse(&code, op, success, "Pet", "result", "    ")
+	if !strings.Contains(code.String(), "var resp Pet\n") {
+		t.Errorf("a response of the entity's schema should be decoded into its struct:\n%s", code.String())
+	}
+
+	// A response of another schema, or of a oneOf the struct cannot hold,
+	// sends the stored document as it is
+	code.Reset()
+	writeEntityResponse(&code, op, success, "Owner", "result", "    ")
+	want := "    writeDocument(w, r, http.StatusOK, \"Pet\", json.RawMessage(result))\n"
+	if code.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", code.String(), want)
+	}
+}
+
+func TestCheckSecurity(t *testing.T) {
+	spec := &OpenAPISpec{
+		Components: map[string]interface{}{
+			"securitySchemes": map[string]interface{}{
+				"apiKey": map[string]interface{}{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+			},
+		},
+		Security: []map[string][]string{{"apiKey": {}}},
+	}
+	op := func(security ...interface{}) operation {
+		return operation{Method: "GET", Path: "/pets", Endpoint: map[string]interface{}{"security": security}}
+	}
+	if err := checkSecurity(spec, []operation{op(map[string]interface{}{"apiKey": []interface{}{}})}); err != nil {
+		t.Errorf("declared scheme: %v", err)
+	}
+
+	// A typo must not turn authentication off
+	err := checkSecurity(spec, []operation{op(map[string]interface{}{"api_key": []interface{}{}})})
+	if err == nil || !strings.Contains(err.Error(), `GET /pets: security: unknown security scheme "api_key"`) {
+		t.Errorf("got %v, want the unknown scheme named", err)
+	}
+	spec.Security = []map[string][]string{{"bearer": {}}}
+	if err := checkSecurity(spec, nil); err == nil || !strings.Contains(err.Error(), `"bearer"`) {
+		t.Errorf("got %v, want the unknown root scheme named", err)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden output in testdata/golden")
+
+// corpus lists the specs in testdata/specs by name, without the extension
+func corpus(t *testing.T) []string {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join("testdata", "specs", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no specs in testdata/specs")
+	}
+	names := make([]string, len(paths))
+	for i, path := range paths {
+		names[i] = strings.TrimSuffix(filepath.Base(path), ".json")
+	}
+	return names
+}
+
+// generateProject writes what 'oapi-gen generate -client -cli' writes for the
+// named spec of the corpus into dir
+func generateProject(t *testing.T, name, dir string) {
+	t.Helper()
+	spec, err := readOpenAPISpec(filepath.Join("testdata", "specs", name+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := generateCode(spec, dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := generateClient(spec, filepath.Join(dir, "client")); err != nil {
+		t.Fatal(err)
+	}
+	if err := generateCLIClient(spec, filepath.Join(dir, "cmd", specSlug(spec))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readTree returns the files below dir by their slash-separated relative path
+func readTree(t *testing.T, dir string) map[string][]byte {
+	t.Helper()
+	files := map[string][]byte{}
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		files[filepath.ToSlash(rel)] = data
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestGolden(t *testing.T) {
+	for _, name := range corpus(t) {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			generateProject(t, name, dir)
+			got := readTree(t, dir)
+			golden := filepath.Join("testdata", "golden", name)
+
+			if *update {
+				if err := os.RemoveAll(golden); err != nil {
+					t.Fatal(err)
+				}
+				for rel, data := range got {
+					path := filepath.Join(golden, filepath.FromSlash(rel))
+					if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+						t.Fatal(err)
+					}
+					if err := os.WriteFile(path, data, 0644); err != nil {
+						t.Fatal(err)
+					}
+				}
+				return
+			}
+
+			want := readTree(t, golden)
+			if len(want) == 0 {
+				t.Fatalf("no golden output in %s; run go test -run TestGolden -update", golden)
+			}
+			var files []string
+			for rel := range got {
+				files = append(files, rel)
+			}
+			for rel := range want {
+				if _, ok := got[rel]; !ok {
+					files = append(files, rel)
+				}
+			}
+			sort.Strings(files)
+			for _, rel := range files {
+				g, generated := got[rel]
+				w, expected := want[rel]
+				switch {
+				case !expected:
+					t.Errorf("%s is generated but not in the golden output", rel)
+				case !generated:
+					t.Errorf("%s is in the golden output but no longer generated", rel)
+				case !bytes.Equal(g, w):
+					t.Errorf("%s differs from the golden output:\n%s", rel, firstDifference(string(g), string(w)))
+				}
+			}
+		})
+	}
+}
+
+// firstDifference describes the first line where got and want disagree
+func firstDifference(got, want string) string {
+	gotLines := strings.Split(got, "\n")
+	wantLines := strings.Split(want, "\n")
+	for i := 0; i < len(gotLines) || i < len(wantLines); i++ {
+		var g, w string
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if g != w {
+			return fmt.Sprintf("line %d:\n  got:  %s\n  want: %s", i+1, g, w)
+		}
+	}
+	return "(no line differs)"
+}
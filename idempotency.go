@@ -0,0 +1,250 @@
+package main
+
+import (
+	"strings"
+)
+
+// idempotencyEnabled reports whether a POST operation honours the
+// Idempotency-Key header. It is on unless x-idempotency: false is set on the
+// operation, its path item or the root of the spec.
+func idempotencyEnabled(op operation, spec map[string]interface{}) bool {
+	if op.Method != "POST" {
+		return false
+	}
+	for _, scope := range []map[string]interface{}{op.Endpoint, op.PathItem, spec} {
+		if v, ok := scope["x-idempotency"].(bool); ok {
+			return v
+		}
+	}
+	return true
+}
+
+// generateIdempotencyCode creates the Idempotency-Key middleware. Keys are
+// stored in BadgerDB with a fingerprint of the request and, once the request
+// succeeded, the response to replay.
+func generateIdempotencyCode(withSecurity bool) string {
+	var code strings.Builder
+	code.WriteString("package main\n\n")
+	code.WriteString("import (\n    \"bytes\"\n    \"crypto/sha256\"\n    \"encoding/hex\"\n    \"encoding/json\"\n    \"errors\"\n    \"hash\"\n    \"io\"\n    \"net/http\"\n    \"os\"\n    \"slices\"\n    \"strconv\"\n    \"time\"\n    \"github.com/dgraph-io/badger/v3\"\n)\n\n")
+	code.WriteString(`// IdempotencyTTL is how long a successful response is kept for replay
+var IdempotencyTTL = 24 * time.Hour
+
+// maxIdempotencyKey and maxReplayBody bound what is stored per key; larger
+// responses are not kept, so retries run the operation again
+const (
+    maxIdempotencyKey = 255
+    maxReplayBody     = 1 << 20
+)
+
+`)
+	code.WriteString("// idempotencyRecord is stored under a key while its request runs, then with\n")
+	code.WriteString("// the response once it succeeded\n")
+	code.WriteString("type idempotencyRecord struct {\n")
+	code.WriteString("    Fingerprint string      `json:\"fingerprint\"`\n")
+	code.WriteString("    Done        bool        `json:\"done\"`\n")
+	code.WriteString("    Status      int         `json:\"status,omitempty\"`\n")
+	code.WriteString("    Header      http.Header `json:\"header,omitempty\"`\n")
+	code.WriteString("    Body        []byte      `json:\"body,omitempty\"`\n")
+	code.WriteString("}\n\n")
+	code.WriteString(`// idempotent makes retries of a POST carrying an Idempotency-Key header safe.
+// The first request runs and its 2xx response is stored; repeats with the same
+// key replay it with an Idempotent-Replayed header. A repeat with a different
+// body gets 422, and one arriving while the first still runs gets 409.
+// Requests without the header run as usual.
+func idempotent(next http.Handler, operationID string) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        key := r.Header.Get("Idempotency-Key")
+        if key == "" {
+            next.ServeHTTP(w, r)
+            return
+        }
+        if len(key) > maxIdempotencyKey {
+            http.Error(w, "Idempotency-Key is longer than "+strconv.Itoa(maxIdempotencyKey)+" characters", http.StatusBadRequest)
+            return
+        }
+        sum := sha256.New()
+        io.WriteString(sum, r.Method+" "+r.URL.RequestURI()+"\n"+r.Header.Get("Content-Type")+"\n")
+        cleanup, err := spoolBody(r, sum)
+        if err != nil {
+            var maxErr *http.MaxBytesError
+            if errors.As(err, &maxErr) {
+                http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+            } else {
+                http.Error(w, "Failed to read request body", http.StatusBadRequest)
+            }
+            return
+        }
+        defer cleanup()
+        fingerprint := hex.EncodeToString(sum.Sum(nil))
+        storeKey := idempotencyStoreKey(r, operationID, key)
+
+        // Claim the key; the request lasts no longer than the server timeouts,
+        // after which an abandoned claim expires
+        var stored *idempotencyRecord
+        err = dbUpdate(r.Context(), func(txn *badger.Txn) error {
+            item, err := txn.Get(storeKey)
+            if err == nil {
+                stored = &idempotencyRecord{}
+                return item.Value(func(v []byte) error {
+                    return json.Unmarshal(v, stored)
+                })
+            } else if err != badger.ErrKeyNotFound {
+                return err
+            }
+            return putIdempotencyRecord(txn, storeKey, idempotencyRecord{Fingerprint: fingerprint}, ReadTimeout+WriteTimeout)
+        })
+        switch {
+        case err == badger.ErrConflict || (stored != nil && stored.Fingerprint == fingerprint && !stored.Done):
+            http.Error(w, "A request with this Idempotency-Key is in progress", http.StatusConflict)
+            return
+        case err != nil:
+            http.Error(w, "Database error", http.StatusInternalServerError)
+            return
+        case stored != nil && stored.Fingerprint != fingerprint:
+            http.Error(w, "Idempotency-Key was already used with a different request", http.StatusUnprocessableEntity)
+            return
+        case stored != nil:
+            for name, values := range stored.Header {
+                w.Header()[name] = values
+            }
+            w.Header().Set("Idempotent-Replayed", "true")
+            w.WriteHeader(stored.Status)
+            w.Write(stored.Body)
+            return
+        }
+
+        before := w.Header().Clone()
+        rec := &replayRecorder{ResponseWriter: w}
+        saved := false
+        defer func() {
+            // Failed or panicking requests release the key so they can be retried
+            if !saved {
+                err := dbUpdate(r.Context(), func(txn *badger.Txn) error { return txn.Delete(storeKey) })
+                if err != nil {
+                    LoggerFrom(r.Context()).Error("Failed to release Idempotency-Key", "error", err)
+                }
+            }
+        }()
+        next.ServeHTTP(rec, r)
+        if rec.status < 200 || rec.status > 299 || rec.overflow {
+            return
+        }
+        record := idempotencyRecord{Fingerprint: fingerprint, Done: true, Status: rec.status, Header: http.Header{}, Body: rec.body.Bytes()}
+        // Keep the headers the operation set, not those of the middleware around
+        // it. The body is recorded before compression, so the headers describing
+        // its encoding are left for the replay to set again.
+        for name, values := range w.Header() {
+            if !slices.Equal(before[name], values) && !encodingHeaders[name] {
+                record.Header[name] = values
+            }
+        }
+        err = dbUpdate(r.Context(), func(txn *badger.Txn) error {
+            return putIdempotencyRecord(txn, storeKey, record, IdempotencyTTL)
+        })
+        if err != nil {
+            LoggerFrom(r.Context()).Error("Failed to store idempotent response", "error", err)
+            return
+        }
+        saved = true
+    })
+}
+
+// encodingHeaders describe the encoding of a response rather than its content
+var encodingHeaders = map[string]bool{"Content-Encoding": true, "Content-Length": true, "Vary": true}
+
+func putIdempotencyRecord(txn *badger.Txn, key []byte, record idempotencyRecord, ttl time.Duration) error {
+    value, err := json.Marshal(record)
+    if err != nil {
+        return err
+    }
+    return txn.SetEntry(badger.NewEntry(key, value).WithTTL(ttl))
+}
+
+`)
+	code.WriteString("// idempotencyStoreKey scopes a client's key to the operation")
+	if withSecurity {
+		code.WriteString(" and the\n// authenticated principal, so callers cannot replay each other's responses\n")
+	} else {
+		code.WriteString("\n")
+	}
+	code.WriteString("func idempotencyStoreKey(r *http.Request, operationID, key string) []byte {\n")
+	code.WriteString("    scope := operationID\n")
+	if withSecurity {
+		code.WriteString("    if p, ok := PrincipalFrom(r.Context()); ok {\n")
+		code.WriteString("        scope += \"\\x00\" + p.Scheme + \"\\x00\" + p.Subject\n")
+		code.WriteString("    }\n")
+	}
+	code.WriteString("    sum := sha256.Sum256([]byte(scope + \"\\x00\" + key))\n")
+	code.WriteString("    return []byte(\"_idempotency:\" + hex.EncodeToString(sum[:]))\n")
+	code.WriteString("}\n\n")
+	code.WriteString(`// spoolBody reads the request body into sum and replaces it with a copy, held
+// in memory or, past MultipartMemory, in a temporary file
+func spoolBody(r *http.Request, sum hash.Hash) (cleanup func(), err error) {
+    var buf bytes.Buffer
+    _, err = io.CopyN(io.MultiWriter(&buf, sum), r.Body, MultipartMemory+1)
+    if err == io.EOF {
+        r.Body = io.NopCloser(&buf)
+        return func() {}, nil
+    } else if err != nil {
+        return nil, err
+    }
+    f, err := os.CreateTemp("", "idempotency-*")
+    if err != nil {
+        return nil, err
+    }
+    cleanup = func() {
+        f.Close()
+        os.Remove(f.Name())
+    }
+    if _, err := f.Write(buf.Bytes()); err != nil {
+        cleanup()
+        return nil, err
+    }
+    if _, err := io.Copy(io.MultiWriter(f, sum), r.Body); err != nil {
+        cleanup()
+        return nil, err
+    }
+    if _, err := f.Seek(0, io.SeekStart); err != nil {
+        cleanup()
+        return nil, err
+    }
+    r.Body = f
+    return cleanup, nil
+}
+
+// replayRecorder passes a response through while keeping a copy to replay
+type replayRecorder struct {
+    http.ResponseWriter
+    status   int
+    body     bytes.Buffer
+    overflow bool // the body exceeded maxReplayBody and was not kept
+}
+
+func (rr *replayRecorder) WriteHeader(status int) {
+    if rr.status == 0 {
+        rr.status = status
+    }
+    rr.ResponseWriter.WriteHeader(status)
+}
+
+func (rr *replayRecorder) Write(p []byte) (int, error) {
+    if rr.status == 0 {
+        rr.status = http.StatusOK
+    }
+    if !rr.overflow {
+        if rr.body.Len()+len(p) > maxReplayBody {
+            rr.overflow = true
+            rr.body = bytes.Buffer{}
+        } else {
+            rr.body.Write(p)
+        }
+    }
+    return rr.ResponseWriter.Write(p)
+}
+
+func (rr *replayRecorder) Unwrap() http.ResponseWriter {
+    return rr.ResponseWriter
+}
+`)
+	return code.String()
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// namingConventions are the cases the casing rules can require
+var namingConventions = map[string]*regexp.Regexp{
+	"camelCase":  regexp.MustCompile(`^[a-z][a-zA-Z0-9]*$`),
+	"PascalCase": regexp.MustCompile(`^[A-Z][a-zA-Z0-9]*$`),
+	"snake_case": regexp.MustCompile(`^[a-z][a-z0-9]*(_[a-z0-9]+)*$`),
+	"kebab-case": regexp.MustCompile(`^[a-z][a-z0-9]*(-[a-z0-9]+)*$`),
+}
+
+// checkCase reports a name that does not follow the running rule's case
+func (c *specCheck) checkCase(pointer, what, name string) {
+	if re := namingConventions[c.setting.Case]; re != nil && !re.MatchString(name) {
+		c.report(pointer, "%s %q is not %s", what, name, c.setting.Case)
+	}
+}
+
+func lintOperationIDCasing(c *specCheck) {
+	for _, op := range c.operations() {
+		if id, ok := op.Op["operationId"].(string); ok {
+			c.checkCase(pointerTo(op.Pointer, "operationId"), "operationId", id)
+		}
+	}
+}
+
+func lintSchemaCasing(c *specCheck) {
+	components, _ := c.doc["components"].(map[string]interface{})
+	schemas, _ := components["schemas"].(map[string]interface{})
+	for _, name := range sortedKeys(schemas) {
+		c.checkCase(pointerTo("/components/schemas", name), "schema name", name)
+	}
+}
+
+func lintPropertyCasing(c *specCheck) {
+	c.schemas(func(pointer string, schema map[string]interface{}) {
+		properties, _ := schema["properties"].(map[string]interface{})
+		for _, name := range sortedKeys(properties) {
+			c.checkCase(pointerTo(pointerTo(pointer, "properties"), name), "property", name)
+		}
+	})
+}
+
+// lintPathCasing checks the fixed segments of paths; segments with a
+// parameter are left alone
+func lintPathCasing(c *specCheck) {
+	paths, _ := c.doc["paths"].(map[string]interface{})
+	for _, path := range sortedKeys(paths) {
+		if !strings.HasPrefix(path, "/") {
+			continue
+		}
+		for _, segment := range strings.Split(path, "/") {
+			if segment != "" && !strings.Contains(segment, "{") {
+				c.checkCase(pointerTo("/paths", path), "path segment", segment)
+			}
+		}
+	}
+}
+
+// lintOperationDescription requires a description or summary of operations
+func lintOperationDescription(c *specCheck) {
+	for _, op := range c.operations() {
+		if !hasText(op.Op, "description") && !hasText(op.Op, "summary") {
+			c.report(op.Pointer, "operation %s %s has no description or summary", op.Method, op.Path)
+		}
+	}
+}
+
+// lintParameterDescription requires a description of the parameters of
+// paths, operations and components; referenced ones are checked where they
+// are defined
+func lintParameterDescription(c *specCheck) {
+	check := func(pointer string, list []interface{}) {
+		for i, p := range list {
+			param, ok := p.(map[string]interface{})
+			if !ok || param["$ref"] != nil || hasText(param, "description") {
+				continue
+			}
+			c.report(fmt.Sprintf("%s/%d", pointer, i), "parameter %q has no description", param["name"])
+		}
+	}
+	paths, _ := c.doc["paths"].(map[string]interface{})
+	for _, path := range sortedKeys(paths) {
+		item, _ := paths[path].(map[string]interface{})
+		list, _ := item["parameters"].([]interface{})
+		check(pointerTo(pointerTo("/paths", path), "parameters"), list)
+		for _, method := range specMethods {
+			op, _ := item[method].(map[string]interface{})
+			list, _ := op["parameters"].([]interface{})
+			check(pointerTo(pointerTo(pointerTo("/paths", path), method), "parameters"), list)
+		}
+	}
+	components, _ := c.doc["components"].(map[string]interface{})
+	params, _ := components["parameters"].(map[string]interface{})
+	for _, name := range sortedKeys(params) {
+		param, ok := params[name].(map[string]interface{})
+		if ok && param["$ref"] == nil && !hasText(param, "description") {
+			c.report(pointerTo("/components/parameters", name), "parameter %q has no description", param["name"])
+		}
+	}
+}
+
+// lintSchemaDescription requires a description of component schemas
+func lintSchemaDescription(c *specCheck) {
+	components, _ := c.doc["components"].(map[string]interface{})
+	schemas, _ := components["schemas"].(map[string]interface{})
+	for _, name := range sortedKeys(schemas) {
+		schema, ok := schemas[name].(map[string]interface{})
+		if ok && schema["$ref"] == nil && !hasText(schema, "description") {
+			c.report(pointerTo("/components/schemas", name), "schema %s has no description", name)
+		}
+	}
+}
+
+// mediaSchema matches the pointer of a request or response body schema, or
+// of the items of one
+var mediaSchema = regexp.MustCompile(`/content/[^/]+/schema(/items)?$`)
+
+// lintInlineSchemas reports request and response bodies whose object schema
+// is written inline instead of referring to components/schemas
+func lintInlineSchemas(c *specCheck) {
+	c.schemas(func(pointer string, schema map[string]interface{}) {
+		if _, ok := schema["properties"]; ok && mediaSchema.MatchString(pointer) {
+			c.report(pointer, "inline object schema, define it in components/schemas and refer to it")
+		}
+	})
+}
+
+// hasText reports whether a field of an object is a non-empty string
+func hasText(obj map[string]interface{}, field string) bool {
+	s, _ := obj[field].(string)
+	return strings.TrimSpace(s) != ""
+}
+
+// schemas calls visit for every schema written in the document, nested ones
+// included. References are not followed: schemas are visited where they are
+// defined. Examples and extensions are skipped.
+func (c *specCheck) schemas(visit func(pointer string, schema map[string]interface{})) {
+	var schema func(v interface{}, pointer string)
+	schema = func(v interface{}, pointer string) {
+		s, ok := v.(map[string]interface{})
+		if !ok || s["$ref"] != nil {
+			return
+		}
+		visit(pointer, s)
+		for _, key := range []string{"properties", "patternProperties"} {
+			children, _ := s[key].(map[string]interface{})
+			for _, name := range sortedKeys(children) {
+				schema(children[name], pointerTo(pointerTo(pointer, key), name))
+			}
+		}
+		for _, key := range []string{"items", "additionalProperties", "not"} {
+			schema(s[key], pointerTo(pointer, key))
+		}
+		for _, key := range []string{"allOf", "oneOf", "anyOf", "prefixItems"} {
+			list, _ := s[key].([]interface{})
+			for i, sub := range list {
+				schema(sub, fmt.Sprintf("%s/%s/%d", pointer, key, i))
+			}
+		}
+	}
+
+	// Everything else is searched for the schema of parameters, headers and
+	// media types
+	var walk func(v interface{}, pointer string)
+	walk = func(v interface{}, pointer string) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for _, k := range sortedKeys(v) {
+				switch {
+				case k == "schema":
+					schema(v[k], pointerTo(pointer, k))
+				case k == "example" || k == "examples" || strings.HasPrefix(k, "x-"):
+				case pointer == "/components" && k == "schemas":
+				default:
+					walk(v[k], pointerTo(pointer, k))
+				}
+			}
+		case []interface{}:
+			for i, item := range v {
+				walk(item, fmt.Sprintf("%s/%d", pointer, i))
+			}
+		}
+	}
+
+	components, _ := c.doc["components"].(map[string]interface{})
+	schemas, _ := components["schemas"].(map[string]interface{})
+	for _, name := range sortedKeys(schemas) {
+		schema(schemas[name], pointerTo("/components/schemas", name))
+	}
+	walk(c.doc, "")
+}
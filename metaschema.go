@@ -0,0 +1,633 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// openAPI30MetaSchema describes the structure of OpenAPI 3.0 documents, after
+// the official meta-schema: every object has its fixed fields, x- extensions
+// and nothing else. Fields holding either an object or a Reference Object use
+// if/then/else on $ref, so a mistake is reported against the object meant.
+// Responses are checked by the operation-responses rule instead.
+const openAPI30MetaSchema = `{
+  "type": "object",
+  "required": ["openapi", "info", "paths"],
+  "properties": {
+    "openapi": {"type": "string"},
+    "info": {"$ref": "#/$defs/Info"},
+    "externalDocs": {"$ref": "#/$defs/ExternalDocumentation"},
+    "servers": {"type": "array", "items": {"$ref": "#/$defs/Server"}},
+    "security": {"type": "array", "items": {"$ref": "#/$defs/SecurityRequirement"}},
+    "tags": {"type": "array", "items": {"$ref": "#/$defs/Tag"}},
+    "paths": {"$ref": "#/$defs/Paths"},
+    "components": {"$ref": "#/$defs/Components"}
+  },
+  "patternProperties": {"^x-": {}},
+  "additionalProperties": false,
+  "$defs": {
+    "Reference": {
+      "type": "object",
+      "required": ["$ref"],
+      "properties": {"$ref": {"type": "string"}}
+    },
+    "Info": {
+      "type": "object",
+      "required": ["title", "version"],
+      "properties": {
+        "title": {"type": "string"},
+        "description": {"type": "string"},
+        "termsOfService": {"type": "string"},
+        "contact": {"$ref": "#/$defs/Contact"},
+        "license": {"$ref": "#/$defs/License"},
+        "version": {"type": "string"}
+      },
+      "patternProperties": {"^x-": {}},
+      "additionalProperties": false
+    },
+    "Contact": {
+      "type": "object",
+      "properties": {
+        "name": {"type": "string"},
+        "url": {"type": "string"},
+        "email": {"type": "string"}
+      },
+      "patternProperties": {"^x-": {}},
+      "additionalProperties": false
+    },
+    "License": {
+      "type": "object",
+      "required": ["name"],
+      "properties": {
+        "name": {"type": "string"},
+        "url": {"type": "string"}
+      },
+      "patternProperties": {"^x-": {}},
+      "additionalProperties": false
+    },
+    "ExternalDocumentation": {
+      "type": "object",
+      "required": ["url"],
+      "properties": {
+        "description": {"type": "string"},
+        "url": {"type": "string"}
+      },
+      "patternProperties": {"^x-": {}},
+      "additionalProperties": false
+    },
+    "Server": {
+      "type": "object",
+      "required": ["url"],
+      "properties": {
+        "url": {"type": "string"},
+        "description": {"type": "string"},
+        "variables": {"type": "object", "additionalProperties": {"$ref": "#/$defs/ServerVariable"}}
+      },
+      "patternProperties": {"^x-": {}},
+      "additionalProperties": false
+    },
+    "ServerVariable": {
+      "type": "object",
+      "required": ["default"],
+      "properties": {
+        "enum": {"type": "array", "items": {"type": "string"}},
+        "default": {"type": "string"},
+        "description": {"type": "string"}
+      },
+      "patternProperties": {"^x-": {}},
+      "additionalProperties": false
+    },
+    "SecurityRequirement": {
+      "type": "object",
+      "additionalProperties": {"type": "array", "items": {"type": "string"}}
+    },
+    "Tag": {
+      "type": "object",
+      "required": ["name"],
+      "properties": {
+        "name": {"type": "string"},
+        "description": {"type": "string"},
+        "externalDocs": {"$ref": "#/$defs/ExternalDocumentation"}
+      },
+      "patternProperties": {"^x-": {}},
+      "additionalProperties": false
+    },
+    "Components": {
+      "type": "object",
+      "properties": {
+        "schemas": {"$ref": "#/$defs/ComponentMap", "additionalProperties": {"$ref": "#/$defs/SchemaOrReference"}},
+        "responses": {"$ref": "#/$defs/ComponentMap", "additionalProperties": {"$ref": "#/$defs/ResponseOrReference"}},
+        "parameters": {"$ref": "#/$defs/ComponentMap", "additionalProperties": {"$ref": "#/$defs/ParameterOrReference"}},
+        "examples": {"$ref": "#/$defs/ComponentMap", "additionalProperties": {"$ref": "#/$defs/ExampleOrReference"}},
+        "requestBodies": {"$ref": "#/$defs/ComponentMap", "additionalProperties": {"$ref": "#/$defs/RequestBodyOrReference"}},
+        "headers": {"$ref": "#/$defs/ComponentMap", "additionalProperties": {"$ref": "#/$defs/HeaderOrReference"}},
+        "securitySchemes": {"$ref": "#/$defs/ComponentMap", "additionalProperties": {"$ref": "#/$defs/SecuritySchemeOrReference"}},
+        "links": {"$ref": "#/$defs/ComponentMap", "additionalProperties": {"$ref": "#/$defs/LinkOrReference"}},
+        "callbacks": {"$ref": "#/$defs/ComponentMap", "additionalProperties": {"$ref": "#/$defs/CallbackOrReference"}}
+      },
+      "patternProperties": {"^x-": {}},
+      "additionalProperties": false
+    },
+    "ComponentMap": {
+      "type": "object",
+      "patternProperties": {"^[a-zA-Z0-9.\\-_]+$": {}},
+      "additionalProperties": false
+    },
+    "Paths": {
+      "type": "object",
+      "patternProperties": {"^/": {"$ref": "#/$defs/PathItem"}, "^x-": {}},
+      "additionalProperties": false
+    },
+    "PathItem": {
+      "type": "object",
+      "properties": {
+        "$ref": {"type": "string"},
+        "summary": {"type": "string"},
+        "description": {"type": "string"},
+        "get": {"$ref": "#/$defs/Operation"},
+        "put": {"$ref": "#/$defs/Operation"},
+        "post": {"$ref": "#/$defs/Operation"},
+        "delete": {"$ref": "#/$defs/Operation"},
+        "options": {"$ref": "#/$defs/Operation"},
+        "head": {"$ref": "#/$defs/Operation"},
+        "patch": {"$ref": "#/$defs/Operation"},
+        "trace": {"$ref": "#/$defs/Operation"},
+        "servers": {"type": "array", "items": {"$ref": "#/$defs/Server"}},
+        "parameters": {"type": "array", "items": {"$ref": "#/$defs/ParameterOrReference"}}
+      },
+      "patternProperties": {"^x-": {}},
+      "additionalProperties": false
+    },
+    "Operation": {
+      "type": "object",
+      "properties": {
+        "tags": {"type": "array", "items": {"type": "string"}},
+        "summary": {"type": "string"},
+        "description": {"type": "string"},
+        "externalDocs": {"$ref": "#/$defs/ExternalDocumentation"},
+        "operationId": {"type": "string"},
+        "parameters": {"type": "array", "items": {"$ref": "#/$defs/ParameterOrReference"}},
+        "requestBody": {"$ref": "#/$defs/RequestBodyOrReference"},
+        "responses": {"$ref": "#/$defs/Responses"},
+        "callbacks": {"type": "object", "additionalProperties": {"$ref": "#/$defs/CallbackOrReference"}},
+        "deprecated": {"type": "boolean"},
+        "security": {"type": "array", "items": {"$ref": "#/$defs/SecurityRequirement"}},
+        "servers": {"type": "array", "items": {"$ref": "#/$defs/Server"}}
+      },
+      "patternProperties": {"^x-": {}},
+      "additionalProperties": false
+    },
+    "Responses": {
+      "type": "object",
+      "properties": {"default": {"$ref": "#/$defs/ResponseOrReference"}},
+      "patternProperties": {"^[1-5](?:[0-9]{2}|XX)$": {"$ref": "#/$defs/ResponseOrReference"}, "^x-": {}},
+      "additionalProperties": false
+    },
+    "Response": {
+      "type": "object",
+      "required": ["description"],
+      "properties": {
+        "description": {"type": "string"},
+        "headers": {"type": "object", "additionalProperties": {"$ref": "#/$defs/HeaderOrReference"}},
+        "content": {"type": "object", "additionalProperties": {"$ref": "#/$defs/MediaType"}},
+        "links": {"type": "object", "additionalProperties": {"$ref": "#/$defs/LinkOrReference"}}
+      },
+      "patternProperties": {"^x-": {}},
+      "additionalProperties": false
+    },
+    "MediaType": {
+      "type": "object",
+      "properties": {
+        "schema": {"$ref": "#/$defs/SchemaOrReference"},
+        "example": {},
+        "examples": {"type": "object", "additionalProperties": {"$ref": "#/$defs/ExampleOrReference"}},
+        "encoding": {"type": "object", "additionalProperties": {"$ref": "#/$defs/Encoding"}}
+      },
+      "patternProperties": {"^x-": {}},
+      "additionalProperties": false
+    },
+    "Encoding": {
+      "type": "object",
+      "properties": {
+        "contentType": {"type": "string"},
+        "headers": {"type": "object", "additionalProperties": {"$ref": "#/$defs/HeaderOrReference"}},
+        "style": {"type": "string", "enum": ["form", "spaceDelimited", "pipeDelimited", "deepObject"]},
+        "explode": {"type": "boolean"},
+        "allowReserved": {"type": "boolean"}
+      },
+      "patternProperties": {"^x-": {}},
+      "additionalProperties": false
+    },
+    "Example": {
+      "type": "object",
+      "properties": {
+        "summary": {"type": "string"},
+        "description": {"type": "string"},
+        "value": {},
+        "externalValue": {"type": "string"}
+      },
+      "patternProperties": {"^x-": {}},
+      "additionalProperties": false
+    },
+    "Header": {
+      "type": "object",
+      "properties": {
+        "description": {"type": "string"},
+        "required": {"type": "boolean"},
+        "deprecated": {"type": "boolean"},
+        "allowEmptyValue": {"type": "boolean"},
+        "style": {"type": "string", "enum": ["simple"]},
+        "explode": {"type": "boolean"},
+        "allowReserved": {"type": "boolean"},
+        "schema": {"$ref": "#/$defs/SchemaOrReference"},
+        "content": {"type": "object", "additionalProperties": {"$ref": "#/$defs/MediaType"}},
+        "example": {},
+        "examples": {"type": "object", "additionalProperties": {"$ref": "#/$defs/ExampleOrReference"}}
+      },
+      "patternProperties": {"^x-": {}},
+      "additionalProperties": false
+    },
+    "Parameter": {
+      "type": "object",
+      "required": ["name", "in"],
+      "properties": {
+        "name": {"type": "string"},
+        "in": {"type": "string", "enum": ["query", "header", "path", "cookie"]},
+        "description": {"type": "string"},
+        "required": {"type": "boolean"},
+        "deprecated": {"type": "boolean"},
+        "allowEmptyValue": {"type": "boolean"},
+        "style": {"type": "string", "enum": ["matrix", "label", "form", "simple", "spaceDelimited", "pipeDelimited", "deepObject"]},
+        "explode": {"type": "boolean"},
+        "allowReserved": {"type": "boolean"},
+        "schema": {"$ref": "#/$defs/SchemaOrReference"},
+        "content": {"type": "object", "additionalProperties": {"$ref": "#/$defs/MediaType"}},
+        "example": {},
+        "examples": {"type": "object", "additionalProperties": {"$ref": "#/$defs/ExampleOrReference"}}
+      },
+      "patternProperties": {"^x-": {}},
+      "additionalProperties": false
+    },
+    "RequestBody": {
+      "type": "object",
+      "required": ["content"],
+      "properties": {
+        "description": {"type": "string"},
+        "content": {"type": "object", "additionalProperties": {"$ref": "#/$defs/MediaType"}},
+        "required": {"type": "boolean"}
+      },
+      "patternProperties": {"^x-": {}},
+      "additionalProperties": false
+    },
+    "SecurityScheme": {
+      "type": "object",
+      "required": ["type"],
+      "properties": {
+        "type": {"type": "string", "enum": ["apiKey", "http", "oauth2", "openIdConnect"]},
+        "description": {"type": "string"},
+        "name": {"type": "string"},
+        "in": {"type": "string", "enum": ["query", "header", "cookie"]},
+        "scheme": {"type": "string"},
+        "bearerFormat": {"type": "string"},
+        "flows": {"$ref": "#/$defs/OAuthFlows"},
+        "openIdConnectUrl": {"type": "string"}
+      },
+      "patternProperties": {"^x-": {}},
+      "additionalProperties": false
+    },
+    "OAuthFlows": {
+      "type": "object",
+      "properties": {
+        "implicit": {"$ref": "#/$defs/OAuthFlow"},
+        "password": {"$ref": "#/$defs/OAuthFlow"},
+        "clientCredentials": {"$ref": "#/$defs/OAuthFlow"},
+        "authorizationCode": {"$ref": "#/$defs/OAuthFlow"}
+      },
+      "patternProperties": {"^x-": {}},
+      "additionalProperties": false
+    },
+    "OAuthFlow": {
+      "type": "object",
+      "required": ["scopes"],
+      "properties": {
+        "authorizationUrl": {"type": "string"},
+        "tokenUrl": {"type": "string"},
+        "refreshUrl": {"type": "string"},
+        "scopes": {"type": "object", "additionalProperties": {"type": "string"}}
+      },
+      "patternProperties": {"^x-": {}},
+      "additionalProperties": false
+    },
+    "Link": {
+      "type": "object",
+      "properties": {
+        "operationId": {"type": "string"},
+        "operationRef": {"type": "string"},
+        "parameters": {"type": "object"},
+        "requestBody": {},
+        "description": {"type": "string"},
+        "server": {"$ref": "#/$defs/Server"}
+      },
+      "patternProperties": {"^x-": {}},
+      "additionalProperties": false
+    },
+    "Callback": {
+      "type": "object",
+      "patternProperties": {"^x-": {}},
+      "additionalProperties": {"$ref": "#/$defs/PathItem"}
+    },
+    "Schema": {
+      "type": "object",
+      "properties": {
+        "title": {"type": "string"},
+        "multipleOf": {"type": "number"},
+        "maximum": {"type": "number"},
+        "exclusiveMaximum": {"type": "boolean"},
+        "minimum": {"type": "number"},
+        "exclusiveMinimum": {"type": "boolean"},
+        "maxLength": {"type": "integer"},
+        "minLength": {"type": "integer"},
+        "pattern": {"type": "string"},
+        "maxItems": {"type": "integer"},
+        "minItems": {"type": "integer"},
+        "uniqueItems": {"type": "boolean"},
+        "maxProperties": {"type": "integer"},
+        "minProperties": {"type": "integer"},
+        "required": {"type": "array", "items": {"type": "string"}},
+        "enum": {"type": "array"},
+        "type": {"type": "string", "enum": ["array", "boolean", "integer", "number", "object", "string"]},
+        "not": {"$ref": "#/$defs/SchemaOrReference"},
+        "allOf": {"type": "array", "items": {"$ref": "#/$defs/SchemaOrReference"}},
+        "oneOf": {"type": "array", "items": {"$ref": "#/$defs/SchemaOrReference"}},
+        "anyOf": {"type": "array", "items": {"$ref": "#/$defs/SchemaOrReference"}},
+        "items": {"$ref": "#/$defs/SchemaOrReference"},
+        "properties": {"type": "object", "additionalProperties": {"$ref": "#/$defs/SchemaOrReference"}},
+        "additionalProperties": {"if": {"type": "boolean"}, "else": {"$ref": "#/$defs/SchemaOrReference"}},
+        "description": {"type": "string"},
+        "format": {"type": "string"},
+        "default": {},
+        "nullable": {"type": "boolean"},
+        "discriminator": {"$ref": "#/$defs/Discriminator"},
+        "readOnly": {"type": "boolean"},
+        "writeOnly": {"type": "boolean"},
+        "example": {},
+        "externalDocs": {"$ref": "#/$defs/ExternalDocumentation"},
+        "deprecated": {"type": "boolean"},
+        "xml": {"$ref": "#/$defs/XML"}
+      },
+      "patternProperties": {"^x-": {}},
+      "additionalProperties": false
+    },
+    "Discriminator": {
+      "type": "object",
+      "required": ["propertyName"],
+      "properties": {
+        "propertyName": {"type": "string"},
+        "mapping": {"type": "object", "additionalProperties": {"type": "string"}}
+      }
+    },
+    "XML": {
+      "type": "object",
+      "properties": {
+        "name": {"type": "string"},
+        "namespace": {"type": "string"},
+        "prefix": {"type": "string"},
+        "attribute": {"type": "boolean"},
+        "wrapped": {"type": "boolean"}
+      },
+      "patternProperties": {"^x-": {}},
+      "additionalProperties": false
+    },
+    "SchemaOrReference": {"if": {"type": "object", "required": ["$ref"]}, "then": {"$ref": "#/$defs/Reference"}, "else": {"$ref": "#/$defs/Schema"}},
+    "ResponseOrReference": {"if": {"type": "object", "required": ["$ref"]}, "then": {"$ref": "#/$defs/Reference"}, "else": {"$ref": "#/$defs/Response"}},
+    "ParameterOrReference": {"if": {"type": "object", "required": ["$ref"]}, "then": {"$ref": "#/$defs/Reference"}, "else": {"$ref": "#/$defs/Parameter"}},
+    "ExampleOrReference": {"if": {"type": "object", "required": ["$ref"]}, "then": {"$ref": "#/$defs/Reference"}, "else": {"$ref": "#/$defs/Example"}},
+    "RequestBodyOrReference": {"if": {"type": "object", "required": ["$ref"]}, "then": {"$ref": "#/$defs/Reference"}, "else": {"$ref": "#/$defs/RequestBody"}},
+    "HeaderOrReference": {"if": {"type": "object", "required": ["$ref"]}, "then": {"$ref": "#/$defs/Reference"}, "else": {"$ref": "#/$defs/Header"}},
+    "SecuritySchemeOrReference": {"if": {"type": "object", "required": ["$ref"]}, "then": {"$ref": "#/$defs/Reference"}, "else": {"$ref": "#/$defs/SecurityScheme"}},
+    "LinkOrReference": {"if": {"type": "object", "required": ["$ref"]}, "then": {"$ref": "#/$defs/Reference"}, "else": {"$ref": "#/$defs/Link"}},
+    "CallbackOrReference": {"if": {"type": "object", "required": ["$ref"]}, "then": {"$ref": "#/$defs/Reference"}, "else": {"$ref": "#/$defs/Callback"}}
+  }
+}`
+
+// openAPI31Patch turns the 3.0 meta-schema into the 3.1 one as a JSON merge
+// patch: paths become optional next to webhooks, a few fields are added and
+// Schema Objects are JSON Schema 2020-12, which is not checked any further
+const openAPI31Patch = `{
+  "required": ["openapi", "info"],
+  "properties": {
+    "jsonSchemaDialect": {"type": "string"},
+    "webhooks": {"type": "object", "additionalProperties": {"$ref": "#/$defs/PathItem"}}
+  },
+  "$defs": {
+    "Reference": {
+      "properties": {"summary": {"type": "string"}, "description": {"type": "string"}}
+    },
+    "Info": {"properties": {"summary": {"type": "string"}}},
+    "License": {"properties": {"identifier": {"type": "string"}}},
+    "Components": {
+      "properties": {
+        "pathItems": {"$ref": "#/$defs/ComponentMap", "additionalProperties": {"$ref": "#/$defs/PathItem"}}
+      }
+    },
+    "SecurityScheme": {
+      "properties": {"type": {"enum": ["apiKey", "http", "mutualTLS", "oauth2", "openIdConnect"]}}
+    },
+    "Schema": {"type": ["object", "boolean"], "properties": null, "patternProperties": null, "additionalProperties": null}
+  }
+}`
+
+// metaSchemas holds the meta-schema of each supported OpenAPI version
+var metaSchemas = func() map[string]map[string]interface{} {
+	var v30, patch map[string]interface{}
+	if err := json.Unmarshal([]byte(openAPI30MetaSchema), &v30); err != nil {
+		panic("meta-schema: " + err.Error())
+	}
+	if err := json.Unmarshal([]byte(openAPI31Patch), &patch); err != nil {
+		panic("meta-schema patch: " + err.Error())
+	}
+	return map[string]map[string]interface{}{"3.0": v30, "3.1": mergePatch(v30, patch).(map[string]interface{})}
+}()
+
+// specVersion returns the major and minor OpenAPI version of a document,
+// "3.0" or "3.1", or "" when it is not one of those
+func specVersion(doc map[string]interface{}) string {
+	version, _ := doc["openapi"].(string)
+	for _, v := range []string{"3.0", "3.1"} {
+		if strings.HasPrefix(version, v+".") {
+			return v
+		}
+	}
+	return ""
+}
+
+// checkMetaSchema reports where the document does not match the meta-schema
+// of its OpenAPI version
+func checkMetaSchema(c *specCheck) {
+	if c.version == "" {
+		switch version, ok := c.doc["openapi"].(string); {
+		case c.doc["swagger"] != nil:
+			c.report("/swagger", "Swagger 2.0 documents are not supported, convert the spec to OpenAPI 3 first")
+		case !ok:
+			c.report("", "%q is required and must be a 3.0.x or 3.1.x version", "openapi")
+		default:
+			c.report("/openapi", "OpenAPI version %q is not supported, want 3.0.x or 3.1.x", version)
+		}
+		return
+	}
+	meta := metaSchemas[c.version]
+	m := &metaCheck{root: meta, report: c.report}
+	m.check(meta, c.doc, "")
+}
+
+// metaCheck validates a document against a meta-schema, a JSON Schema using
+// $ref, if/then/else, not, type, enum, required, properties,
+// patternProperties and additionalProperties
+type metaCheck struct {
+	root     map[string]interface{}
+	report   func(pointer, format string, args ...interface{})
+	patterns map[string]*regexp.Regexp
+}
+
+func (m *metaCheck) check(schema map[string]interface{}, value interface{}, pointer string) {
+	for _, problem := range m.problems(schema, value, pointer) {
+		m.report(problem.pointer, "%s", problem.message)
+	}
+}
+
+// pattern compiles a patternProperties key once
+func (m *metaCheck) pattern(expr string) *regexp.Regexp {
+	if m.patterns == nil {
+		m.patterns = map[string]*regexp.Regexp{}
+	}
+	re, ok := m.patterns[expr]
+	if !ok {
+		re = regexp.MustCompile(expr)
+		m.patterns[expr] = re
+	}
+	return re
+}
+
+type metaProblem struct {
+	pointer string
+	message string
+}
+
+// problems lists where value does not match schema
+func (m *metaCheck) problems(schema map[string]interface{}, value interface{}, pointer string) []metaProblem {
+	var problems []metaProblem
+	if ref, ok := schema["$ref"].(string); ok {
+		target, _ := lookupPointer(m.root, strings.TrimPrefix(ref, "#"))
+		def, _ := target.(map[string]interface{})
+		problems = append(problems, m.problems(def, value, pointer)...)
+	}
+	if cond, ok := schema["if"].(map[string]interface{}); ok {
+		branch := "else"
+		if len(m.problems(cond, value, pointer)) == 0 {
+			branch = "then"
+		}
+		if s, ok := schema[branch].(map[string]interface{}); ok {
+			problems = append(problems, m.problems(s, value, pointer)...)
+		}
+	}
+	if not, ok := schema["not"].(map[string]interface{}); ok && len(m.problems(not, value, pointer)) == 0 {
+		problems = append(problems, metaProblem{pointer, "must not match " + compactJSON(not)})
+	}
+	if _, ok := schema["type"]; ok && !metaTypeMatches(schema["type"], value) {
+		return append(problems, metaProblem{pointer, "must be " + typeName(schema)})
+	}
+	if values, ok := schema["enum"].([]interface{}); ok && !enumContains(values, value) {
+		problems = append(problems, metaProblem{pointer, "must be one of " + compactJSON(values)})
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		required, _ := schema["required"].([]interface{})
+		for _, name := range required {
+			if n, _ := name.(string); !hasKey(v, n) {
+				problems = append(problems, metaProblem{pointer, fmt.Sprintf("%q is required", n)})
+			}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		patterns, _ := schema["patternProperties"].(map[string]interface{})
+		for _, name := range sortedKeys(v) {
+			child := pointerTo(pointer, name)
+			matched := false
+			if s, ok := properties[name].(map[string]interface{}); ok {
+				matched = true
+				problems = append(problems, m.problems(s, v[name], child)...)
+			}
+			for _, pattern := range sortedKeys(patterns) {
+				if m.pattern(pattern).MatchString(name) {
+					matched = true
+					s, _ := patterns[pattern].(map[string]interface{})
+					problems = append(problems, m.problems(s, v[name], child)...)
+				}
+			}
+			if matched {
+				continue
+			}
+			switch additional := schema["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					problems = append(problems, metaProblem{child, fmt.Sprintf("property %q is not allowed", name)})
+				}
+			case map[string]interface{}:
+				problems = append(problems, m.problems(additional, v[name], child)...)
+			}
+		}
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				problems = append(problems, m.problems(items, item, fmt.Sprintf("%s/%d", pointer, i))...)
+			}
+		}
+	}
+	return problems
+}
+
+func hasKey(m map[string]interface{}, key string) bool {
+	_, ok := m[key]
+	return ok
+}
+
+// metaTypeMatches reports whether a decoded JSON value has one of the types
+// of a type keyword
+func metaTypeMatches(declared interface{}, value interface{}) bool {
+	types, ok := declared.([]interface{})
+	if !ok {
+		types = []interface{}{declared}
+	}
+	for _, t := range types {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if t == "object" {
+				return true
+			}
+		case []interface{}:
+			if t == "array" {
+				return true
+			}
+		case string:
+			if t == "string" {
+				return true
+			}
+		case bool:
+			if t == "boolean" {
+				return true
+			}
+		case float64:
+			if t == "number" || (t == "integer" && v == math.Trunc(v)) {
+				return true
+			}
+		case nil:
+			if t == "null" {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,493 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// defaultBodyLimit caps request bodies when the spec sets no x-body-limit
+const defaultBodyLimit = 1 << 20
+
+// routeTags returns the tags of an operation
+func routeTags(op operation) []string {
+	raw, _ := op.Endpoint["tags"].([]interface{})
+	tags := make([]string, 0, len(raw))
+	for _, t := range raw {
+		if s, ok := t.(string); ok {
+			tags = append(tags, s)
+		}
+	}
+	return tags
+}
+
+// stringList renders a Go []string literal
+func stringList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = fmt.Sprintf("%q", v)
+	}
+	return "[]string{" + strings.Join(quoted, ", ") + "}"
+}
+
+// writeRoute emits the routes table entry of an operation
+func writeRoute(code *strings.Builder, op operation, spec *OpenAPISpec, schemes map[string]securityScheme) {
+	code.WriteString(fmt.Sprintf("    {Pattern: %q, OperationID: %q, Handler: %s", muxPattern(op.Method, op.Path), op.OperationID, op.HandlerName))
+	if tags := routeTags(op); len(tags) > 0 {
+		code.WriteString(", Tags: " + stringList(tags))
+	}
+	if limit, ok := extensionInt(op, nil, "x-body-limit"); ok {
+		code.WriteString(fmt.Sprintf(", BodyLimit: %d", limit))
+	}
+	if consumes := requestMediaTypes(op); len(consumes) > 0 {
+		code.WriteString(", Consumes: " + stringList(consumes))
+	}
+	if produces := responseMediaTypes(op); len(produces) > 0 {
+		code.WriteString(", Produces: " + stringList(produces))
+	}
+	if idempotencyEnabled(op, spec.Extensions) {
+		code.WriteString(", Idempotent: true")
+	}
+	if limits := rateLimitsLiteral(op, spec); limits != "" {
+		code.WriteString(", RateLimits: " + limits)
+	}
+	if reqs := operationSecurity(op, spec, schemes); len(reqs) > 0 {
+		code.WriteString(", Security: " + securityLiteral(reqs))
+	}
+	code.WriteString("},\n")
+}
+
+// corsLiteral renders the x-cors extension of the spec as a CORSConfig
+// literal; CORS stays disabled without it
+func corsLiteral(ext map[string]interface{}) string {
+	cors, _ := ext["x-cors"].(map[string]interface{})
+	strs := func(key string) []string {
+		raw, _ := cors[key].([]interface{})
+		var out []string
+		for _, v := range raw {
+			if s, ok := v.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	var fields []string
+	for _, f := range []struct{ key, field string }{
+		{"allowOrigins", "AllowOrigins"},
+		{"allowMethods", "AllowMethods"},
+		{"allowHeaders", "AllowHeaders"},
+		{"exposeHeaders", "ExposeHeaders"},
+	} {
+		if values := strs(f.key); len(values) > 0 {
+			fields = append(fields, fmt.Sprintf("%s: %s", f.field, stringList(values)))
+		}
+	}
+	if v, ok := cors["allowCredentials"].(bool); ok && v {
+		fields = append(fields, "AllowCredentials: true")
+	}
+	if v, ok := cors["maxAge"].(float64); ok {
+		fields = append(fields, fmt.Sprintf("MaxAge: %d", int(v)))
+	}
+	return "CORSConfig{" + strings.Join(fields, ", ") + "}"
+}
+
+// generateMiddlewareCode creates the middleware pipeline of the generated
+// server: built-in middleware around the whole mux, and user middleware
+// registered globally, per tag or per operation from files of their own
+func generateMiddlewareCode(spec *OpenAPISpec, withSecurity bool) string {
+	var code strings.Builder
+	code.WriteString("package main\n\n")
+	code.WriteString("import (\n    \"compress/gzip\"\n    \"context\"\n    \"crypto/rand\"\n    \"encoding/hex\"\n    \"fmt\"\n    \"io\"\n    \"log/slog\"\n    \"net/http\"\n    \"runtime/debug\"\n    \"strconv\"\n    \"strings\"\n    \"sync\"\n    \"time\"\n    \"github.com/klauspost/compress/zstd\"\n)\n\n")
+
+	code.WriteString("// route is an operation of the spec registered on the mux\n")
+	code.WriteString("type route struct {\n")
+	code.WriteString("    Pattern     string\n")
+	code.WriteString("    OperationID string\n")
+	code.WriteString("    Tags        []string\n")
+	code.WriteString("    Handler     http.HandlerFunc\n")
+	code.WriteString("    BodyLimit   int64    // overrides MaxBodyBytes when set\n")
+	code.WriteString("    Consumes    []string // request media types; others are answered with 415\n")
+	code.WriteString("    Produces    []string // response media types; clients accepting none get 406\n")
+	code.WriteString("    Idempotent  bool     // POST honouring Idempotency-Key\n")
+	code.WriteString("    RateLimits  []*RateLimit\n")
+	if withSecurity {
+		code.WriteString("    Security    []securityRequirement\n")
+	}
+	code.WriteString("}\n\n")
+	code.WriteString("// handler wraps the operation in its span, metrics, body limit, rate limits,\n")
+	code.WriteString("// authentication, content negotiation, Idempotency-Key replay and the\n")
+	code.WriteString("// middleware registered for its tags and operation ID\n")
+	code.WriteString("func (rt route) handler() http.Handler {\n")
+	code.WriteString("    h := chain(rt.Handler, operationMiddleware[rt.OperationID])\n")
+	code.WriteString("    for i := len(rt.Tags) - 1; i >= 0; i-- {\n")
+	code.WriteString("        h = chain(h, tagMiddleware[rt.Tags[i]])\n")
+	code.WriteString("    }\n")
+	code.WriteString("    if rt.Idempotent {\n")
+	code.WriteString("        h = idempotent(h, rt.OperationID)\n")
+	code.WriteString("    }\n")
+	code.WriteString("    if len(rt.Consumes) > 0 || len(rt.Produces) > 0 {\n")
+	code.WriteString("        h = negotiate(h, rt.Consumes, rt.Produces)\n")
+	code.WriteString("    }\n")
+	if withSecurity {
+		// Limits per principal or API key need authentication, so only
+		// verified credentials pick a bucket; limits per IP run first so they
+		// also throttle clients guessing credentials
+		code.WriteString("    var afterAuth, before []*RateLimit\n")
+		code.WriteString("    for _, limit := range rt.RateLimits {\n")
+		code.WriteString("        if limit.Key == \"principal\" || limit.Key == \"apiKey\" {\n")
+		code.WriteString("            afterAuth = append(afterAuth, limit)\n")
+		code.WriteString("        } else {\n")
+		code.WriteString("            before = append(before, limit)\n")
+		code.WriteString("        }\n")
+		code.WriteString("    }\n")
+		code.WriteString("    if len(afterAuth) > 0 {\n")
+		code.WriteString("        h = limitRate(h, afterAuth)\n")
+		code.WriteString("    }\n")
+		code.WriteString("    if len(rt.Security) > 0 {\n")
+		code.WriteString("        h = requireAuth(h, rt.Security)\n")
+		code.WriteString("    }\n")
+		code.WriteString("    if len(before) > 0 {\n")
+		code.WriteString("        h = limitRate(h, before)\n")
+		code.WriteString("    }\n")
+	} else {
+		code.WriteString("    if len(rt.RateLimits) > 0 {\n")
+		code.WriteString("        h = limitRate(h, rt.RateLimits)\n")
+		code.WriteString("    }\n")
+	}
+	code.WriteString("    h = instrument(limitBody(h, rt.BodyLimit), rt.OperationID, strings.Fields(rt.Pattern)[0])\n")
+	code.WriteString("    return traceOperation(h, rt.OperationID, rt.Pattern)\n")
+	code.WriteString("}\n\n")
+
+	bodyLimit := int64(defaultBodyLimit)
+	if limit, ok := extensionInt(operation{}, spec.Extensions, "x-body-limit"); ok {
+		bodyLimit = limit
+	}
+	code.WriteString("// MaxBodyBytes caps request bodies of routes without their own limit\n")
+	code.WriteString(fmt.Sprintf("var MaxBodyBytes int64 = %d\n\n", bodyLimit))
+	code.WriteString("// Cors configures cross-origin requests; an empty AllowOrigins disables CORS\n")
+	code.WriteString(fmt.Sprintf("var Cors = %s\n\n", corsLiteral(spec.Extensions)))
+
+	code.WriteString(`// Middleware wraps an http.Handler
+type Middleware func(http.Handler) http.Handler
+
+var (
+    globalMiddleware    []Middleware
+    tagMiddleware       = map[string][]Middleware{}
+    operationMiddleware = map[string][]Middleware{}
+)
+
+// Use registers middleware for every request. Like UseTag and UseOperation it
+// must be called before the server starts, typically from an init function in
+// a file of your own so regenerating the server keeps it:
+//
+//     func init() {
+//         Use(myMiddleware)
+//         UseTag("admin", requireAdmin)
+//         UseOperation("createUser", audit)
+//     }
+//
+// Global middleware runs before routing. Tag middleware, in the order the
+// operation lists its tags, then operation middleware run after authentication.
+// Each list runs in registration order.
+func Use(mw ...Middleware) {
+    globalMiddleware = append(globalMiddleware, mw...)
+}
+
+// UseTag registers middleware for the operations carrying a tag
+func UseTag(tag string, mw ...Middleware) {
+    tagMiddleware[tag] = append(tagMiddleware[tag], mw...)
+}
+
+// UseOperation registers middleware for a single operation ID
+func UseOperation(operationID string, mw ...Middleware) {
+    operationMiddleware[operationID] = append(operationMiddleware[operationID], mw...)
+}
+
+// chain applies middleware so the first one registered runs first
+func chain(h http.Handler, mw []Middleware) http.Handler {
+    for i := len(mw) - 1; i >= 0; i-- {
+        h = mw[i](h)
+    }
+    return h
+}
+
+// buildHandler wraps the mux in the built-in middleware and global user
+// middleware, outermost first: request ID, access log, panic recovery, CORS,
+// compression
+func buildHandler(mux http.Handler) http.Handler {
+    h := chain(mux, globalMiddleware)
+    h = compress(h)
+    h = cors(h)
+    h = recoverPanics(h)
+    h = accessLog(h)
+    return requestID(h)
+}
+
+// requestID propagates a well-formed X-Request-ID header or assigns a new ID,
+// echoing it in the response
+func requestID(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        id := r.Header.Get("X-Request-ID")
+        if !validRequestID(id) {
+            buf := make([]byte, 16)
+            rand.Read(buf)
+            id = hex.EncodeToString(buf)
+        }
+        w.Header().Set("X-Request-ID", id)
+        next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestInfoKey{}, &requestInfo{ID: id})))
+    })
+}
+
+func validRequestID(id string) bool {
+    if id == "" || len(id) > 128 {
+        return false
+    }
+    for _, c := range id {
+        if c < '!' || c > '~' {
+            return false
+        }
+    }
+    return true
+}
+
+// statusRecorder captures the status and size of a response
+type statusRecorder struct {
+    http.ResponseWriter
+    status int
+    bytes  int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+    if s.status == 0 {
+        s.status = status
+    }
+    s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(p []byte) (int, error) {
+    if s.status == 0 {
+        s.status = http.StatusOK
+    }
+    n, err := s.ResponseWriter.Write(p)
+    s.bytes += n
+    return n, err
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+    return s.ResponseWriter
+}
+
+// accessLog logs one line per request
+func accessLog(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        start := time.Now()
+        rec := &statusRecorder{ResponseWriter: w}
+        next.ServeHTTP(rec, r)
+        if rec.status == 0 {
+            rec.status = http.StatusOK
+        }
+        attrs := append(requestAttrs(r.Context()),
+            slog.String("method", r.Method),
+            slog.String("path", r.URL.RequestURI()),
+            slog.Int("status", rec.status),
+            slog.Int("bytes", rec.bytes),
+            slog.Duration("latency", time.Since(start)))
+        slog.InfoContext(r.Context(), "request", attrs...)
+    })
+}
+
+// recoverPanics turns a panicking handler into a 500 response
+func recoverPanics(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        defer func() {
+            if err := recover(); err != nil {
+                if err == http.ErrAbortHandler {
+                    panic(err)
+                }
+                LoggerFrom(r.Context()).Error("panic serving request", "method", r.Method, "path", r.URL.Path, "panic", fmt.Sprint(err), "stack", string(debug.Stack()))
+                http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+            }
+        }()
+        next.ServeHTTP(w, r)
+    })
+}
+
+// CORSConfig lists the cross-origin requests the server accepts. An origin of
+// "*" allows any origin.
+type CORSConfig struct {
+    AllowOrigins     []string
+    AllowMethods     []string
+    AllowHeaders     []string
+    ExposeHeaders    []string
+    AllowCredentials bool
+    MaxAge           int // seconds browsers may cache a preflight response
+}
+
+func (c CORSConfig) allowed(origin string) bool {
+    for _, o := range c.AllowOrigins {
+        if o == "*" || strings.EqualFold(o, origin) {
+            return true
+        }
+    }
+    return false
+}
+
+// cors adds CORS headers for allowed origins and answers preflight requests
+func cors(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        origin := r.Header.Get("Origin")
+        if origin == "" || !Cors.allowed(origin) {
+            next.ServeHTTP(w, r)
+            return
+        }
+        h := w.Header()
+        h.Add("Vary", "Origin")
+        h.Set("Access-Control-Allow-Origin", origin)
+        if Cors.AllowCredentials {
+            h.Set("Access-Control-Allow-Credentials", "true")
+        }
+        if len(Cors.ExposeHeaders) > 0 {
+            h.Set("Access-Control-Expose-Headers", strings.Join(Cors.ExposeHeaders, ", "))
+        }
+        if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" {
+            next.ServeHTTP(w, r)
+            return
+        }
+        methods := Cors.AllowMethods
+        if len(methods) == 0 {
+            methods = []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+        }
+        h.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+        if len(Cors.AllowHeaders) > 0 {
+            h.Set("Access-Control-Allow-Headers", strings.Join(Cors.AllowHeaders, ", "))
+        } else if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+            h.Set("Access-Control-Allow-Headers", requested)
+        }
+        if Cors.MaxAge > 0 {
+            h.Set("Access-Control-Max-Age", strconv.Itoa(Cors.MaxAge))
+        }
+        w.WriteHeader(http.StatusNoContent)
+    })
+}
+
+// limitBody rejects bodies larger than limit, or MaxBodyBytes when limit is 0
+func limitBody(next http.Handler, limit int64) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        max := limit
+        if max == 0 {
+            max = MaxBodyBytes
+        }
+        if max > 0 {
+            if r.ContentLength > max {
+                http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+                return
+            }
+            r.Body = http.MaxBytesReader(w, r.Body, max)
+        }
+        next.ServeHTTP(w, r)
+    })
+}
+
+var zstdEncoders = sync.Pool{New: func() interface{} {
+    enc, _ := zstd.NewWriter(nil)
+    return enc
+}}
+
+// compress encodes responses with zstd or gzip when the client accepts them
+func compress(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Add("Vary", "Accept-Encoding")
+        encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+        if encoding == "" || r.Method == http.MethodHead {
+            next.ServeHTTP(w, r)
+            return
+        }
+        cw := &compressWriter{ResponseWriter: w, encoding: encoding}
+        defer cw.Close()
+        next.ServeHTTP(cw, r)
+    })
+}
+
+// negotiateEncoding picks zstd, then gzip, from an Accept-Encoding header
+func negotiateEncoding(header string) string {
+    accepted := map[string]bool{}
+    for _, part := range strings.Split(header, ",") {
+        fields := strings.Split(part, ";")
+        name := strings.ToLower(strings.TrimSpace(fields[0]))
+        q := 1.0
+        for _, param := range fields[1:] {
+            if v, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+                q, _ = strconv.ParseFloat(v, 64)
+            }
+        }
+        accepted[name] = q > 0
+    }
+    for _, encoding := range []string{"zstd", "gzip"} {
+        if accepted[encoding] {
+            return encoding
+        }
+    }
+    return ""
+}
+
+// compressWriter starts compressing when the response turns out to have a
+// body that is not already encoded
+type compressWriter struct {
+    http.ResponseWriter
+    encoding    string
+    writer      io.WriteCloser
+    wroteHeader bool
+}
+
+func (c *compressWriter) WriteHeader(status int) {
+    if c.wroteHeader {
+        return
+    }
+    c.wroteHeader = true
+    h := c.Header()
+    // Responses served with byte ranges stay identity-encoded so offsets match
+    ranged := h.Get("Accept-Ranges") != "" || h.Get("Content-Range") != ""
+    if status != http.StatusNoContent && status != http.StatusNotModified && status >= 200 && h.Get("Content-Encoding") == "" && !ranged {
+        h.Del("Content-Length")
+        h.Set("Content-Encoding", c.encoding)
+        if c.encoding == "zstd" {
+            enc := zstdEncoders.Get().(*zstd.Encoder)
+            enc.Reset(c.ResponseWriter)
+            c.writer = enc
+        } else {
+            c.writer = gzip.NewWriter(c.ResponseWriter)
+        }
+    }
+    c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *compressWriter) Write(p []byte) (int, error) {
+    if !c.wroteHeader {
+        // sniff before compressing, net/http would see only encoded bytes
+        if c.Header().Get("Content-Type") == "" {
+            c.Header().Set("Content-Type", http.DetectContentType(p))
+        }
+        c.WriteHeader(http.StatusOK)
+    }
+    if c.writer == nil {
+        return c.ResponseWriter.Write(p)
+    }
+    return c.writer.Write(p)
+}
+
+func (c *compressWriter) Close() {
+    if c.writer == nil {
+        return
+    }
+    c.writer.Close()
+    if enc, ok := c.writer.(*zstd.Encoder); ok {
+        enc.Reset(nil)
+        zstdEncoders.Put(enc)
+    }
+}
+
+func (c *compressWriter) Unwrap() http.ResponseWriter {
+    return c.ResponseWriter
+}
+`)
+	return code.String()
+}
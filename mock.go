@@ -0,0 +1,576 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mockServer answers the operations of a spec without generating code:
+// responses come from the declared examples or are synthesized from the
+// schemas. In stateful mode created resources are kept in memory, so a POST
+// can be read back, replaced and deleted.
+type mockServer struct {
+	spec     *OpenAPISpec
+	schemes  map[string]securityScheme
+	stateful bool
+	log      func(string)
+
+	mu          sync.Mutex
+	collections map[string]*mockCollection // by concrete collection path
+	itemParams  map[string]string          // item path parameter of each collection template
+	nextID      int
+}
+
+// mockCollection holds the resources created under one collection path
+type mockCollection struct {
+	ids   []string
+	items map[string]interface{}
+}
+
+// newMockServer builds the handler serving the operations of spec. log, if
+// set, receives a line per request.
+func newMockServer(spec *OpenAPISpec, stateful bool, log func(string)) (http.Handler, error) {
+	m := &mockServer{
+		spec:        spec,
+		schemes:     extractSecuritySchemes(spec.Components),
+		stateful:    stateful,
+		log:         log,
+		collections: map[string]*mockCollection{},
+		itemParams:  map[string]string{},
+	}
+	ops := collectOperations(spec.Paths)
+	for _, op := range ops {
+		if parent, param, ok := itemPath(op.Path); ok {
+			m.itemParams[parent] = param
+		}
+	}
+	mux := http.NewServeMux()
+	for _, op := range ops {
+		if err := registerMock(mux, muxPattern(op.Method, op.Path), m.handler(op)); err != nil {
+			return nil, err
+		}
+	}
+	return m.cors(mux), nil
+}
+
+// registerMock adds a route, turning the panic of conflicting patterns into
+// an error
+func registerMock(mux *http.ServeMux, pattern string, h http.HandlerFunc) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	mux.HandleFunc(pattern, h)
+	return nil
+}
+
+// itemPath splits a path ending in a parameter into its collection path and
+// the parameter name
+func itemPath(path string) (string, string, bool) {
+	i := strings.LastIndex(path, "/")
+	last := path[i+1:]
+	if i < 0 || !strings.HasPrefix(last, "{") || !strings.HasSuffix(last, "}") {
+		return "", "", false
+	}
+	return path[:i], strings.Trim(last, "{}"), true
+}
+
+// cors lets browser apps on other origins call the mock and logs requests
+func (m *mockServer) cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		if origin := r.Header.Get("Origin"); origin != "" {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+			w.Header().Set("Access-Control-Expose-Headers", "*")
+			w.Header().Add("Vary", "Origin")
+		}
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", r.Header.Get("Access-Control-Request-Method"))
+			if headers := r.Header.Get("Access-Control-Request-Headers"); headers != "" {
+				w.Header().Set("Access-Control-Allow-Headers", headers)
+			}
+			rec.WriteHeader(http.StatusNoContent)
+		} else {
+			next.ServeHTTP(rec, r)
+		}
+		if m.log != nil {
+			m.log(fmt.Sprintf("%s %s %d %s", r.Method, r.URL.RequestURI(), rec.status, time.Since(start).Round(time.Microsecond)))
+		}
+	})
+}
+
+// statusRecorder remembers the status of a response for the request log
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// mockError answers with a JSON error and the problems found
+func mockError(w http.ResponseWriter, status int, message string, problems []string) {
+	body := map[string]interface{}{"message": message}
+	if len(problems) > 0 {
+		body["errors"] = problems
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// handler serves one operation: it checks credentials and the request, then
+// answers from the in-memory store or the spec
+func (m *mockServer) handler(op operation) http.HandlerFunc {
+	params := operationParameters(op, m.spec.Components)
+	security := operationSecurity(op, m.spec, m.schemes)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if len(security) > 0 && !m.authorized(r, security) {
+			mockError(w, http.StatusUnauthorized, "Missing credentials", nil)
+			return
+		}
+		problems := m.checkParams(r, params)
+		body, status, bodyProblems := m.readBody(r, op)
+		if status != 0 {
+			mockError(w, status, strings.Join(bodyProblems, "; "), nil)
+			return
+		}
+		problems = append(problems, bodyProblems...)
+		if len(problems) > 0 {
+			mockError(w, http.StatusBadRequest, "Invalid request", problems)
+			return
+		}
+
+		// Prefer: code=404 and Prefer: example=name pick a declared response
+		prefer := parsePrefer(r.Header.Get("Prefer"))
+		success := operationSuccess(op)
+		if code := prefer["code"]; code != "" {
+			responses, _ := op.Endpoint["responses"].(map[string]interface{})
+			if resp, ok := responses[code].(map[string]interface{}); ok {
+				m.respond(w, r, code, resp, prefer["example"], nil)
+				return
+			}
+		}
+		if m.stateful && m.serveStateful(w, r, op, success, body) {
+			return
+		}
+		m.respond(w, r, success.Status, success.Response, prefer["example"], nil)
+	}
+}
+
+// parsePrefer reads the key=value preferences of a Prefer header
+func parsePrefer(header string) map[string]string {
+	prefs := map[string]string{}
+	for _, part := range strings.Split(header, ",") {
+		for _, pref := range strings.Split(part, ";") {
+			key, value, _ := strings.Cut(strings.TrimSpace(pref), "=")
+			prefs[strings.ToLower(key)] = strings.Trim(value, `"`)
+		}
+	}
+	return prefs
+}
+
+// authorized reports whether the request carries credentials for one of the
+// security alternatives; their values are not checked
+func (m *mockServer) authorized(r *http.Request, alternatives []map[string][]string) bool {
+	for _, req := range alternatives {
+		complete := true
+		for name := range req {
+			s := m.schemes[name]
+			switch {
+			case s.Type == "apiKey" && s.In == "query":
+				complete = complete && r.URL.Query().Get(s.Name) != ""
+			case s.Type == "apiKey" && s.In == "cookie":
+				_, err := r.Cookie(s.Name)
+				complete = complete && err == nil
+			case s.Type == "apiKey":
+				complete = complete && r.Header.Get(s.Name) != ""
+			case s.Type == "http" && s.Scheme == "basic":
+				_, _, ok := r.BasicAuth()
+				complete = complete && ok
+			default:
+				complete = complete && strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ")
+			}
+		}
+		if complete {
+			return true
+		}
+	}
+	return false
+}
+
+// checkParams validates the parameters of a request against their schemas
+func (m *mockServer) checkParams(r *http.Request, params []parameter) []string {
+	var problems []string
+	for _, p := range params {
+		var raw []string
+		switch p.In {
+		case "path":
+			raw = []string{r.PathValue(wildcardName(p.Name))}
+		case "query":
+			raw = r.URL.Query()[p.Name]
+		case "header":
+			raw = r.Header.Values(p.Name)
+		case "cookie":
+			if c, err := r.Cookie(p.Name); err == nil {
+				raw = []string{c.Value}
+			}
+		}
+		if len(raw) == 0 {
+			if p.Required {
+				problems = append(problems, fmt.Sprintf("%s parameter %s is required", p.In, p.Name))
+			}
+			continue
+		}
+		value := paramValue(resolveSchema(m.spec, p.Schema), raw)
+		for _, problem := range validateValue(m.spec, p.Schema, value, p.Name, true) {
+			problems = append(problems, p.In+" parameter "+problem)
+		}
+	}
+	return problems
+}
+
+// paramValue converts the raw values of a parameter to what its schema
+// describes, so they can be validated like JSON
+func paramValue(schema map[string]interface{}, raw []string) interface{} {
+	if schema["type"] == "array" {
+		var values []string
+		for _, v := range raw {
+			values = append(values, strings.Split(v, ",")...)
+		}
+		items, _ := schema["items"].(map[string]interface{})
+		list := make([]interface{}, len(values))
+		for i, v := range values {
+			list[i] = scalarParam(items, v)
+		}
+		return list
+	}
+	return scalarParam(schema, raw[0])
+}
+
+func scalarParam(schema map[string]interface{}, s string) interface{} {
+	switch schema["type"] {
+	case "integer", "number":
+		if _, err := strconv.ParseFloat(s, 64); err == nil {
+			return json.Number(s)
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b
+		}
+	}
+	return s
+}
+
+// readBody checks the content type of a request body and decodes and
+// validates JSON bodies. A non-zero status rejects the request outright.
+func (m *mockServer) readBody(r *http.Request, op operation) (interface{}, int, []string) {
+	reqBody, _ := resolveRef(m.spec, mapValue(op.Endpoint, "requestBody")).(map[string]interface{})
+	data, err := io.ReadAll(io.LimitReader(r.Body, 10<<20))
+	if err != nil {
+		return nil, http.StatusBadRequest, []string{"Failed to read request body"}
+	}
+	if len(data) == 0 {
+		if required, _ := reqBody["required"].(bool); required {
+			return nil, 0, []string{"request body is required"}
+		}
+		return nil, 0, nil
+	}
+	content, _ := reqBody["content"].(map[string]interface{})
+	if len(content) == 0 {
+		return nil, 0, nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	media, ok := content[mediaType].(map[string]interface{})
+	if !ok {
+		for declared := range content {
+			if matchMediaRange(declared, mediaType) {
+				media, ok = content[declared].(map[string]interface{})
+				break
+			}
+		}
+	}
+	if !ok {
+		return nil, http.StatusUnsupportedMediaType, []string{fmt.Sprintf("Unsupported media type %q", mediaType)}
+	}
+	if mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+		return nil, 0, nil
+	}
+	var body interface{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&body); err != nil {
+		return nil, 0, []string{"body is not valid JSON: " + err.Error()}
+	}
+	schema, _ := media["schema"].(map[string]interface{})
+	if mediaType == mergePatchMediaType || mediaType == jsonPatchMediaType {
+		return body, 0, nil
+	}
+	return body, 0, validateValue(m.spec, schema, body, "body", true)
+}
+
+// matchMediaRange matches a declared media range such as image/* against a
+// media type
+func matchMediaRange(declared, mediaType string) bool {
+	if declared == "*/*" {
+		return true
+	}
+	if strings.HasSuffix(declared, "/*") {
+		return strings.HasPrefix(mediaType, strings.TrimSuffix(declared, "*"))
+	}
+	return declared == mediaType
+}
+
+func mapValue(m map[string]interface{}, key string) map[string]interface{} {
+	v, _ := m[key].(map[string]interface{})
+	return v
+}
+
+// serveStateful answers CRUD requests from the in-memory collections. It
+// returns false for requests it leaves to the examples, such as reads of
+// collections nothing was created in yet.
+func (m *mockServer) serveStateful(w http.ResponseWriter, r *http.Request, op operation, success successSpec, body interface{}) bool {
+	schema := m.responseSchema(success)
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, param, isItem := itemPath(op.Path)
+	if !isItem {
+		switch op.Method {
+		case http.MethodPost:
+			object, ok := body.(map[string]interface{})
+			if !ok {
+				return false
+			}
+			if generated, ok := synthesize(m.spec, schema, 0).(map[string]interface{}); ok {
+				for k, v := range object {
+					generated[k] = v
+				}
+				object = generated
+			}
+			m.nextID++
+			id := strconv.Itoa(m.nextID)
+			if field := idField(resolveSchema(m.spec, schema), m.itemParams[op.Path]); field != "" {
+				object[field] = idValue(resolveSchema(m.spec, schema), field, id)
+			}
+			m.collection(path, true).put(id, object)
+			w.Header().Set("Location", path+"/"+id)
+			m.respond(w, r, success.Status, success.Response, "", object)
+			return true
+		case http.MethodGet:
+			c := m.collection(path, false)
+			if c == nil || resolveSchema(m.spec, schema)["type"] != "array" {
+				return false
+			}
+			list := make([]interface{}, 0, len(c.ids))
+			for _, id := range c.ids {
+				list = append(list, c.items[id])
+			}
+			m.respond(w, r, success.Status, success.Response, "", list)
+			return true
+		}
+		return false
+	}
+
+	id := r.PathValue(wildcardName(param))
+	collectionPath := path[:strings.LastIndex(path, "/")]
+	c := m.collection(collectionPath, op.Method == http.MethodPut)
+	if c == nil {
+		return false
+	}
+	stored, exists := c.items[id]
+	switch op.Method {
+	case http.MethodGet:
+		if !exists {
+			mockError(w, http.StatusNotFound, "Not found", nil)
+			return true
+		}
+		m.respond(w, r, success.Status, success.Response, "", stored)
+	case http.MethodPut:
+		object, ok := body.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		if field := idField(resolveSchema(m.spec, schema), param); field != "" {
+			object[field] = idValue(resolveSchema(m.spec, schema), field, id)
+		}
+		c.put(id, object)
+		m.respond(w, r, success.Status, success.Response, "", object)
+	case http.MethodPatch:
+		if !exists {
+			mockError(w, http.StatusNotFound, "Not found", nil)
+			return true
+		}
+		patched, ok := mergePatch(stored, body).(map[string]interface{})
+		if !ok {
+			return false
+		}
+		c.put(id, patched)
+		m.respond(w, r, success.Status, success.Response, "", patched)
+	case http.MethodDelete:
+		if !exists {
+			mockError(w, http.StatusNotFound, "Not found", nil)
+			return true
+		}
+		c.remove(id)
+		m.respond(w, r, success.Status, success.Response, "", nil)
+	default:
+		return false
+	}
+	return true
+}
+
+// collection returns the resources under a path, creating the collection
+// when create is set
+func (m *mockServer) collection(path string, create bool) *mockCollection {
+	c := m.collections[path]
+	if c == nil && create {
+		c = &mockCollection{items: map[string]interface{}{}}
+		m.collections[path] = c
+	}
+	return c
+}
+
+func (c *mockCollection) put(id string, item interface{}) {
+	if _, ok := c.items[id]; !ok {
+		c.ids = append(c.ids, id)
+	}
+	c.items[id] = item
+}
+
+func (c *mockCollection) remove(id string) {
+	delete(c.items, id)
+	for i, existing := range c.ids {
+		if existing == id {
+			c.ids = append(c.ids[:i], c.ids[i+1:]...)
+			break
+		}
+	}
+}
+
+// idField picks the property holding a resource's ID: the one named like
+// the item path parameter, else id. Schemas without properties get id.
+func idField(schema map[string]interface{}, param string) string {
+	properties, _ := schema["properties"].(map[string]interface{})
+	if len(properties) == 0 {
+		return "id"
+	}
+	for _, name := range []string{param, "id"} {
+		if _, ok := properties[name]; ok && name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// idValue types an ID like its property
+func idValue(schema map[string]interface{}, field, id string) interface{} {
+	prop, _ := mapValue(schema, "properties")[field].(map[string]interface{})
+	if prop["type"] == "integer" || prop["type"] == "number" {
+		return json.Number(id)
+	}
+	return id
+}
+
+// mergePatch applies an RFC 7386 merge patch
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	result := map[string]interface{}{}
+	if ok {
+		for k, v := range t {
+			result[k] = v
+		}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(result, k)
+		} else {
+			result[k] = mergePatch(result[k], v)
+		}
+	}
+	return result
+}
+
+// responseSchema returns the JSON schema of a response, if it declares one
+func (m *mockServer) responseSchema(success successSpec) map[string]interface{} {
+	resp, _ := resolveRef(m.spec, success.Response).(map[string]interface{})
+	content, _ := resp["content"].(map[string]interface{})
+	media, _ := content[jsonMediaType(content)].(map[string]interface{})
+	schema, _ := media["schema"].(map[string]interface{})
+	return schema
+}
+
+// respond writes a declared response. value, when set, is the body; else the
+// named or first example is used, else data synthesized from the schema.
+func (m *mockServer) respond(w http.ResponseWriter, r *http.Request, status string, response map[string]interface{}, example string, value interface{}) {
+	code, err := strconv.Atoi(status)
+	if err != nil {
+		code = http.StatusOK
+	}
+	resp, _ := resolveRef(m.spec, response).(map[string]interface{})
+	content, _ := resp["content"].(map[string]interface{})
+	if len(content) == 0 || code == http.StatusNoContent || r.Method == http.MethodHead {
+		w.WriteHeader(code)
+		return
+	}
+	types := mediaTypes(resp, true)
+	mediaType := types[0]
+	media, _ := content[mediaType].(map[string]interface{})
+	if value == nil {
+		value = mediaExample(m.spec, media, example)
+	}
+	switch data := value.(type) {
+	case string:
+		if mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+			w.Header().Set("Content-Type", mediaType)
+			w.WriteHeader(code)
+			io.WriteString(w, data)
+			return
+		}
+	}
+	if mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+		w.Header().Set("Content-Type", mediaType)
+		w.WriteHeader(code)
+		return
+	}
+	w.Header().Set("Content-Type", mediaType)
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(value)
+}
+
+// startMock listens on addr and serves the mock of spec in the background
+// until the returned server is shut down
+func startMock(spec *OpenAPISpec, addr string, stateful bool, log func(string)) (*http.Server, net.Addr, error) {
+	handler, err := newMockServer(spec, stateful, log)
+	if err != nil {
+		return nil, nil, err
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	server := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	go server.Serve(ln)
+	return server, ln.Addr(), nil
+}
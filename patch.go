@@ -0,0 +1,423 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Media types accepted by generated PATCH handlers
+const (
+	mergePatchMediaType = "application/merge-patch+json"
+	jsonPatchMediaType  = "application/json-patch+json"
+)
+
+// patchMediaTypes returns the patch formats a PATCH operation accepts. Plain
+// application/json is treated as a merge patch; an operation without a
+// request body accepts both formats.
+func patchMediaTypes(op operation) []string {
+	reqBody, _ := op.Endpoint["requestBody"].(map[string]interface{})
+	content, _ := reqBody["content"].(map[string]interface{})
+	if len(content) == 0 {
+		return []string{mergePatchMediaType, jsonPatchMediaType}
+	}
+	var types []string
+	for mediaType := range content {
+		switch mediaType {
+		case mergePatchMediaType, jsonPatchMediaType, "application/json":
+			types = append(types, mediaType)
+		}
+	}
+	sort.Strings(types)
+	return types
+}
+
+// writePatchHandler emits a read-modify-write of the stored document inside a
+// single BadgerDB transaction, re-validating the patched result. The ID stays
+// the one in the path whatever the patch does to it.
+func writePatchHandler(code *strings.Builder, op operation, ops []operation, schemas map[string]Schema, blobs bool) {
+	success := successResponse(op, "200", "200", "204")
+	id := resourceIDFor(ops, op, schemas, success)
+	entitySchema := entitySchemaName(ops, op.Resource, schemas)
+	writeKeyLookup(code, op)
+	if id.Field != "" {
+		code.WriteString(fmt.Sprintf("    id := r.PathValue(%q)\n", wildcardName(op.Resource.Segments[len(op.Resource.Segments)-1].Param)))
+	}
+	code.WriteString("    mediaType, _, _ := mime.ParseMediaType(r.Header.Get(\"Content-Type\"))\n")
+	code.WriteString("    var format patchFormat\n")
+	code.WriteString("    switch mediaType {\n")
+	for _, mediaType := range patchMediaTypes(op) {
+		code.WriteString(fmt.Sprintf("    case %q:\n", mediaType))
+		if mediaType == jsonPatchMediaType {
+			code.WriteString("        format = jsonPatch\n")
+		} else {
+			code.WriteString("        format = mergePatch\n")
+		}
+	}
+	code.WriteString("    default:\n")
+	code.WriteString("        http.Error(w, \"Unsupported patch media type\", http.StatusUnsupportedMediaType)\n")
+	code.WriteString("        return\n")
+	code.WriteString("    }\n")
+	code.WriteString("    patch, err := io.ReadAll(r.Body)\n")
+	code.WriteString("    if err != nil {\n")
+	code.WriteString("        http.Error(w, \"Invalid request body\", http.StatusBadRequest)\n")
+	code.WriteString("        return\n    }\n")
+	code.WriteString("    var result []byte\n")
+	code.WriteString("    var version uint64\n")
+	if blobs {
+		code.WriteString("    var previous []byte\n")
+	}
+	code.WriteString("    err = dbUpdate(r.Context(), func(txn *badger.Txn) error {\n")
+	code.WriteString("        current, prev, err := getRecord(txn, key)\n")
+	code.WriteString("        if err != nil {\n")
+	code.WriteString("            return err\n")
+	code.WriteString("        }\n")
+	code.WriteString("        if err := checkIfMatch(r, prev, true); err != nil {\n")
+	code.WriteString("            return err\n")
+	code.WriteString("        }\n")
+	code.WriteString("        result, err = applyPatch(format, current, patch)\n")
+	code.WriteString("        if err != nil {\n")
+	code.WriteString("            return err\n")
+	code.WriteString("        }\n")
+	if id.Field != "" {
+		code.WriteString(fmt.Sprintf("        result, err = withID(result, %q, id, %t)\n", id.Field, id.Numeric))
+		code.WriteString("        if err != nil {\n")
+		code.WriteString("            return &patchError{status: http.StatusBadRequest, msg: \"Invalid ID\"}\n")
+		code.WriteString("        }\n")
+	}
+	code.WriteString(fmt.Sprintf("        if err := validateJSON(%q, result); err != nil {\n", entitySchema))
+	code.WriteString("            return &patchError{status: http.StatusUnprocessableEntity, msg: err.Error()}\n")
+	code.WriteString("        }\n")
+	if blobs {
+		// Patches may move or drop the record's files but not claim others
+		code.WriteString("        if addsBlobs(current, result) {\n")
+		code.WriteString("            return &patchError{status: http.StatusUnprocessableEntity, msg: errBlobReference.Error()}\n")
+		code.WriteString("        }\n")
+		code.WriteString("        previous = current\n")
+	}
+	code.WriteString("        version, err = putRecord(txn, key, result, prev)\n")
+	code.WriteString("        return err\n")
+	code.WriteString("    })\n")
+	if blobs {
+		code.WriteString("    if err == nil {\n")
+		code.WriteString("        releaseBlobs(previous, result)\n")
+		code.WriteString("    }\n")
+	}
+	code.WriteString("    var perr *patchError\n")
+	code.WriteString("    if err == badger.ErrKeyNotFound {\n")
+	code.WriteString(fmt.Sprintf("        http.Error(w, \"%s not found\", http.StatusNotFound)\n", deriveEntityName(op.Path)))
+	code.WriteString("        return\n")
+	code.WriteString("    } else if errors.As(err, &perr) {\n")
+	code.WriteString("        http.Error(w, perr.Error(), perr.status)\n")
+	code.WriteString("        return\n")
+	code.WriteString("    }\n")
+	writeConditionalErrors(code)
+	code.WriteString("    if err != nil {\n")
+	code.WriteString("        http.Error(w, \"Failed to update data\", http.StatusInternalServerError)\n")
+	code.WriteString("        return\n")
+	code.WriteString("    }\n")
+	code.WriteString("    w.Header().Set(\"ETag\", etagFor(version))\n")
+	writeEntityResponse(code, op, success, entitySchema, "result", "    ")
+}
+
+// generatePatchCode creates the JSON Merge Patch (RFC 7396) and JSON Patch
+// (RFC 6902) implementations used by PATCH handlers
+func generatePatchCode() string {
+	var code strings.Builder
+	code.WriteString("package main\n\n")
+	code.WriteString("import (\n    \"encoding/json\"\n    \"fmt\"\n    \"net/http\"\n    \"reflect\"\n    \"strconv\"\n    \"strings\"\n)\n\n")
+	code.WriteString(`// patchFormat selects how a PATCH body is interpreted
+type patchFormat int
+
+const (
+    mergePatch patchFormat = iota // application/merge-patch+json, RFC 7396
+    jsonPatch                     // application/json-patch+json, RFC 6902
+)
+
+// patchError reports a patch that cannot be applied, with the HTTP status to return
+type patchError struct {
+    status int
+    msg    string
+}
+
+func (e *patchError) Error() string {
+    return e.msg
+}
+
+func badPatch(format string, args ...interface{}) error {
+    return &patchError{status: http.StatusBadRequest, msg: fmt.Sprintf(format, args...)}
+}
+
+func unprocessablePatch(format string, args ...interface{}) error {
+    return &patchError{status: http.StatusUnprocessableEntity, msg: fmt.Sprintf(format, args...)}
+}
+
+// applyPatch applies a patch document to the stored JSON and returns the result
+func applyPatch(format patchFormat, current, patch []byte) ([]byte, error) {
+    var doc interface{}
+    if err := json.Unmarshal(current, &doc); err != nil {
+        return nil, err
+    }
+    var patchDoc interface{}
+    if err := json.Unmarshal(patch, &patchDoc); err != nil {
+        return nil, badPatch("invalid patch document: %v", err)
+    }
+    var err error
+    if format == jsonPatch {
+        doc, err = applyJSONPatch(doc, patchDoc)
+        if err != nil {
+            return nil, err
+        }
+    } else {
+        doc = applyMergePatch(doc, patchDoc)
+    }
+    return json.Marshal(doc)
+}
+
+// applyMergePatch implements RFC 7396: objects are merged recursively, null
+// removes a member and any other value replaces the target
+func applyMergePatch(target, patch interface{}) interface{} {
+    patchObj, ok := patch.(map[string]interface{})
+    if !ok {
+        return patch
+    }
+    targetObj, ok := target.(map[string]interface{})
+    if !ok {
+        targetObj = map[string]interface{}{}
+    }
+    for name, value := range patchObj {
+        if value == nil {
+            delete(targetObj, name)
+            continue
+        }
+        targetObj[name] = applyMergePatch(targetObj[name], value)
+    }
+    return targetObj
+}
+
+// applyJSONPatch implements RFC 6902, applying every operation in order and
+// failing the whole patch if any operation fails
+func applyJSONPatch(doc, patch interface{}) (interface{}, error) {
+    ops, ok := patch.([]interface{})
+    if !ok {
+        return nil, badPatch("JSON Patch document must be an array")
+    }
+    for i, raw := range ops {
+        op, ok := raw.(map[string]interface{})
+        if !ok {
+            return nil, badPatch("operation %d must be an object", i)
+        }
+        name, _ := op["op"].(string)
+        path, ok := op["path"].(string)
+        if !ok {
+            return nil, badPatch("operation %d is missing \"path\"", i)
+        }
+        tokens, err := parsePointer(path)
+        if err != nil {
+            return nil, err
+        }
+        value, hasValue := op["value"]
+        switch name {
+        case "add", "replace", "test":
+            if !hasValue {
+                return nil, badPatch("operation %d is missing \"value\"", i)
+            }
+        case "move", "copy":
+            from, ok := op["from"].(string)
+            if !ok {
+                return nil, badPatch("operation %d is missing \"from\"", i)
+            }
+            fromTokens, err := parsePointer(from)
+            if err != nil {
+                return nil, err
+            }
+            if name == "move" && strings.HasPrefix(path+"/", from+"/") && path != from {
+                return nil, unprocessablePatch("cannot move %s into one of its children", from)
+            }
+            if value, err = getPointer(doc, fromTokens); err != nil {
+                return nil, err
+            }
+            if name == "move" {
+                if doc, err = removePointer(doc, fromTokens); err != nil {
+                    return nil, err
+                }
+            } else {
+                value = deepCopy(value)
+            }
+        }
+        switch name {
+        case "add", "move", "copy":
+            doc, err = addPointer(doc, tokens, value, false)
+        case "replace":
+            doc, err = addPointer(doc, tokens, value, true)
+        case "remove":
+            doc, err = removePointer(doc, tokens)
+        case "test":
+            var actual interface{}
+            if actual, err = getPointer(doc, tokens); err == nil && !reflect.DeepEqual(actual, value) {
+                err = &patchError{status: http.StatusConflict, msg: fmt.Sprintf("test failed at %s", path)}
+            }
+        default:
+            err = badPatch("operation %d has unknown op %q", i, name)
+        }
+        if err != nil {
+            return nil, err
+        }
+    }
+    return doc, nil
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer into unescaped reference tokens
+func parsePointer(ptr string) ([]string, error) {
+    if ptr == "" {
+        return nil, nil
+    }
+    if !strings.HasPrefix(ptr, "/") {
+        return nil, badPatch("invalid JSON pointer %q", ptr)
+    }
+    tokens := strings.Split(ptr[1:], "/")
+    for i, token := range tokens {
+        tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+    }
+    return tokens, nil
+}
+
+// arrayIndex parses an array reference token, allowing size for appends
+func arrayIndex(token string, size int) (int, error) {
+    i, err := strconv.Atoi(token)
+    if err != nil || i < 0 || i > size || (len(token) > 1 && token[0] == '0') {
+        return 0, unprocessablePatch("invalid array index %q", token)
+    }
+    return i, nil
+}
+
+func getPointer(doc interface{}, tokens []string) (interface{}, error) {
+    for _, token := range tokens {
+        switch node := doc.(type) {
+        case map[string]interface{}:
+            value, ok := node[token]
+            if !ok {
+                return nil, unprocessablePatch("path member %q does not exist", token)
+            }
+            doc = value
+        case []interface{}:
+            i, err := arrayIndex(token, len(node)-1)
+            if err != nil {
+                return nil, err
+            }
+            doc = node[i]
+        default:
+            return nil, unprocessablePatch("cannot traverse into a scalar at %q", token)
+        }
+    }
+    return doc, nil
+}
+
+// addPointer inserts value at the location, or replaces an existing value when
+// replace is set, returning the possibly reallocated document
+func addPointer(doc interface{}, tokens []string, value interface{}, replace bool) (interface{}, error) {
+    if len(tokens) == 0 {
+        return value, nil
+    }
+    token := tokens[0]
+    switch node := doc.(type) {
+    case map[string]interface{}:
+        if len(tokens) == 1 {
+            if _, ok := node[token]; replace && !ok {
+                return nil, unprocessablePatch("path member %q does not exist", token)
+            }
+            node[token] = value
+            return node, nil
+        }
+        child, ok := node[token]
+        if !ok {
+            return nil, unprocessablePatch("path member %q does not exist", token)
+        }
+        updated, err := addPointer(child, tokens[1:], value, replace)
+        if err != nil {
+            return nil, err
+        }
+        node[token] = updated
+        return node, nil
+    case []interface{}:
+        if len(tokens) == 1 && !replace {
+            if token == "-" {
+                return append(node, value), nil
+            }
+            i, err := arrayIndex(token, len(node))
+            if err != nil {
+                return nil, err
+            }
+            node = append(node, nil)
+            copy(node[i+1:], node[i:])
+            node[i] = value
+            return node, nil
+        }
+        i, err := arrayIndex(token, len(node)-1)
+        if err != nil {
+            return nil, err
+        }
+        if len(tokens) == 1 {
+            node[i] = value
+            return node, nil
+        }
+        updated, err := addPointer(node[i], tokens[1:], value, replace)
+        if err != nil {
+            return nil, err
+        }
+        node[i] = updated
+        return node, nil
+    }
+    return nil, unprocessablePatch("cannot traverse into a scalar at %q", token)
+}
+
+// removePointer deletes the value at the location, which must exist
+func removePointer(doc interface{}, tokens []string) (interface{}, error) {
+    if len(tokens) == 0 {
+        return nil, unprocessablePatch("cannot remove the whole document")
+    }
+    token := tokens[0]
+    switch node := doc.(type) {
+    case map[string]interface{}:
+        child, ok := node[token]
+        if !ok {
+            return nil, unprocessablePatch("path member %q does not exist", token)
+        }
+        if len(tokens) == 1 {
+            delete(node, token)
+            return node, nil
+        }
+        updated, err := removePointer(child, tokens[1:])
+        if err != nil {
+            return nil, err
+        }
+        node[token] = updated
+        return node, nil
+    case []interface{}:
+        i, err := arrayIndex(token, len(node)-1)
+        if err != nil {
+            return nil, err
+        }
+        if len(tokens) == 1 {
+            return append(node[:i], node[i+1:]...), nil
+        }
+        updated, err := removePointer(node[i], tokens[1:])
+        if err != nil {
+            return nil, err
+        }
+        node[i] = updated
+        return node, nil
+    }
+    return nil, unprocessablePatch("cannot traverse into a scalar at %q", token)
+}
+
+// deepCopy clones a decoded JSON value so copies do not alias the source
+func deepCopy(value interface{}) interface{} {
+    data, _ := json.Marshal(value)
+    var clone interface{}
+    json.Unmarshal(data, &clone)
+    return clone
+}
+`)
+	return code.String()
+}
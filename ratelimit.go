@@ -0,0 +1,414 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// rateLimitSpec is an x-rate-limit extension: a token bucket holding burst
+// requests, refilled with requests tokens per period
+type rateLimitSpec struct {
+	Requests int64
+	Period   time.Duration
+	Burst    int64
+	Key      string // ip, apiKey or principal
+}
+
+// rateLimitPeriods names the periods accepted besides Go durations
+var rateLimitPeriods = map[string]time.Duration{
+	"second": time.Second,
+	"minute": time.Minute,
+	"hour":   time.Hour,
+	"day":    24 * time.Hour,
+}
+
+// parseRateLimit reads an x-rate-limit object such as
+// {"requests": 100, "period": "1m", "burst": 20, "key": "apiKey"}
+func parseRateLimit(raw interface{}) (rateLimitSpec, error) {
+	ext, ok := raw.(map[string]interface{})
+	if !ok {
+		return rateLimitSpec{}, fmt.Errorf("must be an object or false")
+	}
+	var limit rateLimitSpec
+	requests, _ := ext["requests"].(float64)
+	limit.Requests = int64(requests)
+	if limit.Requests <= 0 {
+		return limit, fmt.Errorf("requests must be a positive integer")
+	}
+	period, _ := ext["period"].(string)
+	if period == "" {
+		period = "minute"
+	}
+	limit.Period, ok = rateLimitPeriods[period]
+	if !ok {
+		d, err := time.ParseDuration(period)
+		if err != nil || d <= 0 {
+			return limit, fmt.Errorf("period %q must be a duration such as 1m or one of second, minute, hour, day", period)
+		}
+		limit.Period = d
+	}
+	limit.Burst = limit.Requests
+	if burst, ok := ext["burst"].(float64); ok {
+		limit.Burst = int64(burst)
+	}
+	if limit.Burst <= 0 {
+		return limit, fmt.Errorf("burst must be a positive integer")
+	}
+	limit.Key, _ = ext["key"].(string)
+	switch limit.Key {
+	case "":
+		limit.Key = "ip"
+	case "ip", "apiKey", "principal":
+	default:
+		return limit, fmt.Errorf("key %q must be ip, apiKey or principal", limit.Key)
+	}
+	return limit, nil
+}
+
+// operationRateLimit resolves the x-rate-limit of an operation or its path
+// item. exempt is set by x-rate-limit: false, which also lifts the global limit.
+func operationRateLimit(op operation) (limit *rateLimitSpec, exempt bool, err error) {
+	for _, scope := range []map[string]interface{}{op.Endpoint, op.PathItem} {
+		raw, ok := scope["x-rate-limit"]
+		if !ok {
+			continue
+		}
+		if b, ok := raw.(bool); ok && !b {
+			return nil, true, nil
+		}
+		parsed, err := parseRateLimit(raw)
+		if err != nil {
+			return nil, false, err
+		}
+		return &parsed, false, nil
+	}
+	return nil, false, nil
+}
+
+// checkRateLimits reports invalid x-rate-limit extensions before any code is
+// generated
+func checkRateLimits(spec *OpenAPISpec, ops []operation) error {
+	if raw, ok := spec.Extensions["x-rate-limit"]; ok {
+		if _, err := parseRateLimit(raw); err != nil {
+			return fmt.Errorf("x-rate-limit: %w", err)
+		}
+	}
+	for _, op := range ops {
+		if _, _, err := operationRateLimit(op); err != nil {
+			return fmt.Errorf("%s %s: x-rate-limit: %w", op.Method, op.Path, err)
+		}
+	}
+	return nil
+}
+
+// rateLimitLiteral renders a RateLimit composite literal
+func rateLimitLiteral(name string, limit rateLimitSpec) string {
+	period := fmt.Sprintf("%d * time.Millisecond", limit.Period.Milliseconds())
+	if limit.Period%time.Second == 0 {
+		period = fmt.Sprintf("%d * time.Second", limit.Period/time.Second)
+	}
+	return fmt.Sprintf("{Name: %q, Requests: %d, Period: %s, Burst: %d, Key: %q}", name, limit.Requests, period, limit.Burst, limit.Key)
+}
+
+// rateLimitsLiteral renders the limits applying to an operation: the global
+// one shared by every operation, then its own. It returns "" when none apply.
+func rateLimitsLiteral(op operation, spec *OpenAPISpec) string {
+	limit, exempt, _ := operationRateLimit(op)
+	if exempt {
+		return ""
+	}
+	var limits []string
+	if _, ok := spec.Extensions["x-rate-limit"]; ok {
+		limits = append(limits, "globalRateLimit")
+	}
+	if limit != nil {
+		limits = append(limits, rateLimitLiteral(op.OperationID, *limit))
+	}
+	if len(limits) == 0 {
+		return ""
+	}
+	return "[]*RateLimit{" + strings.Join(limits, ", ") + "}"
+}
+
+// generateRateLimitCode creates the token bucket rate limiter. Buckets live in
+// memory or in BadgerDB with a TTL, keyed by client IP, API key or principal.
+func generateRateLimitCode(spec *OpenAPISpec, schemes map[string]securityScheme) string {
+	var code strings.Builder
+	code.WriteString("package main\n\n")
+	code.WriteString("import (\n    \"context\"\n    \"crypto/sha256\"\n    \"encoding/binary\"\n    \"encoding/hex\"\n    \"fmt\"\n    \"math\"\n    \"net\"\n    \"net/http\"\n    \"strconv\"\n    \"strings\"\n    \"sync\"\n    \"time\"\n    \"github.com/dgraph-io/badger/v3\"\n)\n\n")
+	code.WriteString("// RateLimit is a token bucket per client holding Burst requests and refilled\n")
+	code.WriteString("// with Requests tokens per Period. Key selects how clients are told apart: ip,\n")
+	code.WriteString("// apiKey or principal; clients without the credential are limited by IP.\n")
+	code.WriteString("type RateLimit struct {\n")
+	code.WriteString("    Name     string\n")
+	code.WriteString("    Requests int64\n")
+	code.WriteString("    Period   time.Duration\n")
+	code.WriteString("    Burst    int64\n")
+	code.WriteString("    Key      string\n")
+	code.WriteString("}\n\n")
+	code.WriteString("// globalRateLimit is the quota from the root x-rate-limit, shared by every\n")
+	code.WriteString("// operation not exempted with x-rate-limit: false\n")
+	if raw, ok := spec.Extensions["x-rate-limit"]; ok {
+		limit, _ := parseRateLimit(raw)
+		code.WriteString(fmt.Sprintf("var globalRateLimit = &RateLimit%s\n\n", rateLimitLiteral("global", limit)))
+	} else {
+		code.WriteString("var globalRateLimit *RateLimit\n\n")
+	}
+	code.WriteString(`var (
+    // RateLimitStore keeps buckets in "memory", or in "badger" so limits
+    // survive restarts. BadgerDB is opened by one process at a time, so
+    // servers behind a load balancer each keep their own buckets.
+    RateLimitStore = "memory"
+    // TrustProxy takes client IPs from the last X-Forwarded-For entry, for
+    // servers running behind a reverse proxy that sets it
+    TrustProxy = false
+)
+
+// limitRate rejects requests exceeding any of the limits with 429 Too Many
+// Requests and Retry-After. RateLimit headers describe the limit closest to
+// being exhausted.
+func limitRate(next http.Handler, limits []*RateLimit) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        var tightest *RateLimit
+        var state bucketState
+        for _, limit := range limits {
+            s, err := takeToken(r.Context(), limit, clientKey(r, limit.Key))
+            if err != nil {
+                // Fail open: an unavailable store must not take the API down
+                LoggerFrom(r.Context()).Error("Rate limit store failed", "limit", limit.Name, "error", err)
+                continue
+            }
+            if tightest == nil || !s.Allowed || (state.Allowed && s.Tokens < state.Tokens) {
+                tightest, state = limit, s
+            }
+            if !s.Allowed {
+                break
+            }
+        }
+        if tightest == nil {
+            next.ServeHTTP(w, r)
+            return
+        }
+        h := w.Header()
+        h.Set("RateLimit-Limit", strconv.FormatInt(tightest.Requests, 10))
+        h.Set("RateLimit-Remaining", strconv.FormatInt(int64(state.Tokens), 10))
+        h.Set("RateLimit-Reset", strconv.FormatInt(ceilSeconds(tightest.fullAfter(state.Tokens)), 10))
+        h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d;burst=%d", tightest.Requests, ceilSeconds(tightest.Period), tightest.Burst))
+        if !state.Allowed {
+            h.Set("Retry-After", strconv.FormatInt(ceilSeconds(tightest.tokenAfter(state.Tokens)), 10))
+            http.Error(w, "Too many requests", http.StatusTooManyRequests)
+            return
+        }
+        next.ServeHTTP(w, r)
+    })
+}
+
+// bucketState is the outcome of taking a token from a bucket
+type bucketState struct {
+    Allowed bool
+    Tokens  float64 // tokens left after the request
+}
+
+// perToken is the time the bucket takes to regain one token
+func (l *RateLimit) perToken() time.Duration {
+    return l.Period / time.Duration(l.Requests)
+}
+
+// tokenAfter is how long a bucket holding tokens takes to allow a request
+func (l *RateLimit) tokenAfter(tokens float64) time.Duration {
+    return time.Duration((1 - tokens) * float64(l.perToken()))
+}
+
+// fullAfter is how long a bucket holding tokens takes to refill completely;
+// a bucket not touched for that long is the same as a new one
+func (l *RateLimit) fullAfter(tokens float64) time.Duration {
+    return time.Duration((float64(l.Burst) - tokens) * float64(l.perToken()))
+}
+
+// refill returns the tokens of a bucket last updated at updated, or a full
+// bucket when it has none
+func (l *RateLimit) refill(tokens float64, updated, now time.Time, found bool) float64 {
+    if !found {
+        return float64(l.Burst)
+    }
+    tokens += float64(now.Sub(updated)) / float64(l.perToken())
+    return math.Min(tokens, float64(l.Burst))
+}
+
+func ceilSeconds(d time.Duration) int64 {
+    return int64(math.Ceil(d.Seconds()))
+}
+
+// clientKey identifies the client a limit applies to. Credentials are hashed
+// so stored bucket keys do not reveal them.
+func clientKey(r *http.Request, by string) string {
+    id := "ip:" + clientIP(r)
+`)
+	if len(schemes) > 0 {
+		code.WriteString(`    switch by {
+    case "apiKey":
+        // Only credentials that passed authentication pick the bucket, or a
+        // client could get a fresh one by sending a new key each time
+        if p, ok := PrincipalFrom(r.Context()); ok {
+            if key := presentedKey(r, p.Scheme); key != "" {
+                id = "key:" + p.Scheme + ":" + key
+            }
+        }
+    case "principal":
+        if p, ok := PrincipalFrom(r.Context()); ok && p.Subject != "" {
+            id = "sub:" + p.Scheme + ":" + p.Subject
+        }
+    }
+`)
+	}
+	code.WriteString(`    sum := sha256.Sum256([]byte(id))
+    return hex.EncodeToString(sum[:16])
+}
+
+// clientIP returns the address of the client, or of the last proxy when
+// TrustProxy is off
+func clientIP(r *http.Request) string {
+    if TrustProxy {
+        if fwd := r.Header.Values("X-Forwarded-For"); len(fwd) > 0 {
+            hops := strings.Split(fwd[len(fwd)-1], ",")
+            if ip := strings.TrimSpace(hops[len(hops)-1]); ip != "" {
+                return ip
+            }
+        }
+    }
+    host, _, err := net.SplitHostPort(r.RemoteAddr)
+    if err != nil {
+        return r.RemoteAddr
+    }
+    return host
+}
+
+`)
+	if len(schemes) > 0 {
+		code.WriteString(`// presentedKey returns the credential a request carries for a scheme: the
+// API key of an apiKey scheme, otherwise the Authorization header
+func presentedKey(r *http.Request, name string) string {
+    scheme := securitySchemes[name]
+    if scheme.Type != "apiKey" {
+        return r.Header.Get("Authorization")
+    }
+    switch scheme.In {
+    case "header":
+        return r.Header.Get(scheme.Name)
+    case "query":
+        return r.URL.Query().Get(scheme.Name)
+    case "cookie":
+        if c, err := r.Cookie(scheme.Name); err == nil {
+            return c.Value
+        }
+    }
+    return ""
+}
+
+`)
+	}
+	code.WriteString(`// takeToken takes a token from the client's bucket in the configured store
+func takeToken(ctx context.Context, limit *RateLimit, client string) (bucketState, error) {
+    key := "_ratelimit:" + limit.Name + ":" + client
+    if RateLimitStore == "badger" {
+        return badgerBuckets.take(ctx, limit, key)
+    }
+    return memoryBuckets.take(limit, key), nil
+}
+
+// take applies a request to a bucket holding tokens
+func (l *RateLimit) take(tokens float64) bucketState {
+    if tokens < 1 {
+        return bucketState{Tokens: tokens}
+    }
+    return bucketState{Allowed: true, Tokens: tokens - 1}
+}
+
+// memoryBucket is a bucket of the in-memory store
+type memoryBucket struct {
+    tokens  float64
+    updated time.Time
+    expires time.Time // when the bucket is full again and can be dropped
+}
+
+// memoryStore keeps buckets in a map, dropping full ones once a minute
+type memoryStore struct {
+    mu        sync.Mutex
+    buckets   map[string]*memoryBucket
+    lastSweep time.Time
+}
+
+var memoryBuckets = &memoryStore{buckets: map[string]*memoryBucket{}}
+
+func (m *memoryStore) take(limit *RateLimit, key string) bucketState {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    now := time.Now()
+    if now.Sub(m.lastSweep) > time.Minute {
+        for k, b := range m.buckets {
+            if now.After(b.expires) {
+                delete(m.buckets, k)
+            }
+        }
+        m.lastSweep = now
+    }
+    b, found := m.buckets[key]
+    if !found {
+        b = &memoryBucket{}
+        m.buckets[key] = b
+    }
+    state := limit.take(limit.refill(b.tokens, b.updated, now, found))
+    b.tokens, b.updated = state.Tokens, now
+    b.expires = now.Add(limit.fullAfter(state.Tokens))
+    return state
+}
+
+// badgerStore keeps buckets in BadgerDB as the token count and update time,
+// expiring once the bucket would be full again
+type badgerStore struct{}
+
+var badgerBuckets badgerStore
+
+func (badgerStore) take(ctx context.Context, limit *RateLimit, key string) (bucketState, error) {
+    var state bucketState
+    var err error
+    // Concurrent requests of a client conflict on its bucket; retry them
+    for attempt := 0; attempt < 5; attempt++ {
+        err = dbUpdate(ctx, func(txn *badger.Txn) error {
+            now := time.Now()
+            var tokens float64
+            var updated time.Time
+            item, err := txn.Get([]byte(key))
+            found := err == nil
+            if found {
+                err = item.Value(func(v []byte) error {
+                    if len(v) != 16 {
+                        return fmt.Errorf("invalid bucket %s", key)
+                    }
+                    tokens = math.Float64frombits(binary.BigEndian.Uint64(v[:8]))
+                    updated = time.Unix(0, int64(binary.BigEndian.Uint64(v[8:])))
+                    return nil
+                })
+                if err != nil {
+                    return err
+                }
+            } else if err != badger.ErrKeyNotFound {
+                return err
+            }
+            state = limit.take(limit.refill(tokens, updated, now, found))
+            value := make([]byte, 16)
+            binary.BigEndian.PutUint64(value[:8], math.Float64bits(state.Tokens))
+            binary.BigEndian.PutUint64(value[8:], uint64(now.UnixNano()))
+            ttl := limit.fullAfter(state.Tokens) + time.Second
+            return txn.SetEntry(badger.NewEntry([]byte(key), value).WithTTL(ttl))
+        })
+        if err != badger.ErrConflict {
+            break
+        }
+    }
+    return state, err
+}
+`)
+	return code.String()
+}
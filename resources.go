@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// httpMethods lists the path item keys that describe operations, in the order
+// handlers are emitted
+var httpMethods = []string{"get", "post", "put", "patch", "delete"}
+
+// operation is a single method on a path from the OpenAPI paths object
+type operation struct {
+	Path        string
+	Method      string // upper-case HTTP method
+	OperationID string
+	HandlerName string
+	Endpoint    map[string]interface{}
+	PathItem    map[string]interface{}
+	Resource    resourcePath
+}
+
+// resourceSegment is one level of a resource hierarchy, e.g. "users/{userId}"
+type resourceSegment struct {
+	Collection string // static path segment naming the collection
+	Param      string // path parameter identifying a member, empty for the collection itself
+}
+
+// resourcePath describes the parent-child resources encoded in a path such as
+// /users/{userId}/posts/{postId}
+type resourcePath struct {
+	Segments []resourceSegment
+}
+
+// collectOperations flattens the paths object into operations sorted by path
+// and method so generated output is stable between runs
+func collectOperations(paths map[string]interface{}) []operation {
+	pathNames := make([]string, 0, len(paths))
+	for path := range paths {
+		pathNames = append(pathNames, path)
+	}
+	sort.Strings(pathNames)
+
+	var ops []operation
+	for _, path := range pathNames {
+		pathItem, _ := paths[path].(map[string]interface{})
+		for _, method := range httpMethods {
+			endpoint, ok := pathItem[method].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			operationID, _ := endpoint["operationId"].(string)
+			if operationID == "" {
+				operationID = fmt.Sprintf("%s%s", strings.ToUpper(method), strings.ReplaceAll(path, "/", ""))
+			}
+			ops = append(ops, operation{
+				Path:        path,
+				Method:      strings.ToUpper(method),
+				OperationID: operationID,
+				// Remove spaces from operationId to ensure valid Go identifier
+				HandlerName: toGoIdentifier(strings.ReplaceAll(operationID, " ", "")),
+				Endpoint:    endpoint,
+				PathItem:    pathItem,
+				Resource:    parseResourcePath(path),
+			})
+		}
+	}
+	return ops
+}
+
+// parseResourcePath detects the resource hierarchy of a path. A static segment
+// followed by a parameter is a collection member; static segments with no
+// parameter after them (e.g. an /api/v1 prefix) only contribute the last one as
+// the collection name.
+func parseResourcePath(path string) resourcePath {
+	var res resourcePath
+	var pending string
+	for _, part := range strings.Split(strings.Trim(path, "/"), "/") {
+		if part == "" {
+			continue
+		}
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			res.Segments = append(res.Segments, resourceSegment{Collection: pending, Param: strings.Trim(part, "{}")})
+			pending = ""
+			continue
+		}
+		pending = part
+	}
+	if pending != "" {
+		res.Segments = append(res.Segments, resourceSegment{Collection: pending})
+	}
+	return res
+}
+
+// IsItem reports whether the path addresses a single member rather than a collection
+func (r resourcePath) IsItem() bool {
+	return len(r.Segments) > 0 && r.Segments[len(r.Segments)-1].Param != ""
+}
+
+// Collection returns the name of the innermost collection
+func (r resourcePath) Collection() string {
+	if len(r.Segments) == 0 {
+		return ""
+	}
+	return r.Segments[len(r.Segments)-1].Collection
+}
+
+// Parents returns the member segments enclosing the innermost collection
+func (r resourcePath) Parents() []resourceSegment {
+	if len(r.Segments) == 0 {
+		return nil
+	}
+	return r.Segments[:len(r.Segments)-1]
+}
+
+// keyPrefix returns the BadgerDB key prefix of the collection, e.g. "posts:"
+func (r resourcePath) keyPrefix() string {
+	return strings.ToLower(r.Collection()) + ":"
+}
+
+// IsAncestorOf reports whether other is nested below the member addressed by r
+func (r resourcePath) IsAncestorOf(other resourcePath) bool {
+	if !r.IsItem() || len(other.Segments) <= len(r.Segments) {
+		return false
+	}
+	for i, seg := range r.Segments {
+		if !strings.EqualFold(seg.Collection, other.Segments[i].Collection) || other.Segments[i].Param == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// keyArgs renders the segments as arguments for the generated resourceKey
+// helper, alternating lower-cased collection names and path wildcard names
+func keyArgs(segments []resourceSegment) string {
+	args := make([]string, 0, len(segments)*2)
+	for _, seg := range segments {
+		args = append(args, fmt.Sprintf("%q", strings.ToLower(seg.Collection)), fmt.Sprintf("%q", wildcardName(seg.Param)))
+	}
+	return strings.Join(args, ", ")
+}
+
+// muxPattern converts an OpenAPI path into a net/http ServeMux pattern,
+// sanitising parameter names into valid wildcard identifiers
+func muxPattern(method, path string) string {
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			parts[i] = "{" + wildcardName(strings.Trim(part, "{}")) + "}"
+		}
+	}
+	return method + " " + strings.Join(parts, "/")
+}
+
+// wildcardName strips characters that ServeMux does not accept in wildcard names
+func wildcardName(param string) string {
+	var b strings.Builder
+	for i, c := range param {
+		if unicode.IsLetter(c) || c == '_' || (i > 0 && unicode.IsDigit(c)) {
+			b.WriteRune(c)
+		}
+	}
+	if b.Len() == 0 {
+		return "id"
+	}
+	return b.String()
+}
+
+// hasChildResources reports whether any operation is nested below res
+func hasChildResources(ops []operation, res resourcePath) bool {
+	for _, op := range ops {
+		if res.IsAncestorOf(op.Resource) {
+			return true
+		}
+	}
+	return false
+}
+
+// extensionBool looks up a boolean x- extension on the operation, then its
+// path item, then the root of the spec, so narrower scopes override wider ones
+func extensionBool(op operation, spec map[string]interface{}, name string) bool {
+	for _, scope := range []map[string]interface{}{op.Endpoint, op.PathItem, spec} {
+		if v, ok := scope[name].(bool); ok {
+			return v
+		}
+	}
+	return false
+}
+
+// extensionInt looks up an integer x- extension with the same scoping as
+// extensionBool
+func extensionInt(op operation, spec map[string]interface{}, name string) (int64, bool) {
+	for _, scope := range []map[string]interface{}{op.Endpoint, op.PathItem, spec} {
+		if v, ok := scope[name].(float64); ok {
+			return int64(v), true
+		}
+	}
+	return 0, false
+}
+
+// parameter is a path, query, header or cookie parameter of an operation
+type parameter struct {
+	Name     string
+	In       string
+	Required bool
+	Schema   map[string]interface{}
+}
+
+// operationParameters lists the parameters of an operation: path parameters
+// in the order of the path template, then the others as declared. Operation
+// parameters override path item ones with the same name and location, and
+// path variables the spec leaves undeclared are taken as strings.
+func operationParameters(op operation, components map[string]interface{}) []parameter {
+	declared := map[string]parameter{}
+	var order []string
+	for _, scope := range []map[string]interface{}{op.PathItem, op.Endpoint} {
+		list, _ := scope["parameters"].([]interface{})
+		for _, raw := range list {
+			def, _ := raw.(map[string]interface{})
+			if ref, _ := def["$ref"].(string); strings.HasPrefix(ref, "#/components/parameters/") {
+				params, _ := components["parameters"].(map[string]interface{})
+				def, _ = params[strings.TrimPrefix(ref, "#/components/parameters/")].(map[string]interface{})
+			}
+			var p parameter
+			p.Name, _ = def["name"].(string)
+			p.In, _ = def["in"].(string)
+			p.Required, _ = def["required"].(bool)
+			p.Schema, _ = def["schema"].(map[string]interface{})
+			if p.Name == "" || p.In == "" {
+				continue
+			}
+			key := p.In + ":" + p.Name
+			if _, ok := declared[key]; !ok {
+				order = append(order, key)
+			}
+			declared[key] = p
+		}
+	}
+	var params []parameter
+	for _, part := range strings.Split(op.Path, "/") {
+		if !strings.HasPrefix(part, "{") || !strings.HasSuffix(part, "}") {
+			continue
+		}
+		name := strings.Trim(part, "{}")
+		p, ok := declared["path:"+name]
+		if !ok {
+			p = parameter{Name: name, In: "path", Schema: map[string]interface{}{"type": "string"}}
+		}
+		p.Required = true
+		params = append(params, p)
+	}
+	for _, key := range order {
+		if p := declared[key]; p.In != "path" {
+			params = append(params, p)
+		}
+	}
+	return params
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// statusConstants maps status codes to their net/http constant names
+var statusConstants = map[string]string{
+	"200": "http.StatusOK",
+	"201": "http.StatusCreated",
+	"202": "http.StatusAccepted",
+	"204": "http.StatusNoContent",
+}
+
+// statusConst renders a status code for generated code
+func statusConst(status string) string {
+	if name, ok := statusConstants[status]; ok {
+		return name
+	}
+	return status
+}
+
+// successSpec is the response a handler sends when its operation succeeds
+type successSpec struct {
+	Status   string                 // status code sent to the client
+	Declared string                 // key under responses, e.g. "201" or "2XX"; empty when undeclared
+	Response map[string]interface{} // declared response object, nil when undeclared
+}
+
+// successResponse picks the success response of an operation: the first
+// declared candidate, else the lowest other declared 2xx code, else a 2XX
+// range answered with fallback. Operations declaring none use fallback.
+func successResponse(op operation, fallback string, candidates ...string) successSpec {
+	responses, _ := op.Endpoint["responses"].(map[string]interface{})
+	for _, status := range candidates {
+		if resp, ok := responses[status].(map[string]interface{}); ok {
+			return successSpec{Status: status, Declared: status, Response: resp}
+		}
+	}
+	var codes []string
+	for status := range responses {
+		if len(status) == 3 && status[0] == '2' && strings.Trim(status, "0123456789") == "" {
+			codes = append(codes, status)
+		}
+	}
+	sort.Strings(codes)
+	if len(codes) > 0 {
+		resp, _ := responses[codes[0]].(map[string]interface{})
+		return successSpec{Status: codes[0], Declared: codes[0], Response: resp}
+	}
+	for _, status := range []string{"2XX", "2xx"} {
+		if resp, ok := responses[status].(map[string]interface{}); ok {
+			return successSpec{Status: fallback, Declared: status, Response: resp}
+		}
+	}
+	return successSpec{Status: fallback}
+}
+
+// StructName resolves the Go struct for the declared JSON body, or "" when the
+// response declares no body
+func (s successSpec) StructName(op operation) string {
+	if s.Response == nil {
+		return ""
+	}
+	return schemaStructName(s.Response, fmt.Sprintf("%sResponse%s", op.HandlerName, s.Declared))
+}
+
+// jsonMediaType picks application/json, or else any +json media type, from a
+// content map
+func jsonMediaType(content map[string]interface{}) string {
+	if _, ok := content["application/json"]; ok {
+		return "application/json"
+	}
+	var types []string
+	for mediaType := range content {
+		if strings.HasSuffix(mediaType, "+json") {
+			types = append(types, mediaType)
+		}
+	}
+	sort.Strings(types)
+	if len(types) > 0 {
+		return types[0]
+	}
+	return ""
+}
+
+// writeEntityResponse emits a reply with the success status. A declared body
+// of the entity's own schema is decoded from the document held in docVar into
+// entity, its struct; bodies of other schemas send the stored document
+// unchanged, as decoding it into an unrelated struct would drop its fields.
+// Either is encoded in the negotiated media type; 204 and bodiless responses
+// only write the status.
+func writeEntityResponse(code *strings.Builder, op operation, spec successSpec, entity, docVar, indent string) {
+	structName := spec.StructName(op)
+	if spec.Status == "204" || structName == "" {
+		code.WriteString(fmt.Sprintf("%sw.WriteHeader(%s)\n", indent, statusConst(spec.Status)))
+		return
+	}
+	if structName != entity {
+		code.WriteString(fmt.Sprintf("%swriteDocument(w, r, %s, %q, json.RawMessage(%s))\n", indent, statusConst(spec.Status), structName, docVar))
+		return
+	}
+	code.WriteString(fmt.Sprintf("%svar resp %s\n", indent, structName))
+	code.WriteString(fmt.Sprintf("%sif err := json.Unmarshal(%s, &resp); err != nil {\n", indent, docVar))
+	code.WriteString(indent + "    http.Error(w, \"Failed to parse data\", http.StatusInternalServerError)\n")
+	code.WriteString(indent + "    return\n")
+	code.WriteString(indent + "}\n")
+	code.WriteString(fmt.Sprintf("%swriteDocument(w, r, %s, %q, resp)\n", indent, statusConst(spec.Status), structName))
+}
+
+// resourceID describes where a resource keeps its ID
+type resourceID struct {
+	Field   string // property holding the ID in stored documents, empty if none
+	Numeric bool   // the ID property is an integer
+	Param   string // path wildcard naming a member, empty without an item path
+}
+
+// findResourceID locates the ID property of a collection's documents by
+// looking for "id", the member path parameter, "ID" or "_id" in the given
+// schemas, and the member path parameter among sibling operations
+func findResourceID(ops []operation, res resourcePath, schemas map[string]Schema, structNames ...string) resourceID {
+	var id resourceID
+	for _, op := range ops {
+		if op.Resource.IsItem() && sameCollection(op.Resource, res) {
+			id.Param = wildcardName(op.Resource.Segments[len(op.Resource.Segments)-1].Param)
+			break
+		}
+	}
+	for _, structName := range structNames {
+		schema, ok := findSchema(schemas, structName)
+		if !ok {
+			continue
+		}
+		for _, field := range []string{"id", id.Param, "ID", "_id"} {
+			if prop, ok := schema.Properties[field].(map[string]interface{}); ok && field != "" {
+				propType, _ := prop["type"].(string)
+				id.Field, id.Numeric = field, propType == "integer"
+				return id
+			}
+		}
+	}
+	return id
+}
+
+// resourceIDFor finds the ID property of the documents op writes, checking
+// its success response, the stored entity and its request body in that order
+func resourceIDFor(ops []operation, op operation, schemas map[string]Schema, success successSpec) resourceID {
+	reqBody, _ := op.Endpoint["requestBody"].(map[string]interface{})
+	return findResourceID(ops, op.Resource, schemas,
+		success.StructName(op),
+		entitySchemaName(ops, op.Resource, schemas),
+		schemaStructName(reqBody, op.HandlerName+"Request"))
+}
+
+// writeIDInjection emits code storing the record's ID in its document so
+// responses and later reads include it
+func writeIDInjection(code *strings.Builder, id resourceID) {
+	if id.Field == "" {
+		return
+	}
+	code.WriteString(fmt.Sprintf("    data, err = withID(data, %q, id, %t)\n", id.Field, id.Numeric))
+	code.WriteString("    if err != nil {\n")
+	code.WriteString("        http.Error(w, \"Invalid ID\", http.StatusBadRequest)\n")
+	code.WriteString("        return\n    }\n")
+}
+
+// findSchema looks up a schema by its generated struct name
+func findSchema(schemas map[string]Schema, structName string) (Schema, bool) {
+	for name, schema := range schemas {
+		if toGoIdentifier(name) == structName {
+			return schema, true
+		}
+	}
+	return Schema{}, false
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// validateValue checks a decoded JSON value against a schema of the spec and
+// describes each mismatch with its location. Numbers are expected as
+// json.Number. Read-only properties are not required in requests, nor
+// write-only properties in responses.
+func validateValue(spec *OpenAPISpec, schema map[string]interface{}, value interface{}, path string, request bool) []string {
+	schema = resolveSchema(spec, schema)
+	if schema == nil {
+		return nil
+	}
+	if value == nil {
+		if nullable, _ := schema["nullable"].(bool); nullable || schemaAllowsType(schema, "null") || schema["type"] == nil {
+			return nil
+		}
+		return []string{path + " must not be null"}
+	}
+	if values, ok := schema["enum"].([]interface{}); ok && !enumContains(values, value) {
+		return []string{fmt.Sprintf("%s must be one of %s", path, compactJSON(values))}
+	}
+	var problems []string
+	if all, ok := schema["allOf"].([]interface{}); ok {
+		for _, sub := range all {
+			s, _ := sub.(map[string]interface{})
+			problems = append(problems, validateValue(spec, s, value, path, request)...)
+		}
+	}
+	for _, keyword := range []string{"oneOf", "anyOf"} {
+		variants, ok := schema[keyword].([]interface{})
+		if !ok {
+			continue
+		}
+		matched := false
+		for _, sub := range variants {
+			s, _ := sub.(map[string]interface{})
+			if len(validateValue(spec, s, value, path, request)) == 0 {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			problems = append(problems, path+" matches none of the "+keyword+" schemas")
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if !schemaAllowsType(schema, "object") {
+			return append(problems, path+" must be "+typeName(schema))
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		required, _ := schema["required"].([]interface{})
+		for _, name := range required {
+			n, _ := name.(string)
+			prop := resolveSchema(spec, mapValue(properties, n))
+			readOnly, _ := prop["readOnly"].(bool)
+			writeOnly, _ := prop["writeOnly"].(bool)
+			if (request && readOnly) || (!request && writeOnly) {
+				continue
+			}
+			if _, ok := v[n]; !ok {
+				problems = append(problems, path+"."+n+" is required")
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if prop, ok := properties[name].(map[string]interface{}); ok {
+				problems = append(problems, validateValue(spec, prop, v[name], path+"."+name, request)...)
+			} else if additional, ok := schema["additionalProperties"]; ok {
+				if allowed, isBool := additional.(bool); isBool && !allowed {
+					problems = append(problems, path+"."+name+" is not allowed")
+				} else if s, isSchema := additional.(map[string]interface{}); isSchema {
+					problems = append(problems, validateValue(spec, s, v[name], path+"."+name, request)...)
+				}
+			}
+		}
+	case []interface{}:
+		if !schemaAllowsType(schema, "array") {
+			return append(problems, path+" must be "+typeName(schema))
+		}
+		if min, ok := schema["minItems"].(float64); ok && float64(len(v)) < min {
+			problems = append(problems, fmt.Sprintf("%s must have at least %v items", path, min))
+		}
+		if max, ok := schema["maxItems"].(float64); ok && float64(len(v)) > max {
+			problems = append(problems, fmt.Sprintf("%s must have at most %v items", path, max))
+		}
+		items, _ := schema["items"].(map[string]interface{})
+		for i, item := range v {
+			problems = append(problems, validateValue(spec, items, item, fmt.Sprintf("%s[%d]", path, i), request)...)
+		}
+	case string:
+		if !schemaAllowsType(schema, "string") {
+			return append(problems, path+" must be "+typeName(schema))
+		}
+		if min, ok := schema["minLength"].(float64); ok && float64(len([]rune(v))) < min {
+			problems = append(problems, fmt.Sprintf("%s must be at least %v characters", path, min))
+		}
+		if max, ok := schema["maxLength"].(float64); ok && float64(len([]rune(v))) > max {
+			problems = append(problems, fmt.Sprintf("%s must be at most %v characters", path, max))
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(v) {
+				problems = append(problems, path+" must match "+pattern)
+			}
+		}
+	case json.Number:
+		f, _ := v.Float64()
+		switch {
+		case schemaAllowsType(schema, "number"):
+		case schemaAllowsType(schema, "integer"):
+			if strings.ContainsAny(v.String(), ".eE") && f != float64(int64(f)) {
+				return append(problems, path+" must be an integer")
+			}
+		default:
+			return append(problems, path+" must be "+typeName(schema))
+		}
+		if min, ok := schema["minimum"].(float64); ok && f < min {
+			problems = append(problems, fmt.Sprintf("%s must be at least %v", path, min))
+		}
+		if max, ok := schema["maximum"].(float64); ok && f > max {
+			problems = append(problems, fmt.Sprintf("%s must be at most %v", path, max))
+		}
+	case bool:
+		if !schemaAllowsType(schema, "boolean") {
+			return append(problems, path+" must be "+typeName(schema))
+		}
+	}
+	return problems
+}
+
+// schemaAllowsType reports whether a schema accepts values of a JSON type;
+// schemas without a type accept anything
+func schemaAllowsType(schema map[string]interface{}, t string) bool {
+	switch declared := schema["type"].(type) {
+	case string:
+		return declared == t || (declared == "number" && t == "integer")
+	case []interface{}:
+		for _, d := range declared {
+			if d == t || (d == "number" && t == "integer") {
+				return true
+			}
+		}
+		return false
+	}
+	return t != "null"
+}
+
+func typeName(schema map[string]interface{}) string {
+	switch t := schema["type"].(type) {
+	case string:
+		return "of type " + t
+	case []interface{}:
+		return "of type " + compactJSON(t)
+	}
+	return "valid"
+}
+
+func enumContains(values []interface{}, value interface{}) bool {
+	want := compactJSON(value)
+	for _, v := range values {
+		if compactJSON(v) == want {
+			return true
+		}
+	}
+	return false
+}
+
+// compactJSON encodes a value, normalising json.Number and float64 values
+// so equal numbers compare equal
+func compactJSON(v interface{}) string {
+	data, _ := json.Marshal(v)
+	var normal interface{}
+	json.Unmarshal(data, &normal)
+	data, _ = json.Marshal(normal)
+	return string(data)
+}
@@ -0,0 +1,564 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// securityScheme is an entry of components.securitySchemes
+type securityScheme struct {
+	Type   string // apiKey, http, oauth2 or openIdConnect
+	Scheme string // http authentication scheme, e.g. "basic" or "bearer"
+	In     string // apiKey location: header, query or cookie
+	Name   string // apiKey header, query parameter or cookie name
+}
+
+// extractSecuritySchemes reads components.securitySchemes
+func extractSecuritySchemes(components map[string]interface{}) map[string]securityScheme {
+	schemes := make(map[string]securityScheme)
+	raw, _ := components["securitySchemes"].(map[string]interface{})
+	for name, v := range raw {
+		def, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		var s securityScheme
+		s.Type, _ = def["type"].(string)
+		s.Scheme, _ = def["scheme"].(string)
+		s.Scheme = strings.ToLower(s.Scheme)
+		s.In, _ = def["in"].(string)
+		s.Name, _ = def["name"].(string)
+		schemes[name] = s
+	}
+	return schemes
+}
+
+// operationSecurity returns the security requirements of an operation: its own
+// security field when present (an empty list makes it public), else the root
+// security of the spec. Requirements naming undeclared schemes are dropped;
+// checkSecurity refuses to generate a server for such a spec.
+func operationSecurity(op operation, spec *OpenAPISpec, schemes map[string]securityScheme) []map[string][]string {
+	reqs := spec.Security
+	if raw, ok := op.Endpoint["security"].([]interface{}); ok {
+		reqs = nil
+		for _, r := range raw {
+			entry, _ := r.(map[string]interface{})
+			req := make(map[string][]string)
+			for name, scopes := range entry {
+				list, _ := scopes.([]interface{})
+				req[name] = []string{}
+				for _, scope := range list {
+					if s, ok := scope.(string); ok {
+						req[name] = append(req[name], s)
+					}
+				}
+			}
+			reqs = append(reqs, req)
+		}
+	}
+	var valid []map[string][]string
+	for _, req := range reqs {
+		known := true
+		for name := range req {
+			if _, ok := schemes[name]; !ok {
+				known = false
+			}
+		}
+		if known {
+			valid = append(valid, req)
+		}
+	}
+	return valid
+}
+
+// checkSecurity reports security requirements naming a scheme the spec does
+// not declare before any code is generated. Dropping them would leave the
+// operation without the authentication it asks for.
+func checkSecurity(spec *OpenAPISpec, ops []operation) error {
+	schemes := extractSecuritySchemes(spec.Components)
+	for _, req := range spec.Security {
+		names := make([]string, 0, len(req))
+		for name := range req {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if _, ok := schemes[name]; !ok {
+				return fmt.Errorf("security: unknown security scheme %q", name)
+			}
+		}
+	}
+	for _, op := range ops {
+		raw, _ := op.Endpoint["security"].([]interface{})
+		for _, r := range raw {
+			entry, _ := r.(map[string]interface{})
+			for _, name := range sortedKeys(entry) {
+				if _, ok := schemes[name]; !ok {
+					return fmt.Errorf("%s %s: security: unknown security scheme %q", op.Method, op.Path, name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// securityLiteral renders requirements as a generated []securityRequirement
+func securityLiteral(reqs []map[string][]string) string {
+	alternatives := make([]string, 0, len(reqs))
+	for _, req := range reqs {
+		names := make([]string, 0, len(req))
+		for name := range req {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		schemes := make([]string, 0, len(names))
+		for _, name := range names {
+			scopes := make([]string, 0, len(req[name]))
+			for _, scope := range req[name] {
+				scopes = append(scopes, fmt.Sprintf("%q", scope))
+			}
+			schemes = append(schemes, fmt.Sprintf("{%q, []string{%s}}", name, strings.Join(scopes, ", ")))
+		}
+		alternatives = append(alternatives, "{"+strings.Join(schemes, ", ")+"}")
+	}
+	return "[]securityRequirement{" + strings.Join(alternatives, ", ") + "}"
+}
+
+// envName turns a scheme name into the prefix of its environment variables,
+// e.g. "bearerAuth" becomes "BEARER_AUTH"
+func envName(name string) string {
+	var b strings.Builder
+	for i, c := range name {
+		switch {
+		case c >= 'A' && c <= 'Z':
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(c)
+		case c >= 'a' && c <= 'z':
+			b.WriteRune(c - 'a' + 'A')
+		case c >= '0' && c <= '9':
+			b.WriteRune(c)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+// generateSecurityCode creates the authentication middleware for the declared
+// security schemes. Credentials are checked against environment variables
+// named after each scheme unless the application replaces the Validate hooks.
+func generateSecurityCode(schemes map[string]securityScheme) string {
+	names := make([]string, 0, len(schemes))
+	for name := range schemes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var code strings.Builder
+	code.WriteString("package main\n\n")
+	code.WriteString("import (\n    \"context\"\n    \"crypto\"\n    \"crypto/hmac\"\n    \"crypto/rsa\"\n    \"crypto/sha256\"\n    \"crypto/subtle\"\n    \"crypto/x509\"\n    \"encoding/base64\"\n    \"encoding/json\"\n    \"encoding/pem\"\n    \"errors\"\n    \"fmt\"\n    \"math/big\"\n    \"net/http\"\n    \"os\"\n    \"strings\"\n    \"sync\"\n    \"time\"\n)\n\n")
+	code.WriteString("// securitySchemes lists components.securitySchemes by name\n")
+	code.WriteString("var securitySchemes = map[string]securityScheme{\n")
+	for _, name := range names {
+		s := schemes[name]
+		code.WriteString(fmt.Sprintf("    %q: {Type: %q, Scheme: %q, In: %q, Name: %q, Env: %q},\n", name, s.Type, s.Scheme, s.In, s.Name, envName(name)))
+	}
+	code.WriteString("}\n\n")
+	code.WriteString(`// securityScheme describes how a scheme carries credentials. Env prefixes the
+// environment variables holding its keys, e.g. BEARER_AUTH_JWT_KEY_FILE.
+type securityScheme struct {
+    Type   string
+    Scheme string
+    In     string
+    Name   string
+    Env    string
+}
+
+// schemeRequirement names a scheme and the scopes it must grant
+type schemeRequirement struct {
+    Scheme string
+    Scopes []string
+}
+
+// securityRequirement is satisfied when every listed scheme authenticates;
+// an empty requirement allows anonymous access
+type securityRequirement []schemeRequirement
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+    Subject string
+    Scheme  string
+    Scopes  []string
+    Claims  map[string]interface{}
+}
+
+type principalKey struct{}
+
+// PrincipalFrom returns the principal authenticated for the request, if any
+func PrincipalFrom(ctx context.Context) (*Principal, bool) {
+    p, ok := ctx.Value(principalKey{}).(*Principal)
+    return p, ok
+}
+
+var (
+    errNoCredentials      = errors.New("no credentials")
+    errInvalidCredentials = errors.New("invalid credentials")
+    errInsufficientScope  = errors.New("insufficient scope")
+)
+
+// ValidateAPIKey checks an API key. By default keys are read from the
+// comma-separated <SCHEME>_API_KEYS environment variable.
+var ValidateAPIKey = func(scheme, key string) (*Principal, error) {
+    for _, valid := range strings.Split(os.Getenv(securitySchemes[scheme].Env+"_API_KEYS"), ",") {
+        if valid != "" && subtle.ConstantTimeCompare([]byte(valid), []byte(key)) == 1 {
+            return &Principal{Subject: "apikey", Scheme: scheme}, nil
+        }
+    }
+    return nil, errInvalidCredentials
+}
+
+// ValidateBasic checks HTTP basic credentials. By default users are read from
+// the comma-separated user:password pairs in <SCHEME>_USERS.
+var ValidateBasic = func(scheme, user, password string) (*Principal, error) {
+    want := []byte(user + ":" + password)
+    for _, valid := range strings.Split(os.Getenv(securitySchemes[scheme].Env+"_USERS"), ",") {
+        if valid != "" && subtle.ConstantTimeCompare([]byte(valid), want) == 1 {
+            return &Principal{Subject: user, Scheme: scheme}, nil
+        }
+    }
+    return nil, errInvalidCredentials
+}
+
+// requireAuth admits requests meeting any of the requirements and stores the
+// principal in the request context. Requests without valid credentials get
+// 401; authenticated requests lacking a scope get 403.
+func requireAuth(next http.Handler, reqs []securityRequirement) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        forbidden := false
+        for _, req := range reqs {
+            principal, err := authenticateAll(r, req)
+            if err == nil {
+                if principal != nil {
+                    r = r.WithContext(context.WithValue(r.Context(), principalKey{}, principal))
+                }
+                next.ServeHTTP(w, r)
+                return
+            }
+            if err == errInsufficientScope {
+                forbidden = true
+            }
+        }
+        if forbidden {
+            http.Error(w, "Forbidden", http.StatusForbidden)
+            return
+        }
+        for _, req := range reqs {
+            for _, s := range req {
+                switch scheme := securitySchemes[s.Scheme]; {
+                case scheme.Type == "http" && scheme.Scheme == "basic":
+                    w.Header().Add("WWW-Authenticate", "Basic realm=\"api\"")
+                case scheme.Type != "apiKey":
+                    w.Header().Add("WWW-Authenticate", "Bearer")
+                }
+            }
+        }
+        http.Error(w, "Unauthorized", http.StatusUnauthorized)
+    })
+}
+
+// authenticateAll authenticates every scheme of a requirement, returning the
+// first principal; nil means the requirement allows anonymous access
+func authenticateAll(r *http.Request, req securityRequirement) (*Principal, error) {
+    var first *Principal
+    for _, s := range req {
+        p, err := authenticate(r, s.Scheme)
+        if err != nil {
+            return nil, err
+        }
+        for _, scope := range s.Scopes {
+            if !hasScope(p.Scopes, scope) {
+                return nil, errInsufficientScope
+            }
+        }
+        if first == nil {
+            first = p
+        }
+    }
+    return first, nil
+}
+
+func hasScope(scopes []string, scope string) bool {
+    for _, s := range scopes {
+        if s == scope {
+            return true
+        }
+    }
+    return false
+}
+
+// authenticate extracts and verifies the credentials of one scheme
+func authenticate(r *http.Request, name string) (*Principal, error) {
+    scheme := securitySchemes[name]
+    switch {
+    case scheme.Type == "apiKey":
+        var key string
+        switch scheme.In {
+        case "header":
+            key = r.Header.Get(scheme.Name)
+        case "query":
+            key = r.URL.Query().Get(scheme.Name)
+        case "cookie":
+            if c, err := r.Cookie(scheme.Name); err == nil {
+                key = c.Value
+            }
+        }
+        if key == "" {
+            return nil, errNoCredentials
+        }
+        return ValidateAPIKey(name, key)
+    case scheme.Type == "http" && scheme.Scheme == "basic":
+        user, password, ok := r.BasicAuth()
+        if !ok {
+            return nil, errNoCredentials
+        }
+        return ValidateBasic(name, user, password)
+    default:
+        // bearer, oauth2 and openIdConnect all carry a JWT access token
+        auth := r.Header.Get("Authorization")
+        if len(auth) < 7 || !strings.EqualFold(auth[:7], "Bearer ") {
+            return nil, errNoCredentials
+        }
+        claims, err := verifyJWT(name, strings.TrimSpace(auth[7:]))
+        if err != nil {
+            return nil, err
+        }
+        p := &Principal{Scheme: name, Claims: claims}
+        p.Subject, _ = claims["sub"].(string)
+        p.Scopes = claimScopes(claims)
+        return p, nil
+    }
+}
+
+// claimScopes reads OAuth2 scopes from a space-separated "scope" claim or an
+// "scp" array
+func claimScopes(claims map[string]interface{}) []string {
+    if s, ok := claims["scope"].(string); ok {
+        return strings.Fields(s)
+    }
+    var scopes []string
+    if list, ok := claims["scp"].([]interface{}); ok {
+        for _, v := range list {
+            if s, ok := v.(string); ok {
+                scopes = append(scopes, s)
+            }
+        }
+    }
+    return scopes
+}
+
+// jwtKeys holds the verification keys of a scheme, loaded on first use from
+// <SCHEME>_JWT_KEY_FILE (an HS256 secret or RSA public key PEM) and/or
+// <SCHEME>_JWKS_FILE (a JSON Web Key Set)
+type jwtKeys struct {
+    secret []byte
+    rsa    map[string]*rsa.PublicKey // by kid; "" holds the key file's key
+    err    error
+}
+
+var (
+    jwtKeysMu    sync.Mutex
+    jwtKeysCache = map[string]*jwtKeys{}
+)
+
+func loadJWTKeys(name string) *jwtKeys {
+    jwtKeysMu.Lock()
+    defer jwtKeysMu.Unlock()
+    if keys, ok := jwtKeysCache[name]; ok {
+        return keys
+    }
+    keys := &jwtKeys{rsa: map[string]*rsa.PublicKey{}}
+    env := securitySchemes[name].Env
+    if path := os.Getenv(env + "_JWT_KEY_FILE"); path != "" {
+        data, err := os.ReadFile(path)
+        if err != nil {
+            keys.err = err
+        } else if block, _ := pem.Decode(data); block != nil {
+            keys.rsa[""], keys.err = parseRSAPublicKey(block)
+        } else {
+            keys.secret = []byte(strings.TrimSpace(string(data)))
+        }
+    }
+    if path := os.Getenv(env + "_JWKS_FILE"); path != "" && keys.err == nil {
+        keys.err = loadJWKS(path, keys)
+    }
+    if keys.err == nil && keys.secret == nil && len(keys.rsa) == 0 {
+        keys.err = fmt.Errorf("no JWT keys configured; set %s_JWT_KEY_FILE or %s_JWKS_FILE", env, env)
+    }
+    jwtKeysCache[name] = keys
+    return keys
+}
+
+func parseRSAPublicKey(block *pem.Block) (*rsa.PublicKey, error) {
+    switch block.Type {
+    case "CERTIFICATE":
+        cert, err := x509.ParseCertificate(block.Bytes)
+        if err != nil {
+            return nil, err
+        }
+        if key, ok := cert.PublicKey.(*rsa.PublicKey); ok {
+            return key, nil
+        }
+    case "RSA PUBLIC KEY":
+        return x509.ParsePKCS1PublicKey(block.Bytes)
+    default:
+        key, err := x509.ParsePKIXPublicKey(block.Bytes)
+        if err != nil {
+            return nil, err
+        }
+        if key, ok := key.(*rsa.PublicKey); ok {
+            return key, nil
+        }
+    }
+    return nil, errors.New("JWT key is not an RSA public key")
+}
+
+// loadJWKS adds the RSA and symmetric keys of a JSON Web Key Set
+func loadJWKS(path string, keys *jwtKeys) error {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return err
+    }
+    var set struct {
+        Keys []map[string]interface{}
+    }
+    if err := json.Unmarshal(data, &set); err != nil {
+        return fmt.Errorf("invalid JWKS: %v", err)
+    }
+    for _, jwk := range set.Keys {
+        field := func(name string) string {
+            s, _ := jwk[name].(string)
+            return s
+        }
+        switch field("kty") {
+        case "RSA":
+            n, err1 := base64.RawURLEncoding.DecodeString(field("n"))
+            e, err2 := base64.RawURLEncoding.DecodeString(field("e"))
+            if err1 != nil || err2 != nil {
+                return errors.New("invalid RSA key in JWKS")
+            }
+            keys.rsa[field("kid")] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+        case "oct":
+            secret, err := base64.RawURLEncoding.DecodeString(field("k"))
+            if err != nil {
+                return errors.New("invalid symmetric key in JWKS")
+            }
+            keys.secret = secret
+        }
+    }
+    return nil
+}
+
+// verifyJWT checks the signature and validity period of a compact JWT and
+// returns its claims. The algorithm must match the configured key type, so an
+// RSA public key can never be used as an HMAC secret.
+func verifyJWT(name, token string) (map[string]interface{}, error) {
+    parts := strings.Split(token, ".")
+    if len(parts) != 3 {
+        return nil, errInvalidCredentials
+    }
+    var header struct {
+        Alg string
+        Kid string
+    }
+    if err := decodeSegment(parts[0], &header); err != nil {
+        return nil, errInvalidCredentials
+    }
+    sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+    if err != nil {
+        return nil, errInvalidCredentials
+    }
+    keys := loadJWTKeys(name)
+    if keys.err != nil {
+        return nil, keys.err
+    }
+    signed := []byte(parts[0] + "." + parts[1])
+    switch header.Alg {
+    case "HS256":
+        if keys.secret == nil {
+            return nil, errInvalidCredentials
+        }
+        mac := hmac.New(sha256.New, keys.secret)
+        mac.Write(signed)
+        if !hmac.Equal(sig, mac.Sum(nil)) {
+            return nil, errInvalidCredentials
+        }
+    case "RS256":
+        key := keys.rsa[header.Kid]
+        if key == nil {
+            key = keys.rsa[""]
+        }
+        if key == nil && header.Kid == "" && len(keys.rsa) == 1 {
+            // tokens without a kid may use the only key of the set
+            for _, only := range keys.rsa {
+                key = only
+            }
+        }
+        if key == nil {
+            return nil, errInvalidCredentials
+        }
+        digest := sha256.Sum256(signed)
+        if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) != nil {
+            return nil, errInvalidCredentials
+        }
+    default:
+        return nil, errInvalidCredentials
+    }
+    var claims map[string]interface{}
+    if err := decodeSegment(parts[1], &claims); err != nil {
+        return nil, errInvalidCredentials
+    }
+    now := float64(time.Now().Unix())
+    if exp, ok := claims["exp"].(float64); ok && now >= exp {
+        return nil, errInvalidCredentials
+    }
+    if nbf, ok := claims["nbf"].(float64); ok && now < nbf {
+        return nil, errInvalidCredentials
+    }
+    env := securitySchemes[name].Env
+    if iss := os.Getenv(env + "_JWT_ISSUER"); iss != "" && claims["iss"] != iss {
+        return nil, errInvalidCredentials
+    }
+    if aud := os.Getenv(env + "_JWT_AUDIENCE"); aud != "" && !hasAudience(claims["aud"], aud) {
+        return nil, errInvalidCredentials
+    }
+    return claims, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+    data, err := base64.RawURLEncoding.DecodeString(segment)
+    if err != nil {
+        return err
+    }
+    return json.Unmarshal(data, v)
+}
+
+// hasAudience matches an "aud" claim, which may be a string or a list
+func hasAudience(claim interface{}, aud string) bool {
+    switch v := claim.(type) {
+    case string:
+        return v == aud
+    case []interface{}:
+        for _, a := range v {
+            if a == aud {
+                return true
+            }
+        }
+    }
+    return false
+}
+`)
+	return code.String()
+}
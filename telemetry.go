@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// generateTelemetryCode creates structured logging with log/slog and optional
+// OpenTelemetry tracing of operations and BadgerDB transactions
+func generateTelemetryCode(spec *OpenAPISpec) string {
+	title, _ := spec.Info["title"].(string)
+	if title == "" {
+		title = "generated"
+	}
+	var code strings.Builder
+	code.WriteString("package main\n\n")
+	code.WriteString("import (\n    \"context\"\n    \"fmt\"\n    \"log/slog\"\n    \"net/http\"\n    \"os\"\n    \"github.com/dgraph-io/badger/v3\"\n    \"go.opentelemetry.io/otel\"\n    \"go.opentelemetry.io/otel/attribute\"\n    \"go.opentelemetry.io/otel/codes\"\n    \"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp\"\n    \"go.opentelemetry.io/otel/exporters/stdout/stdouttrace\"\n    \"go.opentelemetry.io/otel/propagation\"\n    sdktrace \"go.opentelemetry.io/otel/sdk/trace\"\n    \"go.opentelemetry.io/otel/trace\"\n)\n\n")
+	code.WriteString("// tracer names the spans of this server; it records nothing until tracing is set up\n")
+	code.WriteString(fmt.Sprintf("var tracer = otel.Tracer(%q)\n\n", title))
+	code.WriteString(`// LogLevel is the least severe level that is logged
+var LogLevel = new(slog.LevelVar)
+
+// setupLogging installs the default slog logger, writing text or JSON to stderr
+func setupLogging(format string) {
+    opts := &slog.HandlerOptions{Level: LogLevel}
+    var handler slog.Handler = slog.NewTextHandler(os.Stderr, opts)
+    if format == "json" {
+        handler = slog.NewJSONHandler(os.Stderr, opts)
+    }
+    slog.SetDefault(slog.New(handler))
+}
+
+// requestInfo collects the per-request fields attached to log lines. The
+// route fills in the operation and trace once the request has been matched.
+type requestInfo struct {
+    ID          string
+    OperationID string
+    TraceID     string
+}
+
+type requestInfoKey struct{}
+
+func requestInfoFrom(ctx context.Context) *requestInfo {
+    info, _ := ctx.Value(requestInfoKey{}).(*requestInfo)
+    if info == nil {
+        return &requestInfo{}
+    }
+    return info
+}
+
+// RequestIDFrom returns the ID assigned to the request by the requestID middleware
+func RequestIDFrom(ctx context.Context) string {
+    return requestInfoFrom(ctx).ID
+}
+
+// LoggerFrom returns the default logger with the request's ID, operation and
+// trace ID attached, for use in handlers and middleware
+func LoggerFrom(ctx context.Context) *slog.Logger {
+    return slog.Default().With(requestAttrs(ctx)...)
+}
+
+func requestAttrs(ctx context.Context) []any {
+    info := requestInfoFrom(ctx)
+    attrs := []any{slog.String("request_id", info.ID)}
+    if info.OperationID != "" {
+        attrs = append(attrs, slog.String("operationId", info.OperationID))
+    }
+    if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+        attrs = append(attrs, slog.String("trace_id", sc.TraceID().String()))
+    } else if info.TraceID != "" {
+        attrs = append(attrs, slog.String("trace_id", info.TraceID))
+    }
+    return attrs
+}
+
+// setupTracing exports spans with OTLP over HTTP (configured by endpoint or
+// the standard OTEL_EXPORTER_OTLP_* variables) or to stdout. An empty exporter
+// leaves tracing disabled. The returned function flushes pending spans.
+func setupTracing(ctx context.Context, exporter, endpoint string) (func(context.Context) error, error) {
+    var exp sdktrace.SpanExporter
+    var err error
+    switch exporter {
+    case "":
+        return func(context.Context) error { return nil }, nil
+    case "otlp":
+        var opts []otlptracehttp.Option
+        if endpoint != "" {
+            opts = append(opts, otlptracehttp.WithEndpointURL(endpoint))
+        }
+        exp, err = otlptracehttp.New(ctx, opts...)
+    case "stdout":
+        exp, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+    default:
+        err = fmt.Errorf("unknown trace exporter %q", exporter)
+    }
+    if err != nil {
+        return nil, err
+    }
+    return SetupTracing(sdktrace.WithBatcher(exp)), nil
+}
+
+// SetupTracing installs a tracer provider using the given span processor and
+// returns its shutdown function. Tests can record spans in memory with
+//
+//     exporter := tracetest.NewInMemoryExporter()
+//     defer SetupTracing(sdktrace.WithSyncer(exporter))(context.Background())
+func SetupTracing(processor sdktrace.TracerProviderOption) func(context.Context) error {
+    provider := sdktrace.NewTracerProvider(processor)
+    otel.SetTracerProvider(provider)
+    otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+    return provider.Shutdown
+}
+
+// traceOperation starts a server span for an operation, continuing any trace
+// propagated by the caller, and records the operation on the request
+func traceOperation(next http.Handler, operationID, pattern string) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+        ctx, span := tracer.Start(ctx, operationID,
+            trace.WithSpanKind(trace.SpanKindServer),
+            trace.WithAttributes(
+                attribute.String("http.request.method", r.Method),
+                attribute.String("http.route", pattern),
+                attribute.String("request.id", RequestIDFrom(ctx)),
+            ))
+        defer span.End()
+        info := requestInfoFrom(ctx)
+        info.OperationID = operationID
+        if sc := span.SpanContext(); sc.HasTraceID() {
+            info.TraceID = sc.TraceID().String()
+        }
+        rec := &statusRecorder{ResponseWriter: w}
+        next.ServeHTTP(rec, r.WithContext(ctx))
+        if rec.status == 0 {
+            rec.status = http.StatusOK
+        }
+        span.SetAttributes(attribute.Int("http.response.status_code", rec.status))
+        if rec.status >= 500 {
+            span.SetStatus(codes.Error, http.StatusText(rec.status))
+        }
+    })
+}
+
+// dbUpdate runs a read-write BadgerDB transaction in a span
+func dbUpdate(ctx context.Context, fn func(txn *badger.Txn) error) error {
+    return traceTxn(ctx, "badger.Update", func() error { return DB.Update(fn) })
+}
+
+// dbView runs a read-only BadgerDB transaction in a span
+func dbView(ctx context.Context, fn func(txn *badger.Txn) error) error {
+    return traceTxn(ctx, "badger.View", func() error { return DB.View(fn) })
+}
+
+func traceTxn(ctx context.Context, name string, run func() error) error {
+    _, span := tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindInternal), trace.WithAttributes(attribute.String("db.system", "badger")))
+    defer span.End()
+    err := run()
+    if err != nil && err != badger.ErrKeyNotFound {
+        span.RecordError(err)
+        span.SetStatus(codes.Error, err.Error())
+    }
+    return err
+}
+`)
+	return code.String()
+}
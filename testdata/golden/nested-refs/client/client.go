@@ -0,0 +1,457 @@
+// Package client is a generated Go client for Library API.
+package client
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "math/rand/v2"
+    "net/http"
+    "net/url"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Client calls Library API. Methods retry idempotent requests that fail with a
+// network error, 429, 502, 503 or 504, following Retry.
+type Client struct {
+    BaseURL     string
+    HTTPClient  *http.Client
+    Retry       RetryPolicy
+    credentials map[string]func(*http.Request)
+    editors     []RequestEditor
+}
+
+// RetryPolicy sets how often a request is attempted and how long to wait in
+// between: exponential backoff from MinBackoff up to MaxBackoff, with jitter,
+// or the server's Retry-After when longer
+type RetryPolicy struct {
+    MaxAttempts int
+    MinBackoff  time.Duration
+    MaxBackoff  time.Duration
+}
+
+// DefaultRetry is the retry policy of new clients
+var DefaultRetry = RetryPolicy{MaxAttempts: 3, MinBackoff: 100 * time.Millisecond, MaxBackoff: 2 * time.Second}
+
+// RequestEditor changes requests before they are sent, e.g. to add headers
+type RequestEditor func(*http.Request) error
+
+// Option configures a Client
+type Option func(*Client)
+
+// New returns a client for the API served at baseURL, e.g. http://localhost:8080
+func New(baseURL string, opts ...Option) (*Client, error) {
+    u, err := url.Parse(baseURL)
+    if err != nil || u.Scheme == "" || u.Host == "" {
+        return nil, fmt.Errorf("invalid base URL %q", baseURL)
+    }
+    c := &Client{
+        BaseURL:     strings.TrimSuffix(baseURL, "/"),
+        HTTPClient:  http.DefaultClient,
+        Retry:       DefaultRetry,
+        credentials: map[string]func(*http.Request){},
+    }
+    for _, opt := range opts {
+        opt(c)
+    }
+    return c, nil
+}
+
+// WithHTTPClient sends requests with hc instead of http.DefaultClient
+func WithHTTPClient(hc *http.Client) Option {
+    return func(c *Client) {
+        c.HTTPClient = hc
+    }
+}
+
+// WithTransport sends requests through rt, e.g. to add tracing or logging
+func WithTransport(rt http.RoundTripper) Option {
+    return func(c *Client) {
+        c.HTTPClient = &http.Client{Transport: rt}
+    }
+}
+
+// WithRetry replaces DefaultRetry; MaxAttempts 1 disables retries
+func WithRetry(policy RetryPolicy) Option {
+    return func(c *Client) {
+        c.Retry = policy
+    }
+}
+
+// WithRequestEditor runs fn on every request before it is sent
+func WithRequestEditor(fn RequestEditor) Option {
+    return func(c *Client) {
+        c.editors = append(c.editors, fn)
+    }
+}
+
+// BlobRef references a file stored by the server
+type BlobRef struct {
+    Blob        string `json:"blob"`
+    ContentType string `json:"contentType,omitempty"`
+    Filename    string `json:"filename,omitempty"`
+    Size        int64  `json:"size"`
+    SHA256      string `json:"sha256,omitempty"`
+}
+
+// APIError is returned for responses without a success status. Model holds
+// the body decoded into the type the spec declares for the status, if any.
+type APIError struct {
+    Operation  string
+    StatusCode int
+    Header     http.Header
+    Body       []byte
+    Model      interface{}
+}
+
+func (e *APIError) Error() string {
+    msg := strings.TrimSpace(string(e.Body))
+    if len(msg) > 200 {
+        msg = msg[:200] + "..."
+    }
+    return fmt.Sprintf("%s: %d %s: %s", e.Operation, e.StatusCode, http.StatusText(e.StatusCode), msg)
+}
+
+// request describes a call to an operation
+type request struct {
+    operation      string
+    method         string
+    path           string
+    query          url.Values
+    header         http.Header
+    cookies        []*http.Cookie
+    body           []byte    // encoded JSON body
+    reader         io.Reader // raw body, retried only when it is an io.Seeker
+    contentType    string
+    accept         string
+    security       [][]string // alternatives of schemes that must all be sent
+    idempotencyKey bool       // send an Idempotency-Key so the POST can be retried
+    errorModel     func(status int) interface{}
+}
+
+// pathValue escapes a path parameter
+func pathValue(v interface{}) string {
+    return url.PathEscape(fmt.Sprint(v))
+}
+
+// jsonBody encodes a request body
+func jsonBody(v interface{}) ([]byte, error) {
+    data, err := json.Marshal(v)
+    if err != nil {
+        return nil, fmt.Errorf("encoding request body: %w", err)
+    }
+    return data, nil
+}
+
+// do sends req and decodes a JSON success body into out, when given
+func (c *Client) do(ctx context.Context, req request, out interface{}) error {
+    resp, err := c.send(ctx, req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    if out == nil {
+        io.Copy(io.Discard, resp.Body)
+        return nil
+    }
+    data, err := io.ReadAll(resp.Body)
+    if err != nil {
+        return fmt.Errorf("%s: reading response: %w", req.operation, err)
+    }
+    if len(bytes.TrimSpace(data)) == 0 {
+        return nil
+    }
+    if err := json.Unmarshal(data, out); err != nil {
+        return fmt.Errorf("%s: decoding response: %w", req.operation, err)
+    }
+    return nil
+}
+
+// send performs req, retrying idempotent requests, and turns responses
+// without a success status into an *APIError
+func (c *Client) send(ctx context.Context, req request) (*http.Response, error) {
+    if req.idempotencyKey && (req.header == nil || req.header.Get("Idempotency-Key") == "") {
+        if req.header == nil {
+            req.header = http.Header{}
+        }
+        req.header.Set("Idempotency-Key", fmt.Sprintf("%016x%016x", rand.Uint64(), rand.Uint64()))
+    }
+    seeker, seekable := req.reader.(io.Seeker)
+    retryable := req.reader == nil || seekable
+    switch req.method {
+    case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+    default:
+        retryable = retryable && req.header.Get("Idempotency-Key") != ""
+    }
+    attempts := c.Retry.MaxAttempts
+    if attempts < 1 || !retryable {
+        attempts = 1
+    }
+    for attempt := 1; ; attempt++ {
+        if seekable && attempt > 1 {
+            if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+                return nil, err
+            }
+        }
+        httpReq, err := c.newRequest(ctx, req)
+        if err != nil {
+            return nil, err
+        }
+        resp, err := c.HTTPClient.Do(httpReq)
+        var wait time.Duration
+        switch {
+        case err != nil:
+            if ctx.Err() != nil || attempt >= attempts {
+                return nil, fmt.Errorf("%s: %w", req.operation, err)
+            }
+        case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+            return resp, nil
+        case attempt < attempts && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusBadGateway ||
+            resp.StatusCode == http.StatusServiceUnavailable || resp.StatusCode == http.StatusGatewayTimeout):
+            if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+                wait = time.Duration(seconds) * time.Second
+            }
+            io.Copy(io.Discard, resp.Body)
+            resp.Body.Close()
+        default:
+            return nil, c.apiError(req, resp)
+        }
+        if backoff := c.backoff(attempt); backoff > wait {
+            wait = backoff
+        }
+        select {
+        case <-ctx.Done():
+            return nil, fmt.Errorf("%s: %w", req.operation, ctx.Err())
+        case <-time.After(wait):
+        }
+    }
+}
+
+// backoff is the jittered exponential delay after a failed attempt
+func (c *Client) backoff(attempt int) time.Duration {
+    d := c.Retry.MinBackoff << (attempt - 1)
+    if d > c.Retry.MaxBackoff || d <= 0 {
+        d = c.Retry.MaxBackoff
+    }
+    if d <= 0 {
+        return 0
+    }
+    return d/2 + time.Duration(rand.Int64N(int64(d/2)+1))
+}
+
+func (c *Client) newRequest(ctx context.Context, req request) (*http.Request, error) {
+    var body io.Reader
+    if req.body != nil {
+        body = bytes.NewReader(req.body)
+    } else if req.reader != nil {
+        body = io.NopCloser(req.reader)
+    }
+    u := c.BaseURL + req.path
+    if len(req.query) > 0 {
+        u += "?" + req.query.Encode()
+    }
+    httpReq, err := http.NewRequestWithContext(ctx, req.method, u, body)
+    if err != nil {
+        return nil, fmt.Errorf("%s: %w", req.operation, err)
+    }
+    for name, values := range req.header {
+        httpReq.Header[name] = values
+    }
+    for _, cookie := range req.cookies {
+        httpReq.AddCookie(cookie)
+    }
+    if body != nil && req.contentType != "" {
+        httpReq.Header.Set("Content-Type", req.contentType)
+    }
+    if req.accept != "" {
+        httpReq.Header.Set("Accept", req.accept)
+    }
+    c.authorize(httpReq, req.security)
+    for _, edit := range c.editors {
+        if err := edit(httpReq); err != nil {
+            return nil, fmt.Errorf("%s: %w", req.operation, err)
+        }
+    }
+    return httpReq, nil
+}
+
+// authorize sends the credentials of the first alternative the client has
+// all credentials for. Without any, the request is sent as is.
+func (c *Client) authorize(r *http.Request, alternatives [][]string) {
+    for _, schemes := range alternatives {
+        complete := true
+        for _, scheme := range schemes {
+            if c.credentials[scheme] == nil {
+                complete = false
+            }
+        }
+        if complete {
+            for _, scheme := range schemes {
+                c.credentials[scheme](r)
+            }
+            return
+        }
+    }
+}
+
+// apiError reads an error response into an *APIError
+func (c *Client) apiError(req request, resp *http.Response) error {
+    defer resp.Body.Close()
+    data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+    apiErr := &APIError{Operation: req.operation, StatusCode: resp.StatusCode, Header: resp.Header, Body: data}
+    if req.errorModel != nil {
+        if model := req.errorModel(resp.StatusCode); model != nil && json.Unmarshal(data, model) == nil {
+            apiErr.Model = model
+        }
+    }
+    return apiErr
+}
+
+// ListAuthorsParams holds the query, header and cookie parameters of ListAuthors
+type ListAuthorsParams struct {
+    Limit *int // query limit
+}
+
+// ListAuthors calls GET /authors
+// params may be nil.
+func (c *Client) ListAuthors(ctx context.Context, params *ListAuthorsParams) ([]Author, error) {
+    req := request{operation: "listAuthors", method: "GET", path: "/authors"}
+    if params != nil {
+        req.query = url.Values{}
+        req.header = http.Header{}
+        if params.Limit != nil {
+            req.query.Add("limit", fmt.Sprint(*params.Limit))
+        }
+    }
+    req.accept = "application/json"
+    var result []Author
+    if err := c.do(ctx, req, &result); err != nil {
+        return nil, err
+    }
+    return result, nil
+}
+
+// CreateAuthor calls POST /authors
+func (c *Client) CreateAuthor(ctx context.Context, body io.Reader, contentType string) (*Author, error) {
+    req := request{operation: "createAuthor", method: "POST", path: "/authors"}
+    req.idempotencyKey = true
+    req.contentType = "application/octet-stream"
+    if contentType != "" {
+        req.contentType = contentType
+    }
+    req.reader = body
+    req.accept = "application/json"
+    var result Author
+    if err := c.do(ctx, req, &result); err != nil {
+        return nil, err
+    }
+    return &result, nil
+}
+
+// GetAuthor calls GET /authors/{authorId}
+func (c *Client) GetAuthor(ctx context.Context, authorId int) (*Author, error) {
+    req := request{operation: "getAuthor", method: "GET", path: "/authors/" + pathValue(authorId)}
+    req.accept = "application/json"
+    var result Author
+    if err := c.do(ctx, req, &result); err != nil {
+        return nil, err
+    }
+    return &result, nil
+}
+
+// UpdateAuthor calls PUT /authors/{authorId}
+func (c *Client) UpdateAuthor(ctx context.Context, authorId int, body io.Reader, contentType string) (*Author, error) {
+    req := request{operation: "updateAuthor", method: "PUT", path: "/authors/" + pathValue(authorId)}
+    req.contentType = "application/octet-stream"
+    if contentType != "" {
+        req.contentType = contentType
+    }
+    req.reader = body
+    req.accept = "application/json"
+    var result Author
+    if err := c.do(ctx, req, &result); err != nil {
+        return nil, err
+    }
+    return &result, nil
+}
+
+// DeleteAuthor calls DELETE /authors/{authorId}
+func (c *Client) DeleteAuthor(ctx context.Context, authorId int) error {
+    req := request{operation: "deleteAuthor", method: "DELETE", path: "/authors/" + pathValue(authorId)}
+    return c.do(ctx, req, nil)
+}
+
+// ListBooksParams holds the query, header and cookie parameters of ListBooks
+type ListBooksParams struct {
+    Limit *int // query limit
+}
+
+// ListBooks calls GET /authors/{authorId}/books
+// params may be nil.
+func (c *Client) ListBooks(ctx context.Context, authorId int, params *ListBooksParams) ([]Book, error) {
+    req := request{operation: "listBooks", method: "GET", path: "/authors/" + pathValue(authorId) + "/books"}
+    if params != nil {
+        req.query = url.Values{}
+        req.header = http.Header{}
+        if params.Limit != nil {
+            req.query.Add("limit", fmt.Sprint(*params.Limit))
+        }
+    }
+    req.accept = "application/json"
+    var result []Book
+    if err := c.do(ctx, req, &result); err != nil {
+        return nil, err
+    }
+    return result, nil
+}
+
+// CreateBook calls POST /authors/{authorId}/books
+func (c *Client) CreateBook(ctx context.Context, authorId int, body *Book) (*Book, error) {
+    req := request{operation: "createBook", method: "POST", path: "/authors/" + pathValue(authorId) + "/books"}
+    req.idempotencyKey = true
+    req.contentType = "application/json"
+    data, err := jsonBody(body)
+    if err != nil {
+        return nil, err
+    }
+    req.body = data
+    req.accept = "application/json"
+    var result Book
+    if err := c.do(ctx, req, &result); err != nil {
+        return nil, err
+    }
+    return &result, nil
+}
+
+// GetBook calls GET /authors/{authorId}/books/{bookId}
+func (c *Client) GetBook(ctx context.Context, authorId int, bookId int) (*Book, error) {
+    req := request{operation: "getBook", method: "GET", path: "/authors/" + pathValue(authorId) + "/books/" + pathValue(bookId)}
+    req.accept = "application/json"
+    var result Book
+    if err := c.do(ctx, req, &result); err != nil {
+        return nil, err
+    }
+    return &result, nil
+}
+
+// PatchBook calls PATCH /authors/{authorId}/books/{bookId}
+func (c *Client) PatchBook(ctx context.Context, authorId int, bookId int, body interface{}) (*Book, error) {
+    req := request{operation: "patchBook", method: "PATCH", path: "/authors/" + pathValue(authorId) + "/books/" + pathValue(bookId)}
+    req.contentType = "application/merge-patch+json"
+    data, err := jsonBody(body)
+    if err != nil {
+        return nil, err
+    }
+    req.body = data
+    req.accept = "application/json"
+    var result Book
+    if err := c.do(ctx, req, &result); err != nil {
+        return nil, err
+    }
+    return &result, nil
+}
+
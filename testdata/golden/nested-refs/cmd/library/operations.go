@@ -0,0 +1,79 @@
+package main
+
+// operations lists a command per operation, grouped by its first tag
+var operations = []operation{
+    {Group: "authors", Name: "list-authors", OperationID: "listAuthors", Method: "GET", Path: "/authors", Summary: "",
+        Params: []param{
+            {Name: "limit", Flag: "limit", In: "query", Type: "integer"},
+        },
+        Accept: "application/json",
+    },
+    {Group: "authors", Name: "create-author", OperationID: "createAuthor", Method: "POST", Path: "/authors", Summary: "",
+        BodyType: "application/octet-stream", BodyRequired: false,
+        Accept: "application/json",
+        Idempotent: true,
+    },
+    {Group: "authors", Name: "get-author", OperationID: "getAuthor", Method: "GET", Path: "/authors/{authorId}", Summary: "",
+        Params: []param{
+            {Name: "authorId", Flag: "author-id", In: "path", Type: "integer", Required: true},
+        },
+        Accept: "application/json",
+    },
+    {Group: "authors", Name: "update-author", OperationID: "updateAuthor", Method: "PUT", Path: "/authors/{authorId}", Summary: "",
+        BodyType: "application/octet-stream", BodyRequired: false,
+        Params: []param{
+            {Name: "authorId", Flag: "author-id", In: "path", Type: "integer", Required: true},
+        },
+        Accept: "application/json",
+    },
+    {Group: "authors", Name: "delete-author", OperationID: "deleteAuthor", Method: "DELETE", Path: "/authors/{authorId}", Summary: "",
+        Params: []param{
+            {Name: "authorId", Flag: "author-id", In: "path", Type: "integer", Required: true},
+        },
+    },
+    {Group: "books", Name: "list-books", OperationID: "listBooks", Method: "GET", Path: "/authors/{authorId}/books", Summary: "",
+        Params: []param{
+            {Name: "authorId", Flag: "author-id", In: "path", Type: "integer", Required: true},
+            {Name: "limit", Flag: "limit", In: "query", Type: "integer"},
+        },
+        Accept: "application/json",
+    },
+    {Group: "books", Name: "create-book", OperationID: "createBook", Method: "POST", Path: "/authors/{authorId}/books", Summary: "",
+        BodyType: "application/json", BodyRequired: true,
+        Params: []param{
+            {Name: "authorId", Flag: "author-id", In: "path", Type: "integer", Required: true},
+            {Name: "author", Flag: "author", In: "body", Type: "object"},
+            {Name: "id", Flag: "id", In: "body", Type: "integer"},
+            {Name: "isbn", Flag: "isbn", In: "body", Type: "string"},
+            {Name: "metadata", Flag: "metadata", In: "body", Type: "object"},
+            {Name: "title", Flag: "title", In: "body", Type: "string"},
+        },
+        Accept: "application/json",
+        Idempotent: true,
+    },
+    {Group: "books", Name: "get-book", OperationID: "getBook", Method: "GET", Path: "/authors/{authorId}/books/{bookId}", Summary: "",
+        Params: []param{
+            {Name: "authorId", Flag: "author-id", In: "path", Type: "integer", Required: true},
+            {Name: "bookId", Flag: "book-id", In: "path", Type: "integer", Required: true},
+        },
+        Accept: "application/json",
+    },
+    {Group: "books", Name: "patch-book", OperationID: "patchBook", Method: "PATCH", Path: "/authors/{authorId}/books/{bookId}", Summary: "",
+        BodyType: "application/merge-patch+json", BodyRequired: false,
+        Params: []param{
+            {Name: "authorId", Flag: "author-id", In: "path", Type: "integer", Required: true},
+            {Name: "bookId", Flag: "book-id", In: "path", Type: "integer", Required: true},
+            {Name: "author", Flag: "author", In: "body", Type: "object"},
+            {Name: "id", Flag: "id", In: "body", Type: "integer"},
+            {Name: "isbn", Flag: "isbn", In: "body", Type: "string"},
+            {Name: "metadata", Flag: "metadata", In: "body", Type: "object"},
+            {Name: "title", Flag: "title", In: "body", Type: "string"},
+        },
+        Accept: "application/json",
+    },
+}
+
+// schemes lists the security schemes and the environment variables holding
+// their credentials
+var schemes = map[string]scheme{
+}
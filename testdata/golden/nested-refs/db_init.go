@@ -0,0 +1,33 @@
+package main
+
+import (
+    "log/slog"
+    "github.com/dgraph-io/badger/v3"
+)
+
+// SetupDB initializes the database with necessary prefixes or initial data
+func SetupDB(db *badger.DB) error {
+    // BadgerDB is a key-value store, so we simulate 'tables' with key prefixes
+    // Prefixes are used to organize data by entity type; child resources are
+    // nested below their parent's key and need no prefix of their own
+    prefixes := []string{
+        "authors:",
+    }
+
+    // Metadata lives in its own namespace so it never shows up in collection scans
+    err := db.Update(func(txn *badger.Txn) error {
+        for _, prefix := range prefixes {
+            metaKey := "_meta:" + prefix
+            if err := txn.Set([]byte(metaKey), []byte("initialized")); err != nil {
+                return err
+            }
+        }
+        return nil
+    })
+    if err != nil {
+        slog.Error("Failed to setup database", "error", err)
+        return err
+    }
+    slog.Info("Database setup completed with prefixes for entities")
+    return nil
+}
@@ -0,0 +1,420 @@
+package main
+
+import (
+    "encoding/json"
+    "errors"
+    "io"
+    "mime"
+    "net/http"
+    "strings"
+    "github.com/dgraph-io/badger/v3"
+)
+
+func ListAuthors(w http.ResponseWriter, r *http.Request) {
+    prefix := "authors:"
+    var err error
+    id := r.URL.Query().Get("id")
+    if id == "" {
+        var items []json.RawMessage
+        err = dbView(r.Context(), func(txn *badger.Txn) error {
+            var err error
+            items, err = listChildren(txn, prefix)
+            return err
+        })
+        if err == badger.ErrKeyNotFound {
+            http.Error(w, "Authors not found", http.StatusNotFound)
+            return
+        } else if err != nil {
+            http.Error(w, "Database error", http.StatusInternalServerError)
+            return
+        }
+        writeDocument(w, r, http.StatusOK, "Authors", items)
+        return
+    }
+    if !validKeyPart(id) {
+        http.Error(w, "Invalid ID", http.StatusBadRequest)
+        return
+    }
+    key := prefix + id
+    var result []byte
+    var version uint64
+    err = dbView(r.Context(), func(txn *badger.Txn) error {
+        var err error
+        result, version, err = getRecord(txn, key)
+        return err
+    })
+    if err == badger.ErrKeyNotFound {
+        http.Error(w, "Authors not found", http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, "Database error", http.StatusInternalServerError)
+        return
+    }
+    if notModified(w, r, version) {
+        return
+    }
+    writeDocument(w, r, http.StatusOK, "Authors", json.RawMessage(result))
+}
+
+func CreateAuthor(w http.ResponseWriter, r *http.Request) {
+    prefix := "authors:"
+    var err error
+    id := newID()
+    key := prefix + id
+    data, err := readValidated(r, "")
+    if err != nil {
+        http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+        return
+    }
+    data, err = withID(data, "id", id, true)
+    if err != nil {
+        http.Error(w, "Invalid ID", http.StatusBadRequest)
+        return
+    }
+    var version uint64
+    err = dbUpdate(r.Context(), func(txn *badger.Txn) error {
+        var err error
+        version, err = putRecord(txn, key, data, 0)
+        return err
+    })
+    if err != nil {
+        http.Error(w, "Failed to save data", http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("ETag", etagFor(version))
+    w.Header().Set("Location", strings.TrimSuffix(r.URL.Path, "/")+"/"+id)
+    var resp Author
+    if err := json.Unmarshal(data, &resp); err != nil {
+        http.Error(w, "Failed to parse data", http.StatusInternalServerError)
+        return
+    }
+    writeDocument(w, r, http.StatusCreated, "Author", resp)
+}
+
+func GetAuthor(w http.ResponseWriter, r *http.Request) {
+    key, err := resourceKey(r, "authors", "authorId")
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    var result []byte
+    var version uint64
+    err = dbView(r.Context(), func(txn *badger.Txn) error {
+        var err error
+        result, version, err = getRecord(txn, key)
+        return err
+    })
+    if err == badger.ErrKeyNotFound {
+        http.Error(w, "Authors not found", http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, "Database error", http.StatusInternalServerError)
+        return
+    }
+    if notModified(w, r, version) {
+        return
+    }
+    var resp Author
+    if err := json.Unmarshal(result, &resp); err != nil {
+        http.Error(w, "Failed to parse data", http.StatusInternalServerError)
+        return
+    }
+    writeDocument(w, r, http.StatusOK, "Author", resp)
+}
+
+func UpdateAuthor(w http.ResponseWriter, r *http.Request) {
+    key, err := resourceKey(r, "authors", "authorId")
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    id := r.PathValue("authorId")
+    data, err := readValidated(r, "")
+    if err != nil {
+        http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+        return
+    }
+    data, err = withID(data, "id", id, true)
+    if err != nil {
+        http.Error(w, "Invalid ID", http.StatusBadRequest)
+        return
+    }
+    var version uint64
+    err = dbUpdate(r.Context(), func(txn *badger.Txn) error {
+        _, current, err := getRecord(txn, key)
+        if err != nil {
+            return err
+        }
+        if err := checkIfMatch(r, current, true); err != nil {
+            return err
+        }
+        version, err = putRecord(txn, key, data, current)
+        return err
+    })
+    if err == badger.ErrKeyNotFound {
+        http.Error(w, "Authors not found", http.StatusNotFound)
+        return
+    }
+    if err == errPreconditionFailed {
+        http.Error(w, "Precondition failed", http.StatusPreconditionFailed)
+        return
+    } else if err == badger.ErrConflict {
+        http.Error(w, "Concurrent modification, retry the request", conflictStatus(r))
+        return
+    }
+    if err != nil {
+        http.Error(w, "Failed to update data", http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("ETag", etagFor(version))
+    var resp Author
+    if err := json.Unmarshal(data, &resp); err != nil {
+        http.Error(w, "Failed to parse data", http.StatusInternalServerError)
+        return
+    }
+    writeDocument(w, r, http.StatusOK, "Author", resp)
+}
+
+func DeleteAuthor(w http.ResponseWriter, r *http.Request) {
+    key, err := resourceKey(r, "authors", "authorId")
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    err = dbUpdate(r.Context(), func(txn *badger.Txn) error {
+        _, current, err := getRecord(txn, key)
+        if err != nil {
+            return err
+        }
+        if err := checkIfMatch(r, current, true); err != nil {
+            return err
+        }
+        if hasChildren(txn, key) {
+            return errHasChildren
+        }
+        return txn.Delete([]byte(key))
+    })
+    if err == badger.ErrKeyNotFound {
+        http.Error(w, "Authors not found", http.StatusNotFound)
+        return
+    }
+    if err == errHasChildren {
+        http.Error(w, "Authors has child resources", http.StatusConflict)
+        return
+    }
+    if err == errPreconditionFailed {
+        http.Error(w, "Precondition failed", http.StatusPreconditionFailed)
+        return
+    } else if err == badger.ErrConflict {
+        http.Error(w, "Concurrent modification, retry the request", conflictStatus(r))
+        return
+    }
+    if err != nil {
+        http.Error(w, "Failed to delete data", http.StatusInternalServerError)
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+func ListBooks(w http.ResponseWriter, r *http.Request) {
+    parentKey, err := resourceKey(r, "authors", "authorId")
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    prefix := parentKey + ":books:"
+    id := r.URL.Query().Get("id")
+    if id == "" {
+        var items []json.RawMessage
+        err = dbView(r.Context(), func(txn *badger.Txn) error {
+            if _, err := txn.Get([]byte(parentKey)); err != nil {
+                return err
+            }
+            var err error
+            items, err = listChildren(txn, prefix)
+            return err
+        })
+        if err == badger.ErrKeyNotFound {
+            http.Error(w, "Authors not found", http.StatusNotFound)
+            return
+        } else if err != nil {
+            http.Error(w, "Database error", http.StatusInternalServerError)
+            return
+        }
+        writeDocument(w, r, http.StatusOK, "Books", items)
+        return
+    }
+    if !validKeyPart(id) {
+        http.Error(w, "Invalid ID", http.StatusBadRequest)
+        return
+    }
+    key := prefix + id
+    var result []byte
+    var version uint64
+    err = dbView(r.Context(), func(txn *badger.Txn) error {
+        var err error
+        result, version, err = getRecord(txn, key)
+        return err
+    })
+    if err == badger.ErrKeyNotFound {
+        http.Error(w, "Books not found", http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, "Database error", http.StatusInternalServerError)
+        return
+    }
+    if notModified(w, r, version) {
+        return
+    }
+    writeDocument(w, r, http.StatusOK, "Books", json.RawMessage(result))
+}
+
+func CreateBook(w http.ResponseWriter, r *http.Request) {
+    parentKey, err := resourceKey(r, "authors", "authorId")
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    prefix := parentKey + ":books:"
+    id := newID()
+    key := prefix + id
+    data, err := readValidated(r, "Book")
+    if err != nil {
+        http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+        return
+    }
+    data, err = withID(data, "id", id, true)
+    if err != nil {
+        http.Error(w, "Invalid ID", http.StatusBadRequest)
+        return
+    }
+    var version uint64
+    err = dbUpdate(r.Context(), func(txn *badger.Txn) error {
+        if _, err := txn.Get([]byte(parentKey)); err != nil {
+            return err
+        }
+        var err error
+        version, err = putRecord(txn, key, data, 0)
+        return err
+    })
+    if err == badger.ErrKeyNotFound {
+        http.Error(w, "Authors not found", http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, "Failed to save data", http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("ETag", etagFor(version))
+    w.Header().Set("Location", strings.TrimSuffix(r.URL.Path, "/")+"/"+id)
+    var resp Book
+    if err := json.Unmarshal(data, &resp); err != nil {
+        http.Error(w, "Failed to parse data", http.StatusInternalServerError)
+        return
+    }
+    writeDocument(w, r, http.StatusCreated, "Book", resp)
+}
+
+func GetBook(w http.ResponseWriter, r *http.Request) {
+    key, err := resourceKey(r, "authors", "authorId", "books", "bookId")
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    var result []byte
+    var version uint64
+    err = dbView(r.Context(), func(txn *badger.Txn) error {
+        var err error
+        result, version, err = getRecord(txn, key)
+        return err
+    })
+    if err == badger.ErrKeyNotFound {
+        http.Error(w, "Books not found", http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, "Database error", http.StatusInternalServerError)
+        return
+    }
+    if notModified(w, r, version) {
+        return
+    }
+    var resp Book
+    if err := json.Unmarshal(result, &resp); err != nil {
+        http.Error(w, "Failed to parse data", http.StatusInternalServerError)
+        return
+    }
+    writeDocument(w, r, http.StatusOK, "Book", resp)
+}
+
+func PatchBook(w http.ResponseWriter, r *http.Request) {
+    key, err := resourceKey(r, "authors", "authorId", "books", "bookId")
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    id := r.PathValue("bookId")
+    mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+    var format patchFormat
+    switch mediaType {
+    case "application/merge-patch+json":
+        format = mergePatch
+    default:
+        http.Error(w, "Unsupported patch media type", http.StatusUnsupportedMediaType)
+        return
+    }
+    patch, err := io.ReadAll(r.Body)
+    if err != nil {
+        http.Error(w, "Invalid request body", http.StatusBadRequest)
+        return
+    }
+    var result []byte
+    var version uint64
+    err = dbUpdate(r.Context(), func(txn *badger.Txn) error {
+        current, prev, err := getRecord(txn, key)
+        if err != nil {
+            return err
+        }
+        if err := checkIfMatch(r, prev, true); err != nil {
+            return err
+        }
+        result, err = applyPatch(format, current, patch)
+        if err != nil {
+            return err
+        }
+        result, err = withID(result, "id", id, true)
+        if err != nil {
+            return &patchError{status: http.StatusBadRequest, msg: "Invalid ID"}
+        }
+        if err := validateJSON("Book", result); err != nil {
+            return &patchError{status: http.StatusUnprocessableEntity, msg: err.Error()}
+        }
+        version, err = putRecord(txn, key, result, prev)
+        return err
+    })
+    var perr *patchError
+    if err == badger.ErrKeyNotFound {
+        http.Error(w, "Books not found", http.StatusNotFound)
+        return
+    } else if errors.As(err, &perr) {
+        http.Error(w, perr.Error(), perr.status)
+        return
+    }
+    if err == errPreconditionFailed {
+        http.Error(w, "Precondition failed", http.StatusPreconditionFailed)
+        return
+    } else if err == badger.ErrConflict {
+        http.Error(w, "Concurrent modification, retry the request", conflictStatus(r))
+        return
+    }
+    if err != nil {
+        http.Error(w, "Failed to update data", http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("ETag", etagFor(version))
+    var resp Book
+    if err := json.Unmarshal(result, &resp); err != nil {
+        http.Error(w, "Failed to parse data", http.StatusInternalServerError)
+        return
+    }
+    writeDocument(w, r, http.StatusOK, "Book", resp)
+}
+
@@ -0,0 +1,98 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "flag"
+    "log/slog"
+    "net/http"
+    "os"
+    "os/signal"
+    "syscall"
+    "time"
+)
+
+func main() {
+    // Load configuration from flags, environment and an optional file
+    cfg, err := LoadConfig(os.Args[1:])
+    if errors.Is(err, flag.ErrHelp) {
+        return
+    }
+    if err != nil {
+        slog.Error("Invalid configuration", "error", err)
+        os.Exit(2)
+    }
+    cfg.Apply()
+    shutdownTracing, err := setupTracing(context.Background(), cfg.TraceExporter, cfg.OTLPEndpoint)
+    if err != nil {
+        slog.Error("Failed to set up tracing", "error", err)
+        os.Exit(1)
+    }
+
+    // Initialize BadgerDB
+    db, err := InitializeDB(cfg)
+    if err != nil {
+        os.Exit(1)
+    }
+
+    // Setup database with prefixes or initial data
+    if err := SetupDB(db); err != nil {
+        CloseDB(db)
+        os.Exit(1)
+    }
+
+    // Start HTTP server
+    srv := NewServer(db, cfg.Addr)
+    serveErr := make(chan error, 1)
+    go func() {
+        slog.Info("Server starting", "addr", srv.Addr)
+        if cfg.TLSCert != "" {
+            serveErr <- srv.ListenAndServeTLS(cfg.TLSCert, cfg.TLSKey)
+            return
+        }
+        serveErr <- srv.ListenAndServe()
+    }()
+    ready.Store(true)
+
+    // Wait for an interrupt signal, or for the server to fail
+    sigChan := make(chan os.Signal, 1)
+    signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+    exitCode := 0
+    var serveFailure error
+    select {
+    case <-sigChan:
+        slog.Info("Shutting down server", "drain_delay", DrainDelay)
+    case serveFailure = <-serveErr:
+    }
+
+    // Report not ready and keep serving for DrainDelay, then stop accepting
+    // connections and wait for in-flight requests. The DB is closed only once
+    // no handler can use it; a second signal skips the wait.
+    ready.Store(false)
+    signal.Stop(sigChan)
+    signal.Reset(syscall.SIGINT, syscall.SIGTERM)
+    time.Sleep(DrainDelay)
+    ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+    err = srv.Shutdown(ctx)
+    cancel()
+    if err != nil {
+        slog.Error("Shutdown did not complete", "error", err)
+        srv.Close()
+        exitCode = 1
+    }
+    if serveFailure == nil {
+        serveFailure = <-serveErr
+    }
+    if !errors.Is(serveFailure, http.ErrServerClosed) {
+        slog.Error("Server failed", "error", serveFailure)
+        exitCode = 1
+    }
+    CloseDB(db)
+    ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+    if err := shutdownTracing(ctx); err != nil {
+        slog.Error("Failed to flush traces", "error", err)
+    }
+    cancel()
+    slog.Info("Server stopped")
+    os.Exit(exitCode)
+}
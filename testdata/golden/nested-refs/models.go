@@ -0,0 +1,40 @@
+package main
+
+// Auto-generated structs from OpenAPI spec
+
+type Address struct {
+    City string `json:"city,omitempty"`
+    Country interface{} `json:"country,omitempty"`
+    Street string `json:"street,omitempty"`
+}
+
+type Author struct {
+    Address interface{} `json:"address,omitempty"`
+    Books []interface{} `json:"books,omitempty"`
+    Id int `json:"id,omitempty"`
+    Name string `json:"name"`
+}
+
+type Book struct {
+    Author interface{} `json:"author,omitempty"`
+    Id int `json:"id,omitempty"`
+    Isbn string `json:"isbn,omitempty"`
+    Metadata interface{} `json:"metadata,omitempty"`
+    Title string `json:"title"`
+}
+
+type Country string
+
+type ListAuthorsResponse200 []interface{}
+
+type ListBooksResponse200 []interface{}
+
+type Metadata struct {
+}
+
+type Problem struct {
+    Detail string `json:"detail,omitempty"`
+    Status int `json:"status,omitempty"`
+    Title string `json:"title,omitempty"`
+}
+
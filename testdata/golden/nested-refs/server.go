@@ -0,0 +1,63 @@
+package main
+
+import (
+    "net/http"
+    "sync/atomic"
+    "time"
+    "github.com/dgraph-io/badger/v3"
+)
+
+var DB *badger.DB
+
+// Server timeouts. DrainDelay keeps serving, while reporting not ready, before
+// the listener closes so load balancers stop routing new requests; in-flight
+// requests then get ShutdownTimeout to finish.
+var (
+    ReadHeaderTimeout = 10 * time.Second
+    ReadTimeout       = 30 * time.Second
+    WriteTimeout      = 60 * time.Second
+    IdleTimeout       = 120 * time.Second
+    DrainDelay        = 0 * time.Second
+    ShutdownTimeout   = 30 * time.Second
+)
+
+// ready is true while the server accepts traffic and false during startup and drain
+var ready atomic.Bool
+
+// Ready reports whether the server should receive traffic
+func Ready() bool {
+    return ready.Load()
+}
+
+// routes lists the operations of the spec. Method-qualified patterns let
+// several operations share a path and expose path parameters through r.PathValue.
+var routes = []route{
+    {Pattern: "GET /authors", OperationID: "listAuthors", Handler: ListAuthors, Tags: []string{"authors"}, Produces: []string{"application/json"}},
+    {Pattern: "POST /authors", OperationID: "createAuthor", Handler: CreateAuthor, Tags: []string{"authors"}, Produces: []string{"application/json"}, Idempotent: true},
+    {Pattern: "GET /authors/{authorId}", OperationID: "getAuthor", Handler: GetAuthor, Tags: []string{"authors"}, Produces: []string{"application/json"}},
+    {Pattern: "PUT /authors/{authorId}", OperationID: "updateAuthor", Handler: UpdateAuthor, Tags: []string{"authors"}, Produces: []string{"application/json"}},
+    {Pattern: "DELETE /authors/{authorId}", OperationID: "deleteAuthor", Handler: DeleteAuthor, Tags: []string{"authors"}},
+    {Pattern: "GET /authors/{authorId}/books", OperationID: "listBooks", Handler: ListBooks, Tags: []string{"books"}, Produces: []string{"application/json"}},
+    {Pattern: "POST /authors/{authorId}/books", OperationID: "createBook", Handler: CreateBook, Tags: []string{"books"}, Consumes: []string{"application/json"}, Produces: []string{"application/json"}, Idempotent: true},
+    {Pattern: "GET /authors/{authorId}/books/{bookId}", OperationID: "getBook", Handler: GetBook, Tags: []string{"books"}, Produces: []string{"application/json"}},
+    {Pattern: "PATCH /authors/{authorId}/books/{bookId}", OperationID: "patchBook", Handler: PatchBook, Tags: []string{"books"}, Produces: []string{"application/json"}},
+}
+
+// NewServer registers the routes and returns the HTTP server for db
+func NewServer(db *badger.DB, addr string) *http.Server {
+    DB = db
+    mux := http.NewServeMux()
+    for _, rt := range routes {
+        mux.Handle(rt.Pattern, rt.handler())
+    }
+    registerProbes(mux)
+    registerDocs(mux)
+    return &http.Server{
+        Addr:              addr,
+        Handler:           buildHandler(mux),
+        ReadHeaderTimeout: ReadHeaderTimeout,
+        ReadTimeout:       ReadTimeout,
+        WriteTimeout:      WriteTimeout,
+        IdleTimeout:       IdleTimeout,
+    }
+}
@@ -0,0 +1,66 @@
+package main
+
+// operations lists a command per operation, grouped by its first tag
+var operations = []operation{
+    {Group: "", Name: "list-items", OperationID: "listItems", Method: "GET", Path: "/items", Summary: "List items",
+        Accept: "application/json",
+    },
+    {Group: "", Name: "create-item", OperationID: "createItem", Method: "POST", Path: "/items", Summary: "Create an empty item",
+        Accept: "application/json",
+        Idempotent: true,
+    },
+    {Group: "", Name: "get-item", OperationID: "getItem", Method: "GET", Path: "/items/{itemId}", Summary: "Get an item",
+        Params: []param{
+            {Name: "itemId", Flag: "item-id", In: "path", Type: "integer", Required: true},
+        },
+        Accept: "application/json",
+    },
+    {Group: "", Name: "reset-item", OperationID: "resetItem", Method: "PUT", Path: "/items/{itemId}", Summary: "Reset an item to an empty one",
+        Params: []param{
+            {Name: "itemId", Flag: "item-id", In: "path", Type: "integer", Required: true},
+        },
+        Accept: "application/json",
+    },
+    {Group: "", Name: "rename-item", OperationID: "renameItem", Method: "PATCH", Path: "/items/{itemId}", Summary: "Change an item",
+        BodyType: "application/merge-patch+json", BodyRequired: true,
+        Params: []param{
+            {Name: "itemId", Flag: "item-id", In: "path", Type: "integer", Required: true},
+            {Name: "id", Flag: "id", In: "body", Type: "integer"},
+            {Name: "name", Flag: "name", In: "body", Type: "string"},
+        },
+        Accept: "application/json",
+    },
+    {Group: "", Name: "delete-item", OperationID: "deleteItem", Method: "DELETE", Path: "/items/{itemId}", Summary: "Delete an item",
+        Params: []param{
+            {Name: "itemId", Flag: "item-id", In: "path", Type: "integer", Required: true},
+        },
+    },
+    {Group: "", Name: "create-note", OperationID: "createNote", Method: "POST", Path: "/notes", Summary: "Create a note",
+        BodyType: "application/json", BodyRequired: true,
+        Params: []param{
+            {Name: "id", Flag: "id", In: "body", Type: "string"},
+            {Name: "kind", Flag: "kind", In: "body", Type: "string"},
+            {Name: "meta", Flag: "meta", In: "body", Type: "object"},
+            {Name: "tags", Flag: "tags", In: "body", Type: "array", Items: "string"},
+            {Name: "title", Flag: "title", In: "body", Type: "string"},
+        },
+        Accept: "application/json",
+        Idempotent: true,
+    },
+    {Group: "", Name: "get-note", OperationID: "getNote", Method: "GET", Path: "/notes/{noteId}", Summary: "Get a note",
+        Params: []param{
+            {Name: "noteId", Flag: "note-id", In: "path", Type: "string", Required: true},
+        },
+        Accept: "application/json",
+    },
+    {Group: "", Name: "delete-note", OperationID: "deleteNote", Method: "DELETE", Path: "/notes/{noteId}", Summary: "Delete a note",
+        Params: []param{
+            {Name: "noteId", Flag: "note-id", In: "path", Type: "string", Required: true},
+        },
+    },
+}
+
+// schemes lists the security schemes and the environment variables holding
+// their credentials
+var schemes = map[string]scheme{
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+    "encoding/binary"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "log/slog"
+    "net/http"
+    "strconv"
+    "strings"
+    "sync/atomic"
+    "time"
+    "github.com/dgraph-io/badger/v3"
+)
+
+// InitializeDB sets up the BadgerDB connection
+func InitializeDB(cfg ServerConfig) (*badger.DB, error) {
+    opts := badger.DefaultOptions(cfg.DBPath)
+    if cfg.DBInMemory {
+        opts = badger.DefaultOptions("").WithInMemory(true)
+    }
+    opts = opts.WithSyncWrites(cfg.SyncWrites).WithValueLogFileSize(cfg.ValueLogFileSize)
+    opts.Logger = nil // Disable logging or customize as needed
+    db, err := badger.Open(opts)
+    if err != nil {
+        slog.Error("Failed to open BadgerDB", "error", err)
+        return nil, err
+    }
+    return db, nil
+}
+
+// CloseDB closes the BadgerDB connection
+func CloseDB(db *badger.DB) {
+    if err := db.Close(); err != nil {
+        slog.Error("Failed to close BadgerDB", "error", err)
+    }
+}
+
+// Keys are composed of collection/ID pairs joined by ":" so that child
+// resources live under their parent, e.g. users:42:posts:7
+
+// Stored values carry an 8-byte big-endian version ahead of the JSON document.
+// Handlers derive ETags from it and compare it for conditional requests.
+
+// getRecord loads the document and version stored at key
+func getRecord(txn *badger.Txn, key string) ([]byte, uint64, error) {
+    item, err := txn.Get([]byte(key))
+    if err != nil {
+        return nil, 0, err
+    }
+    value, err := item.ValueCopy(nil)
+    if err != nil {
+        return nil, 0, err
+    }
+    doc, version := decodeRecord(value)
+    return doc, version, nil
+}
+
+// decodeRecord splits a stored value into its document and version. Values
+// written before versioning start with the JSON document and get version 0.
+func decodeRecord(value []byte) ([]byte, uint64) {
+    if len(value) < 8 || value[0] == '{' || value[0] == '[' {
+        return value, 0
+    }
+    return value[8:], binary.BigEndian.Uint64(value[:8])
+}
+
+// putRecord stores doc at key with a version newer than prev and returns it.
+// Versions are timestamps so a recreated record never reuses an old ETag.
+func putRecord(txn *badger.Txn, key string, doc []byte, prev uint64) (uint64, error) {
+    version := uint64(time.Now().UnixNano())
+    if version <= prev {
+        version = prev + 1
+    }
+    value := make([]byte, 8+len(doc))
+    binary.BigEndian.PutUint64(value, version)
+    copy(value[8:], doc)
+    return version, txn.Set([]byte(key), value)
+}
+
+// lastID is the ID newID handed out last
+var lastID atomic.Int64
+
+// newID returns the ID of a record created in a collection: the time in
+// microseconds, counted on when records are created faster than that. IDs
+// sort in creation order and stay below 2^53, so JavaScript clients read
+// integer IDs exactly.
+func newID() string {
+    for {
+        last := lastID.Load()
+        id := time.Now().UnixMicro()
+        if id <= last {
+            id = last + 1
+        }
+        if lastID.CompareAndSwap(last, id) {
+            return strconv.FormatInt(id, 10)
+        }
+    }
+}
+
+// errHasChildren aborts a delete of a resource that still owns child resources
+var errHasChildren = errors.New("resource has child resources")
+
+// validKeyPart reports whether an ID can be embedded in a composite key
+func validKeyPart(id string) bool {
+    return id != "" && !strings.Contains(id, ":")
+}
+
+// resourceKey builds a composite key from alternating collection names and
+// path wildcard names, e.g. resourceKey(r, "users", "userId", "posts", "postId")
+func resourceKey(r *http.Request, parts ...string) (string, error) {
+    segments := make([]string, 0, len(parts)/2)
+    for i := 0; i+1 < len(parts); i += 2 {
+        id := r.PathValue(parts[i+1])
+        if !validKeyPart(id) {
+            return "", fmt.Errorf("invalid %s", parts[i+1])
+        }
+        segments = append(segments, parts[i]+":"+id)
+    }
+    return strings.Join(segments, ":"), nil
+}
+
+// withID stores a record's ID in its JSON object document under field,
+// as a number when the schema declares an integer ID
+func withID(doc []byte, field, id string, numeric bool) ([]byte, error) {
+    var obj map[string]interface{}
+    if err := json.Unmarshal(doc, &obj); err != nil || obj == nil {
+        return doc, nil
+    }
+    if numeric {
+        if _, err := strconv.ParseInt(id, 10, 64); err != nil {
+            return nil, fmt.Errorf("%s must be an integer", field)
+        }
+        obj[field] = json.Number(id)
+    } else {
+        obj[field] = id
+    }
+    return json.Marshal(obj)
+}
+
+// listChildren returns the documents stored directly under prefix, skipping
+// keys that belong to deeper nested resources
+func listChildren(txn *badger.Txn, prefix string) ([]json.RawMessage, error) {
+    items := []json.RawMessage{}
+    opts := badger.DefaultIteratorOptions
+    opts.Prefix = []byte(prefix)
+    it := txn.NewIterator(opts)
+    defer it.Close()
+    for it.Rewind(); it.Valid(); it.Next() {
+        item := it.Item()
+        if strings.Contains(string(item.Key()[len(prefix):]), ":") {
+            continue
+        }
+        value, err := item.ValueCopy(nil)
+        if err != nil {
+            return nil, err
+        }
+        doc, _ := decodeRecord(value)
+        items = append(items, doc)
+    }
+    return items, nil
+}
+
+// hasChildren reports whether any key is nested below the given resource key
+func hasChildren(txn *badger.Txn, key string) bool {
+    opts := badger.DefaultIteratorOptions
+    opts.PrefetchValues = false
+    opts.Prefix = []byte(key + ":")
+    it := txn.NewIterator(opts)
+    defer it.Close()
+    it.Rewind()
+    return it.Valid()
+}
+
+// deleteChildren removes every child resource nested below key. Large trees
+// are deleted across several transactions to stay within Badger's limits.
+// With release, the blobs the deleted records reference are deleted after
+// each batch.
+func deleteChildren(db *badger.DB, key string, release bool) error {
+    prefix := []byte(key + ":")
+    for {
+        var keys, docs [][]byte
+        err := db.View(func(txn *badger.Txn) error {
+            opts := badger.DefaultIteratorOptions
+            opts.PrefetchValues = release
+            opts.Prefix = prefix
+            it := txn.NewIterator(opts)
+            defer it.Close()
+            for it.Rewind(); it.Valid() && len(keys) < 1000; it.Next() {
+                keys = append(keys, it.Item().KeyCopy(nil))
+                if release {
+                    value, err := it.Item().ValueCopy(nil)
+                    if err != nil {
+                        return err
+                    }
+                    doc, _ := decodeRecord(value)
+                    docs = append(docs, doc)
+                }
+            }
+            return nil
+        })
+        if err != nil || len(keys) == 0 {
+            return err
+        }
+        wb := db.NewWriteBatch()
+        for _, k := range keys {
+            if err := wb.Delete(k); err != nil {
+                wb.Cancel()
+                return err
+            }
+        }
+        if err := wb.Flush(); err != nil {
+            return err
+        }
+        for _, doc := range docs {
+            releaseBlobs(doc, nil)
+        }
+    }
+}
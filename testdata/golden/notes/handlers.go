@@ -0,0 +1,376 @@
+package main
+
+import (
+    "encoding/json"
+    "errors"
+    "io"
+    "mime"
+    "net/http"
+    "strings"
+    "github.com/dgraph-io/badger/v3"
+)
+
+func ListItems(w http.ResponseWriter, r *http.Request) {
+    prefix := "items:"
+    var err error
+    id := r.URL.Query().Get("id")
+    if id == "" {
+        var items []json.RawMessage
+        err = dbView(r.Context(), func(txn *badger.Txn) error {
+            var err error
+            items, err = listChildren(txn, prefix)
+            return err
+        })
+        if err == badger.ErrKeyNotFound {
+            http.Error(w, "Items not found", http.StatusNotFound)
+            return
+        } else if err != nil {
+            http.Error(w, "Database error", http.StatusInternalServerError)
+            return
+        }
+        writeDocument(w, r, http.StatusOK, "Items", items)
+        return
+    }
+    if !validKeyPart(id) {
+        http.Error(w, "Invalid ID", http.StatusBadRequest)
+        return
+    }
+    key := prefix + id
+    var result []byte
+    var version uint64
+    err = dbView(r.Context(), func(txn *badger.Txn) error {
+        var err error
+        result, version, err = getRecord(txn, key)
+        return err
+    })
+    if err == badger.ErrKeyNotFound {
+        http.Error(w, "Items not found", http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, "Database error", http.StatusInternalServerError)
+        return
+    }
+    if notModified(w, r, version) {
+        return
+    }
+    writeDocument(w, r, http.StatusOK, "Items", json.RawMessage(result))
+}
+
+func CreateItem(w http.ResponseWriter, r *http.Request) {
+    prefix := "items:"
+    var err error
+    id := newID()
+    key := prefix + id
+    data := []byte("{}")
+    data, err = withID(data, "id", id, true)
+    if err != nil {
+        http.Error(w, "Invalid ID", http.StatusBadRequest)
+        return
+    }
+    var version uint64
+    err = dbUpdate(r.Context(), func(txn *badger.Txn) error {
+        var err error
+        version, err = putRecord(txn, key, data, 0)
+        return err
+    })
+    if err != nil {
+        http.Error(w, "Failed to save data", http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("ETag", etagFor(version))
+    w.Header().Set("Location", strings.TrimSuffix(r.URL.Path, "/")+"/"+id)
+    var resp Item
+    if err := json.Unmarshal(data, &resp); err != nil {
+        http.Error(w, "Failed to parse data", http.StatusInternalServerError)
+        return
+    }
+    writeDocument(w, r, http.StatusCreated, "Item", resp)
+}
+
+func GetItem(w http.ResponseWriter, r *http.Request) {
+    key, err := resourceKey(r, "items", "itemId")
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    var result []byte
+    var version uint64
+    err = dbView(r.Context(), func(txn *badger.Txn) error {
+        var err error
+        result, version, err = getRecord(txn, key)
+        return err
+    })
+    if err == badger.ErrKeyNotFound {
+        http.Error(w, "Items not found", http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, "Database error", http.StatusInternalServerError)
+        return
+    }
+    if notModified(w, r, version) {
+        return
+    }
+    var resp Item
+    if err := json.Unmarshal(result, &resp); err != nil {
+        http.Error(w, "Failed to parse data", http.StatusInternalServerError)
+        return
+    }
+    writeDocument(w, r, http.StatusOK, "Item", resp)
+}
+
+func ResetItem(w http.ResponseWriter, r *http.Request) {
+    key, err := resourceKey(r, "items", "itemId")
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    id := r.PathValue("itemId")
+    data := []byte("{}")
+    data, err = withID(data, "id", id, true)
+    if err != nil {
+        http.Error(w, "Invalid ID", http.StatusBadRequest)
+        return
+    }
+    var version uint64
+    err = dbUpdate(r.Context(), func(txn *badger.Txn) error {
+        _, current, err := getRecord(txn, key)
+        if err != nil {
+            return err
+        }
+        if err := checkIfMatch(r, current, true); err != nil {
+            return err
+        }
+        version, err = putRecord(txn, key, data, current)
+        return err
+    })
+    if err == badger.ErrKeyNotFound {
+        http.Error(w, "Items not found", http.StatusNotFound)
+        return
+    }
+    if err == errPreconditionFailed {
+        http.Error(w, "Precondition failed", http.StatusPreconditionFailed)
+        return
+    } else if err == badger.ErrConflict {
+        http.Error(w, "Concurrent modification, retry the request", conflictStatus(r))
+        return
+    }
+    if err != nil {
+        http.Error(w, "Failed to update data", http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("ETag", etagFor(version))
+    var resp Item
+    if err := json.Unmarshal(data, &resp); err != nil {
+        http.Error(w, "Failed to parse data", http.StatusInternalServerError)
+        return
+    }
+    writeDocument(w, r, http.StatusOK, "Item", resp)
+}
+
+func RenameItem(w http.ResponseWriter, r *http.Request) {
+    key, err := resourceKey(r, "items", "itemId")
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    id := r.PathValue("itemId")
+    mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+    var format patchFormat
+    switch mediaType {
+    case "application/merge-patch+json":
+        format = mergePatch
+    default:
+        http.Error(w, "Unsupported patch media type", http.StatusUnsupportedMediaType)
+        return
+    }
+    patch, err := io.ReadAll(r.Body)
+    if err != nil {
+        http.Error(w, "Invalid request body", http.StatusBadRequest)
+        return
+    }
+    var result []byte
+    var version uint64
+    err = dbUpdate(r.Context(), func(txn *badger.Txn) error {
+        current, prev, err := getRecord(txn, key)
+        if err != nil {
+            return err
+        }
+        if err := checkIfMatch(r, prev, true); err != nil {
+            return err
+        }
+        result, err = applyPatch(format, current, patch)
+        if err != nil {
+            return err
+        }
+        result, err = withID(result, "id", id, true)
+        if err != nil {
+            return &patchError{status: http.StatusBadRequest, msg: "Invalid ID"}
+        }
+        if err := validateJSON("Item", result); err != nil {
+            return &patchError{status: http.StatusUnprocessableEntity, msg: err.Error()}
+        }
+        version, err = putRecord(txn, key, result, prev)
+        return err
+    })
+    var perr *patchError
+    if err == badger.ErrKeyNotFound {
+        http.Error(w, "Items not found", http.StatusNotFound)
+        return
+    } else if errors.As(err, &perr) {
+        http.Error(w, perr.Error(), perr.status)
+        return
+    }
+    if err == errPreconditionFailed {
+        http.Error(w, "Precondition failed", http.StatusPreconditionFailed)
+        return
+    } else if err == badger.ErrConflict {
+        http.Error(w, "Concurrent modification, retry the request", conflictStatus(r))
+        return
+    }
+    if err != nil {
+        http.Error(w, "Failed to update data", http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("ETag", etagFor(version))
+    var resp Item
+    if err := json.Unmarshal(result, &resp); err != nil {
+        http.Error(w, "Failed to parse data", http.StatusInternalServerError)
+        return
+    }
+    writeDocument(w, r, http.StatusOK, "Item", resp)
+}
+
+func DeleteItem(w http.ResponseWriter, r *http.Request) {
+    key, err := resourceKey(r, "items", "itemId")
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    err = dbUpdate(r.Context(), func(txn *badger.Txn) error {
+        _, current, err := getRecord(txn, key)
+        if err != nil {
+            return err
+        }
+        if err := checkIfMatch(r, current, true); err != nil {
+            return err
+        }
+        return txn.Delete([]byte(key))
+    })
+    if err == badger.ErrKeyNotFound {
+        http.Error(w, "Items not found", http.StatusNotFound)
+        return
+    }
+    if err == errPreconditionFailed {
+        http.Error(w, "Precondition failed", http.StatusPreconditionFailed)
+        return
+    } else if err == badger.ErrConflict {
+        http.Error(w, "Concurrent modification, retry the request", conflictStatus(r))
+        return
+    }
+    if err != nil {
+        http.Error(w, "Failed to delete data", http.StatusInternalServerError)
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+func CreateNote(w http.ResponseWriter, r *http.Request) {
+    prefix := "notes:"
+    var err error
+    id := newID()
+    key := prefix + id
+    data, err := readValidated(r, "Note")
+    if err != nil {
+        http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+        return
+    }
+    data, err = withID(data, "id", id, false)
+    if err != nil {
+        http.Error(w, "Invalid ID", http.StatusBadRequest)
+        return
+    }
+    var version uint64
+    err = dbUpdate(r.Context(), func(txn *badger.Txn) error {
+        var err error
+        version, err = putRecord(txn, key, data, 0)
+        return err
+    })
+    if err != nil {
+        http.Error(w, "Failed to save data", http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("ETag", etagFor(version))
+    w.Header().Set("Location", strings.TrimSuffix(r.URL.Path, "/")+"/"+id)
+    var resp Note
+    if err := json.Unmarshal(data, &resp); err != nil {
+        http.Error(w, "Failed to parse data", http.StatusInternalServerError)
+        return
+    }
+    writeDocument(w, r, http.StatusCreated, "Note", resp)
+}
+
+func GetNote(w http.ResponseWriter, r *http.Request) {
+    key, err := resourceKey(r, "notes", "noteId")
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    var result []byte
+    var version uint64
+    err = dbView(r.Context(), func(txn *badger.Txn) error {
+        var err error
+        result, version, err = getRecord(txn, key)
+        return err
+    })
+    if err == badger.ErrKeyNotFound {
+        http.Error(w, "Notes not found", http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, "Database error", http.StatusInternalServerError)
+        return
+    }
+    if notModified(w, r, version) {
+        return
+    }
+    var resp Note
+    if err := json.Unmarshal(result, &resp); err != nil {
+        http.Error(w, "Failed to parse data", http.StatusInternalServerError)
+        return
+    }
+    writeDocument(w, r, http.StatusOK, "Note", resp)
+}
+
+func DeleteNote(w http.ResponseWriter, r *http.Request) {
+    key, err := resourceKey(r, "notes", "noteId")
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    err = dbUpdate(r.Context(), func(txn *badger.Txn) error {
+        _, current, err := getRecord(txn, key)
+        if err != nil {
+            return err
+        }
+        if err := checkIfMatch(r, current, true); err != nil {
+            return err
+        }
+        return txn.Delete([]byte(key))
+    })
+    if err == badger.ErrKeyNotFound {
+        http.Error(w, "Notes not found", http.StatusNotFound)
+        return
+    }
+    if err == errPreconditionFailed {
+        http.Error(w, "Precondition failed", http.StatusPreconditionFailed)
+        return
+    } else if err == badger.ErrConflict {
+        http.Error(w, "Concurrent modification, retry the request", conflictStatus(r))
+        return
+    }
+    if err != nil {
+        http.Error(w, "Failed to delete data", http.StatusInternalServerError)
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
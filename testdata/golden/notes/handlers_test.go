@@ -0,0 +1,408 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "io"
+    "math"
+    "net/http"
+    "net/http/httptest"
+    "path"
+    "strings"
+    "testing"
+    "github.com/dgraph-io/badger/v3"
+)
+
+// operationTest is an example request for one operation. Path parameters
+// are filled in by creating the resources they address where the spec has
+// an operation for that, else with the example in Params.
+type operationTest struct {
+    OperationID string
+    Method      string
+    Path        string
+    Params      map[string]string
+    Query       map[string]string
+    Header      map[string]string
+    ContentType string
+    Body        string
+    Status      int
+    Schema      string // JSON pointer to the response schema in openapi.json, empty without a JSON body
+}
+
+// crudTest names the operations of a collection. Update, when set, changes
+// Field to Value, which the following read must return. ID is the property
+// the server sets to the ID it assigns.
+type crudTest struct {
+    Create     string
+    ID         string
+    Get        string
+    Update     string
+    UpdateType string
+    UpdateBody string
+    Field      string
+    Value      string
+    Delete     string
+}
+
+// operationTests holds an example request for every operation of the spec
+var operationTests = []operationTest{
+    {OperationID: "listItems", Method: "GET", Path: "/items", Status: http.StatusOK, Schema: "/paths/~1items/get/responses/200/content/application~1json/schema"},
+    {OperationID: "createItem", Method: "POST", Path: "/items", Status: http.StatusCreated, Schema: "/paths/~1items/post/responses/201/content/application~1json/schema"},
+    {OperationID: "getItem", Method: "GET", Path: "/items/{itemId}", Params: map[string]string{"itemId": "0"}, Status: http.StatusOK, Schema: "/paths/~1items~1{itemId}/get/responses/200/content/application~1json/schema"},
+    {OperationID: "resetItem", Method: "PUT", Path: "/items/{itemId}", Params: map[string]string{"itemId": "0"}, Status: http.StatusOK, Schema: "/paths/~1items~1{itemId}/put/responses/200/content/application~1json/schema"},
+    {OperationID: "renameItem", Method: "PATCH", Path: "/items/{itemId}", Params: map[string]string{"itemId": "0"}, ContentType: "application/merge-patch+json", Body: "{}", Status: http.StatusOK, Schema: "/paths/~1items~1{itemId}/patch/responses/200/content/application~1json/schema"},
+    {OperationID: "deleteItem", Method: "DELETE", Path: "/items/{itemId}", Params: map[string]string{"itemId": "0"}, Status: http.StatusNoContent},
+    {OperationID: "createNote", Method: "POST", Path: "/notes", ContentType: "application/json", Body: "{\"kind\":\"a\",\"meta\":{\"x\":0},\"tags\":[\"string\"],\"title\":\"string\"}", Status: http.StatusCreated, Schema: "/paths/~1notes/post/responses/201/content/application~1json/schema"},
+    {OperationID: "getNote", Method: "GET", Path: "/notes/{noteId}", Params: map[string]string{"noteId": "string"}, Status: http.StatusOK, Schema: "/paths/~1notes~1{noteId}/get/responses/200/content/application~1json/schema"},
+    {OperationID: "deleteNote", Method: "DELETE", Path: "/notes/{noteId}", Params: map[string]string{"noteId": "string"}, Status: http.StatusNoContent},
+}
+
+// crudTests lists the collections whose members can be created, read and deleted
+var crudTests = []crudTest{
+    {Create: "createItem", Get: "getItem", Delete: "deleteItem", ID: "id", Update: "renameItem", UpdateType: "application/merge-patch+json", UpdateBody: "{}"},
+    {Create: "createNote", Get: "getNote", Delete: "deleteNote", ID: "id"},
+}
+
+// newTestServer serves the API from an in-memory database for the duration
+// of a test. Rate limits are left out so the tests' requests are not refused.
+func newTestServer(t testing.TB) *httptest.Server {
+    t.Helper()
+    db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+    if err != nil {
+        t.Fatalf("opening database: %v", err)
+    }
+    t.Cleanup(func() { db.Close() })
+    if err := SetupDB(db); err != nil {
+        t.Fatalf("setting up database: %v", err)
+    }
+    saved := routes
+    routes = make([]route, len(saved))
+    for i, rt := range saved {
+        rt.RateLimits = nil
+        routes[i] = rt
+    }
+    srv := NewServer(db, "")
+    routes = saved
+    ts := httptest.NewServer(srv.Handler)
+    t.Cleanup(ts.Close)
+    return ts
+}
+
+// findTest returns the example request of an operation
+func findTest(t testing.TB, operationID string) operationTest {
+    t.Helper()
+    for _, test := range operationTests {
+        if test.OperationID == operationID {
+            return test
+        }
+    }
+    t.Fatalf("no test for operation %s", operationID)
+    return operationTest{}
+}
+
+// send performs the request of a test on the concrete path
+func send(t testing.TB, ts *httptest.Server, test operationTest, target string) (*http.Response, []byte) {
+    t.Helper()
+    var body io.Reader
+    if test.ContentType != "" {
+        body = strings.NewReader(test.Body)
+    }
+    req, err := http.NewRequest(test.Method, ts.URL+target, body)
+    if err != nil {
+        t.Fatalf("%s: %v", test.OperationID, err)
+    }
+    if len(test.Query) > 0 {
+        query := req.URL.Query()
+        for name, value := range test.Query {
+            query.Set(name, value)
+        }
+        req.URL.RawQuery = query.Encode()
+    }
+    for name, value := range test.Header {
+        req.Header.Set(name, value)
+    }
+    if test.ContentType != "" {
+        req.Header.Set("Content-Type", test.ContentType)
+    }
+    resp, err := ts.Client().Do(req)
+    if err != nil {
+        t.Fatalf("%s %s: %v", test.Method, target, err)
+    }
+    defer resp.Body.Close()
+    data, err := io.ReadAll(resp.Body)
+    if err != nil {
+        t.Fatalf("%s %s: reading response: %v", test.Method, target, err)
+    }
+    return resp, data
+}
+
+// fillPath substitutes the path parameters of a test. The member each one
+// selects is created first when the spec can create it, so the request finds
+// its parents and the item it addresses; complete is false when an example
+// value had to be used instead. POSTs to an item path pick their own ID.
+func fillPath(t testing.TB, ts *httptest.Server, test operationTest) (string, bool) {
+    t.Helper()
+    complete := true
+    segments := strings.Split(test.Path, "/")
+    templates := append([]string(nil), segments...)
+    for i, segment := range segments {
+        if !strings.HasPrefix(segment, "{") || !strings.HasSuffix(segment, "}") {
+            continue
+        }
+        name := strings.Trim(segment, "{}")
+        segments[i] = test.Params[name]
+        if i == len(segments)-1 && test.Method == http.MethodPost {
+            continue
+        }
+        var create *operationTest
+        for j, candidate := range operationTests {
+            if candidate.Method == http.MethodPost && candidate.Path == strings.Join(templates[:i], "/") {
+                create = &operationTests[j]
+                break
+            }
+        }
+        if create == nil {
+            complete = false
+            continue
+        }
+        resp, body := send(t, ts, *create, strings.Join(segments[:i], "/"))
+        location := resp.Header.Get("Location")
+        if resp.StatusCode != create.Status || location == "" {
+            t.Fatalf("creating the %s of %s with %s: status %d, Location %q: %s", name, test.OperationID, create.OperationID, resp.StatusCode, location, body)
+        }
+        segments[i] = path.Base(location)
+    }
+    return strings.Join(segments, "/"), complete
+}
+
+// expectStatus fails the test unless the response has the wanted status
+func expectStatus(t *testing.T, test operationTest, resp *http.Response, body []byte, want int) {
+    t.Helper()
+    if resp.StatusCode != want {
+        t.Fatalf("%s %s: status %d, want %d: %s", test.Method, resp.Request.URL.Path, resp.StatusCode, want, body)
+    }
+}
+
+// TestOperations sends every operation its example request and checks the
+// status and that JSON responses match their schema
+func TestOperations(t *testing.T) {
+    ts := newTestServer(t)
+    for _, test := range operationTests {
+        t.Run(test.OperationID, func(t *testing.T) {
+            target, complete := fillPath(t, ts, test)
+            resp, body := send(t, ts, test, target)
+            // Nothing could be created for made-up IDs, and upserts create them
+            if !complete && (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusCreated) {
+                return
+            }
+            expectStatus(t, test, resp, body, test.Status)
+            checkSchema(t, test.Schema, resp, body)
+        })
+    }
+}
+
+// TestCRUD creates a member of each collection, reads it back, updates it,
+// deletes it and checks that it is gone
+func TestCRUD(t *testing.T) {
+    ts := newTestServer(t)
+    for _, crud := range crudTests {
+        t.Run(crud.Create, func(t *testing.T) {
+            create, get, del := findTest(t, crud.Create), findTest(t, crud.Get), findTest(t, crud.Delete)
+            collection, _ := fillPath(t, ts, create)
+            resp, body := send(t, ts, create, collection)
+            expectStatus(t, create, resp, body, create.Status)
+            item := resp.Header.Get("Location")
+            if item == "" {
+                t.Fatalf("%s: no Location header", crud.Create)
+            }
+
+            resp, body = send(t, ts, get, item)
+            expectStatus(t, get, resp, body, get.Status)
+            checkSchema(t, get.Schema, resp, body)
+            checkFields(t, create.Body, body, crud.ID)
+
+            if crud.Update != "" {
+                update := findTest(t, crud.Update)
+                update.ContentType, update.Body = crud.UpdateType, crud.UpdateBody
+                resp, body = send(t, ts, update, item)
+                expectStatus(t, update, resp, body, update.Status)
+                if crud.Field != "" {
+                    resp, body = send(t, ts, get, item)
+                    expectStatus(t, get, resp, body, get.Status)
+                    want, _ := json.Marshal(map[string]string{crud.Field: crud.Value})
+                    checkFields(t, string(want), body, crud.ID)
+                }
+            }
+
+            resp, body = send(t, ts, del, item)
+            expectStatus(t, del, resp, body, del.Status)
+            resp, body = send(t, ts, get, item)
+            expectStatus(t, get, resp, body, http.StatusNotFound)
+        })
+    }
+}
+
+// checkFields compares the properties of a sent JSON object, but the ID,
+// with the returned document. Zero values may be left out of responses.
+func checkFields(t *testing.T, sent string, got []byte, id string) {
+    t.Helper()
+    var want, doc map[string]interface{}
+    if json.Unmarshal([]byte(sent), &want) != nil || json.Unmarshal(got, &doc) != nil {
+        return
+    }
+    for name, value := range want {
+        if name == id {
+            continue
+        }
+        switch value {
+        case nil, "", 0.0, false:
+            continue
+        }
+        wantJSON, _ := json.Marshal(value)
+        gotJSON, _ := json.Marshal(doc[name])
+        if string(wantJSON) != string(gotJSON) {
+            t.Errorf("field %s is %s, want %s", name, gotJSON, wantJSON)
+        }
+    }
+}
+
+// testSpec is the embedded spec, decoded for schema lookups
+var testSpec = func() interface{} {
+    var spec interface{}
+    json.Unmarshal(specJSON, &spec)
+    return spec
+}()
+
+// specNode returns the value at a JSON pointer into the spec, following
+// $refs on the way
+func specNode(pointer string) interface{} {
+    node := testSpec
+    for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+        token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+        obj, _ := resolveNode(node).(map[string]interface{})
+        node = obj[token]
+    }
+    return resolveNode(node)
+}
+
+// resolveNode follows local $refs
+func resolveNode(node interface{}) interface{} {
+    for i := 0; i < 32; i++ {
+        obj, _ := node.(map[string]interface{})
+        ref, ok := obj["$ref"].(string)
+        if !ok || !strings.HasPrefix(ref, "#/") {
+            return node
+        }
+        node = specNode(ref[1:])
+    }
+    return node
+}
+
+// checkSchema validates a JSON response body against the schema at pointer
+func checkSchema(t *testing.T, pointer string, resp *http.Response, body []byte) {
+    t.Helper()
+    if pointer == "" || !strings.Contains(resp.Header.Get("Content-Type"), "json") {
+        return
+    }
+    var doc interface{}
+    if err := json.Unmarshal(body, &doc); err != nil {
+        t.Fatalf("response is not JSON: %v: %s", err, body)
+    }
+    schema, _ := specNode(pointer).(map[string]interface{})
+    for _, problem := range schemaProblems(schema, doc, "response") {
+        t.Error(problem)
+    }
+}
+
+// schemaProblems lists where a decoded JSON value does not match a schema.
+// Formats are not checked, and files may be returned as references.
+func schemaProblems(schema map[string]interface{}, value interface{}, at string) []string {
+    schema, _ = resolveNode(schema).(map[string]interface{})
+    if schema == nil || schema["format"] == "binary" {
+        return nil
+    }
+    if value == nil {
+        if schema["nullable"] == true || schema["type"] == nil {
+            return nil
+        }
+        return []string{at + " is null"}
+    }
+    var problems []string
+    if values, ok := schema["enum"].([]interface{}); ok {
+        found := false
+        for _, v := range values {
+            a, _ := json.Marshal(v)
+            b, _ := json.Marshal(value)
+            found = found || string(a) == string(b)
+        }
+        if !found {
+            problems = append(problems, fmt.Sprintf("%s is %v, not one of the enum values", at, value))
+        }
+    }
+    if all, ok := schema["allOf"].([]interface{}); ok {
+        for _, sub := range all {
+            s, _ := sub.(map[string]interface{})
+            problems = append(problems, schemaProblems(s, value, at)...)
+        }
+    }
+    for _, keyword := range []string{"oneOf", "anyOf"} {
+        variants, ok := schema[keyword].([]interface{})
+        if !ok {
+            continue
+        }
+        matched := false
+        for _, sub := range variants {
+            s, _ := sub.(map[string]interface{})
+            matched = matched || len(schemaProblems(s, value, at)) == 0
+        }
+        if !matched {
+            problems = append(problems, at+" matches none of the "+keyword+" schemas")
+        }
+    }
+    want, _ := schema["type"].(string)
+    switch v := value.(type) {
+    case map[string]interface{}:
+        if want != "" && want != "object" {
+            return append(problems, at+" is an object, want "+want)
+        }
+        properties, _ := schema["properties"].(map[string]interface{})
+        required, _ := schema["required"].([]interface{})
+        for _, name := range required {
+            if _, ok := v[name.(string)]; !ok {
+                problems = append(problems, at+"."+name.(string)+" is missing")
+            }
+        }
+        for name, field := range v {
+            if prop, ok := properties[name].(map[string]interface{}); ok {
+                problems = append(problems, schemaProblems(prop, field, at+"."+name)...)
+            } else if extra, ok := schema["additionalProperties"].(map[string]interface{}); ok {
+                problems = append(problems, schemaProblems(extra, field, at+"."+name)...)
+            } else if schema["additionalProperties"] == false {
+                problems = append(problems, at+"."+name+" is not declared")
+            }
+        }
+    case []interface{}:
+        if want != "" && want != "array" {
+            return append(problems, at+" is an array, want "+want)
+        }
+        items, _ := schema["items"].(map[string]interface{})
+        for i, item := range v {
+            problems = append(problems, schemaProblems(items, item, fmt.Sprintf("%s[%d]", at, i))...)
+        }
+    case string:
+        if want != "" && want != "string" {
+            problems = append(problems, at+" is a string, want "+want)
+        }
+    case float64:
+        if want == "integer" && v != math.Trunc(v) {
+            problems = append(problems, fmt.Sprintf("%s is %v, want an integer", at, v))
+        } else if want != "" && want != "integer" && want != "number" {
+            problems = append(problems, at+" is a number, want "+want)
+        }
+    case bool:
+        if want != "" && want != "boolean" {
+            problems = append(problems, at+" is a boolean, want "+want)
+        }
+    }
+    return problems
+}
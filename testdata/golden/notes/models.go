@@ -0,0 +1,19 @@
+package main
+
+// Auto-generated structs from OpenAPI spec
+
+type Item struct {
+    Id int `json:"id,omitempty"`
+    Name string `json:"name,omitempty"`
+}
+
+type ListItemsResponse200 []interface{}
+
+type Note struct {
+    Id string `json:"id,omitempty"`
+    Kind string `json:"kind,omitempty"`
+    Meta map[string]interface{} `json:"meta,omitempty"`
+    Tags []interface{} `json:"tags,omitempty"`
+    Title string `json:"title"`
+}
+
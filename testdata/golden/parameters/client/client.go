@@ -0,0 +1,419 @@
+// Package client is a generated Go client for Parameter Styles API.
+package client
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "math/rand/v2"
+    "net/http"
+    "net/url"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Client calls Parameter Styles API. Methods retry idempotent requests that fail with a
+// network error, 429, 502, 503 or 504, following Retry.
+type Client struct {
+    BaseURL     string
+    HTTPClient  *http.Client
+    Retry       RetryPolicy
+    credentials map[string]func(*http.Request)
+    editors     []RequestEditor
+}
+
+// RetryPolicy sets how often a request is attempted and how long to wait in
+// between: exponential backoff from MinBackoff up to MaxBackoff, with jitter,
+// or the server's Retry-After when longer
+type RetryPolicy struct {
+    MaxAttempts int
+    MinBackoff  time.Duration
+    MaxBackoff  time.Duration
+}
+
+// DefaultRetry is the retry policy of new clients
+var DefaultRetry = RetryPolicy{MaxAttempts: 3, MinBackoff: 100 * time.Millisecond, MaxBackoff: 2 * time.Second}
+
+// RequestEditor changes requests before they are sent, e.g. to add headers
+type RequestEditor func(*http.Request) error
+
+// Option configures a Client
+type Option func(*Client)
+
+// New returns a client for the API served at baseURL, e.g. http://localhost:8080
+func New(baseURL string, opts ...Option) (*Client, error) {
+    u, err := url.Parse(baseURL)
+    if err != nil || u.Scheme == "" || u.Host == "" {
+        return nil, fmt.Errorf("invalid base URL %q", baseURL)
+    }
+    c := &Client{
+        BaseURL:     strings.TrimSuffix(baseURL, "/"),
+        HTTPClient:  http.DefaultClient,
+        Retry:       DefaultRetry,
+        credentials: map[string]func(*http.Request){},
+    }
+    for _, opt := range opts {
+        opt(c)
+    }
+    return c, nil
+}
+
+// WithHTTPClient sends requests with hc instead of http.DefaultClient
+func WithHTTPClient(hc *http.Client) Option {
+    return func(c *Client) {
+        c.HTTPClient = hc
+    }
+}
+
+// WithTransport sends requests through rt, e.g. to add tracing or logging
+func WithTransport(rt http.RoundTripper) Option {
+    return func(c *Client) {
+        c.HTTPClient = &http.Client{Transport: rt}
+    }
+}
+
+// WithRetry replaces DefaultRetry; MaxAttempts 1 disables retries
+func WithRetry(policy RetryPolicy) Option {
+    return func(c *Client) {
+        c.Retry = policy
+    }
+}
+
+// WithRequestEditor runs fn on every request before it is sent
+func WithRequestEditor(fn RequestEditor) Option {
+    return func(c *Client) {
+        c.editors = append(c.editors, fn)
+    }
+}
+
+// BlobRef references a file stored by the server
+type BlobRef struct {
+    Blob        string `json:"blob"`
+    ContentType string `json:"contentType,omitempty"`
+    Filename    string `json:"filename,omitempty"`
+    Size        int64  `json:"size"`
+    SHA256      string `json:"sha256,omitempty"`
+}
+
+// APIError is returned for responses without a success status. Model holds
+// the body decoded into the type the spec declares for the status, if any.
+type APIError struct {
+    Operation  string
+    StatusCode int
+    Header     http.Header
+    Body       []byte
+    Model      interface{}
+}
+
+func (e *APIError) Error() string {
+    msg := strings.TrimSpace(string(e.Body))
+    if len(msg) > 200 {
+        msg = msg[:200] + "..."
+    }
+    return fmt.Sprintf("%s: %d %s: %s", e.Operation, e.StatusCode, http.StatusText(e.StatusCode), msg)
+}
+
+// request describes a call to an operation
+type request struct {
+    operation      string
+    method         string
+    path           string
+    query          url.Values
+    header         http.Header
+    cookies        []*http.Cookie
+    body           []byte    // encoded JSON body
+    reader         io.Reader // raw body, retried only when it is an io.Seeker
+    contentType    string
+    accept         string
+    security       [][]string // alternatives of schemes that must all be sent
+    idempotencyKey bool       // send an Idempotency-Key so the POST can be retried
+    errorModel     func(status int) interface{}
+}
+
+// pathValue escapes a path parameter
+func pathValue(v interface{}) string {
+    return url.PathEscape(fmt.Sprint(v))
+}
+
+// jsonBody encodes a request body
+func jsonBody(v interface{}) ([]byte, error) {
+    data, err := json.Marshal(v)
+    if err != nil {
+        return nil, fmt.Errorf("encoding request body: %w", err)
+    }
+    return data, nil
+}
+
+// do sends req and decodes a JSON success body into out, when given
+func (c *Client) do(ctx context.Context, req request, out interface{}) error {
+    resp, err := c.send(ctx, req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    if out == nil {
+        io.Copy(io.Discard, resp.Body)
+        return nil
+    }
+    data, err := io.ReadAll(resp.Body)
+    if err != nil {
+        return fmt.Errorf("%s: reading response: %w", req.operation, err)
+    }
+    if len(bytes.TrimSpace(data)) == 0 {
+        return nil
+    }
+    if err := json.Unmarshal(data, out); err != nil {
+        return fmt.Errorf("%s: decoding response: %w", req.operation, err)
+    }
+    return nil
+}
+
+// send performs req, retrying idempotent requests, and turns responses
+// without a success status into an *APIError
+func (c *Client) send(ctx context.Context, req request) (*http.Response, error) {
+    if req.idempotencyKey && (req.header == nil || req.header.Get("Idempotency-Key") == "") {
+        if req.header == nil {
+            req.header = http.Header{}
+        }
+        req.header.Set("Idempotency-Key", fmt.Sprintf("%016x%016x", rand.Uint64(), rand.Uint64()))
+    }
+    seeker, seekable := req.reader.(io.Seeker)
+    retryable := req.reader == nil || seekable
+    switch req.method {
+    case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+    default:
+        retryable = retryable && req.header.Get("Idempotency-Key") != ""
+    }
+    attempts := c.Retry.MaxAttempts
+    if attempts < 1 || !retryable {
+        attempts = 1
+    }
+    for attempt := 1; ; attempt++ {
+        if seekable && attempt > 1 {
+            if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+                return nil, err
+            }
+        }
+        httpReq, err := c.newRequest(ctx, req)
+        if err != nil {
+            return nil, err
+        }
+        resp, err := c.HTTPClient.Do(httpReq)
+        var wait time.Duration
+        switch {
+        case err != nil:
+            if ctx.Err() != nil || attempt >= attempts {
+                return nil, fmt.Errorf("%s: %w", req.operation, err)
+            }
+        case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+            return resp, nil
+        case attempt < attempts && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusBadGateway ||
+            resp.StatusCode == http.StatusServiceUnavailable || resp.StatusCode == http.StatusGatewayTimeout):
+            if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+                wait = time.Duration(seconds) * time.Second
+            }
+            io.Copy(io.Discard, resp.Body)
+            resp.Body.Close()
+        default:
+            return nil, c.apiError(req, resp)
+        }
+        if backoff := c.backoff(attempt); backoff > wait {
+            wait = backoff
+        }
+        select {
+        case <-ctx.Done():
+            return nil, fmt.Errorf("%s: %w", req.operation, ctx.Err())
+        case <-time.After(wait):
+        }
+    }
+}
+
+// backoff is the jittered exponential delay after a failed attempt
+func (c *Client) backoff(attempt int) time.Duration {
+    d := c.Retry.MinBackoff << (attempt - 1)
+    if d > c.Retry.MaxBackoff || d <= 0 {
+        d = c.Retry.MaxBackoff
+    }
+    if d <= 0 {
+        return 0
+    }
+    return d/2 + time.Duration(rand.Int64N(int64(d/2)+1))
+}
+
+func (c *Client) newRequest(ctx context.Context, req request) (*http.Request, error) {
+    var body io.Reader
+    if req.body != nil {
+        body = bytes.NewReader(req.body)
+    } else if req.reader != nil {
+        body = io.NopCloser(req.reader)
+    }
+    u := c.BaseURL + req.path
+    if len(req.query) > 0 {
+        u += "?" + req.query.Encode()
+    }
+    httpReq, err := http.NewRequestWithContext(ctx, req.method, u, body)
+    if err != nil {
+        return nil, fmt.Errorf("%s: %w", req.operation, err)
+    }
+    for name, values := range req.header {
+        httpReq.Header[name] = values
+    }
+    for _, cookie := range req.cookies {
+        httpReq.AddCookie(cookie)
+    }
+    if body != nil && req.contentType != "" {
+        httpReq.Header.Set("Content-Type", req.contentType)
+    }
+    if req.accept != "" {
+        httpReq.Header.Set("Accept", req.accept)
+    }
+    c.authorize(httpReq, req.security)
+    for _, edit := range c.editors {
+        if err := edit(httpReq); err != nil {
+            return nil, fmt.Errorf("%s: %w", req.operation, err)
+        }
+    }
+    return httpReq, nil
+}
+
+// authorize sends the credentials of the first alternative the client has
+// all credentials for. Without any, the request is sent as is.
+func (c *Client) authorize(r *http.Request, alternatives [][]string) {
+    for _, schemes := range alternatives {
+        complete := true
+        for _, scheme := range schemes {
+            if c.credentials[scheme] == nil {
+                complete = false
+            }
+        }
+        if complete {
+            for _, scheme := range schemes {
+                c.credentials[scheme](r)
+            }
+            return
+        }
+    }
+}
+
+// apiError reads an error response into an *APIError
+func (c *Client) apiError(req request, resp *http.Response) error {
+    defer resp.Body.Close()
+    data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+    apiErr := &APIError{Operation: req.operation, StatusCode: resp.StatusCode, Header: resp.Header, Body: data}
+    if req.errorModel != nil {
+        if model := req.errorModel(resp.StatusCode); model != nil && json.Unmarshal(data, model) == nil {
+            apiErr.Model = model
+        }
+    }
+    return apiErr
+}
+
+// GetFileParams holds the query, header and cookie parameters of GetFile
+type GetFileParams struct {
+    Download *bool // query download
+}
+
+// GetFile calls GET /files/{path}
+// The caller closes the returned body.
+// params may be nil.
+func (c *Client) GetFile(ctx context.Context, path string, params *GetFileParams) (io.ReadCloser, error) {
+    req := request{operation: "getFile", method: "GET", path: "/files/" + pathValue(path)}
+    if params != nil {
+        req.query = url.Values{}
+        req.header = http.Header{}
+        if params.Download != nil {
+            req.query.Add("download", fmt.Sprint(*params.Download))
+        }
+    }
+    req.accept = "application/octet-stream"
+    httpResp, err := c.send(ctx, req)
+    if err != nil {
+        return nil, err
+    }
+    return httpResp.Body, nil
+}
+
+// PathLabel calls GET /label/{color}
+func (c *Client) PathLabel(ctx context.Context, color []interface{}) error {
+    req := request{operation: "pathLabel", method: "GET", path: "/label/" + pathValue(color)}
+    return c.do(ctx, req, nil)
+}
+
+// PathMatrix calls GET /matrix/{point}
+func (c *Client) PathMatrix(ctx context.Context, point map[string]interface{}) error {
+    req := request{operation: "pathMatrix", method: "GET", path: "/matrix/" + pathValue(point)}
+    return c.do(ctx, req, nil)
+}
+
+// SearchParams holds the query, header and cookie parameters of Search
+type SearchParams struct {
+    Q string // query q
+    Tags []string // query tags
+    Fields []string // query fields
+    Sizes []int // query sizes
+    Ranks []int // query ranks
+    Filter *map[string]interface{} // query filter
+    Page *int // query page
+    Exact *bool // query exact
+    XRequestId string // header X-Request-Id
+    XTrace *map[string]interface{} // header X-Trace
+    Session *string // cookie session
+    Prefs []string // cookie prefs
+}
+
+// Search calls GET /search
+func (c *Client) Search(ctx context.Context, params *SearchParams) (*SearchResponse200, error) {
+    req := request{operation: "search", method: "GET", path: "/search"}
+    if params != nil {
+        req.query = url.Values{}
+        req.header = http.Header{}
+        req.query.Add("q", fmt.Sprint(params.Q))
+        for _, v := range params.Tags {
+            req.query.Add("tags", fmt.Sprint(v))
+        }
+        for _, v := range params.Fields {
+            req.query.Add("fields", fmt.Sprint(v))
+        }
+        for _, v := range params.Sizes {
+            req.query.Add("sizes", fmt.Sprint(v))
+        }
+        for _, v := range params.Ranks {
+            req.query.Add("ranks", fmt.Sprint(v))
+        }
+        if params.Filter != nil {
+            req.query.Add("filter", fmt.Sprint(*params.Filter))
+        }
+        if params.Page != nil {
+            req.query.Add("page", fmt.Sprint(*params.Page))
+        }
+        if params.Exact != nil {
+            req.query.Add("exact", fmt.Sprint(*params.Exact))
+        }
+        req.header.Add("X-Request-Id", fmt.Sprint(params.XRequestId))
+        if params.XTrace != nil {
+            req.header.Add("X-Trace", fmt.Sprint(*params.XTrace))
+        }
+        if params.Session != nil {
+            req.cookies = append(req.cookies, &http.Cookie{Name: "session", Value: fmt.Sprint(*params.Session)})
+        }
+        for _, v := range params.Prefs {
+            req.cookies = append(req.cookies, &http.Cookie{Name: "prefs", Value: fmt.Sprint(v)})
+        }
+    }
+    req.accept = "application/json"
+    var result SearchResponse200
+    if err := c.do(ctx, req, &result); err != nil {
+        return nil, err
+    }
+    return &result, nil
+}
+
+// PathSimple calls GET /simple/{ids}
+func (c *Client) PathSimple(ctx context.Context, ids []interface{}) error {
+    req := request{operation: "pathSimple", method: "GET", path: "/simple/" + pathValue(ids)}
+    return c.do(ctx, req, nil)
+}
+
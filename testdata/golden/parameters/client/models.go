@@ -0,0 +1,11 @@
+package client
+
+// Auto-generated structs from OpenAPI spec
+
+type GetFileResponse200 BlobRef
+
+type SearchResponse200 struct {
+    Items []interface{} `json:"items,omitempty"`
+    Total int `json:"total,omitempty"`
+}
+
@@ -0,0 +1,49 @@
+package main
+
+// operations lists a command per operation, grouped by its first tag
+var operations = []operation{
+    {Group: "", Name: "get-file", OperationID: "getFile", Method: "GET", Path: "/files/{path}", Summary: "",
+        Params: []param{
+            {Name: "path", Flag: "path", In: "path", Type: "string", Required: true},
+            {Name: "download", Flag: "download", In: "query", Type: "boolean"},
+        },
+        Accept: "application/octet-stream",
+    },
+    {Group: "", Name: "path-label", OperationID: "pathLabel", Method: "GET", Path: "/label/{color}", Summary: "",
+        Params: []param{
+            {Name: "color", Flag: "color", In: "path", Type: "array", Items: "string", Required: true},
+        },
+    },
+    {Group: "", Name: "path-matrix", OperationID: "pathMatrix", Method: "GET", Path: "/matrix/{point}", Summary: "",
+        Params: []param{
+            {Name: "point", Flag: "point", In: "path", Type: "object", Required: true},
+        },
+    },
+    {Group: "", Name: "search", OperationID: "search", Method: "GET", Path: "/search", Summary: "",
+        Params: []param{
+            {Name: "q", Flag: "q", In: "query", Type: "string", Required: true},
+            {Name: "tags", Flag: "tags", In: "query", Type: "array", Items: "string"},
+            {Name: "fields", Flag: "fields", In: "query", Type: "array", Items: "string"},
+            {Name: "sizes", Flag: "sizes", In: "query", Type: "array", Items: "integer"},
+            {Name: "ranks", Flag: "ranks", In: "query", Type: "array", Items: "integer"},
+            {Name: "filter", Flag: "filter", In: "query", Type: "object"},
+            {Name: "page", Flag: "page", In: "query", Type: "integer"},
+            {Name: "exact", Flag: "exact", In: "query", Type: "boolean"},
+            {Name: "X-Request-Id", Flag: "x-request-id", In: "header", Type: "string", Required: true},
+            {Name: "X-Trace", Flag: "x-trace", In: "header", Type: "object"},
+            {Name: "session", Flag: "session", In: "cookie", Type: "string"},
+            {Name: "prefs", Flag: "prefs", In: "cookie", Type: "array", Items: "string"},
+        },
+        Accept: "application/json",
+    },
+    {Group: "", Name: "path-simple", OperationID: "pathSimple", Method: "GET", Path: "/simple/{ids}", Summary: "",
+        Params: []param{
+            {Name: "ids", Flag: "ids", In: "path", Type: "array", Items: "integer", Required: true},
+        },
+    },
+}
+
+// schemes lists the security schemes and the environment variables holding
+// their credentials
+var schemes = map[string]scheme{
+}
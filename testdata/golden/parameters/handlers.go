@@ -0,0 +1,158 @@
+package main
+
+import (
+    "encoding/json"
+    "net/http"
+    "github.com/dgraph-io/badger/v3"
+)
+
+func GetFile(w http.ResponseWriter, r *http.Request) {
+    key, err := resourceKey(r, "files", "path")
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    var result []byte
+    var version uint64
+    err = dbView(r.Context(), func(txn *badger.Txn) error {
+        var err error
+        result, version, err = getRecord(txn, key)
+        return err
+    })
+    if err == badger.ErrKeyNotFound {
+        http.Error(w, "Files not found", http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, "Database error", http.StatusInternalServerError)
+        return
+    }
+    if notModified(w, r, version) {
+        return
+    }
+    writeDocument(w, r, http.StatusOK, "GetFileResponse200", json.RawMessage(result))
+}
+
+func PathLabel(w http.ResponseWriter, r *http.Request) {
+    key, err := resourceKey(r, "label", "color")
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    var result []byte
+    var version uint64
+    err = dbView(r.Context(), func(txn *badger.Txn) error {
+        var err error
+        result, version, err = getRecord(txn, key)
+        return err
+    })
+    if err == badger.ErrKeyNotFound {
+        http.Error(w, "Label not found", http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, "Database error", http.StatusInternalServerError)
+        return
+    }
+    if notModified(w, r, version) {
+        return
+    }
+    writeDocument(w, r, http.StatusOK, "Label", json.RawMessage(result))
+}
+
+func PathMatrix(w http.ResponseWriter, r *http.Request) {
+    key, err := resourceKey(r, "matrix", "point")
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    var result []byte
+    var version uint64
+    err = dbView(r.Context(), func(txn *badger.Txn) error {
+        var err error
+        result, version, err = getRecord(txn, key)
+        return err
+    })
+    if err == badger.ErrKeyNotFound {
+        http.Error(w, "Matrix not found", http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, "Database error", http.StatusInternalServerError)
+        return
+    }
+    if notModified(w, r, version) {
+        return
+    }
+    writeDocument(w, r, http.StatusOK, "Matrix", json.RawMessage(result))
+}
+
+func Search(w http.ResponseWriter, r *http.Request) {
+    prefix := "search:"
+    var err error
+    id := r.URL.Query().Get("id")
+    if id == "" {
+        var items []json.RawMessage
+        err = dbView(r.Context(), func(txn *badger.Txn) error {
+            var err error
+            items, err = listChildren(txn, prefix)
+            return err
+        })
+        if err == badger.ErrKeyNotFound {
+            http.Error(w, "Search not found", http.StatusNotFound)
+            return
+        } else if err != nil {
+            http.Error(w, "Database error", http.StatusInternalServerError)
+            return
+        }
+        writeDocument(w, r, http.StatusOK, "Search", items)
+        return
+    }
+    if !validKeyPart(id) {
+        http.Error(w, "Invalid ID", http.StatusBadRequest)
+        return
+    }
+    key := prefix + id
+    var result []byte
+    var version uint64
+    err = dbView(r.Context(), func(txn *badger.Txn) error {
+        var err error
+        result, version, err = getRecord(txn, key)
+        return err
+    })
+    if err == badger.ErrKeyNotFound {
+        http.Error(w, "Search not found", http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, "Database error", http.StatusInternalServerError)
+        return
+    }
+    if notModified(w, r, version) {
+        return
+    }
+    writeDocument(w, r, http.StatusOK, "Search", json.RawMessage(result))
+}
+
+func PathSimple(w http.ResponseWriter, r *http.Request) {
+    key, err := resourceKey(r, "simple", "ids")
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    var result []byte
+    var version uint64
+    err = dbView(r.Context(), func(txn *badger.Txn) error {
+        var err error
+        result, version, err = getRecord(txn, key)
+        return err
+    })
+    if err == badger.ErrKeyNotFound {
+        http.Error(w, "Simple not found", http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, "Database error", http.StatusInternalServerError)
+        return
+    }
+    if notModified(w, r, version) {
+        return
+    }
+    writeDocument(w, r, http.StatusOK, "Simple", json.RawMessage(result))
+}
+
@@ -0,0 +1,526 @@
+package main
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "encoding/xml"
+    "errors"
+    "fmt"
+    "io"
+    "mime"
+    "net/http"
+    "net/url"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// MultipartMemory is how much of a multipart body is held in memory; larger
+// file parts are buffered in temporary files before they are stored
+var MultipartMemory int64 = 8 << 20
+
+// mediaClass groups media types by how bodies of that type are read and written
+func mediaClass(mediaType string) string {
+    switch {
+    case mediaType == "" || mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+        return "json"
+    case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+        return "xml"
+    case mediaType == "application/x-www-form-urlencoded":
+        return "form"
+    case strings.HasPrefix(mediaType, "multipart/"):
+        return "multipart"
+    case strings.HasPrefix(mediaType, "text/"):
+        return "text"
+    }
+    return "binary"
+}
+
+// matchMediaType reports whether mediaType matches pattern, which may be a
+// range such as image/* or */*
+func matchMediaType(pattern, mediaType string) bool {
+    if pattern == "*/*" || pattern == mediaType {
+        return true
+    }
+    return strings.HasSuffix(pattern, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(pattern, "*"))
+}
+
+type responseTypeKey struct{}
+
+// negotiate answers 415 when the request body has a type the operation does
+// not consume and 406 when the client accepts none of the types it produces.
+// A body without Content-Type is read as JSON. The selected response type is
+// used by writeDocument.
+func negotiate(next http.Handler, consumes, produces []string) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if len(consumes) > 0 && r.ContentLength != 0 {
+            mediaType := "application/json"
+            if header := r.Header.Get("Content-Type"); header != "" {
+                mediaType, _, _ = mime.ParseMediaType(header)
+            }
+            supported := false
+            for _, pattern := range consumes {
+                supported = supported || matchMediaType(pattern, mediaType)
+            }
+            if !supported {
+                w.Header().Set("Accept", strings.Join(consumes, ", "))
+                http.Error(w, fmt.Sprintf("Unsupported media type %q, expected one of %s", mediaType, strings.Join(consumes, ", ")), http.StatusUnsupportedMediaType)
+                return
+            }
+        }
+        if len(produces) > 0 {
+            if len(produces) > 1 {
+                w.Header().Add("Vary", "Accept")
+            }
+            mediaType := selectMediaType(r.Header.Get("Accept"), produces)
+            if mediaType == "" {
+                http.Error(w, "Not acceptable, available types are "+strings.Join(produces, ", "), http.StatusNotAcceptable)
+                return
+            }
+            r = r.WithContext(context.WithValue(r.Context(), responseTypeKey{}, mediaType))
+        }
+        next.ServeHTTP(w, r)
+    })
+}
+
+// selectMediaType picks the offer the Accept header prefers, the first offer
+// when the header is empty, or "" when none is acceptable
+func selectMediaType(accept string, offers []string) string {
+    if strings.TrimSpace(accept) == "" {
+        return offers[0]
+    }
+    best, bestQ := "", 0.0
+    for _, offer := range offers {
+        if q := acceptQuality(accept, offer); q > bestQ {
+            best, bestQ = offer, q
+        }
+    }
+    return best
+}
+
+// acceptQuality returns the q-value of the most specific range in the Accept
+// header matching mediaType, or 0 when none does
+func acceptQuality(accept, mediaType string) float64 {
+    q, specificity := 0.0, -1
+    for _, part := range strings.Split(accept, ",") {
+        rangeType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+        if err != nil {
+            continue
+        }
+        s := 0
+        switch {
+        case rangeType == mediaType || strings.Contains(mediaType, "*") && matchMediaType(mediaType, rangeType):
+            s = 2
+        case rangeType != "*/*" && matchMediaType(rangeType, mediaType):
+            s = 1
+        case rangeType != "*/*":
+            continue
+        }
+        if s <= specificity {
+            continue
+        }
+        value := 1.0
+        if v, ok := params["q"]; ok {
+            if f, err := strconv.ParseFloat(v, 64); err == nil {
+                value = f
+            }
+        }
+        q, specificity = value, s
+    }
+    return q
+}
+
+// responseType returns the media type negotiated for the response
+func responseType(r *http.Request) string {
+    if mediaType, _ := r.Context().Value(responseTypeKey{}).(string); mediaType != "" {
+        return mediaType
+    }
+    return "application/json"
+}
+
+// errBlobReference rejects a body referencing a blob it did not upload
+var errBlobReference = errors.New("blob references cannot be sent in a request body, upload the file instead")
+
+// readDocument reads the request body in its declared media type and returns
+// it as the JSON document stored for the named schema. Form, multipart and XML
+// fields are converted to the schema's property types; text bodies become a
+// JSON string; files and other binary bodies are stored as blobs and
+// replaced by their BlobRef. The document may only reference the blobs
+// uploaded with it, so a client cannot claim a blob of another record; the
+// uploaded blobs are deleted again when the body is refused.
+func readDocument(r *http.Request, schema string) ([]byte, error) {
+    uploaded := map[string]bool{}
+    data, err := readBody(r, schema, uploaded)
+    if err == nil {
+        for id := range blobIDs(data) {
+            if !uploaded[id] {
+                err = errBlobReference
+            }
+        }
+    }
+    if err != nil {
+        for id := range uploaded {
+            deleteBlob(id)
+        }
+        return nil, err
+    }
+    return data, nil
+}
+
+// readBody converts the request body to a JSON document, recording the blobs
+// it stores in uploaded
+func readBody(r *http.Request, schema string, uploaded map[string]bool) ([]byte, error) {
+    var mediaType string
+    if header := r.Header.Get("Content-Type"); header != "" {
+        var err error
+        if mediaType, _, err = mime.ParseMediaType(header); err != nil {
+            return nil, err
+        }
+    }
+    switch mediaClass(mediaType) {
+    case "json":
+        return io.ReadAll(r.Body)
+    case "text":
+        data, err := io.ReadAll(r.Body)
+        if err != nil {
+            return nil, err
+        }
+        return json.Marshal(string(data))
+    case "xml":
+        doc, err := readXML(r.Body)
+        if err != nil {
+            return nil, fmt.Errorf("invalid XML: %v", err)
+        }
+        if obj, ok := doc.(map[string]interface{}); ok {
+            if err := coerceFields(schema, obj); err != nil {
+                return nil, err
+            }
+        }
+        return json.Marshal(doc)
+    case "form":
+        if err := r.ParseForm(); err != nil {
+            return nil, err
+        }
+        doc := formFields(r.PostForm)
+        if err := coerceFields(schema, doc); err != nil {
+            return nil, err
+        }
+        return json.Marshal(doc)
+    case "multipart":
+        doc, err := readMultipart(r, schema, uploaded)
+        if err != nil {
+            return nil, err
+        }
+        return json.Marshal(doc)
+    }
+    ref, err := putBlob(r.Context(), r.Body, mediaType, "")
+    if err != nil {
+        return nil, err
+    }
+    uploaded[ref.Blob] = true
+    return json.Marshal(ref)
+}
+
+// formFields turns form values into a document, with repeated fields as arrays
+func formFields(values map[string][]string) map[string]interface{} {
+    doc := make(map[string]interface{}, len(values))
+    for name, list := range values {
+        if len(list) == 1 {
+            doc[name] = list[0]
+            continue
+        }
+        items := make([]interface{}, len(list))
+        for i, v := range list {
+            items[i] = v
+        }
+        doc[name] = items
+    }
+    return doc
+}
+
+// readMultipart reads the fields of a multipart body. File parts of binary
+// properties, or of properties the schema does not know, are stored as blobs
+// and recorded in uploaded; file parts of other properties are read as text.
+func readMultipart(r *http.Request, schema string, uploaded map[string]bool) (map[string]interface{}, error) {
+    if err := r.ParseMultipartForm(MultipartMemory); err != nil {
+        return nil, err
+    }
+    defer r.MultipartForm.RemoveAll()
+    doc := formFields(r.MultipartForm.Value)
+    if err := coerceFields(schema, doc); err != nil {
+        return nil, err
+    }
+    for name, files := range r.MultipartForm.File {
+        want := schemaRules[schema].Properties[name]
+        values := make([]interface{}, 0, len(files))
+        for _, fh := range files {
+            f, err := fh.Open()
+            if err != nil {
+                return nil, err
+            }
+            if want == "" || want == "binary" || want == "binary[]" {
+                ref, err := putBlob(r.Context(), f, fh.Header.Get("Content-Type"), fh.Filename)
+                f.Close()
+                if err != nil {
+                    return nil, err
+                }
+                uploaded[ref.Blob] = true
+                values = append(values, ref)
+                continue
+            }
+            data, err := io.ReadAll(f)
+            f.Close()
+            if err != nil {
+                return nil, err
+            }
+            values = append(values, string(data))
+        }
+        if len(values) == 1 && want != "array" && want != "binary[]" {
+            doc[name] = values[0]
+        } else {
+            doc[name] = values
+        }
+    }
+    return doc, nil
+}
+
+// coerceFields converts the text values of form, multipart and XML fields to
+// the property types the named schema declares
+func coerceFields(schema string, doc map[string]interface{}) error {
+    props := schemaRules[schema].Properties
+    for name, value := range doc {
+        want := props[name]
+        if want == "array" || want == "binary[]" {
+            if _, ok := value.([]interface{}); !ok {
+                doc[name] = []interface{}{value}
+            }
+            continue
+        }
+        s, ok := value.(string)
+        if !ok || want == "" || want == "string" {
+            continue
+        }
+        if s == "" {
+            doc[name] = nil
+            continue
+        }
+        switch want {
+        case "integer", "number":
+            n, err := strconv.ParseFloat(s, 64)
+            if err != nil {
+                return fmt.Errorf("field %q must be a number", name)
+            }
+            doc[name] = n
+        case "boolean":
+            b, err := strconv.ParseBool(s)
+            if err != nil {
+                return fmt.Errorf("field %q must be a boolean", name)
+            }
+            doc[name] = b
+        case "object":
+            var obj interface{}
+            if err := json.Unmarshal([]byte(s), &obj); err != nil {
+                return fmt.Errorf("field %q must be a JSON object", name)
+            }
+            doc[name] = obj
+        }
+    }
+    return nil
+}
+
+// readXML decodes an XML document into the shape writeXML produces: elements
+// with children become objects, repeated elements arrays and the remaining
+// elements their text. Attributes are ignored.
+func readXML(body io.Reader) (interface{}, error) {
+    dec := xml.NewDecoder(body)
+    for {
+        tok, err := dec.Token()
+        if err != nil {
+            return nil, err
+        }
+        if _, ok := tok.(xml.StartElement); ok {
+            return readXMLElement(dec)
+        }
+    }
+}
+
+func readXMLElement(dec *xml.Decoder) (interface{}, error) {
+    var text strings.Builder
+    var children map[string]interface{}
+    repeated := map[string]bool{}
+    for {
+        tok, err := dec.Token()
+        if err != nil {
+            return nil, err
+        }
+        switch t := tok.(type) {
+        case xml.StartElement:
+            child, err := readXMLElement(dec)
+            if err != nil {
+                return nil, err
+            }
+            if children == nil {
+                children = map[string]interface{}{}
+            }
+            name := t.Name.Local
+            prev, seen := children[name]
+            switch {
+            case !seen:
+                children[name] = child
+            case repeated[name]:
+                children[name] = append(prev.([]interface{}), child)
+            default:
+                children[name] = []interface{}{prev, child}
+                repeated[name] = true
+            }
+        case xml.CharData:
+            text.Write(t)
+        case xml.EndElement:
+            if children != nil {
+                return children, nil
+            }
+            return text.String(), nil
+        }
+    }
+}
+
+// writeDocument encodes v, any value that marshals to JSON, in the media type
+// negotiated for the response. root names the XML document element.
+func writeDocument(w http.ResponseWriter, r *http.Request, status int, root string, v interface{}) {
+    mediaType := responseType(r)
+    class := mediaClass(mediaType)
+    if class == "json" {
+        w.Header().Set("Content-Type", mediaType)
+        w.WriteHeader(status)
+        json.NewEncoder(w).Encode(v)
+        return
+    }
+    data, err := json.Marshal(v)
+    if err != nil {
+        http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+        return
+    }
+    var doc interface{}
+    dec := json.NewDecoder(bytes.NewReader(data))
+    dec.UseNumber()
+    dec.Decode(&doc)
+    switch class {
+    case "xml":
+        w.Header().Set("Content-Type", mediaType)
+        w.WriteHeader(status)
+        io.WriteString(w, xml.Header)
+        writeXML(w, root, doc)
+    case "form":
+        values := url.Values{}
+        obj, _ := doc.(map[string]interface{})
+        for name, value := range obj {
+            if items, ok := value.([]interface{}); ok {
+                for _, item := range items {
+                    values.Add(name, scalarText(item))
+                }
+                continue
+            }
+            values.Set(name, scalarText(value))
+        }
+        w.Header().Set("Content-Type", mediaType)
+        w.WriteHeader(status)
+        io.WriteString(w, values.Encode())
+    case "text":
+        w.Header().Set("Content-Type", mediaType+"; charset=utf-8")
+        w.WriteHeader(status)
+        if s, ok := doc.(string); ok {
+            io.WriteString(w, s)
+        } else {
+            w.Write(append(data, '\n'))
+        }
+    default:
+        if ref, ok := blobRefOf(doc); ok {
+            serveBlob(w, r, status, ref, mediaType)
+            return
+        }
+        if strings.Contains(mediaType, "*") {
+            mediaType = "application/octet-stream"
+        }
+        w.Header().Set("Content-Type", mediaType)
+        w.WriteHeader(status)
+        if s, ok := doc.(string); ok {
+            io.WriteString(w, s)
+        } else {
+            w.Write(data)
+        }
+    }
+}
+
+// writeXML writes v as an element named name. Object keys become child
+// elements in sorted order, null fields are left out, array fields repeat
+// their element and arrays without a field name use item elements.
+func writeXML(w io.Writer, name string, v interface{}) {
+    name = xmlName(name)
+    switch t := v.(type) {
+    case map[string]interface{}:
+        keys := make([]string, 0, len(t))
+        for k := range t {
+            keys = append(keys, k)
+        }
+        sort.Strings(keys)
+        fmt.Fprintf(w, "<%s>", name)
+        for _, k := range keys {
+            switch value := t[k].(type) {
+            case nil:
+            case []interface{}:
+                for _, item := range value {
+                    writeXML(w, k, item)
+                }
+            default:
+                writeXML(w, k, value)
+            }
+        }
+        fmt.Fprintf(w, "</%s>", name)
+    case []interface{}:
+        fmt.Fprintf(w, "<%s>", name)
+        for _, item := range t {
+            writeXML(w, "item", item)
+        }
+        fmt.Fprintf(w, "</%s>", name)
+    case nil:
+        fmt.Fprintf(w, "<%s/>", name)
+    default:
+        fmt.Fprintf(w, "<%s>", name)
+        xml.EscapeText(w, []byte(scalarText(t)))
+        fmt.Fprintf(w, "</%s>", name)
+    }
+}
+
+// xmlName turns a property name into a valid XML element name
+func xmlName(name string) string {
+    name = strings.Map(func(r rune) rune {
+        if r == '_' || r == '-' || r == '.' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+            return r
+        }
+        return '_'
+    }, name)
+    if name == "" {
+        return "item"
+    }
+    if c := name[0]; c == '-' || c == '.' || c >= '0' && c <= '9' {
+        return "_" + name
+    }
+    return name
+}
+
+// scalarText renders a decoded JSON scalar as text
+func scalarText(v interface{}) string {
+    switch t := v.(type) {
+    case nil:
+        return ""
+    case string:
+        return t
+    case json.Number:
+        return t.String()
+    case bool:
+        return strconv.FormatBool(t)
+    }
+    data, _ := json.Marshal(v)
+    return string(data)
+}
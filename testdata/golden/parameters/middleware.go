@@ -0,0 +1,381 @@
+package main
+
+import (
+    "compress/gzip"
+    "context"
+    "crypto/rand"
+    "encoding/hex"
+    "fmt"
+    "io"
+    "log/slog"
+    "net/http"
+    "runtime/debug"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+    "github.com/klauspost/compress/zstd"
+)
+
+// route is an operation of the spec registered on the mux
+type route struct {
+    Pattern     string
+    OperationID string
+    Tags        []string
+    Handler     http.HandlerFunc
+    BodyLimit   int64    // overrides MaxBodyBytes when set
+    Consumes    []string // request media types; others are answered with 415
+    Produces    []string // response media types; clients accepting none get 406
+    Idempotent  bool     // POST honouring Idempotency-Key
+    RateLimits  []*RateLimit
+}
+
+// handler wraps the operation in its span, metrics, body limit, rate limits,
+// authentication, content negotiation, Idempotency-Key replay and the
+// middleware registered for its tags and operation ID
+func (rt route) handler() http.Handler {
+    h := chain(rt.Handler, operationMiddleware[rt.OperationID])
+    for i := len(rt.Tags) - 1; i >= 0; i-- {
+        h = chain(h, tagMiddleware[rt.Tags[i]])
+    }
+    if rt.Idempotent {
+        h = idempotent(h, rt.OperationID)
+    }
+    if len(rt.Consumes) > 0 || len(rt.Produces) > 0 {
+        h = negotiate(h, rt.Consumes, rt.Produces)
+    }
+    if len(rt.RateLimits) > 0 {
+        h = limitRate(h, rt.RateLimits)
+    }
+    h = instrument(limitBody(h, rt.BodyLimit), rt.OperationID, strings.Fields(rt.Pattern)[0])
+    return traceOperation(h, rt.OperationID, rt.Pattern)
+}
+
+// MaxBodyBytes caps request bodies of routes without their own limit
+var MaxBodyBytes int64 = 1048576
+
+// Cors configures cross-origin requests; an empty AllowOrigins disables CORS
+var Cors = CORSConfig{}
+
+// Middleware wraps an http.Handler
+type Middleware func(http.Handler) http.Handler
+
+var (
+    globalMiddleware    []Middleware
+    tagMiddleware       = map[string][]Middleware{}
+    operationMiddleware = map[string][]Middleware{}
+)
+
+// Use registers middleware for every request. Like UseTag and UseOperation it
+// must be called before the server starts, typically from an init function in
+// a file of your own so regenerating the server keeps it:
+//
+//     func init() {
+//         Use(myMiddleware)
+//         UseTag("admin", requireAdmin)
+//         UseOperation("createUser", audit)
+//     }
+//
+// Global middleware runs before routing. Tag middleware, in the order the
+// operation lists its tags, then operation middleware run after authentication.
+// Each list runs in registration order.
+func Use(mw ...Middleware) {
+    globalMiddleware = append(globalMiddleware, mw...)
+}
+
+// UseTag registers middleware for the operations carrying a tag
+func UseTag(tag string, mw ...Middleware) {
+    tagMiddleware[tag] = append(tagMiddleware[tag], mw...)
+}
+
+// UseOperation registers middleware for a single operation ID
+func UseOperation(operationID string, mw ...Middleware) {
+    operationMiddleware[operationID] = append(operationMiddleware[operationID], mw...)
+}
+
+// chain applies middleware so the first one registered runs first
+func chain(h http.Handler, mw []Middleware) http.Handler {
+    for i := len(mw) - 1; i >= 0; i-- {
+        h = mw[i](h)
+    }
+    return h
+}
+
+// buildHandler wraps the mux in the built-in middleware and global user
+// middleware, outermost first: request ID, access log, panic recovery, CORS,
+// compression
+func buildHandler(mux http.Handler) http.Handler {
+    h := chain(mux, globalMiddleware)
+    h = compress(h)
+    h = cors(h)
+    h = recoverPanics(h)
+    h = accessLog(h)
+    return requestID(h)
+}
+
+// requestID propagates a well-formed X-Request-ID header or assigns a new ID,
+// echoing it in the response
+func requestID(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        id := r.Header.Get("X-Request-ID")
+        if !validRequestID(id) {
+            buf := make([]byte, 16)
+            rand.Read(buf)
+            id = hex.EncodeToString(buf)
+        }
+        w.Header().Set("X-Request-ID", id)
+        next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestInfoKey{}, &requestInfo{ID: id})))
+    })
+}
+
+func validRequestID(id string) bool {
+    if id == "" || len(id) > 128 {
+        return false
+    }
+    for _, c := range id {
+        if c < '!' || c > '~' {
+            return false
+        }
+    }
+    return true
+}
+
+// statusRecorder captures the status and size of a response
+type statusRecorder struct {
+    http.ResponseWriter
+    status int
+    bytes  int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+    if s.status == 0 {
+        s.status = status
+    }
+    s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(p []byte) (int, error) {
+    if s.status == 0 {
+        s.status = http.StatusOK
+    }
+    n, err := s.ResponseWriter.Write(p)
+    s.bytes += n
+    return n, err
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+    return s.ResponseWriter
+}
+
+// accessLog logs one line per request
+func accessLog(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        start := time.Now()
+        rec := &statusRecorder{ResponseWriter: w}
+        next.ServeHTTP(rec, r)
+        if rec.status == 0 {
+            rec.status = http.StatusOK
+        }
+        attrs := append(requestAttrs(r.Context()),
+            slog.String("method", r.Method),
+            slog.String("path", r.URL.RequestURI()),
+            slog.Int("status", rec.status),
+            slog.Int("bytes", rec.bytes),
+            slog.Duration("latency", time.Since(start)))
+        slog.InfoContext(r.Context(), "request", attrs...)
+    })
+}
+
+// recoverPanics turns a panicking handler into a 500 response
+func recoverPanics(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        defer func() {
+            if err := recover(); err != nil {
+                if err == http.ErrAbortHandler {
+                    panic(err)
+                }
+                LoggerFrom(r.Context()).Error("panic serving request", "method", r.Method, "path", r.URL.Path, "panic", fmt.Sprint(err), "stack", string(debug.Stack()))
+                http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+            }
+        }()
+        next.ServeHTTP(w, r)
+    })
+}
+
+// CORSConfig lists the cross-origin requests the server accepts. An origin of
+// "*" allows any origin.
+type CORSConfig struct {
+    AllowOrigins     []string
+    AllowMethods     []string
+    AllowHeaders     []string
+    ExposeHeaders    []string
+    AllowCredentials bool
+    MaxAge           int // seconds browsers may cache a preflight response
+}
+
+func (c CORSConfig) allowed(origin string) bool {
+    for _, o := range c.AllowOrigins {
+        if o == "*" || strings.EqualFold(o, origin) {
+            return true
+        }
+    }
+    return false
+}
+
+// cors adds CORS headers for allowed origins and answers preflight requests
+func cors(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        origin := r.Header.Get("Origin")
+        if origin == "" || !Cors.allowed(origin) {
+            next.ServeHTTP(w, r)
+            return
+        }
+        h := w.Header()
+        h.Add("Vary", "Origin")
+        h.Set("Access-Control-Allow-Origin", origin)
+        if Cors.AllowCredentials {
+            h.Set("Access-Control-Allow-Credentials", "true")
+        }
+        if len(Cors.ExposeHeaders) > 0 {
+            h.Set("Access-Control-Expose-Headers", strings.Join(Cors.ExposeHeaders, ", "))
+        }
+        if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" {
+            next.ServeHTTP(w, r)
+            return
+        }
+        methods := Cors.AllowMethods
+        if len(methods) == 0 {
+            methods = []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+        }
+        h.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+        if len(Cors.AllowHeaders) > 0 {
+            h.Set("Access-Control-Allow-Headers", strings.Join(Cors.AllowHeaders, ", "))
+        } else if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+            h.Set("Access-Control-Allow-Headers", requested)
+        }
+        if Cors.MaxAge > 0 {
+            h.Set("Access-Control-Max-Age", strconv.Itoa(Cors.MaxAge))
+        }
+        w.WriteHeader(http.StatusNoContent)
+    })
+}
+
+// limitBody rejects bodies larger than limit, or MaxBodyBytes when limit is 0
+func limitBody(next http.Handler, limit int64) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        max := limit
+        if max == 0 {
+            max = MaxBodyBytes
+        }
+        if max > 0 {
+            if r.ContentLength > max {
+                http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+                return
+            }
+            r.Body = http.MaxBytesReader(w, r.Body, max)
+        }
+        next.ServeHTTP(w, r)
+    })
+}
+
+var zstdEncoders = sync.Pool{New: func() interface{} {
+    enc, _ := zstd.NewWriter(nil)
+    return enc
+}}
+
+// compress encodes responses with zstd or gzip when the client accepts them
+func compress(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Add("Vary", "Accept-Encoding")
+        encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+        if encoding == "" || r.Method == http.MethodHead {
+            next.ServeHTTP(w, r)
+            return
+        }
+        cw := &compressWriter{ResponseWriter: w, encoding: encoding}
+        defer cw.Close()
+        next.ServeHTTP(cw, r)
+    })
+}
+
+// negotiateEncoding picks zstd, then gzip, from an Accept-Encoding header
+func negotiateEncoding(header string) string {
+    accepted := map[string]bool{}
+    for _, part := range strings.Split(header, ",") {
+        fields := strings.Split(part, ";")
+        name := strings.ToLower(strings.TrimSpace(fields[0]))
+        q := 1.0
+        for _, param := range fields[1:] {
+            if v, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+                q, _ = strconv.ParseFloat(v, 64)
+            }
+        }
+        accepted[name] = q > 0
+    }
+    for _, encoding := range []string{"zstd", "gzip"} {
+        if accepted[encoding] {
+            return encoding
+        }
+    }
+    return ""
+}
+
+// compressWriter starts compressing when the response turns out to have a
+// body that is not already encoded
+type compressWriter struct {
+    http.ResponseWriter
+    encoding    string
+    writer      io.WriteCloser
+    wroteHeader bool
+}
+
+func (c *compressWriter) WriteHeader(status int) {
+    if c.wroteHeader {
+        return
+    }
+    c.wroteHeader = true
+    h := c.Header()
+    // Responses served with byte ranges stay identity-encoded so offsets match
+    ranged := h.Get("Accept-Ranges") != "" || h.Get("Content-Range") != ""
+    if status != http.StatusNoContent && status != http.StatusNotModified && status >= 200 && h.Get("Content-Encoding") == "" && !ranged {
+        h.Del("Content-Length")
+        h.Set("Content-Encoding", c.encoding)
+        if c.encoding == "zstd" {
+            enc := zstdEncoders.Get().(*zstd.Encoder)
+            enc.Reset(c.ResponseWriter)
+            c.writer = enc
+        } else {
+            c.writer = gzip.NewWriter(c.ResponseWriter)
+        }
+    }
+    c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *compressWriter) Write(p []byte) (int, error) {
+    if !c.wroteHeader {
+        // sniff before compressing, net/http would see only encoded bytes
+        if c.Header().Get("Content-Type") == "" {
+            c.Header().Set("Content-Type", http.DetectContentType(p))
+        }
+        c.WriteHeader(http.StatusOK)
+    }
+    if c.writer == nil {
+        return c.ResponseWriter.Write(p)
+    }
+    return c.writer.Write(p)
+}
+
+func (c *compressWriter) Close() {
+    if c.writer == nil {
+        return
+    }
+    c.writer.Close()
+    if enc, ok := c.writer.(*zstd.Encoder); ok {
+        enc.Reset(nil)
+        zstdEncoders.Put(enc)
+    }
+}
+
+func (c *compressWriter) Unwrap() http.ResponseWriter {
+    return c.ResponseWriter
+}
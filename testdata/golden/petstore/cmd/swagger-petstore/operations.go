@@ -0,0 +1,31 @@
+package main
+
+// operations lists a command per operation, grouped by its first tag
+var operations = []operation{
+    {Group: "pets", Name: "list-pets", OperationID: "listPets", Method: "GET", Path: "/pets", Summary: "List all pets",
+        Params: []param{
+            {Name: "limit", Flag: "limit", In: "query", Type: "integer"},
+        },
+        Accept: "application/json",
+    },
+    {Group: "pets", Name: "create-pets", OperationID: "createPets", Method: "POST", Path: "/pets", Summary: "Create a pet",
+        BodyType: "application/json", BodyRequired: true,
+        Params: []param{
+            {Name: "id", Flag: "id", In: "body", Type: "integer"},
+            {Name: "name", Flag: "name", In: "body", Type: "string"},
+            {Name: "tag", Flag: "tag", In: "body", Type: "string"},
+        },
+        Idempotent: true,
+    },
+    {Group: "pets", Name: "show-pet-by-id", OperationID: "showPetById", Method: "GET", Path: "/pets/{petId}", Summary: "Info for a specific pet",
+        Params: []param{
+            {Name: "petId", Flag: "pet-id", In: "path", Type: "string", Required: true},
+        },
+        Accept: "application/json",
+    },
+}
+
+// schemes lists the security schemes and the environment variables holding
+// their credentials
+var schemes = map[string]scheme{
+}
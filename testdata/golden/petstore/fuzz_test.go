@@ -0,0 +1,196 @@
+package main
+
+import (
+    "bytes"
+    "io"
+    "log/slog"
+    "mime"
+    "net/http"
+    "net/http/httptest"
+    "net/url"
+    "strconv"
+    "strings"
+    "sync"
+    "testing"
+)
+
+// FuzzListPets sends listPets mutations of its example parameters
+func FuzzListPets(f *testing.F) {
+    ts, seed := fuzzSeed(f, "listPets")
+    f.Add("0")
+    f.Fuzz(func(t *testing.T, queryLimit string) {
+        fuzzRequest(t, ts, seed, fuzzInput{
+            Query: map[string]string{"limit": queryLimit},
+        })
+    })
+}
+
+// FuzzCreatePets sends createPets mutations of its example parameters and body
+func FuzzCreatePets(f *testing.F) {
+    ts, seed := fuzzSeed(f, "createPets")
+    f.Add([]byte(seed.Body))
+    f.Fuzz(func(t *testing.T, body []byte) {
+        fuzzRequest(t, ts, seed, fuzzInput{
+            Body: body,
+        })
+    })
+}
+
+// FuzzShowPetById sends showPetById mutations of its example parameters
+func FuzzShowPetById(f *testing.F) {
+    ts, seed := fuzzSeed(f, "showPetById")
+    f.Add(seed.Params["petId"])
+    f.Fuzz(func(t *testing.T, pathPetId string) {
+        fuzzRequest(t, ts, seed, fuzzInput{
+            Path: map[string]string{"petId": pathPetId},
+        })
+    })
+}
+
+// fuzzInput is one mutation of an operation's parameters, by location, and
+// body. Empty query, header and cookie values are left out of the request.
+type fuzzInput struct {
+    Path   map[string]string
+    Query  map[string]string
+    Header map[string]string
+    Cookie map[string]string
+    Body   []byte
+}
+
+// fuzzSeed serves the API for a fuzz target and returns the example request
+// of the operation, with the IDs of the resources created for its path
+func fuzzSeed(f *testing.F, operationID string) (*httptest.Server, operationTest) {
+    ts := newTestServer(f)
+    test := findTest(f, operationID)
+    target, _ := fillPath(f, ts, test)
+    params := map[string]string{}
+    templates, segments := strings.Split(test.Path, "/"), strings.Split(target, "/")
+    for i, segment := range templates {
+        if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") && i < len(segments) {
+            params[strings.Trim(segment, "{}")] = segments[i]
+        }
+    }
+    test.Params = params
+    logger := slog.Default()
+    f.Cleanup(func() { slog.SetDefault(logger) })
+    return ts, test
+}
+
+// fuzzRequest sends an operation a mutated request and fails on a server
+// error, which includes panics, or a success response that does not match
+// the schema the spec declares for it
+func fuzzRequest(t *testing.T, ts *httptest.Server, test operationTest, in fuzzInput) {
+    segments := strings.Split(test.Path, "/")
+    for i, segment := range segments {
+        if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+            segments[i] = url.PathEscape(in.Path[strings.Trim(segment, "{}")])
+        }
+    }
+    var body io.Reader
+    if test.ContentType != "" {
+        body = bytes.NewReader(in.Body)
+    }
+    req, err := http.NewRequest(test.Method, ts.URL+strings.Join(segments, "/"), body)
+    if err != nil {
+        t.Fatalf("%s: %v", test.OperationID, err)
+    }
+    query := req.URL.Query()
+    for name, value := range in.Query {
+        if value != "" {
+            query.Set(name, value)
+        }
+    }
+    req.URL.RawQuery = query.Encode()
+    for name, value := range in.Header {
+        if value == "" {
+            continue
+        }
+        if !validHeaderValue(value) {
+            t.Skip("not a valid header value")
+        }
+        req.Header.Set(name, value)
+    }
+    for name, value := range in.Cookie {
+        if value != "" {
+            req.AddCookie(&http.Cookie{Name: name, Value: value})
+        }
+    }
+    if test.ContentType != "" {
+        req.Header.Set("Content-Type", test.ContentType)
+    }
+
+    // The server's log is kept for the failure message, panics log their
+    // stack. Redirects are not followed, they lead to other operations.
+    logs := &fuzzLog{}
+    slog.SetDefault(slog.New(slog.NewTextHandler(logs, nil)))
+    client := *ts.Client()
+    client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+    resp, err := client.Do(req)
+    if err != nil {
+        t.Fatalf("%s %s: %v\n%s", test.Method, req.URL.RequestURI(), err, logs.String())
+    }
+    defer resp.Body.Close()
+    data, err := io.ReadAll(resp.Body)
+    if err != nil {
+        t.Fatalf("%s %s: reading response: %v", test.Method, req.URL.RequestURI(), err)
+    }
+    if resp.StatusCode >= 500 {
+        t.Fatalf("%s %s: status %d: %s\n%s", test.Method, req.URL.RequestURI(), resp.StatusCode, data, logs.String())
+    }
+    // Operations whose generated handler cannot answer with the declared
+    // schema have none in their test
+    if test.Schema != "" && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+        checkSchema(t, responsePointer(test, resp), resp, data)
+    }
+}
+
+// responsePointer locates the schema the spec declares for the status and
+// content type of a response, or returns "" when it declares none
+func responsePointer(test operationTest, resp *http.Response) string {
+    escape := strings.NewReplacer("~", "~0", "/", "~1")
+    responses := "/paths/" + escape.Replace(test.Path) + "/" + strings.ToLower(test.Method) + "/responses/"
+    status := strconv.Itoa(resp.StatusCode)
+    mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+    for _, code := range []string{status, status[:1] + "XX", "default"} {
+        declared, ok := specNode(responses + code).(map[string]interface{})
+        if !ok {
+            continue
+        }
+        content, _ := declared["content"].(map[string]interface{})
+        if _, ok := content[mediaType]; !ok {
+            return ""
+        }
+        return responses + code + "/content/" + escape.Replace(mediaType) + "/schema"
+    }
+    return ""
+}
+
+// fuzzLog collects the server's log during a request; the server may still
+// be writing when the test reads it
+type fuzzLog struct {
+    mu  sync.Mutex
+    buf bytes.Buffer
+}
+
+func (l *fuzzLog) Write(p []byte) (int, error) {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    return l.buf.Write(p)
+}
+
+func (l *fuzzLog) String() string {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    return l.buf.String()
+}
+
+// validHeaderValue reports whether a value can be sent in a header: no
+// control characters but tabs
+func validHeaderValue(value string) bool {
+    for i := 0; i < len(value); i++ {
+        if c := value[i]; (c < ' ' && c != '\t') || c == 0x7f {
+            return false
+        }
+    }
+    return true
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+    "encoding/json"
+    "net/http"
+    "strings"
+    "github.com/dgraph-io/badger/v3"
+)
+
+func ListPets(w http.ResponseWriter, r *http.Request) {
+    prefix := "pets:"
+    var err error
+    id := r.URL.Query().Get("id")
+    if id == "" {
+        var items []json.RawMessage
+        err = dbView(r.Context(), func(txn *badger.Txn) error {
+            var err error
+            items, err = listChildren(txn, prefix)
+            return err
+        })
+        if err == badger.ErrKeyNotFound {
+            http.Error(w, "Pets not found", http.StatusNotFound)
+            return
+        } else if err != nil {
+            http.Error(w, "Database error", http.StatusInternalServerError)
+            return
+        }
+        writeDocument(w, r, http.StatusOK, "Pets", items)
+        return
+    }
+    if !validKeyPart(id) {
+        http.Error(w, "Invalid ID", http.StatusBadRequest)
+        return
+    }
+    key := prefix + id
+    var result []byte
+    var version uint64
+    err = dbView(r.Context(), func(txn *badger.Txn) error {
+        var err error
+        result, version, err = getRecord(txn, key)
+        return err
+    })
+    if err == badger.ErrKeyNotFound {
+        http.Error(w, "Pets not found", http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, "Database error", http.StatusInternalServerError)
+        return
+    }
+    if notModified(w, r, version) {
+        return
+    }
+    writeDocument(w, r, http.StatusOK, "Pets", json.RawMessage(result))
+}
+
+func CreatePets(w http.ResponseWriter, r *http.Request) {
+    prefix := "pets:"
+    var err error
+    id := newID()
+    key := prefix + id
+    data, err := readValidated(r, "Pet")
+    if err != nil {
+        http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+        return
+    }
+    data, err = withID(data, "id", id, true)
+    if err != nil {
+        http.Error(w, "Invalid ID", http.StatusBadRequest)
+        return
+    }
+    var version uint64
+    err = dbUpdate(r.Context(), func(txn *badger.Txn) error {
+        var err error
+        version, err = putRecord(txn, key, data, 0)
+        return err
+    })
+    if err != nil {
+        http.Error(w, "Failed to save data", http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("ETag", etagFor(version))
+    w.Header().Set("Location", strings.TrimSuffix(r.URL.Path, "/")+"/"+id)
+    w.WriteHeader(http.StatusCreated)
+}
+
+func ShowPetById(w http.ResponseWriter, r *http.Request) {
+    key, err := resourceKey(r, "pets", "petId")
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    var result []byte
+    var version uint64
+    err = dbView(r.Context(), func(txn *badger.Txn) error {
+        var err error
+        result, version, err = getRecord(txn, key)
+        return err
+    })
+    if err == badger.ErrKeyNotFound {
+        http.Error(w, "Pets not found", http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, "Database error", http.StatusInternalServerError)
+        return
+    }
+    if notModified(w, r, version) {
+        return
+    }
+    var resp Pet
+    if err := json.Unmarshal(result, &resp); err != nil {
+        http.Error(w, "Failed to parse data", http.StatusInternalServerError)
+        return
+    }
+    writeDocument(w, r, http.StatusOK, "Pet", resp)
+}
+
@@ -0,0 +1,166 @@
+package main
+
+import (
+    "fmt"
+    "net/http"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+    "github.com/dgraph-io/badger/v3"
+)
+
+// Paths of the probe and metrics endpoints; an empty path disables the endpoint
+var (
+    HealthPath  = "/healthz"
+    ReadyPath   = "/readyz"
+    MetricsPath = "/metrics"
+)
+
+// registerProbes adds the health, readiness and metrics endpoints to mux
+func registerProbes(mux *http.ServeMux) {
+    if HealthPath != "" {
+        mux.HandleFunc("GET "+HealthPath, healthz)
+    }
+    if ReadyPath != "" {
+        mux.HandleFunc("GET "+ReadyPath, readyz)
+    }
+    if MetricsPath != "" {
+        mux.HandleFunc("GET "+MetricsPath, serveMetrics)
+    }
+}
+
+// healthz reports that the process is up
+func healthz(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+    w.Write([]byte("ok\n"))
+}
+
+// readyz reports whether the server should receive traffic: it is not
+// starting or draining, and BadgerDB is open and accepts writes
+func readyz(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+    if !Ready() {
+        http.Error(w, "not ready: server is starting or shutting down", http.StatusServiceUnavailable)
+        return
+    }
+    if DB == nil || DB.IsClosed() {
+        http.Error(w, "not ready: database is closed", http.StatusServiceUnavailable)
+        return
+    }
+    err := DB.Update(func(txn *badger.Txn) error {
+        return txn.Set([]byte("_meta:readyz"), []byte(strconv.FormatInt(time.Now().UnixNano(), 10)))
+    })
+    if err != nil {
+        http.Error(w, "not ready: database is not writable: "+err.Error(), http.StatusServiceUnavailable)
+        return
+    }
+    w.Write([]byte("ok\n"))
+}
+
+// durationBuckets are the upper bounds, in seconds, of the latency histogram
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// operationMetrics accumulates the requests of one operation
+type operationMetrics struct {
+    mu       sync.Mutex
+    method   string
+    statuses map[int]uint64
+    buckets  []uint64 // cumulative counts per durationBuckets entry
+    sum      float64
+    count    uint64
+}
+
+var (
+    metricsMu            sync.Mutex
+    operationMetricsByID = map[string]*operationMetrics{}
+)
+
+// metricsFor returns the metrics of an operation, creating them on first use
+func metricsFor(operationID, method string) *operationMetrics {
+    metricsMu.Lock()
+    defer metricsMu.Unlock()
+    m, ok := operationMetricsByID[operationID]
+    if !ok {
+        m = &operationMetrics{method: method, statuses: map[int]uint64{}, buckets: make([]uint64, len(durationBuckets))}
+        operationMetricsByID[operationID] = m
+    }
+    return m
+}
+
+// instrument records the status and latency of every request to an operation
+func instrument(next http.Handler, operationID, method string) http.Handler {
+    m := metricsFor(operationID, method)
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        start := time.Now()
+        rec := &statusRecorder{ResponseWriter: w}
+        next.ServeHTTP(rec, r)
+        if rec.status == 0 {
+            rec.status = http.StatusOK
+        }
+        elapsed := time.Since(start).Seconds()
+        m.mu.Lock()
+        defer m.mu.Unlock()
+        m.statuses[rec.status]++
+        for i, bound := range durationBuckets {
+            if elapsed <= bound {
+                m.buckets[i]++
+            }
+        }
+        m.sum += elapsed
+        m.count++
+    })
+}
+
+// serveMetrics writes the metrics in the Prometheus text exposition format
+func serveMetrics(w http.ResponseWriter, r *http.Request) {
+    metricsMu.Lock()
+    ids := make([]string, 0, len(operationMetricsByID))
+    for id := range operationMetricsByID {
+        ids = append(ids, id)
+    }
+    metricsMu.Unlock()
+    sort.Strings(ids)
+
+    var b strings.Builder
+    b.WriteString("# HELP http_requests_total Requests handled per operation and status code.\n")
+    b.WriteString("# TYPE http_requests_total counter\n")
+    for _, id := range ids {
+        m := metricsFor(id, "")
+        m.mu.Lock()
+        codes := make([]int, 0, len(m.statuses))
+        for code := range m.statuses {
+            codes = append(codes, code)
+        }
+        sort.Ints(codes)
+        for _, code := range codes {
+            fmt.Fprintf(&b, "http_requests_total{operation=%q,method=%q,code=\"%d\"} %d\n", id, m.method, code, m.statuses[code])
+        }
+        m.mu.Unlock()
+    }
+    b.WriteString("# HELP http_request_duration_seconds Request latency per operation.\n")
+    b.WriteString("# TYPE http_request_duration_seconds histogram\n")
+    for _, id := range ids {
+        m := metricsFor(id, "")
+        m.mu.Lock()
+        for i, bound := range durationBuckets {
+            fmt.Fprintf(&b, "http_request_duration_seconds_bucket{operation=%q,le=%q} %d\n", id, strconv.FormatFloat(bound, 'g', -1, 64), m.buckets[i])
+        }
+        fmt.Fprintf(&b, "http_request_duration_seconds_bucket{operation=%q,le=\"+Inf\"} %d\n", id, m.count)
+        fmt.Fprintf(&b, "http_request_duration_seconds_sum{operation=%q} %g\n", id, m.sum)
+        fmt.Fprintf(&b, "http_request_duration_seconds_count{operation=%q} %d\n", id, m.count)
+        m.mu.Unlock()
+    }
+    if DB != nil && !DB.IsClosed() {
+        lsm, vlog := DB.Size()
+        b.WriteString("# HELP badger_lsm_size_bytes Size of the BadgerDB LSM tree.\n")
+        b.WriteString("# TYPE badger_lsm_size_bytes gauge\n")
+        fmt.Fprintf(&b, "badger_lsm_size_bytes %d\n", lsm)
+        b.WriteString("# HELP badger_vlog_size_bytes Size of the BadgerDB value log.\n")
+        b.WriteString("# TYPE badger_vlog_size_bytes gauge\n")
+        fmt.Fprintf(&b, "badger_vlog_size_bytes %d\n", vlog)
+    }
+    w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+    w.Write([]byte(b.String()))
+}
@@ -0,0 +1,17 @@
+package main
+
+// Auto-generated structs from OpenAPI spec
+
+type Error struct {
+    Code int `json:"code"`
+    Message string `json:"message"`
+}
+
+type Pet struct {
+    Id int `json:"id"`
+    Name string `json:"name"`
+    Tag string `json:"tag,omitempty"`
+}
+
+type Pets []interface{}
+
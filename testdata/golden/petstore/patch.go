@@ -0,0 +1,303 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "reflect"
+    "strconv"
+    "strings"
+)
+
+// patchFormat selects how a PATCH body is interpreted
+type patchFormat int
+
+const (
+    mergePatch patchFormat = iota // application/merge-patch+json, RFC 7396
+    jsonPatch                     // application/json-patch+json, RFC 6902
+)
+
+// patchError reports a patch that cannot be applied, with the HTTP status to return
+type patchError struct {
+    status int
+    msg    string
+}
+
+func (e *patchError) Error() string {
+    return e.msg
+}
+
+func badPatch(format string, args ...interface{}) error {
+    return &patchError{status: http.StatusBadRequest, msg: fmt.Sprintf(format, args...)}
+}
+
+func unprocessablePatch(format string, args ...interface{}) error {
+    return &patchError{status: http.StatusUnprocessableEntity, msg: fmt.Sprintf(format, args...)}
+}
+
+// applyPatch applies a patch document to the stored JSON and returns the result
+func applyPatch(format patchFormat, current, patch []byte) ([]byte, error) {
+    var doc interface{}
+    if err := json.Unmarshal(current, &doc); err != nil {
+        return nil, err
+    }
+    var patchDoc interface{}
+    if err := json.Unmarshal(patch, &patchDoc); err != nil {
+        return nil, badPatch("invalid patch document: %v", err)
+    }
+    var err error
+    if format == jsonPatch {
+        doc, err = applyJSONPatch(doc, patchDoc)
+        if err != nil {
+            return nil, err
+        }
+    } else {
+        doc = applyMergePatch(doc, patchDoc)
+    }
+    return json.Marshal(doc)
+}
+
+// applyMergePatch implements RFC 7396: objects are merged recursively, null
+// removes a member and any other value replaces the target
+func applyMergePatch(target, patch interface{}) interface{} {
+    patchObj, ok := patch.(map[string]interface{})
+    if !ok {
+        return patch
+    }
+    targetObj, ok := target.(map[string]interface{})
+    if !ok {
+        targetObj = map[string]interface{}{}
+    }
+    for name, value := range patchObj {
+        if value == nil {
+            delete(targetObj, name)
+            continue
+        }
+        targetObj[name] = applyMergePatch(targetObj[name], value)
+    }
+    return targetObj
+}
+
+// applyJSONPatch implements RFC 6902, applying every operation in order and
+// failing the whole patch if any operation fails
+func applyJSONPatch(doc, patch interface{}) (interface{}, error) {
+    ops, ok := patch.([]interface{})
+    if !ok {
+        return nil, badPatch("JSON Patch document must be an array")
+    }
+    for i, raw := range ops {
+        op, ok := raw.(map[string]interface{})
+        if !ok {
+            return nil, badPatch("operation %d must be an object", i)
+        }
+        name, _ := op["op"].(string)
+        path, ok := op["path"].(string)
+        if !ok {
+            return nil, badPatch("operation %d is missing \"path\"", i)
+        }
+        tokens, err := parsePointer(path)
+        if err != nil {
+            return nil, err
+        }
+        value, hasValue := op["value"]
+        switch name {
+        case "add", "replace", "test":
+            if !hasValue {
+                return nil, badPatch("operation %d is missing \"value\"", i)
+            }
+        case "move", "copy":
+            from, ok := op["from"].(string)
+            if !ok {
+                return nil, badPatch("operation %d is missing \"from\"", i)
+            }
+            fromTokens, err := parsePointer(from)
+            if err != nil {
+                return nil, err
+            }
+            if name == "move" && strings.HasPrefix(path+"/", from+"/") && path != from {
+                return nil, unprocessablePatch("cannot move %s into one of its children", from)
+            }
+            if value, err = getPointer(doc, fromTokens); err != nil {
+                return nil, err
+            }
+            if name == "move" {
+                if doc, err = removePointer(doc, fromTokens); err != nil {
+                    return nil, err
+                }
+            } else {
+                value = deepCopy(value)
+            }
+        }
+        switch name {
+        case "add", "move", "copy":
+            doc, err = addPointer(doc, tokens, value, false)
+        case "replace":
+            doc, err = addPointer(doc, tokens, value, true)
+        case "remove":
+            doc, err = removePointer(doc, tokens)
+        case "test":
+            var actual interface{}
+            if actual, err = getPointer(doc, tokens); err == nil && !reflect.DeepEqual(actual, value) {
+                err = &patchError{status: http.StatusConflict, msg: fmt.Sprintf("test failed at %s", path)}
+            }
+        default:
+            err = badPatch("operation %d has unknown op %q", i, name)
+        }
+        if err != nil {
+            return nil, err
+        }
+    }
+    return doc, nil
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer into unescaped reference tokens
+func parsePointer(ptr string) ([]string, error) {
+    if ptr == "" {
+        return nil, nil
+    }
+    if !strings.HasPrefix(ptr, "/") {
+        return nil, badPatch("invalid JSON pointer %q", ptr)
+    }
+    tokens := strings.Split(ptr[1:], "/")
+    for i, token := range tokens {
+        tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+    }
+    return tokens, nil
+}
+
+// arrayIndex parses an array reference token, allowing size for appends
+func arrayIndex(token string, size int) (int, error) {
+    i, err := strconv.Atoi(token)
+    if err != nil || i < 0 || i > size || (len(token) > 1 && token[0] == '0') {
+        return 0, unprocessablePatch("invalid array index %q", token)
+    }
+    return i, nil
+}
+
+func getPointer(doc interface{}, tokens []string) (interface{}, error) {
+    for _, token := range tokens {
+        switch node := doc.(type) {
+        case map[string]interface{}:
+            value, ok := node[token]
+            if !ok {
+                return nil, unprocessablePatch("path member %q does not exist", token)
+            }
+            doc = value
+        case []interface{}:
+            i, err := arrayIndex(token, len(node)-1)
+            if err != nil {
+                return nil, err
+            }
+            doc = node[i]
+        default:
+            return nil, unprocessablePatch("cannot traverse into a scalar at %q", token)
+        }
+    }
+    return doc, nil
+}
+
+// addPointer inserts value at the location, or replaces an existing value when
+// replace is set, returning the possibly reallocated document
+func addPointer(doc interface{}, tokens []string, value interface{}, replace bool) (interface{}, error) {
+    if len(tokens) == 0 {
+        return value, nil
+    }
+    token := tokens[0]
+    switch node := doc.(type) {
+    case map[string]interface{}:
+        if len(tokens) == 1 {
+            if _, ok := node[token]; replace && !ok {
+                return nil, unprocessablePatch("path member %q does not exist", token)
+            }
+            node[token] = value
+            return node, nil
+        }
+        child, ok := node[token]
+        if !ok {
+            return nil, unprocessablePatch("path member %q does not exist", token)
+        }
+        updated, err := addPointer(child, tokens[1:], value, replace)
+        if err != nil {
+            return nil, err
+        }
+        node[token] = updated
+        return node, nil
+    case []interface{}:
+        if len(tokens) == 1 && !replace {
+            if token == "-" {
+                return append(node, value), nil
+            }
+            i, err := arrayIndex(token, len(node))
+            if err != nil {
+                return nil, err
+            }
+            node = append(node, nil)
+            copy(node[i+1:], node[i:])
+            node[i] = value
+            return node, nil
+        }
+        i, err := arrayIndex(token, len(node)-1)
+        if err != nil {
+            return nil, err
+        }
+        if len(tokens) == 1 {
+            node[i] = value
+            return node, nil
+        }
+        updated, err := addPointer(node[i], tokens[1:], value, replace)
+        if err != nil {
+            return nil, err
+        }
+        node[i] = updated
+        return node, nil
+    }
+    return nil, unprocessablePatch("cannot traverse into a scalar at %q", token)
+}
+
+// removePointer deletes the value at the location, which must exist
+func removePointer(doc interface{}, tokens []string) (interface{}, error) {
+    if len(tokens) == 0 {
+        return nil, unprocessablePatch("cannot remove the whole document")
+    }
+    token := tokens[0]
+    switch node := doc.(type) {
+    case map[string]interface{}:
+        child, ok := node[token]
+        if !ok {
+            return nil, unprocessablePatch("path member %q does not exist", token)
+        }
+        if len(tokens) == 1 {
+            delete(node, token)
+            return node, nil
+        }
+        updated, err := removePointer(child, tokens[1:])
+        if err != nil {
+            return nil, err
+        }
+        node[token] = updated
+        return node, nil
+    case []interface{}:
+        i, err := arrayIndex(token, len(node)-1)
+        if err != nil {
+            return nil, err
+        }
+        if len(tokens) == 1 {
+            return append(node[:i], node[i+1:]...), nil
+        }
+        updated, err := removePointer(node[i], tokens[1:])
+        if err != nil {
+            return nil, err
+        }
+        node[i] = updated
+        return node, nil
+    }
+    return nil, unprocessablePatch("cannot traverse into a scalar at %q", token)
+}
+
+// deepCopy clones a decoded JSON value so copies do not alias the source
+func deepCopy(value interface{}) interface{} {
+    data, _ := json.Marshal(value)
+    var clone interface{}
+    json.Unmarshal(data, &clone)
+    return clone
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+    "context"
+    "crypto/sha256"
+    "encoding/binary"
+    "encoding/hex"
+    "fmt"
+    "math"
+    "net"
+    "net/http"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+    "github.com/dgraph-io/badger/v3"
+)
+
+// RateLimit is a token bucket per client holding Burst requests and refilled
+// with Requests tokens per Period. Key selects how clients are told apart: ip,
+// apiKey or principal; clients without the credential are limited by IP.
+type RateLimit struct {
+    Name     string
+    Requests int64
+    Period   time.Duration
+    Burst    int64
+    Key      string
+}
+
+// globalRateLimit is the quota from the root x-rate-limit, shared by every
+// operation not exempted with x-rate-limit: false
+var globalRateLimit *RateLimit
+
+var (
+    // RateLimitStore keeps buckets in "memory", or in "badger" so limits
+    // survive restarts. BadgerDB is opened by one process at a time, so
+    // servers behind a load balancer each keep their own buckets.
+    RateLimitStore = "memory"
+    // TrustProxy takes client IPs from the last X-Forwarded-For entry, for
+    // servers running behind a reverse proxy that sets it
+    TrustProxy = false
+)
+
+// limitRate rejects requests exceeding any of the limits with 429 Too Many
+// Requests and Retry-After. RateLimit headers describe the limit closest to
+// being exhausted.
+func limitRate(next http.Handler, limits []*RateLimit) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        var tightest *RateLimit
+        var state bucketState
+        for _, limit := range limits {
+            s, err := takeToken(r.Context(), limit, clientKey(r, limit.Key))
+            if err != nil {
+                // Fail open: an unavailable store must not take the API down
+                LoggerFrom(r.Context()).Error("Rate limit store failed", "limit", limit.Name, "error", err)
+                continue
+            }
+            if tightest == nil || !s.Allowed || (state.Allowed && s.Tokens < state.Tokens) {
+                tightest, state = limit, s
+            }
+            if !s.Allowed {
+                break
+            }
+        }
+        if tightest == nil {
+            next.ServeHTTP(w, r)
+            return
+        }
+        h := w.Header()
+        h.Set("RateLimit-Limit", strconv.FormatInt(tightest.Requests, 10))
+        h.Set("RateLimit-Remaining", strconv.FormatInt(int64(state.Tokens), 10))
+        h.Set("RateLimit-Reset", strconv.FormatInt(ceilSeconds(tightest.fullAfter(state.Tokens)), 10))
+        h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d;burst=%d", tightest.Requests, ceilSeconds(tightest.Period), tightest.Burst))
+        if !state.Allowed {
+            h.Set("Retry-After", strconv.FormatInt(ceilSeconds(tightest.tokenAfter(state.Tokens)), 10))
+            http.Error(w, "Too many requests", http.StatusTooManyRequests)
+            return
+        }
+        next.ServeHTTP(w, r)
+    })
+}
+
+// bucketState is the outcome of taking a token from a bucket
+type bucketState struct {
+    Allowed bool
+    Tokens  float64 // tokens left after the request
+}
+
+// perToken is the time the bucket takes to regain one token
+func (l *RateLimit) perToken() time.Duration {
+    return l.Period / time.Duration(l.Requests)
+}
+
+// tokenAfter is how long a bucket holding tokens takes to allow a request
+func (l *RateLimit) tokenAfter(tokens float64) time.Duration {
+    return time.Duration((1 - tokens) * float64(l.perToken()))
+}
+
+// fullAfter is how long a bucket holding tokens takes to refill completely;
+// a bucket not touched for that long is the same as a new one
+func (l *RateLimit) fullAfter(tokens float64) time.Duration {
+    return time.Duration((float64(l.Burst) - tokens) * float64(l.perToken()))
+}
+
+// refill returns the tokens of a bucket last updated at updated, or a full
+// bucket when it has none
+func (l *RateLimit) refill(tokens float64, updated, now time.Time, found bool) float64 {
+    if !found {
+        return float64(l.Burst)
+    }
+    tokens += float64(now.Sub(updated)) / float64(l.perToken())
+    return math.Min(tokens, float64(l.Burst))
+}
+
+func ceilSeconds(d time.Duration) int64 {
+    return int64(math.Ceil(d.Seconds()))
+}
+
+// clientKey identifies the client a limit applies to. Credentials are hashed
+// so stored bucket keys do not reveal them.
+func clientKey(r *http.Request, by string) string {
+    id := "ip:" + clientIP(r)
+    sum := sha256.Sum256([]byte(id))
+    return hex.EncodeToString(sum[:16])
+}
+
+// clientIP returns the address of the client, or of the last proxy when
+// TrustProxy is off
+func clientIP(r *http.Request) string {
+    if TrustProxy {
+        if fwd := r.Header.Values("X-Forwarded-For"); len(fwd) > 0 {
+            hops := strings.Split(fwd[len(fwd)-1], ",")
+            if ip := strings.TrimSpace(hops[len(hops)-1]); ip != "" {
+                return ip
+            }
+        }
+    }
+    host, _, err := net.SplitHostPort(r.RemoteAddr)
+    if err != nil {
+        return r.RemoteAddr
+    }
+    return host
+}
+
+// takeToken takes a token from the client's bucket in the configured store
+func takeToken(ctx context.Context, limit *RateLimit, client string) (bucketState, error) {
+    key := "_ratelimit:" + limit.Name + ":" + client
+    if RateLimitStore == "badger" {
+        return badgerBuckets.take(ctx, limit, key)
+    }
+    return memoryBuckets.take(limit, key), nil
+}
+
+// take applies a request to a bucket holding tokens
+func (l *RateLimit) take(tokens float64) bucketState {
+    if tokens < 1 {
+        return bucketState{Tokens: tokens}
+    }
+    return bucketState{Allowed: true, Tokens: tokens - 1}
+}
+
+// memoryBucket is a bucket of the in-memory store
+type memoryBucket struct {
+    tokens  float64
+    updated time.Time
+    expires time.Time // when the bucket is full again and can be dropped
+}
+
+// memoryStore keeps buckets in a map, dropping full ones once a minute
+type memoryStore struct {
+    mu        sync.Mutex
+    buckets   map[string]*memoryBucket
+    lastSweep time.Time
+}
+
+var memoryBuckets = &memoryStore{buckets: map[string]*memoryBucket{}}
+
+func (m *memoryStore) take(limit *RateLimit, key string) bucketState {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    now := time.Now()
+    if now.Sub(m.lastSweep) > time.Minute {
+        for k, b := range m.buckets {
+            if now.After(b.expires) {
+                delete(m.buckets, k)
+            }
+        }
+        m.lastSweep = now
+    }
+    b, found := m.buckets[key]
+    if !found {
+        b = &memoryBucket{}
+        m.buckets[key] = b
+    }
+    state := limit.take(limit.refill(b.tokens, b.updated, now, found))
+    b.tokens, b.updated = state.Tokens, now
+    b.expires = now.Add(limit.fullAfter(state.Tokens))
+    return state
+}
+
+// badgerStore keeps buckets in BadgerDB as the token count and update time,
+// expiring once the bucket would be full again
+type badgerStore struct{}
+
+var badgerBuckets badgerStore
+
+func (badgerStore) take(ctx context.Context, limit *RateLimit, key string) (bucketState, error) {
+    var state bucketState
+    var err error
+    // Concurrent requests of a client conflict on its bucket; retry them
+    for attempt := 0; attempt < 5; attempt++ {
+        err = dbUpdate(ctx, func(txn *badger.Txn) error {
+            now := time.Now()
+            var tokens float64
+            var updated time.Time
+            item, err := txn.Get([]byte(key))
+            found := err == nil
+            if found {
+                err = item.Value(func(v []byte) error {
+                    if len(v) != 16 {
+                        return fmt.Errorf("invalid bucket %s", key)
+                    }
+                    tokens = math.Float64frombits(binary.BigEndian.Uint64(v[:8]))
+                    updated = time.Unix(0, int64(binary.BigEndian.Uint64(v[8:])))
+                    return nil
+                })
+                if err != nil {
+                    return err
+                }
+            } else if err != badger.ErrKeyNotFound {
+                return err
+            }
+            state = limit.take(limit.refill(tokens, updated, now, found))
+            value := make([]byte, 16)
+            binary.BigEndian.PutUint64(value[:8], math.Float64bits(state.Tokens))
+            binary.BigEndian.PutUint64(value[8:], uint64(now.UnixNano()))
+            ttl := limit.fullAfter(state.Tokens) + time.Second
+            return txn.SetEntry(badger.NewEntry([]byte(key), value).WithTTL(ttl))
+        })
+        if err != badger.ErrConflict {
+            break
+        }
+    }
+    return state, err
+}
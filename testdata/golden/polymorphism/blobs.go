@@ -0,0 +1,368 @@
+package main
+
+import (
+    "context"
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "hash"
+    "io"
+    "log/slog"
+    "mime"
+    "net/http"
+    "os"
+    "path/filepath"
+    "strconv"
+    "time"
+    "github.com/dgraph-io/badger/v3"
+)
+
+// BlobRef is stored in documents in place of an uploaded file or binary body
+type BlobRef struct {
+    Blob        string `json:"blob"`
+    ContentType string `json:"contentType,omitempty"`
+    Filename    string `json:"filename,omitempty"`
+    Size        int64  `json:"size"`
+    SHA256      string `json:"sha256,omitempty"`
+}
+
+// BlobDir stores blobs as files in this directory; when empty they are
+// stored in BadgerDB in chunks of blobChunkSize
+var BlobDir = ""
+
+// blobChunkSize is the size of the values a blob is split into in BadgerDB
+const blobChunkSize = 256 << 10
+
+func blobChunkKey(id string, n int) []byte {
+    return []byte(fmt.Sprintf("_blob:%s:%08d", id, n))
+}
+
+// errInvalidBlobID refuses a blob ID putBlob did not mint
+var errInvalidBlobID = errors.New("invalid blob ID")
+
+// validBlobID reports whether id has the form putBlob mints: 32 lowercase
+// hex digits. IDs are checked before they name a file or a key, so a
+// reference cannot reach outside the blob store.
+func validBlobID(id string) bool {
+    if len(id) != 32 {
+        return false
+    }
+    for _, c := range id {
+        if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+            return false
+        }
+    }
+    return true
+}
+
+// blobPath spreads blob files over subdirectories named after their ID prefix
+func blobPath(id string) (string, error) {
+    if !validBlobID(id) {
+        return "", errInvalidBlobID
+    }
+    return filepath.Join(BlobDir, id[:2], id), nil
+}
+
+// putBlob streams r into a new blob, recording its size and SHA-256
+// checksum. The blob's ID is always minted here, never taken from a client.
+// In BadgerDB each chunk is written in its own transaction, so blobs are not
+// limited by the size of a transaction.
+func putBlob(ctx context.Context, r io.Reader, contentType, filename string) (*BlobRef, error) {
+    buf := make([]byte, 16)
+    rand.Read(buf)
+    ref := &BlobRef{Blob: hex.EncodeToString(buf), ContentType: contentType, Filename: filename}
+    sum := sha256.New()
+    var err error
+    if BlobDir != "" {
+        ref.Size, err = putBlobFile(ref.Blob, io.TeeReader(r, sum))
+    } else {
+        ref.Size, err = putBlobChunks(ctx, ref.Blob, r, sum)
+    }
+    if err != nil {
+        deleteBlob(ref.Blob)
+        return nil, err
+    }
+    ref.SHA256 = hex.EncodeToString(sum.Sum(nil))
+    return ref, nil
+}
+
+func putBlobFile(id string, r io.Reader) (int64, error) {
+    path, err := blobPath(id)
+    if err != nil {
+        return 0, err
+    }
+    if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+        return 0, err
+    }
+    f, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+    if err != nil {
+        return 0, err
+    }
+    defer os.Remove(f.Name())
+    size, err := io.Copy(f, r)
+    if cerr := f.Close(); err == nil {
+        err = cerr
+    }
+    if err != nil {
+        return 0, err
+    }
+    return size, os.Rename(f.Name(), path)
+}
+
+func putBlobChunks(ctx context.Context, id string, r io.Reader, sum hash.Hash) (int64, error) {
+    buf := make([]byte, blobChunkSize)
+    var size int64
+    for n := 0; ; n++ {
+        read, err := io.ReadFull(r, buf)
+        if read > 0 {
+            sum.Write(buf[:read])
+            werr := dbUpdate(ctx, func(txn *badger.Txn) error {
+                return txn.Set(blobChunkKey(id, n), buf[:read])
+            })
+            if werr != nil {
+                return 0, werr
+            }
+            size += int64(read)
+        }
+        if err == io.EOF || err == io.ErrUnexpectedEOF {
+            return size, nil
+        }
+        if err != nil {
+            return 0, err
+        }
+    }
+}
+
+// openBlob opens a blob for reading. Blobs are looked up in BlobDir first,
+// then in BadgerDB, so changing the setting keeps older blobs readable.
+func openBlob(ref *BlobRef) (io.ReadSeekCloser, error) {
+    if !validBlobID(ref.Blob) {
+        return nil, errInvalidBlobID
+    }
+    if BlobDir != "" {
+        path, _ := blobPath(ref.Blob)
+        f, err := os.Open(path)
+        if !errors.Is(err, os.ErrNotExist) {
+            return f, err
+        }
+    }
+    return &badgerBlob{id: ref.Blob, size: ref.Size, n: -1}, nil
+}
+
+// badgerBlob reads a blob stored in BadgerDB, loading one chunk at a time
+type badgerBlob struct {
+    id     string
+    size   int64
+    offset int64
+    n      int // index of the loaded chunk, -1 before the first read
+    chunk  []byte
+}
+
+func (b *badgerBlob) Read(p []byte) (int, error) {
+    if b.offset >= b.size {
+        return 0, io.EOF
+    }
+    n := int(b.offset / blobChunkSize)
+    if n != b.n {
+        err := DB.View(func(txn *badger.Txn) error {
+            item, err := txn.Get(blobChunkKey(b.id, n))
+            if err != nil {
+                return err
+            }
+            b.chunk, err = item.ValueCopy(b.chunk[:0])
+            return err
+        })
+        if err != nil {
+            return 0, fmt.Errorf("reading blob %s: %w", b.id, err)
+        }
+        b.n = n
+    }
+    copied := copy(p, b.chunk[b.offset-int64(n)*blobChunkSize:])
+    b.offset += int64(copied)
+    return copied, nil
+}
+
+func (b *badgerBlob) Seek(offset int64, whence int) (int64, error) {
+    switch whence {
+    case io.SeekCurrent:
+        offset += b.offset
+    case io.SeekEnd:
+        offset += b.size
+    }
+    if offset < 0 {
+        return 0, errors.New("seek before the start of the blob")
+    }
+    b.offset = offset
+    return offset, nil
+}
+
+func (b *badgerBlob) Close() error {
+    return nil
+}
+
+// deleteBlob removes a blob from both stores
+func deleteBlob(id string) error {
+    if !validBlobID(id) {
+        return errInvalidBlobID
+    }
+    if BlobDir != "" {
+        path, _ := blobPath(id)
+        if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+            return err
+        }
+    }
+    return deleteChildren(DB, "_blob:"+id, false)
+}
+
+// blobRefOf returns the BlobRef a decoded document holds, if it is one
+func blobRefOf(doc interface{}) (*BlobRef, bool) {
+    obj, ok := doc.(map[string]interface{})
+    if !ok {
+        return nil, false
+    }
+    id, ok := obj["blob"].(string)
+    if !ok || !validBlobID(id) {
+        return nil, false
+    }
+    ref := &BlobRef{Blob: id}
+    ref.ContentType, _ = obj["contentType"].(string)
+    ref.Filename, _ = obj["filename"].(string)
+    ref.SHA256, _ = obj["sha256"].(string)
+    switch size := obj["size"].(type) {
+    case json.Number:
+        ref.Size, _ = size.Int64()
+    case float64:
+        ref.Size = int64(size)
+    }
+    return ref, true
+}
+
+// blobIDs collects the blobs a stored document references, as its whole
+// body, in its fields or in the elements of array fields
+func blobIDs(data []byte) map[string]bool {
+    var doc interface{}
+    json.Unmarshal(data, &doc)
+    values := []interface{}{doc}
+    if obj, ok := doc.(map[string]interface{}); ok {
+        for _, v := range obj {
+            values = append(values, v)
+            if items, ok := v.([]interface{}); ok {
+                values = append(values, items...)
+            }
+        }
+    }
+    ids := map[string]bool{}
+    for _, v := range values {
+        if ref, ok := blobRefOf(v); ok {
+            ids[ref.Blob] = true
+        }
+    }
+    return ids
+}
+
+// addsBlobs reports whether current references a blob that previous, the
+// document it replaces, does not
+func addsBlobs(previous, current []byte) bool {
+    kept := blobIDs(previous)
+    for id := range blobIDs(current) {
+        if !kept[id] {
+            return true
+        }
+    }
+    return false
+}
+
+// releaseBlobs deletes the blobs previous references and current, the
+// document replacing it (nil when deleted), does not
+func releaseBlobs(previous, current []byte) {
+    if len(previous) == 0 {
+        return
+    }
+    kept := blobIDs(current)
+    for id := range blobIDs(previous) {
+        if !kept[id] {
+            if err := deleteBlob(id); err != nil {
+                slog.Error("Failed to delete blob", "blob", id, "error", err)
+            }
+        }
+    }
+}
+
+// serveBlob streams a blob as the response body. A negotiated media type
+// range is replaced by the type the blob was uploaded with. 200 responses
+// support Range and If-Range requests.
+func serveBlob(w http.ResponseWriter, r *http.Request, status int, ref *BlobRef, mediaType string) {
+    blob, err := openBlob(ref)
+    if err != nil {
+        LoggerFrom(r.Context()).Error("Failed to open blob", "blob", ref.Blob, "error", err)
+        http.Error(w, "Failed to read file", http.StatusInternalServerError)
+        return
+    }
+    defer blob.Close()
+    if mediaType == "" || mediaType[len(mediaType)-1] == '*' {
+        mediaType = ref.ContentType
+    }
+    if mediaType == "" {
+        mediaType = "application/octet-stream"
+    }
+    w.Header().Set("Content-Type", mediaType)
+    if ref.Filename != "" {
+        w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": ref.Filename}))
+    }
+    if sum, err := hex.DecodeString(ref.SHA256); err == nil && len(sum) == sha256.Size {
+        w.Header().Set("Repr-Digest", "sha-256=:"+base64.StdEncoding.EncodeToString(sum)+":")
+    }
+    if status == http.StatusOK {
+        http.ServeContent(w, r, "", time.Time{}, blob)
+        return
+    }
+    w.Header().Set("Content-Length", strconv.FormatInt(ref.Size, 10))
+    w.WriteHeader(status)
+    if _, err := io.Copy(w, blob); err != nil {
+        LoggerFrom(r.Context()).Error("Failed to stream blob", "blob", ref.Blob, "error", err)
+    }
+}
+
+// serveBlobField streams the file held by a field of the document stored
+// under key; index selects an element of array fields
+func serveBlobField(w http.ResponseWriter, r *http.Request, key, field, index string) {
+    var doc []byte
+    var version uint64
+    err := dbView(r.Context(), func(txn *badger.Txn) error {
+        var err error
+        doc, version, err = getRecord(txn, key)
+        return err
+    })
+    if err == badger.ErrKeyNotFound {
+        http.Error(w, "Not found", http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, "Database error", http.StatusInternalServerError)
+        return
+    }
+    var obj map[string]interface{}
+    json.Unmarshal(doc, &obj)
+    value := obj[field]
+    if index != "" {
+        items, _ := value.([]interface{})
+        i, err := strconv.Atoi(index)
+        if err != nil || i < 0 || i >= len(items) {
+            http.Error(w, "No file at index "+index, http.StatusNotFound)
+            return
+        }
+        value = items[i]
+    }
+    ref, ok := blobRefOf(value)
+    if !ok {
+        http.Error(w, "No file stored in "+field, http.StatusNotFound)
+        return
+    }
+    if notModified(w, r, version) {
+        return
+    }
+    serveBlob(w, r, http.StatusOK, ref, "")
+}
@@ -0,0 +1,56 @@
+package client
+
+// Auto-generated structs from OpenAPI spec
+
+type Animal interface{}
+
+type AnimalBase struct {
+    Id int `json:"id,omitempty"`
+    Kind string `json:"kind"`
+    Name string `json:"name"`
+}
+
+type Cat struct {
+    Id int `json:"id,omitempty"`
+    Indoor bool `json:"indoor,omitempty"`
+    Kind string `json:"kind"`
+    Lives int `json:"lives,omitempty"`
+    Name string `json:"name"`
+}
+
+type Dog struct {
+    Breed string `json:"breed,omitempty"`
+    GoodBoy bool `json:"goodBoy,omitempty"`
+    Id int `json:"id,omitempty"`
+    Kind string `json:"kind"`
+    Name string `json:"name"`
+}
+
+type Email struct {
+    Email string `json:"email,omitempty"`
+}
+
+type Keeper struct {
+    Contact interface{} `json:"contact,omitempty"`
+    Favourite interface{} `json:"favourite,omitempty"`
+    Id int `json:"id,omitempty"`
+    Name string `json:"name"`
+}
+
+type ListAnimalsResponse200 []interface{}
+
+type LogFeedingRequest struct {
+    Amount interface{} `json:"amount,omitempty"`
+    Food interface{} `json:"food"`
+    Note string `json:"note,omitempty"`
+}
+
+type Meal struct {
+    Calories int `json:"calories,omitempty"`
+    Name string `json:"name,omitempty"`
+}
+
+type Phone struct {
+    Phone string `json:"phone,omitempty"`
+}
+
@@ -0,0 +1,49 @@
+package main
+
+// operations lists a command per operation, grouped by its first tag
+var operations = []operation{
+    {Group: "", Name: "list-animals", OperationID: "listAnimals", Method: "GET", Path: "/animals", Summary: "",
+        Accept: "application/json",
+    },
+    {Group: "", Name: "create-animal", OperationID: "createAnimal", Method: "POST", Path: "/animals", Summary: "",
+        BodyType: "application/json", BodyRequired: true,
+        Accept: "application/json",
+        Idempotent: true,
+    },
+    {Group: "", Name: "get-animal", OperationID: "getAnimal", Method: "GET", Path: "/animals/{animalId}", Summary: "",
+        Params: []param{
+            {Name: "animalId", Flag: "animal-id", In: "path", Type: "integer", Required: true},
+        },
+        Accept: "application/json",
+    },
+    {Group: "", Name: "delete-animal", OperationID: "deleteAnimal", Method: "DELETE", Path: "/animals/{animalId}", Summary: "",
+        Params: []param{
+            {Name: "animalId", Flag: "animal-id", In: "path", Type: "integer", Required: true},
+        },
+    },
+    {Group: "", Name: "log-feeding", OperationID: "logFeeding", Method: "POST", Path: "/feedings", Summary: "",
+        BodyType: "application/json", BodyRequired: true,
+        Params: []param{
+            {Name: "amount", Flag: "amount", In: "body", Type: "object"},
+            {Name: "food", Flag: "food", In: "body", Type: "object"},
+            {Name: "note", Flag: "note", In: "body", Type: "string"},
+        },
+        Idempotent: true,
+    },
+    {Group: "", Name: "create-keeper", OperationID: "createKeeper", Method: "POST", Path: "/keepers", Summary: "",
+        BodyType: "application/json", BodyRequired: true,
+        Params: []param{
+            {Name: "contact", Flag: "contact", In: "body", Type: "object"},
+            {Name: "favourite", Flag: "favourite", In: "body", Type: "object"},
+            {Name: "id", Flag: "id", In: "body", Type: "integer"},
+            {Name: "name", Flag: "name", In: "body", Type: "string"},
+        },
+        Accept: "application/json",
+        Idempotent: true,
+    },
+}
+
+// schemes lists the security schemes and the environment variables holding
+// their credentials
+var schemes = map[string]scheme{
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+    _ "embed"
+    "encoding/json"
+    "net/http"
+    "strings"
+)
+
+// Paths of the specification and API explorer endpoints; an empty path disables the endpoint
+var (
+    SpecJSONPath = "/openapi.json"
+    SpecYAMLPath = "/openapi.yaml"
+    DocsPath     = "/docs"
+)
+
+// The source specification, normalized to JSON and YAML, and the API explorer
+var (
+    //go:embed openapi.json
+    specJSON []byte
+    //go:embed openapi.yaml
+    specYAML []byte
+    //go:embed docs.html
+    docsPage string
+)
+
+// registerDocs adds the specification and API explorer endpoints to mux
+func registerDocs(mux *http.ServeMux) {
+    if SpecJSONPath != "" {
+        mux.HandleFunc("GET "+SpecJSONPath, serveSpec(specJSON, "application/json"))
+    }
+    if SpecYAMLPath != "" {
+        mux.HandleFunc("GET "+SpecYAMLPath, serveSpec(specYAML, "application/yaml"))
+    }
+    if DocsPath != "" {
+        mux.HandleFunc("GET "+DocsPath, serveDocs)
+    }
+}
+
+func serveSpec(doc []byte, contentType string) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", contentType)
+        w.Header().Set("Cache-Control", "no-cache")
+        w.Write(doc)
+    }
+}
+
+// serveDocs writes the API explorer, pointing it at the served specification.
+// The page loads nothing but the specification and the operations it calls.
+func serveDocs(w http.ResponseWriter, r *http.Request) {
+    config, _ := json.Marshal(map[string]string{"spec": SpecJSONPath, "yaml": SpecYAMLPath})
+    page := strings.Replace(docsPage, "{{CONFIG}}", string(config), 1)
+    w.Header().Set("Content-Type", "text/html; charset=utf-8")
+    w.Header().Set("Content-Security-Policy", "default-src 'self'; style-src 'unsafe-inline'; script-src 'unsafe-inline'")
+    w.Write([]byte(page))
+}
@@ -0,0 +1,18 @@
+module generated
+
+go 1.23.8
+
+require (
+    github.com/dgraph-io/badger/v3 v3.2103.5
+    github.com/klauspost/compress v1.12.3
+    go.opentelemetry.io/otel v1.28.0
+    go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp v1.28.0
+    go.opentelemetry.io/otel/exporters/stdout/stdouttrace v1.28.0
+    go.opentelemetry.io/otel/sdk v1.28.0
+    go.opentelemetry.io/otel/trace v1.28.0
+    gopkg.in/yaml.v3 v3.0.1
+)
+
+// BadgerDB depends on the monolithic genproto module; a release after the
+// googleapis split keeps it from clashing with the OpenTelemetry exporters.
+require google.golang.org/genproto v0.0.0-20240701130421-f6361c86f094 // indirect
@@ -0,0 +1,198 @@
+package main
+
+import (
+    "encoding/json"
+    "net/http"
+    "strings"
+    "github.com/dgraph-io/badger/v3"
+)
+
+func ListAnimals(w http.ResponseWriter, r *http.Request) {
+    prefix := "animals:"
+    var err error
+    id := r.URL.Query().Get("id")
+    if id == "" {
+        var items []json.RawMessage
+        err = dbView(r.Context(), func(txn *badger.Txn) error {
+            var err error
+            items, err = listChildren(txn, prefix)
+            return err
+        })
+        if err == badger.ErrKeyNotFound {
+            http.Error(w, "Animals not found", http.StatusNotFound)
+            return
+        } else if err != nil {
+            http.Error(w, "Database error", http.StatusInternalServerError)
+            return
+        }
+        writeDocument(w, r, http.StatusOK, "Animals", items)
+        return
+    }
+    if !validKeyPart(id) {
+        http.Error(w, "Invalid ID", http.StatusBadRequest)
+        return
+    }
+    key := prefix + id
+    var result []byte
+    var version uint64
+    err = dbView(r.Context(), func(txn *badger.Txn) error {
+        var err error
+        result, version, err = getRecord(txn, key)
+        return err
+    })
+    if err == badger.ErrKeyNotFound {
+        http.Error(w, "Animals not found", http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, "Database error", http.StatusInternalServerError)
+        return
+    }
+    if notModified(w, r, version) {
+        return
+    }
+    writeDocument(w, r, http.StatusOK, "Animals", json.RawMessage(result))
+}
+
+func CreateAnimal(w http.ResponseWriter, r *http.Request) {
+    prefix := "animals:"
+    var err error
+    id := newID()
+    key := prefix + id
+    data, err := readValidated(r, "Animal")
+    if err != nil {
+        http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+        return
+    }
+    var version uint64
+    err = dbUpdate(r.Context(), func(txn *badger.Txn) error {
+        var err error
+        version, err = putRecord(txn, key, data, 0)
+        return err
+    })
+    if err != nil {
+        http.Error(w, "Failed to save data", http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("ETag", etagFor(version))
+    w.Header().Set("Location", strings.TrimSuffix(r.URL.Path, "/")+"/"+id)
+    writeDocument(w, r, http.StatusCreated, "Animal", json.RawMessage(data))
+}
+
+func GetAnimal(w http.ResponseWriter, r *http.Request) {
+    key, err := resourceKey(r, "animals", "animalId")
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    var result []byte
+    var version uint64
+    err = dbView(r.Context(), func(txn *badger.Txn) error {
+        var err error
+        result, version, err = getRecord(txn, key)
+        return err
+    })
+    if err == badger.ErrKeyNotFound {
+        http.Error(w, "Animals not found", http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, "Database error", http.StatusInternalServerError)
+        return
+    }
+    if notModified(w, r, version) {
+        return
+    }
+    writeDocument(w, r, http.StatusOK, "Animal", json.RawMessage(result))
+}
+
+func DeleteAnimal(w http.ResponseWriter, r *http.Request) {
+    key, err := resourceKey(r, "animals", "animalId")
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    err = dbUpdate(r.Context(), func(txn *badger.Txn) error {
+        _, current, err := getRecord(txn, key)
+        if err != nil {
+            return err
+        }
+        if err := checkIfMatch(r, current, true); err != nil {
+            return err
+        }
+        return txn.Delete([]byte(key))
+    })
+    if err == badger.ErrKeyNotFound {
+        http.Error(w, "Animals not found", http.StatusNotFound)
+        return
+    }
+    if err == errPreconditionFailed {
+        http.Error(w, "Precondition failed", http.StatusPreconditionFailed)
+        return
+    } else if err == badger.ErrConflict {
+        http.Error(w, "Concurrent modification, retry the request", conflictStatus(r))
+        return
+    }
+    if err != nil {
+        http.Error(w, "Failed to delete data", http.StatusInternalServerError)
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+func LogFeeding(w http.ResponseWriter, r *http.Request) {
+    prefix := "feedings:"
+    var err error
+    id := newID()
+    key := prefix + id
+    data, err := readValidated(r, "LogFeedingRequest")
+    if err != nil {
+        http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+        return
+    }
+    var version uint64
+    err = dbUpdate(r.Context(), func(txn *badger.Txn) error {
+        var err error
+        version, err = putRecord(txn, key, data, 0)
+        return err
+    })
+    if err != nil {
+        http.Error(w, "Failed to save data", http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("ETag", etagFor(version))
+    w.WriteHeader(http.StatusCreated)
+}
+
+func CreateKeeper(w http.ResponseWriter, r *http.Request) {
+    prefix := "keepers:"
+    var err error
+    id := newID()
+    key := prefix + id
+    data, err := readValidated(r, "Keeper")
+    if err != nil {
+        http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+        return
+    }
+    data, err = withID(data, "id", id, true)
+    if err != nil {
+        http.Error(w, "Invalid ID", http.StatusBadRequest)
+        return
+    }
+    var version uint64
+    err = dbUpdate(r.Context(), func(txn *badger.Txn) error {
+        var err error
+        version, err = putRecord(txn, key, data, 0)
+        return err
+    })
+    if err != nil {
+        http.Error(w, "Failed to save data", http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("ETag", etagFor(version))
+    var resp Keeper
+    if err := json.Unmarshal(data, &resp); err != nil {
+        http.Error(w, "Failed to parse data", http.StatusInternalServerError)
+        return
+    }
+    writeDocument(w, r, http.StatusCreated, "Keeper", resp)
+}
+
@@ -0,0 +1,343 @@
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "math"
+    "net/http"
+    "regexp"
+    "sort"
+    "strings"
+    "sync"
+)
+
+// schemaRule captures the property types of an object schema
+type schemaRule struct {
+    Properties map[string]string // property name -> JSON type, or binary and binary[] for uploaded files
+}
+
+// schemaRules maps generated struct names to the types of their properties
+var schemaRules = map[string]schemaRule{
+    "AnimalBase": {
+        Properties: map[string]string{
+            "id": "integer",
+            "kind": "string",
+            "name": "string",
+        },
+    },
+    "Email": {
+        Properties: map[string]string{
+            "email": "string",
+        },
+    },
+    "Keeper": {
+        Properties: map[string]string{
+            "id": "integer",
+            "name": "string",
+        },
+    },
+    "LogFeedingRequest": {
+        Properties: map[string]string{
+            "note": "string",
+        },
+    },
+    "Meal": {
+        Properties: map[string]string{
+            "calories": "integer",
+            "name": "string",
+        },
+    },
+    "Phone": {
+        Properties: map[string]string{
+            "phone": "string",
+        },
+    },
+}
+
+// schemaPointers locates the schema of each generated struct in the embedded spec
+var schemaPointers = map[string]string{
+    "Animal": "/components/schemas/Animal",
+    "AnimalBase": "/components/schemas/AnimalBase",
+    "Cat": "/components/schemas/Cat",
+    "Dog": "/components/schemas/Dog",
+    "Email": "/components/schemas/Email",
+    "Keeper": "/components/schemas/Keeper",
+    "ListAnimalsResponse200": "/paths/~1animals/get/responses/200/content/application~1json/schema",
+    "LogFeedingRequest": "/paths/~1feedings/post/requestBody/content/application~1json/schema",
+    "Meal": "/components/schemas/Meal",
+    "Phone": "/components/schemas/Phone",
+}
+
+var (
+    validationSpecOnce sync.Once
+    validationSpec     interface{}
+    patternCache       sync.Map // pattern -> *regexp.Regexp, or nil when it does not compile
+)
+
+// schemaNode returns the value at a JSON pointer into the embedded spec,
+// following local $refs on the way
+func schemaNode(pointer string) interface{} {
+    validationSpecOnce.Do(func() {
+        json.Unmarshal(specJSON, &validationSpec)
+    })
+    node := validationSpec
+    for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+        token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+        obj, _ := resolveSchemaRef(node).(map[string]interface{})
+        node = obj[token]
+    }
+    return resolveSchemaRef(node)
+}
+
+// resolveSchemaRef follows local $refs
+func resolveSchemaRef(node interface{}) interface{} {
+    for i := 0; i < 32; i++ {
+        obj, _ := node.(map[string]interface{})
+        ref, ok := obj["$ref"].(string)
+        if !ok || !strings.HasPrefix(ref, "#/") {
+            return node
+        }
+        node = schemaNode(ref[1:])
+    }
+    return node
+}
+
+// validateDocument checks a decoded request document against the named
+// schema and reports every mismatch. Schemas not found in the spec accept any
+// document.
+func validateDocument(schema string, doc interface{}) error {
+    pointer, ok := schemaPointers[schema]
+    if !ok {
+        return nil
+    }
+    def, _ := schemaNode(pointer).(map[string]interface{})
+    if problems := valueProblems(def, doc, "body"); len(problems) > 0 {
+        return errors.New(strings.Join(problems, "; "))
+    }
+    return nil
+}
+
+// validateJSON parses data and validates it against the named schema
+func validateJSON(schema string, data []byte) error {
+    var doc interface{}
+    if err := json.Unmarshal(data, &doc); err != nil {
+        return fmt.Errorf("invalid JSON: %v", err)
+    }
+    return validateDocument(schema, doc)
+}
+
+// readValidated reads a request body in any supported media type and
+// validates it against the named schema, returning the JSON document as sent
+// so fields left out are not stored as zero values. Blobs stored for a body
+// that fails validation are deleted again.
+func readValidated(r *http.Request, schema string) ([]byte, error) {
+    data, err := readDocument(r, schema)
+    if err != nil {
+        return nil, err
+    }
+    if err := validateJSON(schema, data); err != nil {
+        releaseBlobs(data, nil)
+        return nil, err
+    }
+    var compact bytes.Buffer
+    if err := json.Compact(&compact, data); err != nil {
+        return nil, err
+    }
+    return compact.Bytes(), nil
+}
+
+// decodeValidated reads and validates a request body like readValidated and
+// decodes it into dst
+func decodeValidated(r *http.Request, schema string, dst interface{}) error {
+    data, err := readValidated(r, schema)
+    if err != nil {
+        return err
+    }
+    return json.Unmarshal(data, dst)
+}
+
+// valueProblems describes each place a decoded JSON value does not match a
+// schema. Read-only properties need not be sent, and binary strings are the
+// references to uploaded files stored in their place.
+func valueProblems(schema map[string]interface{}, value interface{}, path string) []string {
+    schema, _ = resolveSchemaRef(schema).(map[string]interface{})
+    if schema == nil {
+        return nil
+    }
+    if value == nil {
+        if nullable, _ := schema["nullable"].(bool); nullable || allowsType(schema, "null") || schema["type"] == nil {
+            return nil
+        }
+        return []string{path + " must not be null"}
+    }
+    if values, ok := schema["enum"].([]interface{}); ok && !enumContains(values, value) {
+        data, _ := json.Marshal(values)
+        return []string{fmt.Sprintf("%s must be one of %s", path, data)}
+    }
+    if schema["format"] == "binary" && allowsType(schema, "string") {
+        if _, ok := blobRefOf(value); !ok {
+            return []string{path + " must be an uploaded file"}
+        }
+        return nil
+    }
+    var problems []string
+    if all, ok := schema["allOf"].([]interface{}); ok {
+        for _, sub := range all {
+            s, _ := sub.(map[string]interface{})
+            problems = append(problems, valueProblems(s, value, path)...)
+        }
+    }
+    for _, keyword := range []string{"oneOf", "anyOf"} {
+        variants, ok := schema[keyword].([]interface{})
+        if !ok {
+            continue
+        }
+        matched := false
+        for _, sub := range variants {
+            s, _ := sub.(map[string]interface{})
+            if len(valueProblems(s, value, path)) == 0 {
+                matched = true
+                break
+            }
+        }
+        if !matched {
+            problems = append(problems, path+" matches none of the "+keyword+" schemas")
+        }
+    }
+
+    switch v := value.(type) {
+    case map[string]interface{}:
+        if !allowsType(schema, "object") {
+            return append(problems, path+" must be "+typeName(schema))
+        }
+        properties, _ := schema["properties"].(map[string]interface{})
+        required, _ := schema["required"].([]interface{})
+        for _, name := range required {
+            n, _ := name.(string)
+            prop, _ := resolveSchemaRef(properties[n]).(map[string]interface{})
+            if readOnly, _ := prop["readOnly"].(bool); readOnly {
+                continue
+            }
+            if _, ok := v[n]; !ok {
+                problems = append(problems, path+"."+n+" is required")
+            }
+        }
+        names := make([]string, 0, len(v))
+        for name := range v {
+            names = append(names, name)
+        }
+        sort.Strings(names)
+        for _, name := range names {
+            if prop, ok := properties[name].(map[string]interface{}); ok {
+                problems = append(problems, valueProblems(prop, v[name], path+"."+name)...)
+            } else if additional, ok := schema["additionalProperties"]; ok {
+                if allowed, isBool := additional.(bool); isBool && !allowed {
+                    problems = append(problems, path+"."+name+" is not allowed")
+                } else if s, isSchema := additional.(map[string]interface{}); isSchema {
+                    problems = append(problems, valueProblems(s, v[name], path+"."+name)...)
+                }
+            }
+        }
+    case []interface{}:
+        if !allowsType(schema, "array") {
+            return append(problems, path+" must be "+typeName(schema))
+        }
+        if min, ok := schema["minItems"].(float64); ok && float64(len(v)) < min {
+            problems = append(problems, fmt.Sprintf("%s must have at least %v items", path, min))
+        }
+        if max, ok := schema["maxItems"].(float64); ok && float64(len(v)) > max {
+            problems = append(problems, fmt.Sprintf("%s must have at most %v items", path, max))
+        }
+        items, _ := schema["items"].(map[string]interface{})
+        for i, item := range v {
+            problems = append(problems, valueProblems(items, item, fmt.Sprintf("%s[%d]", path, i))...)
+        }
+    case string:
+        if !allowsType(schema, "string") {
+            return append(problems, path+" must be "+typeName(schema))
+        }
+        if min, ok := schema["minLength"].(float64); ok && float64(len([]rune(v))) < min {
+            problems = append(problems, fmt.Sprintf("%s must be at least %v characters", path, min))
+        }
+        if max, ok := schema["maxLength"].(float64); ok && float64(len([]rune(v))) > max {
+            problems = append(problems, fmt.Sprintf("%s must be at most %v characters", path, max))
+        }
+        if pattern, ok := schema["pattern"].(string); ok {
+            if re := compilePattern(pattern); re != nil && !re.MatchString(v) {
+                problems = append(problems, path+" must match "+pattern)
+            }
+        }
+    case float64:
+        switch {
+        case allowsType(schema, "number"):
+        case allowsType(schema, "integer"):
+            if v != math.Trunc(v) {
+                return append(problems, path+" must be an integer")
+            }
+        default:
+            return append(problems, path+" must be "+typeName(schema))
+        }
+        if min, ok := schema["minimum"].(float64); ok && v < min {
+            problems = append(problems, fmt.Sprintf("%s must be at least %v", path, min))
+        }
+        if max, ok := schema["maximum"].(float64); ok && v > max {
+            problems = append(problems, fmt.Sprintf("%s must be at most %v", path, max))
+        }
+    case bool:
+        if !allowsType(schema, "boolean") {
+            return append(problems, path+" must be "+typeName(schema))
+        }
+    }
+    return problems
+}
+
+// allowsType reports whether a schema accepts values of a JSON type; schemas
+// without a type accept anything
+func allowsType(schema map[string]interface{}, t string) bool {
+    switch declared := schema["type"].(type) {
+    case string:
+        return declared == t || (declared == "number" && t == "integer")
+    case []interface{}:
+        for _, d := range declared {
+            if d == t || (d == "number" && t == "integer") {
+                return true
+            }
+        }
+        return false
+    }
+    return t != "null"
+}
+
+func typeName(schema map[string]interface{}) string {
+    switch t := schema["type"].(type) {
+    case string:
+        return "of type " + t
+    case []interface{}:
+        data, _ := json.Marshal(t)
+        return "of type " + string(data)
+    }
+    return "valid"
+}
+
+func enumContains(values []interface{}, value interface{}) bool {
+    want, _ := json.Marshal(value)
+    for _, v := range values {
+        if data, _ := json.Marshal(v); string(data) == string(want) {
+            return true
+        }
+    }
+    return false
+}
+
+// compilePattern compiles a schema pattern once; patterns Go cannot compile
+// are not checked
+func compilePattern(pattern string) *regexp.Regexp {
+    if re, ok := patternCache.Load(pattern); ok {
+        return re.(*regexp.Regexp)
+    }
+    re, _ := regexp.Compile(pattern)
+    patternCache.Store(pattern, re)
+    return re
+}
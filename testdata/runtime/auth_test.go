@@ -0,0 +1,222 @@
+package main
+
+import (
+    "crypto"
+    "crypto/hmac"
+    "crypto/rand"
+    "crypto/rsa"
+    "crypto/sha256"
+    "crypto/x509"
+    "encoding/base64"
+    "encoding/json"
+    "encoding/pem"
+    "math/big"
+    "net/http"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+)
+
+// useJWTKeys points the JWT environment variables of the schemes at files
+// holding the given contents, e.g. {"OIDC_JWT_KEY_FILE": pem}, in place of
+// the credentials newTestServer sets up, and drops keys loaded before
+func useJWTKeys(t *testing.T, files map[string]string) {
+    t.Helper()
+    dir := t.TempDir()
+    for _, scheme := range securitySchemes {
+        for _, suffix := range []string{"_JWT_KEY_FILE", "_JWKS_FILE", "_JWT_ISSUER", "_JWT_AUDIENCE"} {
+            t.Setenv(scheme.Env+suffix, "")
+        }
+    }
+    for env, content := range files {
+        if strings.HasSuffix(env, "_FILE") {
+            path := filepath.Join(dir, env)
+            if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+                t.Fatal(err)
+            }
+            content = path
+        }
+        t.Setenv(env, content)
+    }
+    reset := func() {
+        jwtKeysMu.Lock()
+        jwtKeysCache = map[string]*jwtKeys{}
+        jwtKeysMu.Unlock()
+    }
+    reset()
+    t.Cleanup(reset)
+}
+
+// signToken builds a compact JWT with the claims, signed with HS256 when key
+// is a []byte secret and RS256 when it is an RSA private key
+func signToken(t *testing.T, key interface{}, kid string, claims map[string]interface{}) string {
+    t.Helper()
+    encode := func(v interface{}) string {
+        data, _ := json.Marshal(v)
+        return base64.RawURLEncoding.EncodeToString(data)
+    }
+    header := map[string]string{"typ": "JWT", "alg": "HS256"}
+    if _, ok := key.(*rsa.PrivateKey); ok {
+        header["alg"] = "RS256"
+    }
+    if kid != "" {
+        header["kid"] = kid
+    }
+    signed := encode(header) + "." + encode(claims)
+    var sig []byte
+    switch k := key.(type) {
+    case []byte:
+        mac := hmac.New(sha256.New, k)
+        mac.Write([]byte(signed))
+        sig = mac.Sum(nil)
+    case *rsa.PrivateKey:
+        digest := sha256.Sum256([]byte(signed))
+        var err error
+        if sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:]); err != nil {
+            t.Fatal(err)
+        }
+    }
+    return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// authStatus sends a request with the bearer token, if any, and returns the
+// response
+func authStatus(t *testing.T, method, url, token string) *http.Response {
+    t.Helper()
+    body := ""
+    if method == http.MethodPost {
+        body = `{"name": "pen"}`
+    }
+    req, _ := http.NewRequest(method, url, strings.NewReader(body))
+    req.Header.Set("Content-Type", "application/json")
+    if token != "" {
+        req.Header.Set("Authorization", "Bearer "+token)
+    }
+    res, err := http.DefaultClient.Do(req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    res.Body.Close()
+    return res
+}
+
+func newRSAKey(t *testing.T) *rsa.PrivateKey {
+    t.Helper()
+    key, err := rsa.GenerateKey(rand.Reader, 2048)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return key
+}
+
+func TestRuntimeAuthHS256(t *testing.T) {
+    secret := []byte("bearer-secret")
+    ts := newTestServer(t)
+    useJWTKeys(t, map[string]string{"BEARER_AUTH_JWT_KEY_FILE": string(secret) + "\n"})
+    now := time.Now()
+
+    res := authStatus(t, http.MethodGet, ts.URL+"/items", "")
+    if res.StatusCode != http.StatusUnauthorized || res.Header.Get("WWW-Authenticate") != "Bearer" {
+        t.Errorf("no token: status %d, WWW-Authenticate %q, want 401 Bearer", res.StatusCode, res.Header.Get("WWW-Authenticate"))
+    }
+
+    valid := signToken(t, secret, "", map[string]interface{}{"sub": "ann", "exp": now.Add(time.Hour).Unix()})
+    parts := strings.Split(valid, ".")
+    tampered := parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"bob"}`)) + "." + parts[2]
+    unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + parts[1] + "."
+    for _, tc := range []struct {
+        name, token string
+        status      int
+    }{
+        {"valid", valid, http.StatusOK},
+        {"no exp", signToken(t, secret, "", map[string]interface{}{"sub": "ann"}), http.StatusOK},
+        {"nbf passed", signToken(t, secret, "", map[string]interface{}{"nbf": now.Add(-time.Minute).Unix(), "exp": now.Add(time.Hour).Unix()}), http.StatusOK},
+        {"expired", signToken(t, secret, "", map[string]interface{}{"exp": now.Add(-time.Minute).Unix()}), http.StatusUnauthorized},
+        {"not yet valid", signToken(t, secret, "", map[string]interface{}{"nbf": now.Add(time.Hour).Unix()}), http.StatusUnauthorized},
+        {"other secret", signToken(t, []byte("guess"), "", map[string]interface{}{"sub": "ann"}), http.StatusUnauthorized},
+        {"tampered claims", tampered, http.StatusUnauthorized},
+        {"alg none", unsigned, http.StatusUnauthorized},
+        {"RS256 without an RSA key", signToken(t, newRSAKey(t), "", map[string]interface{}{"sub": "ann"}), http.StatusUnauthorized},
+        {"not a JWT", "opaque-token", http.StatusUnauthorized},
+    } {
+        if res := authStatus(t, http.MethodGet, ts.URL+"/items", tc.token); res.StatusCode != tc.status {
+            t.Errorf("%s: status %d, want %d", tc.name, res.StatusCode, tc.status)
+        }
+    }
+}
+
+func TestRuntimeAuthRS256KeyFile(t *testing.T) {
+    key := newRSAKey(t)
+    der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+    if err != nil {
+        t.Fatal(err)
+    }
+    publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+    ts := newTestServer(t)
+    useJWTKeys(t, map[string]string{"OIDC_JWT_KEY_FILE": string(publicPEM), "OIDC_JWT_ISSUER": "https://auth.example.com"})
+    exp := time.Now().Add(time.Hour).Unix()
+    claims := func(scope, iss string) map[string]interface{} {
+        return map[string]interface{}{"sub": "ann", "scope": scope, "iss": iss, "exp": exp}
+    }
+
+    for _, tc := range []struct {
+        name, token string
+        status      int
+    }{
+        {"valid", signToken(t, key, "", claims("profile reports", "https://auth.example.com")), http.StatusOK},
+        {"missing scope", signToken(t, key, "", claims("profile", "https://auth.example.com")), http.StatusForbidden},
+        {"other issuer", signToken(t, key, "", claims("reports", "https://evil.example.com")), http.StatusUnauthorized},
+        {"other key", signToken(t, newRSAKey(t), "", claims("reports", "https://auth.example.com")), http.StatusUnauthorized},
+        // The public key must not work as an HMAC secret
+        {"HS256 with the public key", signToken(t, publicPEM, "", claims("reports", "https://auth.example.com")), http.StatusUnauthorized},
+    } {
+        if res := authStatus(t, http.MethodGet, ts.URL+"/reports", tc.token); res.StatusCode != tc.status {
+            t.Errorf("%s: status %d, want %d", tc.name, res.StatusCode, tc.status)
+        }
+    }
+}
+
+func TestRuntimeAuthJWKS(t *testing.T) {
+    first, second := newRSAKey(t), newRSAKey(t)
+    jwk := func(kid string, key *rsa.PrivateKey) map[string]string {
+        return map[string]string{
+            "kty": "RSA",
+            "kid": kid,
+            "n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+            "e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+        }
+    }
+    set, _ := json.Marshal(map[string]interface{}{"keys": []interface{}{jwk("first", first), jwk("second", second)}})
+    ts := newTestServer(t)
+    useJWTKeys(t, map[string]string{"OAUTH_JWKS_FILE": string(set), "OAUTH_JWT_AUDIENCE": "items-api"})
+    exp := time.Now().Add(time.Hour).Unix()
+
+    for _, tc := range []struct {
+        name, token string
+        status      int
+    }{
+        {"first key", signToken(t, first, "first", map[string]interface{}{"scope": "write:items", "aud": "items-api", "exp": exp}), http.StatusCreated},
+        {"second key, scp claim", signToken(t, second, "second", map[string]interface{}{"scp": []string{"write:items"}, "aud": []string{"other", "items-api"}, "exp": exp}), http.StatusCreated},
+        {"kid of another key", signToken(t, first, "second", map[string]interface{}{"scope": "write:items", "aud": "items-api", "exp": exp}), http.StatusUnauthorized},
+        {"unknown kid", signToken(t, first, "third", map[string]interface{}{"scope": "write:items", "aud": "items-api", "exp": exp}), http.StatusUnauthorized},
+        {"other audience", signToken(t, first, "first", map[string]interface{}{"scope": "write:items", "aud": "other", "exp": exp}), http.StatusUnauthorized},
+        {"missing scope", signToken(t, first, "first", map[string]interface{}{"scope": "read:items", "aud": "items-api", "exp": exp}), http.StatusForbidden},
+        {"expired with the scope", signToken(t, first, "first", map[string]interface{}{"scope": "write:items", "aud": "items-api", "exp": time.Now().Add(-time.Minute).Unix()}), http.StatusUnauthorized},
+    } {
+        if res := authStatus(t, http.MethodPost, ts.URL+"/items", tc.token); res.StatusCode != tc.status {
+            t.Errorf("%s: status %d, want %d", tc.name, res.StatusCode, tc.status)
+        }
+    }
+}
+
+func TestRuntimeAuthWithoutKeys(t *testing.T) {
+    ts := newTestServer(t)
+    useJWTKeys(t, nil)
+    token := signToken(t, []byte("secret"), "", map[string]interface{}{"sub": "ann"})
+    // A server without keys turns tokens away rather than failing
+    if res := authStatus(t, http.MethodGet, ts.URL+"/items", token); res.StatusCode != http.StatusUnauthorized {
+        t.Errorf("status %d, want 401", res.StatusCode)
+    }
+}
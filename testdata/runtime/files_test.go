@@ -0,0 +1,236 @@
+package main
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "io"
+    "mime/multipart"
+    "net/http"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "github.com/dgraph-io/badger/v3"
+)
+
+// createDoc uploads a document with a file below the user and returns its path
+func createDoc(t *testing.T, url, user string) string {
+    t.Helper()
+    var body bytes.Buffer
+    form := multipart.NewWriter(&body)
+    form.WriteField("title", "report")
+    part, _ := form.CreateFormFile("file", "report.txt")
+    part.Write([]byte("quarterly numbers"))
+    form.Close()
+    res, err := http.Post(url+user+"/docs", form.FormDataContentType(), &body)
+    if err != nil {
+        t.Fatal(err)
+    }
+    res.Body.Close()
+    if res.StatusCode != http.StatusCreated {
+        t.Fatalf("creating a document: status %d", res.StatusCode)
+    }
+    return res.Header.Get("Location")
+}
+
+// blobFiles lists the files in the blob directory
+func blobFiles(t *testing.T) []string {
+    t.Helper()
+    var files []string
+    filepath.WalkDir(BlobDir, func(path string, d os.DirEntry, err error) error {
+        if err == nil && !d.IsDir() {
+            files = append(files, path)
+        }
+        return err
+    })
+    return files
+}
+
+// call sends a request and returns the response with its body closed
+func call(t *testing.T, method, url, contentType, body string) *http.Response {
+    t.Helper()
+    req, _ := http.NewRequest(method, url, bytes.NewBufferString(body))
+    if contentType != "" {
+        req.Header.Set("Content-Type", contentType)
+    }
+    res, err := http.DefaultClient.Do(req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    res.Body.Close()
+    return res
+}
+
+func TestRuntimeCascadeDelete(t *testing.T) {
+    BlobDir = t.TempDir()
+    ts := newTestServer(t)
+    res := call(t, "POST", ts.URL+"/users", "application/json", `{"name":"ada"}`)
+    if res.StatusCode != http.StatusCreated {
+        t.Fatalf("creating a user: status %d", res.StatusCode)
+    }
+    user := res.Header.Get("Location")
+    docs := []string{createDoc(t, ts.URL, user), createDoc(t, ts.URL, user)}
+    if files := blobFiles(t); len(files) != 2 {
+        t.Fatalf("got blob files %v, want 2", files)
+    }
+
+    if res := call(t, "DELETE", ts.URL+user, "", ""); res.StatusCode != http.StatusNoContent {
+        t.Fatalf("deleting the user: status %d", res.StatusCode)
+    }
+    for _, path := range append(docs, user) {
+        if res := call(t, "GET", ts.URL+path, "", ""); res.StatusCode != http.StatusNotFound {
+            t.Errorf("GET %s after the cascade: status %d, want 404", path, res.StatusCode)
+        }
+    }
+    if files := blobFiles(t); len(files) != 0 {
+        t.Errorf("the documents' files were not deleted: %v", files)
+    }
+}
+
+func TestRuntimeCascadeDeleteMissing(t *testing.T) {
+    ts := newTestServer(t)
+    if res := call(t, "DELETE", ts.URL+"/users/nobody", "", ""); res.StatusCode != http.StatusNotFound {
+        t.Errorf("status %d, want 404", res.StatusCode)
+    }
+}
+
+// getJSON decodes the JSON document at url
+func getJSON(t *testing.T, url string) map[string]interface{} {
+    t.Helper()
+    res, err := http.Get(url)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer res.Body.Close()
+    var doc map[string]interface{}
+    if err := json.NewDecoder(res.Body).Decode(&doc); err != nil {
+        t.Fatalf("GET %s: %v", url, err)
+    }
+    return doc
+}
+
+// download returns the status and content of a document's file
+func download(t *testing.T, url string) (int, string) {
+    t.Helper()
+    res, err := http.Get(url + "/file")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer res.Body.Close()
+    data, _ := io.ReadAll(res.Body)
+    return res.StatusCode, string(data)
+}
+
+func TestRuntimeBlobStorage(t *testing.T) {
+    // Larger than a chunk, so BadgerDB splits it
+    content := strings.Repeat("0123456789abcdef", blobChunkSize/16+100)
+    for _, dir := range []string{t.TempDir(), ""} {
+        BlobDir = dir
+        ts := newTestServer(t)
+        user := call(t, "POST", ts.URL+"/users", "application/json", `{"name":"ada"}`).Header.Get("Location")
+        var body bytes.Buffer
+        form := multipart.NewWriter(&body)
+        form.WriteField("title", "large")
+        part, _ := form.CreateFormFile("file", "large.txt")
+        part.Write([]byte(content))
+        form.Close()
+        doc := call(t, "POST", ts.URL+user+"/docs", form.FormDataContentType(), body.String()).Header.Get("Location")
+
+        file, _ := getJSON(t, ts.URL+doc)["file"].(map[string]interface{})
+        sum := sha256.Sum256([]byte(content))
+        if file["sha256"] != hex.EncodeToString(sum[:]) || file["size"] != float64(len(content)) {
+            t.Errorf("BlobDir %q: stored reference %v does not describe the upload", dir, file)
+        }
+        if status, got := download(t, ts.URL+doc); status != http.StatusOK || got != content {
+            t.Errorf("BlobDir %q: download: status %d, %d bytes, want the %d uploaded", dir, status, len(got), len(content))
+        }
+        req, _ := http.NewRequest("GET", ts.URL+doc+"/file", nil)
+        req.Header.Set("Range", "bytes=16-31")
+        res, err := http.DefaultClient.Do(req)
+        if err != nil {
+            t.Fatal(err)
+        }
+        ranged, _ := io.ReadAll(res.Body)
+        res.Body.Close()
+        if res.StatusCode != http.StatusPartialContent || string(ranged) != content[16:32] {
+            t.Errorf("BlobDir %q: range: status %d, %q", dir, res.StatusCode, ranged)
+        }
+    }
+}
+
+func TestRuntimeBlobReferences(t *testing.T) {
+    BlobDir = t.TempDir()
+    ts := newTestServer(t)
+    user := call(t, "POST", ts.URL+"/users", "application/json", `{"name":"ada"}`).Header.Get("Location")
+    owner := createDoc(t, ts.URL, user)
+    file, _ := getJSON(t, ts.URL+owner)["file"].(map[string]interface{})
+    id, _ := file["blob"].(string)
+    ref, _ := json.Marshal(file)
+
+    // Another record cannot claim the owner's file by its reference
+    if res := call(t, "POST", ts.URL+user+"/docs", "application/json", `{"title":"theft","file":`+string(ref)+`}`); res.StatusCode != http.StatusBadRequest {
+        t.Errorf("JSON body with a blob reference: status %d, want 400", res.StatusCode)
+    }
+    xml := `<Doc><title>theft</title><file><blob>` + id + `</blob><size>17</size></file></Doc>`
+    if res := call(t, "POST", ts.URL+user+"/docs", "application/xml", xml); res.StatusCode != http.StatusBadRequest {
+        t.Errorf("XML body with a blob reference: status %d, want 400", res.StatusCode)
+    }
+    other := call(t, "POST", ts.URL+user+"/docs", "application/json", `{"title":"other"}`).Header.Get("Location")
+    if res := call(t, "PUT", ts.URL+other, "application/json", `{"title":"theft","file":`+string(ref)+`}`); res.StatusCode != http.StatusBadRequest {
+        t.Errorf("PUT with a blob reference: status %d, want 400", res.StatusCode)
+    }
+    if res := call(t, "PATCH", ts.URL+other, "application/merge-patch+json", `{"file":`+string(ref)+`}`); res.StatusCode != http.StatusUnprocessableEntity {
+        t.Errorf("PATCH adding a blob reference: status %d, want 422", res.StatusCode)
+    }
+    if status, _ := download(t, ts.URL+other); status != http.StatusNotFound {
+        t.Errorf("download from the other record: status %d, want 404", status)
+    }
+
+    // Deleting the other record leaves the owner's file alone
+    call(t, "DELETE", ts.URL+other, "", "")
+    if status, content := download(t, ts.URL+owner); status != http.StatusOK || content != "quarterly numbers" {
+        t.Errorf("owner's file: status %d, content %q", status, content)
+    }
+
+    // The owner's own patches keep working
+    if res := call(t, "PATCH", ts.URL+owner, "application/merge-patch+json", `{"title":"renamed"}`); res.StatusCode != http.StatusOK {
+        t.Errorf("PATCH of the title: status %d", res.StatusCode)
+    }
+    if files := blobFiles(t); len(files) != 1 {
+        t.Errorf("got blob files %v, want only the owner's", files)
+    }
+}
+
+func TestRuntimeBlobPathTraversal(t *testing.T) {
+    root := t.TempDir()
+    BlobDir = filepath.Join(root, "a", "blobs")
+    secret := filepath.Join(root, "poc", "secret.txt")
+    os.MkdirAll(filepath.Dir(secret), 0o755)
+    os.WriteFile(secret, []byte("secret"), 0o644)
+    ts := newTestServer(t)
+    user := call(t, "POST", ts.URL+"/users", "application/json", `{"name":"ada"}`).Header.Get("Location")
+
+    evil := `{"title":"x","file":{"blob":"../poc/secret.txt","size":6}}`
+    if res := call(t, "POST", ts.URL+user+"/docs", "application/json", evil); res.StatusCode != http.StatusBadRequest {
+        t.Errorf("body with a path as blob ID: status %d, want 400", res.StatusCode)
+    }
+
+    // A stored document holding such a reference does not reach the file
+    doc := call(t, "POST", ts.URL+user+"/docs", "application/json", `{"title":"x"}`).Header.Get("Location")
+    key := strings.ReplaceAll(strings.TrimPrefix(doc, "/"), "/", ":")
+    err := DB.Update(func(txn *badger.Txn) error {
+        return txn.Set([]byte(key), []byte(evil))
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    if status, content := download(t, ts.URL+doc); status == http.StatusOK || content == "secret" {
+        t.Errorf("download read the file outside the blob directory: status %d, content %q", status, content)
+    }
+    call(t, "DELETE", ts.URL+doc, "", "")
+    if _, err := os.Stat(secret); err != nil {
+        t.Errorf("deleting the record removed the file outside the blob directory: %v", err)
+    }
+}
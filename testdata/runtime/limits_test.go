@@ -0,0 +1,101 @@
+package main
+
+import (
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "github.com/dgraph-io/badger/v3"
+)
+
+// newLimitedServer starts the server with its rate limits in place
+func newLimitedServer(t *testing.T) *httptest.Server {
+    t.Helper()
+    db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { db.Close() })
+    if err := SetupDB(db); err != nil {
+        t.Fatal(err)
+    }
+    ts := httptest.NewServer(NewServer(db, "").Handler)
+    t.Cleanup(ts.Close)
+    return ts
+}
+
+// listWithKey lists the items sending key and returns the status
+func listWithKey(t *testing.T, url, key string) int {
+    t.Helper()
+    req, _ := http.NewRequest(http.MethodGet, url+"/items", nil)
+    req.Header.Set("X-API-Key", key)
+    res, err := http.DefaultClient.Do(req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    res.Body.Close()
+    return res.StatusCode
+}
+
+func TestRuntimeRateLimitByAPIKey(t *testing.T) {
+    t.Setenv("KEY_AUTH_API_KEYS", "k1,k2")
+    ts := newLimitedServer(t)
+    for i := 0; i < 2; i++ {
+        if status := listWithKey(t, ts.URL, "k1"); status != http.StatusOK {
+            t.Fatalf("request %d with k1: status %d, want 200", i+1, status)
+        }
+    }
+    if status := listWithKey(t, ts.URL, "k1"); status != http.StatusTooManyRequests {
+        t.Errorf("third request with k1: status %d, want 429", status)
+    }
+    if status := listWithKey(t, ts.URL, "k2"); status != http.StatusOK {
+        t.Errorf("first request with k2: status %d, want 200", status)
+    }
+}
+
+func TestRuntimeRateLimitIgnoresUnverifiedKeys(t *testing.T) {
+    t.Setenv("KEY_AUTH_API_KEYS", "k1")
+    t.Setenv("BASIC_AUTH_USERS", "ann:secret")
+    ts := newLimitedServer(t)
+    // A client authenticated otherwise must not get a new bucket by adding a
+    // made-up API key to every request
+    statuses := make([]int, 3)
+    for i := range statuses {
+        req, _ := http.NewRequest(http.MethodGet, ts.URL+"/items", nil)
+        req.SetBasicAuth("ann", "secret")
+        req.Header.Set("X-API-Key", fmt.Sprintf("guess-%d", i))
+        res, err := http.DefaultClient.Do(req)
+        if err != nil {
+            t.Fatal(err)
+        }
+        res.Body.Close()
+        statuses[i] = res.StatusCode
+    }
+    if statuses[0] != http.StatusOK || statuses[1] != http.StatusOK || statuses[2] != http.StatusTooManyRequests {
+        t.Errorf("statuses %v, want [200 200 429]", statuses)
+    }
+    // Made-up keys alone are turned away
+    if status := listWithKey(t, ts.URL, "guess"); status != http.StatusUnauthorized {
+        t.Errorf("request with a made-up key: status %d, want 401", status)
+    }
+}
+
+func TestRuntimeRateLimitByIP(t *testing.T) {
+    ts := newLimitedServer(t)
+    res, err := http.Get(ts.URL + "/status")
+    if err != nil {
+        t.Fatal(err)
+    }
+    res.Body.Close()
+    if res.StatusCode == http.StatusTooManyRequests || res.Header.Get("RateLimit-Limit") != "1" || res.Header.Get("RateLimit-Remaining") != "0" {
+        t.Errorf("first request: status %d, RateLimit-Limit %q, RateLimit-Remaining %q", res.StatusCode, res.Header.Get("RateLimit-Limit"), res.Header.Get("RateLimit-Remaining"))
+    }
+    res, err = http.Get(ts.URL + "/status")
+    if err != nil {
+        t.Fatal(err)
+    }
+    res.Body.Close()
+    if res.StatusCode != http.StatusTooManyRequests || res.Header.Get("Retry-After") != "3600" {
+        t.Errorf("second request: status %d, Retry-After %q, want 429 after 3600s", res.StatusCode, res.Header.Get("Retry-After"))
+    }
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+    "compress/gzip"
+    "io"
+    "net/http"
+    "strings"
+    "testing"
+)
+
+// placeOrder posts an order with an Idempotency-Key and returns the response
+// with its decoded body
+func placeOrder(t *testing.T, url, acceptEncoding string) (*http.Response, string) {
+    t.Helper()
+    req, _ := http.NewRequest(http.MethodPost, url+"/orders", strings.NewReader(`{"item": "book"}`))
+    req.Header.Set("Content-Type", "application/json")
+    req.Header.Set("Idempotency-Key", "order-1")
+    if acceptEncoding != "" {
+        req.Header.Set("Accept-Encoding", acceptEncoding)
+    }
+    res, err := http.DefaultClient.Do(req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer res.Body.Close()
+    var body io.Reader = res.Body
+    if res.Header.Get("Content-Encoding") == "gzip" {
+        gz, err := gzip.NewReader(res.Body)
+        if err != nil {
+            t.Fatalf("body labelled gzip is not gzip: %v", err)
+        }
+        body = gz
+    }
+    data, err := io.ReadAll(body)
+    if err != nil {
+        t.Fatalf("reading body: %v", err)
+    }
+    return res, string(data)
+}
+
+func TestRuntimeReplayCompressed(t *testing.T) {
+    ts := newTestServer(t)
+    first, want := placeOrder(t, ts.URL, "gzip")
+    if first.StatusCode != http.StatusCreated || first.Header.Get("Content-Encoding") != "gzip" {
+        t.Fatalf("first request: status %d, Content-Encoding %q", first.StatusCode, first.Header.Get("Content-Encoding"))
+    }
+    for _, encoding := range []string{"gzip", ""} {
+        res, got := placeOrder(t, ts.URL, encoding)
+        if res.Header.Get("Idempotent-Replayed") != "true" {
+            t.Fatalf("Accept-Encoding %q: response was not replayed", encoding)
+        }
+        if res.StatusCode != http.StatusCreated || got != want {
+            t.Errorf("Accept-Encoding %q: replayed %d %q, want 201 %q", encoding, res.StatusCode, got, want)
+        }
+        if res.Header.Get("Content-Encoding") != encoding {
+            t.Errorf("Accept-Encoding %q: Content-Encoding %q", encoding, res.Header.Get("Content-Encoding"))
+        }
+        if vary := res.Header.Values("Vary"); len(vary) != 1 {
+            t.Errorf("Accept-Encoding %q: Vary %q, want it once", encoding, vary)
+        }
+    }
+}
+
+func TestRuntimeReplayKeyReuse(t *testing.T) {
+    ts := newTestServer(t)
+    first, _ := placeOrder(t, ts.URL, "")
+    if first.StatusCode != http.StatusCreated {
+        t.Fatalf("first request: status %d", first.StatusCode)
+    }
+    again, _ := placeOrder(t, ts.URL, "")
+    if again.Header.Get("Location") != first.Header.Get("Location") {
+        t.Errorf("replay created %q, want the first order %q", again.Header.Get("Location"), first.Header.Get("Location"))
+    }
+
+    // The same key with another body is refused
+    req, _ := http.NewRequest(http.MethodPost, ts.URL+"/orders", strings.NewReader(`{"item": "pen"}`))
+    req.Header.Set("Content-Type", "application/json")
+    req.Header.Set("Idempotency-Key", "order-1")
+    res, err := http.DefaultClient.Do(req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    res.Body.Close()
+    if res.StatusCode != http.StatusUnprocessableEntity {
+        t.Errorf("key reused for another body: status %d, want 422", res.StatusCode)
+    }
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+    "encoding/json"
+    "io"
+    "net/http"
+    "strconv"
+    "strings"
+    "testing"
+)
+
+// submit posts a JSON body and returns the status and body of the response
+func submit(t *testing.T, url, body string) (int, string) {
+    t.Helper()
+    res, err := http.Post(url, "application/json", strings.NewReader(body))
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer res.Body.Close()
+    data, _ := io.ReadAll(res.Body)
+    return res.StatusCode, string(data)
+}
+
+func TestRuntimeValidationAccepts(t *testing.T) {
+    ts := newTestServer(t)
+    for _, body := range []string{
+        `{"title": "x"}`,
+        `{"title": "groceries", "kind": "b", "priority": 5, "meta": {"x": 1.5}, "tags": ["home"]}`,
+        `{"title": "twenty characters ok", "priority": 1, "meta": {}, "tags": []}`,
+    } {
+        if status, msg := submit(t, ts.URL+"/notes", body); status != http.StatusCreated {
+            t.Errorf("%s: status %d, want 201: %s", body, status, msg)
+        }
+    }
+    if status, msg := submit(t, ts.URL+"/tags", `{"name": "home"}`); status != http.StatusCreated {
+        t.Errorf("tag: status %d, want 201: %s", status, msg)
+    }
+}
+
+func TestRuntimeValidationRejects(t *testing.T) {
+    ts := newTestServer(t)
+    for _, tc := range []struct {
+        path, body, problem string
+    }{
+        {"/notes", `{"kind": "a"}`, "body.title is required"},
+        {"/notes", `{"title": ""}`, "body.title must be at least 1 characters"},
+        {"/notes", `{"title": "more than twenty characters"}`, "body.title must be at most 20 characters"},
+        {"/notes", `{"title": "x", "kind": "zzz"}`, `body.kind must be one of ["a","b"]`},
+        {"/notes", `{"title": "x", "priority": 2.5}`, "body.priority must be an integer"},
+        {"/notes", `{"title": "x", "priority": 0}`, "body.priority must be at least 1"},
+        {"/notes", `{"title": "x", "priority": 6}`, "body.priority must be at most 5"},
+        {"/notes", `{"title": "x", "meta": {"x": "notanumber"}}`, "body.meta.x must be of type number"},
+        {"/notes", `{"title": "x", "meta": {"y": 1}}`, "body.meta.y is not allowed"},
+        {"/notes", `{"title": "x", "tags": [1, 2]}`, "body.tags[0] must be of type string"},
+        {"/notes", `{"title": "x", "tags": ["a", "b", "c", "d"]}`, "body.tags must have at most 3 items"},
+        {"/notes", `{"title": null}`, "body.title must not be null"},
+        {"/notes", `["x"]`, "body must be of type object"},
+        {"/tags", `{"name": "Home"}`, "body.name must match ^[a-z]+$"},
+        {"/tags", `{"name": "verylongname"}`, "body.name must be at most 8 characters"},
+    } {
+        status, msg := submit(t, ts.URL+tc.path, tc.body)
+        if status != http.StatusBadRequest || !strings.Contains(msg, tc.problem) {
+            t.Errorf("%s %s: status %d %q, want 400 reporting %q", tc.path, tc.body, status, strings.TrimSpace(msg), tc.problem)
+        }
+    }
+
+    // Every problem of a body is reported at once
+    status, msg := submit(t, ts.URL+"/notes", `{"title":"","kind":"zzz","meta":{"x":"notanumber"},"tags":[1,2]}`)
+    if status != http.StatusBadRequest || strings.Count(msg, ";") != 4 {
+        t.Errorf("invalid note: status %d %q, want 400 with five problems", status, strings.TrimSpace(msg))
+    }
+}
+
+// patchNote sends a patch of the given media type and returns the status and
+// body of the response
+func patchNote(t *testing.T, url, mediaType, patch string) (int, string) {
+    t.Helper()
+    req, _ := http.NewRequest(http.MethodPatch, url, strings.NewReader(patch))
+    req.Header.Set("Content-Type", mediaType)
+    res, err := http.DefaultClient.Do(req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer res.Body.Close()
+    data, _ := io.ReadAll(res.Body)
+    return res.StatusCode, string(data)
+}
+
+func TestRuntimePatchKeepsID(t *testing.T) {
+    ts := newTestServer(t)
+    status, body := submit(t, ts.URL+"/notes", `{"title": "x"}`)
+    if status != http.StatusCreated {
+        t.Fatalf("create: status %d: %s", status, body)
+    }
+    var note map[string]interface{}
+    if err := json.Unmarshal([]byte(body), &note); err != nil {
+        t.Fatalf("create: %v: %s", err, body)
+    }
+    id, _ := note["id"].(string)
+    // Minted IDs stay exact when clients read them as JSON numbers
+    if n, err := strconv.ParseInt(id, 10, 64); err != nil || n >= 1<<53 {
+        t.Errorf("minted ID %q is not an integer below 2^53", id)
+    }
+    url := ts.URL + "/notes/" + id
+
+    for _, tc := range []struct{ mediaType, patch string }{
+        {"application/merge-patch+json", `{"id": "other", "title": "y"}`},
+        {"application/merge-patch+json", `{"id": null}`},
+        {"application/json-patch+json", `[{"op": "replace", "path": "/id", "value": "other"}]`},
+        {"application/json-patch+json", `[{"op": "remove", "path": "/id"}]`},
+    } {
+        status, body := patchNote(t, url, tc.mediaType, tc.patch)
+        if status != http.StatusOK {
+            t.Errorf("%s: status %d: %s", tc.patch, status, body)
+            continue
+        }
+        var patched map[string]interface{}
+        json.Unmarshal([]byte(body), &patched)
+        if patched["id"] != id {
+            t.Errorf("%s: id %v, want %q", tc.patch, patched["id"], id)
+        }
+    }
+    res, err := http.Get(ts.URL + "/notes/other")
+    if err != nil {
+        t.Fatal(err)
+    }
+    res.Body.Close()
+    if res.StatusCode != http.StatusNotFound {
+        t.Errorf("GET /notes/other: status %d, want 404", res.StatusCode)
+    }
+
+    // The patched document is validated like a request body
+    if status, body := patchNote(t, url, "application/merge-patch+json", `{"kind": "zzz"}`); status != http.StatusUnprocessableEntity {
+        t.Errorf("invalid patch: status %d, want 422: %s", status, body)
+    }
+}
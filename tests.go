@@ -0,0 +1,935 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// testUpdateValue is written by the round-trip tests into the field they update
+const testUpdateValue = "updated"
+
+// generateHandlerTests creates handlers_test.go: an example request for every
+// operation, checked against its success status and response schema, and a
+// create, read, update and delete round trip for every collection
+func generateHandlerTests(spec *OpenAPISpec, ops []operation, schemas map[string]Schema, hasSecurity bool) string {
+	var body strings.Builder
+	body.WriteString(handlerTestTypes)
+	body.WriteString("// operationTests holds an example request for every operation of the spec\n")
+	body.WriteString("var operationTests = []operationTest{\n")
+	tests := map[string]exampleRequest{}
+	for _, op := range ops {
+		req := operationRequest(spec, op)
+		tests[op.OperationID] = req
+		body.WriteString(fmt.Sprintf("    {OperationID: %q, Method: %q, Path: %q", op.OperationID, op.Method, op.Path))
+		if len(req.Params) > 0 {
+			body.WriteString(", Params: " + stringMap(req.Params))
+		}
+		if len(req.Query) > 0 {
+			body.WriteString(", Query: " + stringMap(req.Query))
+		}
+		if len(req.Header) > 0 {
+			body.WriteString(", Header: " + stringMap(req.Header))
+		}
+		if req.ContentType != "" {
+			body.WriteString(fmt.Sprintf(", ContentType: %q, Body: %q", req.ContentType, req.Body))
+		}
+		body.WriteString(fmt.Sprintf(", Status: %s", statusConst(operationSuccess(op).Status)))
+		if pointer := responseSchemaPointer(spec, op); pointer != "" {
+			body.WriteString(fmt.Sprintf(", Schema: %q", pointer))
+		}
+		body.WriteString("},\n")
+	}
+	body.WriteString("}\n\n")
+
+	body.WriteString("// crudTests lists the collections whose members can be created, read and deleted\n")
+	body.WriteString("var crudTests = []crudTest{\n")
+	for _, crud := range collectCRUD(spec, ops, schemas, tests) {
+		body.WriteString(fmt.Sprintf("    {Create: %q, Get: %q, Delete: %q", crud.Create, crud.Get, crud.Delete))
+		if crud.ID != "" {
+			body.WriteString(fmt.Sprintf(", ID: %q", crud.ID))
+		}
+		if crud.Update != "" {
+			body.WriteString(fmt.Sprintf(", Update: %q, UpdateType: %q, UpdateBody: %q", crud.Update, crud.UpdateType, crud.UpdateBody))
+		}
+		if crud.Field != "" {
+			body.WriteString(fmt.Sprintf(", Field: %q, Value: %q", crud.Field, testUpdateValue))
+		}
+		body.WriteString("},\n")
+	}
+	body.WriteString("}\n\n")
+
+	// Credentials are only sent to servers with security schemes
+	setup, authorize := "", ""
+	if hasSecurity {
+		setup, authorize = "    useTestCredentials(t)\n", "    authorize(req, test.OperationID)\n"
+	}
+	body.WriteString(strings.NewReplacer("    // setup: credentials\n", setup, "    // send: credentials\n", authorize).Replace(handlerTestRuntime))
+	if hasSecurity {
+		body.WriteString(handlerTestAuth)
+	}
+	code := body.String()
+
+	var out strings.Builder
+	out.WriteString("package main\n\n")
+	out.WriteString(importBlock(code, "crypto/hmac", "crypto/sha256", "encoding/base64", "encoding/json", "fmt", "io", "math", "net/http", "net/http/httptest", "os", "path", "path/filepath", "strings", "testing", "time", "github.com/dgraph-io/badger/v3"))
+	out.WriteString(code)
+	return out.String()
+}
+
+// exampleRequest is the request a generated test sends to an operation
+type exampleRequest struct {
+	Params      map[string]string // path parameters used when no resource is created for them
+	Query       map[string]string
+	Header      map[string]string
+	ContentType string
+	Body        string
+	Object      map[string]interface{} // JSON body as sent, nil for other bodies
+}
+
+// operationRequest builds the example request of an operation from the
+// examples of its parameters and body, or data synthesized from their schemas
+func operationRequest(spec *OpenAPISpec, op operation) exampleRequest {
+	var req exampleRequest
+	var cookies []string
+	for _, p := range operationParameters(op, spec.Components) {
+		if p.In != "path" && !p.Required {
+			continue
+		}
+		value := paramExample(synthesize(spec, p.Schema, 0))
+		switch p.In {
+		case "path":
+			if req.Params == nil {
+				req.Params = map[string]string{}
+			}
+			req.Params[p.Name] = value
+		case "query":
+			if req.Query == nil {
+				req.Query = map[string]string{}
+			}
+			req.Query[p.Name] = value
+		case "header":
+			if req.Header == nil {
+				req.Header = map[string]string{}
+			}
+			req.Header[p.Name] = value
+		case "cookie":
+			cookies = append(cookies, p.Name+"="+value)
+		}
+	}
+	if len(cookies) > 0 {
+		if req.Header == nil {
+			req.Header = map[string]string{}
+		}
+		req.Header["Cookie"] = strings.Join(cookies, "; ")
+	}
+
+	reqBody, _ := resolveRef(spec, op.Endpoint["requestBody"]).(map[string]interface{})
+	switch op.Method {
+	case "PATCH":
+		req.ContentType, req.Body = patchRequest(op, "")
+	case "POST", "PUT":
+		types := mediaTypes(reqBody, false)
+		if len(types) == 0 {
+			return req
+		}
+		content, _ := reqBody["content"].(map[string]interface{})
+		media, _ := content[types[0]].(map[string]interface{})
+		schema := resolveSchema(spec, mapValue(media, "schema"))
+		value := mediaExample(spec, media, "")
+		if object, ok := value.(map[string]interface{}); ok {
+			value = requestFields(spec, schema, object)
+		}
+		req.ContentType, req.Body = encodeExample(types[0], spec, schema, value)
+		if mediaCategory(types[0]) == "json" {
+			req.Object, _ = value.(map[string]interface{})
+		}
+	}
+	return req
+}
+
+// paramExample renders a parameter value the way it appears in a URL or
+// header, arrays as comma-separated lists and objects as comma-separated
+// names and values
+func paramExample(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "test"
+	case string:
+		return v
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = paramExample(item)
+		}
+		return strings.Join(items, ",")
+	case map[string]interface{}:
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		var items []string
+		for _, name := range names {
+			items = append(items, name, paramExample(v[name]))
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(value)
+}
+
+// requestFields drops what a request does not send from an example:
+// read-only properties, unless required, and files, as JSON bodies can only
+// refer to files uploaded before. File lists are sent empty.
+func requestFields(spec *OpenAPISpec, schema map[string]interface{}, object map[string]interface{}) map[string]interface{} {
+	properties, _ := schema["properties"].(map[string]interface{})
+	required := map[string]bool{}
+	names, _ := schema["required"].([]interface{})
+	for _, name := range names {
+		if s, ok := name.(string); ok {
+			required[s] = true
+		}
+	}
+	out := map[string]interface{}{}
+	for name, value := range object {
+		prop := resolveSchema(spec, mapValue(properties, name))
+		if readOnly, _ := prop["readOnly"].(bool); readOnly && !required[name] {
+			continue
+		}
+		switch propertyGoType(prop) {
+		case "*BlobRef":
+		case "[]*BlobRef":
+			out[name] = []interface{}{}
+		default:
+			out[name] = value
+		}
+	}
+	return out
+}
+
+// encodeExample encodes an example body in a media type, returning the
+// Content-Type to send with it
+func encodeExample(mediaType string, spec *OpenAPISpec, schema map[string]interface{}, value interface{}) (string, string) {
+	object, _ := value.(map[string]interface{})
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	switch mediaCategory(mediaType) {
+	case "json":
+		data, _ := json.Marshal(value)
+		return mediaType, string(data)
+	case "form":
+		form := url.Values{}
+		for _, name := range names {
+			if items, ok := object[name].([]interface{}); ok {
+				for _, item := range items {
+					form.Add(name, paramExample(item))
+				}
+				continue
+			}
+			form.Set(name, formValue(object[name]))
+		}
+		return mediaType, form.Encode()
+	case "multipart":
+		var buf bytes.Buffer
+		w := multipart.NewWriter(&buf)
+		w.SetBoundary("oapi-gen-test-boundary")
+		// Files are sent as file parts in place of the example's values
+		files := map[string]bool{}
+		properties, _ := schema["properties"].(map[string]interface{})
+		for name := range properties {
+			if t := propertyGoType(resolveSchema(spec, mapValue(properties, name))); t == "*BlobRef" || t == "[]*BlobRef" {
+				files[name] = true
+				if _, ok := object[name]; !ok {
+					names = append(names, name)
+				}
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if files[name] {
+				part, _ := w.CreateFormFile(name, "test.txt")
+				part.Write([]byte("test file"))
+				continue
+			}
+			if items, ok := object[name].([]interface{}); ok {
+				for _, item := range items {
+					w.WriteField(name, formValue(item))
+				}
+				continue
+			}
+			w.WriteField(name, formValue(object[name]))
+		}
+		w.Close()
+		return w.FormDataContentType(), buf.String()
+	case "xml":
+		var b strings.Builder
+		b.WriteString("<document>")
+		for _, name := range names {
+			b.WriteString(fmt.Sprintf("<%s>%s</%s>", name, formValue(object[name]), name))
+		}
+		b.WriteString("</document>")
+		return mediaType, b.String()
+	case "text":
+		if s, ok := value.(string); ok {
+			return mediaType, s
+		}
+		return mediaType, "test"
+	}
+	switch {
+	case mediaType == "*/*":
+		mediaType = "application/octet-stream"
+	case strings.HasSuffix(mediaType, "/*"):
+		mediaType = strings.TrimSuffix(mediaType, "*") + "test"
+	}
+	return mediaType, "test file"
+}
+
+// formValue renders a field of a form or multipart body; objects are sent
+// as JSON
+func formValue(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		data, _ := json.Marshal(value)
+		return string(data)
+	}
+	return paramExample(value)
+}
+
+// patchRequest encodes a patch setting field to the update value, or an
+// empty patch without a field. Merge patches are preferred.
+func patchRequest(op operation, field string) (string, string) {
+	types := patchMediaTypes(op)
+	mediaType := types[0]
+	for _, t := range types {
+		if t == mergePatchMediaType {
+			mediaType = t
+		}
+	}
+	if mediaType == jsonPatchMediaType {
+		if field == "" {
+			return mediaType, "[]"
+		}
+		data, _ := json.Marshal([]map[string]interface{}{{"op": "replace", "path": "/" + field, "value": testUpdateValue}})
+		return mediaType, string(data)
+	}
+	if field == "" {
+		return mediaType, "{}"
+	}
+	data, _ := json.Marshal(map[string]string{field: testUpdateValue})
+	return mediaType, string(data)
+}
+
+// responseSchemaPointer locates the JSON schema of an operation's success
+// response in the embedded spec, or returns "" when it has none or the
+// generated handler cannot answer with it
+func responseSchemaPointer(spec *OpenAPISpec, op operation) string {
+	success := operationSuccess(op)
+	if success.Declared == "" {
+		return ""
+	}
+	resp, _ := resolveRef(spec, success.Response).(map[string]interface{})
+	content, _ := resp["content"].(map[string]interface{})
+	mediaType := jsonMediaType(content)
+	media, _ := content[mediaType].(map[string]interface{})
+	if media["schema"] == nil {
+		return ""
+	}
+	// A GET of a collection lists the stored members; a response of another
+	// shape, such as search results, needs a handler written by hand
+	schema, _ := resolveRef(spec, media["schema"]).(map[string]interface{})
+	if t, _ := schema["type"].(string); op.Method == "GET" && !op.Resource.IsItem() && t != "" && t != "array" {
+		return ""
+	}
+	escape := strings.NewReplacer("~", "~0", "/", "~1")
+	return "/paths/" + escape.Replace(op.Path) + "/" + strings.ToLower(op.Method) + "/responses/" + escape.Replace(success.Declared) + "/content/" + escape.Replace(mediaType) + "/schema"
+}
+
+// crudCase names the operations taking a member of a collection through its
+// life cycle
+type crudCase struct {
+	Create, Get, Update, Delete string
+	ID                          string // property the server stores the ID in
+	UpdateType, UpdateBody      string
+	Field                       string
+}
+
+// collectCRUD finds the collections with a create operation whose members
+// can be read and deleted, and the update to check in between
+func collectCRUD(spec *OpenAPISpec, ops []operation, schemas map[string]Schema, tests map[string]exampleRequest) []crudCase {
+	var cases []crudCase
+	for _, create := range ops {
+		if create.Method != "POST" || create.Resource.IsItem() {
+			continue
+		}
+		crud := crudCase{Create: create.OperationID, ID: resourceIDFor(ops, create, schemas, operationSuccess(create)).Field}
+		var put, patch *operation
+		var param string
+		for i, op := range ops {
+			parent, p, ok := itemPath(op.Path)
+			if !ok || parent != create.Path {
+				continue
+			}
+			param = p
+			switch op.Method {
+			case "GET":
+				crud.Get = op.OperationID
+			case "DELETE":
+				crud.Delete = op.OperationID
+			case "PUT":
+				if op.Endpoint["requestBody"] != nil {
+					put = &ops[i]
+				}
+			case "PATCH":
+				patch = &ops[i]
+			}
+		}
+		if crud.Get == "" || crud.Delete == "" {
+			continue
+		}
+		created := tests[create.OperationID].Object
+		crud.Field = updatableField(spec, create, created, crud.ID, param)
+		switch {
+		case put != nil:
+			crud.Update = put.OperationID
+			update := tests[put.OperationID]
+			crud.UpdateType, crud.UpdateBody = update.ContentType, update.Body
+			if _, ok := update.Object[crud.Field]; ok && crud.Field != "" {
+				object := map[string]interface{}{}
+				for k, v := range update.Object {
+					object[k] = v
+				}
+				object[crud.Field] = testUpdateValue
+				data, _ := json.Marshal(object)
+				crud.UpdateBody = string(data)
+			} else {
+				crud.Field = ""
+			}
+		case patch != nil:
+			crud.Update = patch.OperationID
+			crud.UpdateType, crud.UpdateBody = patchRequest(*patch, crud.Field)
+		default:
+			crud.Field = ""
+		}
+		cases = append(cases, crud)
+	}
+	return cases
+}
+
+// updatableField picks a plain string property of the created document for
+// the update to change: no enum, format or pattern, not read-only and not
+// the ID
+func updatableField(spec *OpenAPISpec, create operation, created map[string]interface{}, id, param string) string {
+	if created == nil {
+		return ""
+	}
+	reqBody, _ := resolveRef(spec, create.Endpoint["requestBody"]).(map[string]interface{})
+	content, _ := reqBody["content"].(map[string]interface{})
+	media, _ := content[jsonMediaType(content)].(map[string]interface{})
+	properties, _ := resolveSchema(spec, mapValue(media, "schema"))["properties"].(map[string]interface{})
+	names := make([]string, 0, len(created))
+	for name := range created {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		prop := resolveSchema(spec, mapValue(properties, name))
+		if prop["type"] != "string" || name == id || name == "id" || name == param {
+			continue
+		}
+		if prop["enum"] != nil || prop["format"] != nil || prop["pattern"] != nil || prop["readOnly"] == true {
+			continue
+		}
+		if max, ok := prop["maxLength"].(float64); ok && max < float64(len(testUpdateValue)) {
+			continue
+		}
+		return name
+	}
+	return ""
+}
+
+// stringMap renders a map literal with sorted keys
+func stringMap(m map[string]string) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	entries := make([]string, len(keys))
+	for i, k := range keys {
+		entries[i] = strconv.Quote(k) + ": " + strconv.Quote(m[k])
+	}
+	return "map[string]string{" + strings.Join(entries, ", ") + "}"
+}
+
+const handlerTestTypes = `// operationTest is an example request for one operation. Path parameters
+// are filled in by creating the resources they address where the spec has
+// an operation for that, else with the example in Params.
+type operationTest struct {
+    OperationID string
+    Method      string
+    Path        string
+    Params      map[string]string
+    Query       map[string]string
+    Header      map[string]string
+    ContentType string
+    Body        string
+    Status      int
+    Schema      string // JSON pointer to the response schema in openapi.json, empty without a JSON body
+}
+
+// crudTest names the operations of a collection. Update, when set, changes
+// Field to Value, which the following read must return. ID is the property
+// the server sets to the ID it assigns.
+type crudTest struct {
+    Create     string
+    ID         string
+    Get        string
+    Update     string
+    UpdateType string
+    UpdateBody string
+    Field      string
+    Value      string
+    Delete     string
+}
+
+`
+
+const handlerTestRuntime = `// newTestServer serves the API from an in-memory database for the duration
+// of a test. Rate limits are left out so the tests' requests are not refused.
+func newTestServer(t testing.TB) *httptest.Server {
+    t.Helper()
+    db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+    if err != nil {
+        t.Fatalf("opening database: %v", err)
+    }
+    t.Cleanup(func() { db.Close() })
+    if err := SetupDB(db); err != nil {
+        t.Fatalf("setting up database: %v", err)
+    }
+    // setup: credentials
+    saved := routes
+    routes = make([]route, len(saved))
+    for i, rt := range saved {
+        rt.RateLimits = nil
+        routes[i] = rt
+    }
+    srv := NewServer(db, "")
+    routes = saved
+    ts := httptest.NewServer(srv.Handler)
+    t.Cleanup(ts.Close)
+    return ts
+}
+
+// findTest returns the example request of an operation
+func findTest(t testing.TB, operationID string) operationTest {
+    t.Helper()
+    for _, test := range operationTests {
+        if test.OperationID == operationID {
+            return test
+        }
+    }
+    t.Fatalf("no test for operation %s", operationID)
+    return operationTest{}
+}
+
+// send performs the request of a test on the concrete path
+func send(t testing.TB, ts *httptest.Server, test operationTest, target string) (*http.Response, []byte) {
+    t.Helper()
+    var body io.Reader
+    if test.ContentType != "" {
+        body = strings.NewReader(test.Body)
+    }
+    req, err := http.NewRequest(test.Method, ts.URL+target, body)
+    if err != nil {
+        t.Fatalf("%s: %v", test.OperationID, err)
+    }
+    if len(test.Query) > 0 {
+        query := req.URL.Query()
+        for name, value := range test.Query {
+            query.Set(name, value)
+        }
+        req.URL.RawQuery = query.Encode()
+    }
+    for name, value := range test.Header {
+        req.Header.Set(name, value)
+    }
+    if test.ContentType != "" {
+        req.Header.Set("Content-Type", test.ContentType)
+    }
+    // send: credentials
+    resp, err := ts.Client().Do(req)
+    if err != nil {
+        t.Fatalf("%s %s: %v", test.Method, target, err)
+    }
+    defer resp.Body.Close()
+    data, err := io.ReadAll(resp.Body)
+    if err != nil {
+        t.Fatalf("%s %s: reading response: %v", test.Method, target, err)
+    }
+    return resp, data
+}
+
+// fillPath substitutes the path parameters of a test. The member each one
+// selects is created first when the spec can create it, so the request finds
+// its parents and the item it addresses; complete is false when an example
+// value had to be used instead. POSTs to an item path pick their own ID.
+func fillPath(t testing.TB, ts *httptest.Server, test operationTest) (string, bool) {
+    t.Helper()
+    complete := true
+    segments := strings.Split(test.Path, "/")
+    templates := append([]string(nil), segments...)
+    for i, segment := range segments {
+        if !strings.HasPrefix(segment, "{") || !strings.HasSuffix(segment, "}") {
+            continue
+        }
+        name := strings.Trim(segment, "{}")
+        segments[i] = test.Params[name]
+        if i == len(segments)-1 && test.Method == http.MethodPost {
+            continue
+        }
+        var create *operationTest
+        for j, candidate := range operationTests {
+            if candidate.Method == http.MethodPost && candidate.Path == strings.Join(templates[:i], "/") {
+                create = &operationTests[j]
+                break
+            }
+        }
+        if create == nil {
+            complete = false
+            continue
+        }
+        resp, body := send(t, ts, *create, strings.Join(segments[:i], "/"))
+        location := resp.Header.Get("Location")
+        if resp.StatusCode != create.Status || location == "" {
+            t.Fatalf("creating the %s of %s with %s: status %d, Location %q: %s", name, test.OperationID, create.OperationID, resp.StatusCode, location, body)
+        }
+        segments[i] = path.Base(location)
+    }
+    return strings.Join(segments, "/"), complete
+}
+
+// expectStatus fails the test unless the response has the wanted status
+func expectStatus(t *testing.T, test operationTest, resp *http.Response, body []byte, want int) {
+    t.Helper()
+    if resp.StatusCode != want {
+        t.Fatalf("%s %s: status %d, want %d: %s", test.Method, resp.Request.URL.Path, resp.StatusCode, want, body)
+    }
+}
+
+// TestOperations sends every operation its example request and checks the
+// status and that JSON responses match their schema
+func TestOperations(t *testing.T) {
+    ts := newTestServer(t)
+    for _, test := range operationTests {
+        t.Run(test.OperationID, func(t *testing.T) {
+            target, complete := fillPath(t, ts, test)
+            resp, body := send(t, ts, test, target)
+            // Nothing could be created for made-up IDs, and upserts create them
+            if !complete && (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusCreated) {
+                return
+            }
+            expectStatus(t, test, resp, body, test.Status)
+            checkSchema(t, test.Schema, resp, body)
+        })
+    }
+}
+
+// TestCRUD creates a member of each collection, reads it back, updates it,
+// deletes it and checks that it is gone
+func TestCRUD(t *testing.T) {
+    ts := newTestServer(t)
+    for _, crud := range crudTests {
+        t.Run(crud.Create, func(t *testing.T) {
+            create, get, del := findTest(t, crud.Create), findTest(t, crud.Get), findTest(t, crud.Delete)
+            collection, _ := fillPath(t, ts, create)
+            resp, body := send(t, ts, create, collection)
+            expectStatus(t, create, resp, body, create.Status)
+            item := resp.Header.Get("Location")
+            if item == "" {
+                t.Fatalf("%s: no Location header", crud.Create)
+            }
+
+            resp, body = send(t, ts, get, item)
+            expectStatus(t, get, resp, body, get.Status)
+            checkSchema(t, get.Schema, resp, body)
+            checkFields(t, create.Body, body, crud.ID)
+
+            if crud.Update != "" {
+                update := findTest(t, crud.Update)
+                update.ContentType, update.Body = crud.UpdateType, crud.UpdateBody
+                resp, body = send(t, ts, update, item)
+                expectStatus(t, update, resp, body, update.Status)
+                if crud.Field != "" {
+                    resp, body = send(t, ts, get, item)
+                    expectStatus(t, get, resp, body, get.Status)
+                    want, _ := json.Marshal(map[string]string{crud.Field: crud.Value})
+                    checkFields(t, string(want), body, crud.ID)
+                }
+            }
+
+            resp, body = send(t, ts, del, item)
+            expectStatus(t, del, resp, body, del.Status)
+            resp, body = send(t, ts, get, item)
+            expectStatus(t, get, resp, body, http.StatusNotFound)
+        })
+    }
+}
+
+// checkFields compares the properties of a sent JSON object, but the ID,
+// with the returned document. Zero values may be left out of responses.
+func checkFields(t *testing.T, sent string, got []byte, id string) {
+    t.Helper()
+    var want, doc map[string]interface{}
+    if json.Unmarshal([]byte(sent), &want) != nil || json.Unmarshal(got, &doc) != nil {
+        return
+    }
+    for name, value := range want {
+        if name == id {
+            continue
+        }
+        switch value {
+        case nil, "", 0.0, false:
+            continue
+        }
+        wantJSON, _ := json.Marshal(value)
+        gotJSON, _ := json.Marshal(doc[name])
+        if string(wantJSON) != string(gotJSON) {
+            t.Errorf("field %s is %s, want %s", name, gotJSON, wantJSON)
+        }
+    }
+}
+
+// testSpec is the embedded spec, decoded for schema lookups
+var testSpec = func() interface{} {
+    var spec interface{}
+    json.Unmarshal(specJSON, &spec)
+    return spec
+}()
+
+// specNode returns the value at a JSON pointer into the spec, following
+// $refs on the way
+func specNode(pointer string) interface{} {
+    node := testSpec
+    for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+        token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+        obj, _ := resolveNode(node).(map[string]interface{})
+        node = obj[token]
+    }
+    return resolveNode(node)
+}
+
+// resolveNode follows local $refs
+func resolveNode(node interface{}) interface{} {
+    for i := 0; i < 32; i++ {
+        obj, _ := node.(map[string]interface{})
+        ref, ok := obj["$ref"].(string)
+        if !ok || !strings.HasPrefix(ref, "#/") {
+            return node
+        }
+        node = specNode(ref[1:])
+    }
+    return node
+}
+
+// checkSchema validates a JSON response body against the schema at pointer
+func checkSchema(t *testing.T, pointer string, resp *http.Response, body []byte) {
+    t.Helper()
+    if pointer == "" || !strings.Contains(resp.Header.Get("Content-Type"), "json") {
+        return
+    }
+    var doc interface{}
+    if err := json.Unmarshal(body, &doc); err != nil {
+        t.Fatalf("response is not JSON: %v: %s", err, body)
+    }
+    schema, _ := specNode(pointer).(map[string]interface{})
+    for _, problem := range schemaProblems(schema, doc, "response") {
+        t.Error(problem)
+    }
+}
+
+// schemaProblems lists where a decoded JSON value does not match a schema.
+// Formats are not checked, and files may be returned as references.
+func schemaProblems(schema map[string]interface{}, value interface{}, at string) []string {
+    schema, _ = resolveNode(schema).(map[string]interface{})
+    if schema == nil || schema["format"] == "binary" {
+        return nil
+    }
+    if value == nil {
+        if schema["nullable"] == true || schema["type"] == nil {
+            return nil
+        }
+        return []string{at + " is null"}
+    }
+    var problems []string
+    if values, ok := schema["enum"].([]interface{}); ok {
+        found := false
+        for _, v := range values {
+            a, _ := json.Marshal(v)
+            b, _ := json.Marshal(value)
+            found = found || string(a) == string(b)
+        }
+        if !found {
+            problems = append(problems, fmt.Sprintf("%s is %v, not one of the enum values", at, value))
+        }
+    }
+    if all, ok := schema["allOf"].([]interface{}); ok {
+        for _, sub := range all {
+            s, _ := sub.(map[string]interface{})
+            problems = append(problems, schemaProblems(s, value, at)...)
+        }
+    }
+    for _, keyword := range []string{"oneOf", "anyOf"} {
+        variants, ok := schema[keyword].([]interface{})
+        if !ok {
+            continue
+        }
+        matched := false
+        for _, sub := range variants {
+            s, _ := sub.(map[string]interface{})
+            matched = matched || len(schemaProblems(s, value, at)) == 0
+        }
+        if !matched {
+            problems = append(problems, at+" matches none of the "+keyword+" schemas")
+        }
+    }
+    want, _ := schema["type"].(string)
+    switch v := value.(type) {
+    case map[string]interface{}:
+        if want != "" && want != "object" {
+            return append(problems, at+" is an object, want "+want)
+        }
+        properties, _ := schema["properties"].(map[string]interface{})
+        required, _ := schema["required"].([]interface{})
+        for _, name := range required {
+            if _, ok := v[name.(string)]; !ok {
+                problems = append(problems, at+"."+name.(string)+" is missing")
+            }
+        }
+        for name, field := range v {
+            if prop, ok := properties[name].(map[string]interface{}); ok {
+                problems = append(problems, schemaProblems(prop, field, at+"."+name)...)
+            } else if extra, ok := schema["additionalProperties"].(map[string]interface{}); ok {
+                problems = append(problems, schemaProblems(extra, field, at+"."+name)...)
+            } else if schema["additionalProperties"] == false {
+                problems = append(problems, at+"."+name+" is not declared")
+            }
+        }
+    case []interface{}:
+        if want != "" && want != "array" {
+            return append(problems, at+" is an array, want "+want)
+        }
+        items, _ := schema["items"].(map[string]interface{})
+        for i, item := range v {
+            problems = append(problems, schemaProblems(items, item, fmt.Sprintf("%s[%d]", at, i))...)
+        }
+    case string:
+        if want != "" && want != "string" {
+            problems = append(problems, at+" is a string, want "+want)
+        }
+    case float64:
+        if want == "integer" && v != math.Trunc(v) {
+            problems = append(problems, fmt.Sprintf("%s is %v, want an integer", at, v))
+        } else if want != "" && want != "integer" && want != "number" {
+            problems = append(problems, at+" is a number, want "+want)
+        }
+    case bool:
+        if want != "" && want != "boolean" {
+            problems = append(problems, at+" is a boolean, want "+want)
+        }
+    }
+    return problems
+}
+`
+
+const handlerTestAuth = `
+// useTestCredentials makes the server accept the credentials authorize
+// sends: the API key test-key, the user test:test and bearer tokens signed
+// with a secret of the test
+func useTestCredentials(t testing.TB) {
+    validateAPIKey, validateBasic := ValidateAPIKey, ValidateBasic
+    ValidateAPIKey = func(scheme, key string) (*Principal, error) {
+        if key != "test-key" {
+            return nil, errInvalidCredentials
+        }
+        return &Principal{Subject: "test", Scheme: scheme}, nil
+    }
+    ValidateBasic = func(scheme, user, password string) (*Principal, error) {
+        if user != "test" || password != "test" {
+            return nil, errInvalidCredentials
+        }
+        return &Principal{Subject: user, Scheme: scheme}, nil
+    }
+    keyFile := filepath.Join(t.TempDir(), "jwt.key")
+    if err := os.WriteFile(keyFile, []byte(testJWTSecret), 0600); err != nil {
+        t.Fatal(err)
+    }
+    for _, scheme := range securitySchemes {
+        t.Setenv(scheme.Env+"_JWT_KEY_FILE", keyFile)
+        t.Setenv(scheme.Env+"_JWKS_FILE", "")
+        t.Setenv(scheme.Env+"_JWT_ISSUER", "")
+        t.Setenv(scheme.Env+"_JWT_AUDIENCE", "")
+    }
+    resetKeys := func() {
+        jwtKeysMu.Lock()
+        jwtKeysCache = map[string]*jwtKeys{}
+        jwtKeysMu.Unlock()
+    }
+    resetKeys()
+    t.Cleanup(func() {
+        ValidateAPIKey, ValidateBasic = validateAPIKey, validateBasic
+        resetKeys()
+    })
+}
+
+const testJWTSecret = "handler-test-secret"
+
+// authorize adds credentials for the first security requirement of an
+// operation, with bearer tokens granting the scopes it asks for
+func authorize(req *http.Request, operationID string) {
+    for _, rt := range routes {
+        if rt.OperationID != operationID || len(rt.Security) == 0 {
+            continue
+        }
+        for _, required := range rt.Security[0] {
+            scheme := securitySchemes[required.Scheme]
+            switch {
+            case scheme.Type == "apiKey" && scheme.In == "query":
+                query := req.URL.Query()
+                query.Set(scheme.Name, "test-key")
+                req.URL.RawQuery = query.Encode()
+            case scheme.Type == "apiKey" && scheme.In == "cookie":
+                req.AddCookie(&http.Cookie{Name: scheme.Name, Value: "test-key"})
+            case scheme.Type == "apiKey":
+                req.Header.Set(scheme.Name, "test-key")
+            case scheme.Type == "http" && scheme.Scheme == "basic":
+                req.SetBasicAuth("test", "test")
+            default:
+                req.Header.Set("Authorization", "Bearer "+testToken(required.Scopes))
+            }
+        }
+        return
+    }
+}
+
+// testToken signs an HS256 token for the test subject with the scopes
+func testToken(scopes []string) string {
+    encode := func(v interface{}) string {
+        data, _ := json.Marshal(v)
+        return base64.RawURLEncoding.EncodeToString(data)
+    }
+    claims := map[string]interface{}{"sub": "test", "scope": strings.Join(scopes, " "), "exp": time.Now().Add(time.Hour).Unix()}
+    signed := encode(map[string]string{"alg": "HS256", "typ": "JWT"}) + "." + encode(claims)
+    mac := hmac.New(sha256.New, []byte(testJWTSecret))
+    mac.Write([]byte(signed))
+    return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+`
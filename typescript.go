@@ -0,0 +1,621 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// tsIdentifier matches property names that need no quotes in TypeScript
+var tsIdentifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// tsPropertyName quotes a property name when it is not a valid identifier
+func tsPropertyName(name string) string {
+	if tsIdentifier.MatchString(name) {
+		return name
+	}
+	return fmt.Sprintf("%q", name)
+}
+
+// tsMethodName derives the client method of an operation from its handler
+func tsMethodName(op operation) string {
+	return strings.ToLower(op.HandlerName[:1]) + op.HandlerName[1:]
+}
+
+// schemaMap turns a parsed schema back into its JSON form
+func schemaMap(s Schema) map[string]interface{} {
+	data, _ := json.Marshal(s)
+	var m map[string]interface{}
+	json.Unmarshal(data, &m)
+	if m["type"] == "" {
+		delete(m, "type")
+	}
+	return m
+}
+
+// tsType renders the TypeScript type of a JSON schema. Binary strings are
+// the BlobRef objects the server stores in their place.
+func tsType(schema map[string]interface{}) string {
+	if schema == nil {
+		return "unknown"
+	}
+	if ref, _ := schema["$ref"].(string); ref != "" {
+		return toGoIdentifier(strings.TrimPrefix(ref, "#/components/schemas/"))
+	}
+	t := tsBaseType(schema)
+	if nullable, _ := schema["nullable"].(bool); nullable && !strings.HasSuffix(t, " | null") {
+		t += " | null"
+	}
+	return t
+}
+
+func tsBaseType(schema map[string]interface{}) string {
+	if values, ok := schema["enum"].([]interface{}); ok && len(values) > 0 {
+		literals := make([]string, len(values))
+		for i, v := range values {
+			data, _ := json.Marshal(v)
+			literals[i] = string(data)
+		}
+		return strings.Join(literals, " | ")
+	}
+	for _, keyword := range []string{"oneOf", "anyOf", "allOf"} {
+		variants, _ := schema[keyword].([]interface{})
+		if len(variants) == 0 {
+			continue
+		}
+		types := make([]string, len(variants))
+		for i, v := range variants {
+			variant, _ := v.(map[string]interface{})
+			types[i] = tsGroup(tsType(variant))
+		}
+		if keyword == "allOf" {
+			return strings.Join(types, " & ")
+		}
+		return strings.Join(types, " | ")
+	}
+	// OpenAPI 3.1 lists several types, usually one of them "null"
+	if types, ok := schema["type"].([]interface{}); ok {
+		var parts []string
+		for _, t := range types {
+			variant := map[string]interface{}{}
+			for k, v := range schema {
+				variant[k] = v
+			}
+			variant["type"] = t
+			parts = append(parts, tsGroup(tsBaseType(variant)))
+		}
+		return strings.Join(parts, " | ")
+	}
+	switch schema["type"] {
+	case "string":
+		if schema["format"] == "binary" {
+			return "BlobRef"
+		}
+		return "string"
+	case "integer", "number":
+		return "number"
+	case "boolean":
+		return "boolean"
+	case "null":
+		return "null"
+	case "array":
+		items, _ := schema["items"].(map[string]interface{})
+		return tsGroup(tsType(items)) + "[]"
+	case "object", nil:
+		properties, _ := schema["properties"].(map[string]interface{})
+		if len(properties) > 0 {
+			return tsObject(properties, requiredSet(schema), "")
+		}
+		if additional, ok := schema["additionalProperties"].(map[string]interface{}); ok {
+			return "Record<string, " + tsType(additional) + ">"
+		}
+		if schema["type"] == "object" {
+			return "Record<string, unknown>"
+		}
+	}
+	return "unknown"
+}
+
+// tsGroup parenthesizes unions and intersections used inside other types
+func tsGroup(t string) string {
+	if strings.Contains(t, " | ") || strings.Contains(t, " & ") {
+		return "(" + t + ")"
+	}
+	return t
+}
+
+// requiredSet returns the required property names of an object schema
+func requiredSet(schema map[string]interface{}) map[string]bool {
+	required := map[string]bool{}
+	list, _ := schema["required"].([]interface{})
+	for _, name := range list {
+		if s, ok := name.(string); ok {
+			required[s] = true
+		}
+	}
+	return required
+}
+
+// tsObject renders an object literal type with its properties sorted; indent
+// is the indentation of the closing brace
+func tsObject(properties map[string]interface{}, required map[string]bool, indent string) string {
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	b.WriteString("{\n")
+	for _, name := range names {
+		prop, _ := properties[name].(map[string]interface{})
+		optional := "?"
+		if required[name] {
+			optional = ""
+		}
+		b.WriteString(fmt.Sprintf("%s  %s%s: %s;\n", indent, tsPropertyName(name), optional, tsType(prop)))
+	}
+	b.WriteString(indent + "}")
+	return b.String()
+}
+
+// generateTypeScript writes an npm package with the schema types and a fetch
+// client into dir
+func generateTypeScript(spec *OpenAPISpec, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	schemas, err := collectSchemas(spec)
+	if err != nil {
+		return err
+	}
+	files := map[string]string{
+		"types.ts":      generateTSTypes(schemas),
+		"client.ts":     generateTSClient(spec, collectOperations(spec.Paths), schemas),
+		"index.ts":      "export * from \"./types.js\";\nexport * from \"./client.js\";\n",
+		"package.json":  generateTSPackageJSON(spec),
+		"tsconfig.json": tsConfig,
+	}
+	for name, content := range files {
+		if err := writeFile(filepath.Join(dir, name), content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// generateTSTypes renders an interface per object schema and a type alias for
+// every other schema
+func generateTSTypes(schemas map[string]Schema) string {
+	var code strings.Builder
+	code.WriteString("// Auto-generated types from OpenAPI spec\n\n")
+	code.WriteString("/** A file stored by the server in place of a binary property */\n")
+	code.WriteString("export interface BlobRef {\n  blob: string;\n  contentType?: string;\n  filename?: string;\n  size: number;\n  sha256?: string;\n}\n\n")
+	names := make([]string, 0, len(schemas))
+	for name := range schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		schema := schemas[name]
+		raw := schemaMap(schema)
+		if (schema.Type == "object" || schema.Type == "") && len(schema.Properties) > 0 &&
+			len(schema.Enum) == 0 && len(schema.OneOf) == 0 && len(schema.AnyOf) == 0 && len(schema.AllOf) == 0 && !schema.Nullable {
+			code.WriteString(fmt.Sprintf("export interface %s %s\n\n", toGoIdentifier(name), tsObject(schema.Properties, requiredSet(raw), "")))
+			continue
+		}
+		t := tsType(raw)
+		if schema.Type == "string" && schema.Format == "binary" {
+			// Binary bodies are sent and received as they are
+			t = "Blob"
+		}
+		code.WriteString(fmt.Sprintf("export type %s = %s;\n\n", toGoIdentifier(name), t))
+	}
+	return code.String()
+}
+
+// specSlug derives a lower-case, dash-separated name from the spec title
+func specSlug(spec *OpenAPISpec) string {
+	title, _ := spec.Info["title"].(string)
+	slug := strings.Trim(regexp.MustCompile(`[^a-z0-9]+`).ReplaceAllString(strings.ToLower(title), "-"), "-")
+	if slug == "" {
+		return "api"
+	}
+	return slug
+}
+
+// generateTSPackageJSON describes the TypeScript output as an npm package
+// built with tsc
+func generateTSPackageJSON(spec *OpenAPISpec) string {
+	title, _ := spec.Info["title"].(string)
+	version, _ := spec.Info["version"].(string)
+	if version == "" {
+		version = "0.0.0"
+	}
+	pkg := struct {
+		Name            string            `json:"name"`
+		Version         string            `json:"version"`
+		Description     string            `json:"description"`
+		Type            string            `json:"type"`
+		Main            string            `json:"main"`
+		Types           string            `json:"types"`
+		Files           []string          `json:"files"`
+		Scripts         map[string]string `json:"scripts"`
+		DevDependencies map[string]string `json:"devDependencies"`
+	}{
+		Name:            specSlug(spec) + "-client",
+		Version:         version,
+		Description:     strings.TrimSpace(title + " client"),
+		Type:            "module",
+		Main:            "dist/index.js",
+		Types:           "dist/index.d.ts",
+		Files:           []string{"dist"},
+		Scripts:         map[string]string{"build": "tsc", "prepublishOnly": "tsc"},
+		DevDependencies: map[string]string{"typescript": "^5.4.0"},
+	}
+	data, _ := json.MarshalIndent(pkg, "", "  ")
+	return string(data) + "\n"
+}
+
+// tsConfig compiles the package to ES modules with declarations
+const tsConfig = `{
+  "compilerOptions": {
+    "target": "ES2020",
+    "module": "NodeNext",
+    "moduleResolution": "NodeNext",
+    "lib": ["ES2020", "DOM"],
+    "declaration": true,
+    "outDir": "dist",
+    "strict": true,
+    "skipLibCheck": true
+  },
+  "include": ["*.ts"]
+}
+`
+
+// generateTSClient renders the Client class with a method per operation
+func generateTSClient(spec *OpenAPISpec, ops []operation, schemas map[string]Schema) string {
+	var code strings.Builder
+
+	schemes := extractSecuritySchemes(spec.Components)
+	schemeNames := make([]string, 0, len(schemes))
+	for name := range schemes {
+		schemeNames = append(schemeNames, name)
+	}
+	sort.Strings(schemeNames)
+
+	code.WriteString(`/** Options of a Client */
+export interface ClientOptions {
+  /** Where the API is served, e.g. http://localhost:8080 */
+  baseUrl: string;
+  /** Replaces the global fetch, e.g. to add logging */
+  fetch?: typeof fetch;
+  /** Headers sent with every request */
+  headers?: Record<string, string>;
+`)
+	for _, name := range schemeNames {
+		s := schemes[name]
+		switch {
+		case s.Type == "apiKey":
+			code.WriteString(fmt.Sprintf("  /** API key sent in the %s %s to operations requiring %s */\n", s.Name, s.In, name))
+			code.WriteString(fmt.Sprintf("  %s?: string;\n", tsPropertyName(name)))
+		case s.Type == "http" && s.Scheme == "basic":
+			code.WriteString(fmt.Sprintf("  /** HTTP basic credentials sent to operations requiring %s */\n", name))
+			code.WriteString(fmt.Sprintf("  %s?: { username: string; password: string };\n", tsPropertyName(name)))
+		default:
+			code.WriteString(fmt.Sprintf("  /** Bearer token sent to operations requiring %s */\n", name))
+			code.WriteString(fmt.Sprintf("  %s?: string;\n", tsPropertyName(name)))
+		}
+	}
+	code.WriteString("}\n\n")
+
+	for _, op := range ops {
+		writeTSParams(&code, op, spec)
+	}
+
+	code.WriteString(`/** Thrown for responses without a 2xx status. body holds the decoded JSON
+ * body, or the text when it is not JSON. */
+export class ApiError extends globalThis.Error {
+  readonly operation: string;
+  readonly status: number;
+  readonly headers: Headers;
+  readonly body: unknown;
+
+  constructor(operation: string, status: number, headers: Headers, body: unknown) {
+    super(operation + ": " + status + (typeof body === "string" && body ? ": " + body.trim() : ""));
+    this.name = "ApiError";
+    this.operation = operation;
+    this.status = status;
+    this.headers = headers;
+    this.body = body;
+  }
+}
+
+type Query = Record<string, string | number | boolean | Array<string | number | boolean> | undefined>;
+
+interface Call {
+  operation: string;
+  method: string;
+  path: string;
+  query?: Query;
+  headers?: Record<string, string | number | boolean | undefined>;
+  body?: BodyInit;
+  contentType?: string;
+  accept?: string;
+  security?: string[][];
+  init?: RequestInit;
+}
+
+/** Client calls the API with fetch */
+export class Client {
+  private readonly options: ClientOptions;
+
+  constructor(options: ClientOptions) {
+    this.options = { ...options, baseUrl: options.baseUrl.replace(/\/+$/, "") };
+  }
+
+  private async send(req: Call): Promise<Response> {
+    const url = new URL(this.options.baseUrl + req.path);
+    for (const [name, value] of Object.entries(req.query ?? {})) {
+      for (const v of Array.isArray(value) ? value : value === undefined ? [] : [value]) {
+        url.searchParams.append(name, String(v));
+      }
+    }
+    const headers = new Headers(this.options.headers);
+    for (const [name, value] of Object.entries(req.headers ?? {})) {
+      if (value !== undefined) {
+        headers.set(name, String(value));
+      }
+    }
+    if (req.contentType && !(req.body instanceof FormData)) {
+      headers.set("Content-Type", req.contentType);
+    }
+    if (req.accept) {
+      headers.set("Accept", req.accept);
+    }
+    this.authorize(url, headers, req.security ?? []);
+    new Headers(req.init?.headers).forEach((value, name) => headers.set(name, value));
+    const resp = await (this.options.fetch ?? fetch)(url, { ...req.init, method: req.method, headers, body: req.body });
+    if (!resp.ok) {
+      const text = await resp.text();
+      let body: unknown = text;
+      try {
+        body = JSON.parse(text);
+      } catch {
+        // keep the text
+      }
+      throw new ApiError(req.operation, resp.status, resp.headers, body);
+    }
+    return resp;
+  }
+
+  private async json<T>(req: Call): Promise<T> {
+    const resp = await this.send(req);
+    const text = await resp.text();
+    return (text ? JSON.parse(text) : undefined) as T;
+  }
+
+  /** Sends the credentials of the first alternative all credentials are set for */
+  private authorize(url: URL, headers: Headers, alternatives: string[][]): void {
+    const o = this.options as unknown as Record<string, unknown>;
+    for (const schemes of alternatives) {
+      if (!schemes.every((scheme) => o[scheme] !== undefined)) {
+        continue;
+      }
+      for (const scheme of schemes) {
+        switch (scheme) {
+`)
+	for _, name := range schemeNames {
+		s := schemes[name]
+		code.WriteString(fmt.Sprintf("          case %q:\n", name))
+		access := "this.options." + name
+		if !tsIdentifier.MatchString(name) {
+			access = fmt.Sprintf("this.options[%q]", name)
+		}
+		switch {
+		case s.Type == "apiKey" && s.In == "query":
+			code.WriteString(fmt.Sprintf("            url.searchParams.set(%q, %s!);\n", s.Name, access))
+		case s.Type == "apiKey" && s.In == "cookie":
+			code.WriteString(fmt.Sprintf("            headers.append(\"Cookie\", %q + encodeURIComponent(%s!));\n", s.Name+"=", access))
+		case s.Type == "apiKey":
+			code.WriteString(fmt.Sprintf("            headers.set(%q, %s!);\n", s.Name, access))
+		case s.Type == "http" && s.Scheme == "basic":
+			code.WriteString(fmt.Sprintf("            headers.set(\"Authorization\", \"Basic \" + btoa(%s!.username + \":\" + %s!.password));\n", access, access))
+		default:
+			code.WriteString(fmt.Sprintf("            headers.set(\"Authorization\", \"Bearer \" + %s!);\n", access))
+		}
+		code.WriteString("            break;\n")
+	}
+	code.WriteString("        }\n      }\n      return;\n    }\n  }\n")
+
+	for _, op := range ops {
+		writeTSMethod(&code, op, spec, schemas)
+	}
+	code.WriteString("}\n")
+
+	// Import the types the client refers to
+	names := []string{"BlobRef"}
+	for name := range schemas {
+		names = append(names, toGoIdentifier(name))
+	}
+	sort.Strings(names)
+	var header strings.Builder
+	header.WriteString("// Auto-generated fetch client from OpenAPI spec\n\n")
+	header.WriteString("import type {\n")
+	for _, name := range names {
+		if regexp.MustCompile(`\b` + name + `\b`).MatchString(code.String()) {
+			header.WriteString("  " + name + ",\n")
+		}
+	}
+	header.WriteString("} from \"./types.js\";\n\n")
+	return header.String() + code.String()
+}
+
+// writeTSMethod emits the client method of an operation, preceded by the
+// interface of its query, header and cookie parameters
+func writeTSMethod(code *strings.Builder, op operation, spec *OpenAPISpec, schemas map[string]Schema) {
+	var args, pathParams []string
+	var optional []parameter
+	for _, p := range operationParameters(op, spec.Components) {
+		if p.In != "path" {
+			optional = append(optional, p)
+			continue
+		}
+		name := goParamName(p.Name)
+		pathParams = append(pathParams, p.Name)
+		args = append(args, fmt.Sprintf("%s: %s", name, tsType(p.Schema)))
+	}
+
+	reqBody := requestBodyShape(op)
+	bodyType := ""
+	if reqBody != nil {
+		switch {
+		case reqBody.Raw:
+			args = append(args, "body: BodyInit", "contentType?: string")
+		case op.Method == "PATCH":
+			bodyType = "unknown"
+			args = append(args, "body: unknown")
+		default:
+			media, _ := op.Endpoint["requestBody"].(map[string]interface{})["content"].(map[string]interface{})[reqBody.MediaType].(map[string]interface{})
+			schema, _ := media["schema"].(map[string]interface{})
+			bodyType = tsClientType(schema, reqBody.GoType, schemas)
+			args = append(args, "body: "+bodyType)
+		}
+	}
+	paramsType := op.HandlerName + "Params"
+	if len(optional) > 0 {
+		mark := "?"
+		if anyRequired(optional) {
+			mark = ""
+		}
+		args = append(args, fmt.Sprintf("params%s: %s", mark, paramsType))
+	}
+	args = append(args, "init?: RequestInit")
+
+	resp := responseBodyShape(op)
+	result := "void"
+	if resp != nil {
+		if resp.Raw {
+			result = "Response"
+		} else {
+			success := operationSuccess(op)
+			content, _ := success.Response["content"].(map[string]interface{})
+			media, _ := content[resp.MediaType].(map[string]interface{})
+			schema, _ := media["schema"].(map[string]interface{})
+			result = tsClientType(schema, resp.GoType, schemas)
+		}
+	}
+
+	summary, _ := op.Endpoint["summary"].(string)
+	code.WriteString("\n  /** " + op.Method + " " + op.Path)
+	if summary != "" {
+		code.WriteString(": " + strings.TrimSuffix(summary, "."))
+	}
+	if resp != nil && resp.Raw {
+		code.WriteString(". Resolves to the response for its body to be read")
+	}
+	code.WriteString(" */\n")
+	code.WriteString(fmt.Sprintf("  async %s(%s): Promise<%s> {\n", tsMethodName(op), strings.Join(args, ", "), result))
+
+	path := "`" + op.Path + "`"
+	for _, name := range pathParams {
+		path = strings.ReplaceAll(path, "{"+name+"}", "${encodeURIComponent(String("+goParamName(name)+"))}")
+	}
+	code.WriteString(fmt.Sprintf("    const req: Call = { operation: %q, method: %q, path: %s, init };\n", op.OperationID, op.Method, path))
+	if reqs := operationSecurity(op, spec, extractSecuritySchemes(spec.Components)); len(reqs) > 0 {
+		alternatives := make([]string, 0, len(reqs))
+		for _, req := range reqs {
+			names := make([]string, 0, len(req))
+			for name := range req {
+				names = append(names, fmt.Sprintf("%q", name))
+			}
+			sort.Strings(names)
+			alternatives = append(alternatives, "["+strings.Join(names, ", ")+"]")
+		}
+		code.WriteString(fmt.Sprintf("    req.security = [%s];\n", strings.Join(alternatives, ", ")))
+	}
+	if len(optional) > 0 {
+		var query, headers, cookies []string
+		for _, p := range optional {
+			value := "params?." + p.Name
+			if !tsIdentifier.MatchString(p.Name) {
+				value = fmt.Sprintf("params?.[%q]", p.Name)
+			}
+			switch p.In {
+			case "query":
+				query = append(query, fmt.Sprintf("%q: %s", p.Name, value))
+			case "header":
+				headers = append(headers, fmt.Sprintf("%q: %s", p.Name, value))
+			case "cookie":
+				cookies = append(cookies, fmt.Sprintf("%s === undefined ? \"\" : %q + encodeURIComponent(String(%s))", value, p.Name+"=", value))
+			}
+		}
+		if len(cookies) > 0 {
+			headers = append(headers, fmt.Sprintf("Cookie: [%s].filter(Boolean).join(\"; \") || undefined", strings.Join(cookies, ", ")))
+		}
+		if len(query) > 0 {
+			code.WriteString(fmt.Sprintf("    req.query = { %s };\n", strings.Join(query, ", ")))
+		}
+		if len(headers) > 0 {
+			code.WriteString(fmt.Sprintf("    req.headers = { %s };\n", strings.Join(headers, ", ")))
+		}
+	}
+	if idempotencyEnabled(op, spec.Extensions) {
+		code.WriteString("    req.headers = { ...req.headers, \"Idempotency-Key\": crypto.randomUUID() };\n")
+	}
+	if reqBody != nil {
+		if reqBody.Raw {
+			code.WriteString(fmt.Sprintf("    req.body = body;\n    req.contentType = contentType ?? %q;\n", reqBody.MediaType))
+		} else {
+			code.WriteString(fmt.Sprintf("    req.body = JSON.stringify(body);\n    req.contentType = %q;\n", reqBody.MediaType))
+		}
+	}
+	switch {
+	case resp == nil:
+		code.WriteString("    await (await this.send(req)).body?.cancel();\n")
+	case resp.Raw:
+		code.WriteString(fmt.Sprintf("    req.accept = %q;\n", resp.MediaType))
+		code.WriteString("    return this.send(req);\n")
+	default:
+		code.WriteString(fmt.Sprintf("    req.accept = %q;\n", resp.MediaType))
+		code.WriteString(fmt.Sprintf("    return this.json<%s>(req);\n", result))
+	}
+	code.WriteString("  }\n")
+}
+
+// writeTSParams emits the interface of an operation's query, header and
+// cookie parameters, if it has any
+func writeTSParams(code *strings.Builder, op operation, spec *OpenAPISpec) {
+	var params []parameter
+	for _, p := range operationParameters(op, spec.Components) {
+		if p.In != "path" {
+			params = append(params, p)
+		}
+	}
+	if len(params) == 0 {
+		return
+	}
+	code.WriteString(fmt.Sprintf("/** Query, header and cookie parameters of %s */\n", tsMethodName(op)))
+	code.WriteString(fmt.Sprintf("export interface %sParams {\n", op.HandlerName))
+	for _, p := range params {
+		optional := "?"
+		if p.Required {
+			optional = ""
+		}
+		code.WriteString(fmt.Sprintf("  /** %s parameter */\n", p.In))
+		code.WriteString(fmt.Sprintf("  %s%s: %s;\n", tsPropertyName(p.Name), optional, tsType(p.Schema)))
+	}
+	code.WriteString("}\n\n")
+}
+
+// tsClientType names a body type in the client: inline schemas by the type
+// extracted for them, anything else as tsType renders it
+func tsClientType(schema map[string]interface{}, goType string, schemas map[string]Schema) string {
+	if _, ok := schemas[goType]; ok && schema["$ref"] == nil {
+		return goType
+	}
+	return tsType(schema)
+}
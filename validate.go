@@ -0,0 +1,705 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// diagnostic is a problem a rule found in a spec, located by the JSON pointer
+// of the offending value and, when known, its line and column in the file
+type diagnostic struct {
+	Severity string `json:"severity"` // "error" or "warning"
+	Rule     string `json:"rule"`
+	Pointer  string `json:"pointer"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	Message  string `json:"message"`
+}
+
+// format renders the diagnostic like a compiler message for the spec file
+func (d diagnostic) format(file string) string {
+	position := file
+	if d.Line > 0 {
+		position = fmt.Sprintf("%s:%d:%d", file, d.Line, d.Column)
+	}
+	return fmt.Sprintf("%s: %s: #%s: %s [%s]", position, d.Severity, d.Pointer, d.Message, d.Rule)
+}
+
+// countSeverity counts the diagnostics of a severity
+func countSeverity(diagnostics []diagnostic, severity string) int {
+	n := 0
+	for _, d := range diagnostics {
+		if d.Severity == severity {
+			n++
+		}
+	}
+	return n
+}
+
+// summarizeDiagnostics describes how many errors and warnings were found
+func summarizeDiagnostics(diagnostics []diagnostic) string {
+	errs, warnings := countSeverity(diagnostics, "error"), countSeverity(diagnostics, "warning")
+	if errs == 0 && warnings == 0 {
+		return "no problems found"
+	}
+	return fmt.Sprintf("%d %s, %d %s", errs, plural(errs, "error"), warnings, plural(warnings, "warning"))
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return word
+	}
+	return word + "s"
+}
+
+// invalidSpecError is returned for a spec with errors; Diagnostics holds its
+// warnings too
+type invalidSpecError struct {
+	File        string
+	Diagnostics []diagnostic
+}
+
+func (e *invalidSpecError) Error() string {
+	var b strings.Builder
+	n := countSeverity(e.Diagnostics, "error")
+	fmt.Fprintf(&b, "%s has %d %s", e.File, n, plural(n, "error"))
+	for _, d := range e.Diagnostics {
+		if d.Severity == "error" {
+			b.WriteString("\n  " + d.format(e.File))
+		}
+	}
+	return b.String()
+}
+
+// specRule is a check of specs, with its default severity and, for naming
+// rules, naming convention
+type specRule struct {
+	Name     string
+	Severity string
+	Case     string
+	Check    func(c *specCheck)
+}
+
+// specRules lists the rules in the order they run. The structural and
+// semantic rules come first and are errors, the lint rules after them can
+// be configured with a ruleset file.
+var specRules = []specRule{
+	{Name: "meta-schema", Severity: "error", Check: checkMetaSchema},
+	{Name: "unresolved-ref", Severity: "error", Check: checkRefs},
+	{Name: "duplicate-operation-id", Severity: "error", Check: checkOperationIDs},
+	{Name: "security-schemes", Severity: "error", Check: checkSecuritySchemes},
+	{Name: "path-parameters", Severity: "error", Check: checkPathParameters},
+	{Name: "operation-responses", Severity: "error", Check: checkResponses},
+	{Name: "generator-support", Severity: "error", Check: checkGeneratorSupport},
+	{Name: "operation-id-casing", Severity: "warning", Case: "camelCase", Check: lintOperationIDCasing},
+	{Name: "schema-casing", Severity: "warning", Case: "PascalCase", Check: lintSchemaCasing},
+	{Name: "property-casing", Severity: "warning", Case: "camelCase", Check: lintPropertyCasing},
+	{Name: "path-casing", Severity: "warning", Case: "kebab-case", Check: lintPathCasing},
+	{Name: "operation-description", Severity: "warning", Check: lintOperationDescription},
+	{Name: "parameter-description", Severity: "off", Check: lintParameterDescription},
+	{Name: "schema-description", Severity: "off", Check: lintSchemaDescription},
+	{Name: "no-inline-schemas", Severity: "off", Check: lintInlineSchemas},
+}
+
+// defaultRulesFile is the ruleset used when none is given, if it exists
+const defaultRulesFile = ".oapi-lint.yaml"
+
+// ruleSetting is the configured severity and naming convention of a rule.
+// In a ruleset file a rule is set to a severity or to a mapping of both.
+type ruleSetting struct {
+	Severity string `yaml:"severity"`
+	Case     string `yaml:"case"`
+}
+
+func (s *ruleSetting) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		return value.Decode(&s.Severity)
+	}
+	type plain ruleSetting
+	return value.Decode((*plain)(s))
+}
+
+// ruleset maps every rule to its setting
+type ruleset map[string]ruleSetting
+
+// defaultRuleset returns the rules with their default settings
+func defaultRuleset() ruleset {
+	rules := ruleset{}
+	for _, r := range specRules {
+		rules[r.Name] = ruleSetting{Severity: r.Severity, Case: r.Case}
+	}
+	return rules
+}
+
+// loadRuleset reads a YAML or JSON ruleset file over the defaults. Without a
+// path, defaultRulesFile is read when it exists.
+func loadRuleset(path string) (ruleset, error) {
+	rules := defaultRuleset()
+	if path == "" {
+		if _, err := os.Stat(defaultRulesFile); err != nil {
+			return rules, nil
+		}
+		path = defaultRulesFile
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ruleset: %v", err)
+	}
+	var file struct {
+		Rules map[string]ruleSetting `yaml:"rules"`
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&file); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	for name, setting := range file.Rules {
+		current, ok := rules[name]
+		if !ok {
+			return nil, fmt.Errorf("%s: unknown rule %q", path, name)
+		}
+		switch setting.Severity {
+		case "error", "warning", "off":
+			current.Severity = setting.Severity
+		case "warn":
+			current.Severity = "warning"
+		case "":
+		default:
+			return nil, fmt.Errorf("%s: rule %s: severity %q is not error, warning or off", path, name, setting.Severity)
+		}
+		if setting.Case != "" {
+			if current.Case == "" {
+				return nil, fmt.Errorf("%s: rule %s has no naming convention", path, name)
+			}
+			if namingConventions[setting.Case] == nil {
+				return nil, fmt.Errorf("%s: rule %s: unknown naming convention %q", path, name, setting.Case)
+			}
+			current.Case = setting.Case
+		}
+		rules[name] = current
+	}
+	return rules, nil
+}
+
+// specCheck holds a document while the rules run and collects what they
+// report
+type specCheck struct {
+	doc         map[string]interface{}
+	spec        *OpenAPISpec // the document, for resolveRef
+	version     string
+	rule        string
+	setting     ruleSetting
+	diagnostics []diagnostic
+}
+
+// report adds a diagnostic of the running rule
+func (c *specCheck) report(pointer, format string, args ...interface{}) {
+	c.diagnostics = append(c.diagnostics, diagnostic{
+		Severity: c.setting.Severity,
+		Rule:     c.rule,
+		Pointer:  pointer,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// validateSpec runs the rules that are not off against a decoded document
+func validateSpec(doc map[string]interface{}, rules ruleset) []diagnostic {
+	c := &specCheck{doc: doc, spec: &OpenAPISpec{Raw: doc}, version: specVersion(doc)}
+	for _, rule := range specRules {
+		setting, ok := rules[rule.Name]
+		if !ok {
+			setting = ruleSetting{Severity: rule.Severity, Case: rule.Case}
+		}
+		if setting.Severity == "off" {
+			continue
+		}
+		c.rule, c.setting = rule.Name, setting
+		rule.Check(c)
+	}
+	return c.diagnostics
+}
+
+// loadSpec reads and validates a spec file. The spec is returned when the
+// rules found no errors; the diagnostics are ordered by their position.
+func loadSpec(filePath string, rules ruleset) (*OpenAPISpec, []diagnostic, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read file: %v", err)
+	}
+	source, isYAML := data, false
+	if ext := strings.ToLower(filepath.Ext(filePath)); ext == ".yaml" || ext == ".yml" {
+		isYAML = true
+		if data, err = yamlToJSON(data); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse YAML: %v", err)
+		}
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		if syntax, ok := err.(*json.SyntaxError); ok {
+			line, column := lineColumn(data, int(syntax.Offset))
+			return nil, nil, fmt.Errorf("failed to parse JSON: line %d, column %d: %v", line, column, err)
+		}
+		return nil, nil, fmt.Errorf("failed to parse JSON: %v", err)
+	}
+	raw, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, nil, fmt.Errorf("failed to parse JSON: the document is not an object")
+	}
+
+	diagnostics := validateSpec(raw, rules)
+	if len(diagnostics) > 0 {
+		var positions map[string][2]int
+		if isYAML {
+			positions = yamlPositions(source)
+		} else {
+			positions = jsonPositions(source)
+		}
+		for i, d := range diagnostics {
+			pos := locate(positions, d.Pointer)
+			diagnostics[i].Line, diagnostics[i].Column = pos[0], pos[1]
+		}
+		sort.SliceStable(diagnostics, func(i, j int) bool {
+			a, b := diagnostics[i], diagnostics[j]
+			if a.Line != b.Line {
+				return a.Line < b.Line
+			}
+			if a.Column != b.Column {
+				return a.Column < b.Column
+			}
+			return a.Pointer < b.Pointer
+		})
+	}
+	if countSeverity(diagnostics, "error") > 0 {
+		return nil, diagnostics, nil
+	}
+	spec, err := specFromJSON(data, raw)
+	if err != nil {
+		return nil, diagnostics, err
+	}
+	return spec, diagnostics, nil
+}
+
+// pointerTo appends a reference token to a JSON pointer
+func pointerTo(pointer string, token string) string {
+	return pointer + "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
+
+// lookupPointer finds the value a JSON pointer, percent-encoded or not,
+// selects in a decoded document
+func lookupPointer(doc interface{}, pointer string) (interface{}, bool) {
+	if pointer == "" {
+		return doc, true
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, false
+	}
+	v := doc
+	for _, token := range strings.Split(pointer[1:], "/") {
+		if unescaped, err := url.PathUnescape(token); err == nil {
+			token = unescaped
+		}
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		switch parent := v.(type) {
+		case map[string]interface{}:
+			child, ok := parent[token]
+			if !ok {
+				return nil, false
+			}
+			v = child
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(parent) {
+				return nil, false
+			}
+			v = parent[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// locate returns the line and column of a pointer, or of its closest parent
+// when the pointer names a missing value
+func locate(positions map[string][2]int, pointer string) [2]int {
+	for {
+		if pos, ok := positions[pointer]; ok {
+			return pos
+		}
+		i := strings.LastIndex(pointer, "/")
+		if i < 0 {
+			return [2]int{}
+		}
+		pointer = pointer[:i]
+	}
+}
+
+// jsonPositions maps the pointer of every value of a JSON document to its
+// line and column: the key for object members, the value for array items
+func jsonPositions(data []byte) map[string][2]int {
+	positions := map[string][2]int{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	// next skips to the start of the next token after the decoder's offset
+	next := func() int {
+		i := int(dec.InputOffset())
+		for i < len(data) && strings.IndexByte(" \t\r\n,:", data[i]) >= 0 {
+			i++
+		}
+		return i
+	}
+	var value func(pointer string) bool
+	value = func(pointer string) bool {
+		tok, err := dec.Token()
+		if err != nil {
+			return false
+		}
+		switch tok {
+		case json.Delim('{'):
+			for dec.More() {
+				start := next()
+				key, err := dec.Token()
+				if err != nil {
+					return false
+				}
+				child := pointerTo(pointer, fmt.Sprint(key))
+				line, column := lineColumn(data, start)
+				positions[child] = [2]int{line, column}
+				if !value(child) {
+					return false
+				}
+			}
+			_, err = dec.Token()
+		case json.Delim('['):
+			for i := 0; dec.More(); i++ {
+				child := fmt.Sprintf("%s/%d", pointer, i)
+				line, column := lineColumn(data, next())
+				positions[child] = [2]int{line, column}
+				if !value(child) {
+					return false
+				}
+			}
+			_, err = dec.Token()
+		}
+		return err == nil
+	}
+	line, column := lineColumn(data, next())
+	positions[""] = [2]int{line, column}
+	value("")
+	return positions
+}
+
+// lineColumn converts a byte offset to a line and a column in characters,
+// both counted from 1
+func lineColumn(data []byte, offset int) (int, int) {
+	if offset > len(data) {
+		offset = len(data)
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	lineStart := bytes.LastIndexByte(before, '\n') + 1
+	return line, utf8.RuneCount(before[lineStart:]) + 1
+}
+
+// yamlPositions maps the pointer of every value of a YAML document to its
+// line and column: the key for mapping entries, the value for sequence items
+func yamlPositions(data []byte) map[string][2]int {
+	positions := map[string][2]int{}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil || len(root.Content) == 0 {
+		return positions
+	}
+	var walk func(n *yaml.Node, pointer string, depth int)
+	walk = func(n *yaml.Node, pointer string, depth int) {
+		if depth > 64 {
+			return
+		}
+		if n.Kind == yaml.AliasNode && n.Alias != nil {
+			n = n.Alias
+		}
+		switch n.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				key := n.Content[i]
+				child := pointerTo(pointer, key.Value)
+				positions[child] = [2]int{key.Line, key.Column}
+				walk(n.Content[i+1], child, depth+1)
+			}
+		case yaml.SequenceNode:
+			for i, item := range n.Content {
+				child := fmt.Sprintf("%s/%d", pointer, i)
+				positions[child] = [2]int{item.Line, item.Column}
+				walk(item, child, depth+1)
+			}
+		}
+	}
+	doc := root.Content[0]
+	positions[""] = [2]int{doc.Line, doc.Column}
+	walk(doc, "", 0)
+	return positions
+}
+
+// specMethods lists the path item keys that are operations
+var specMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// specOperation is an operation of a decoded document
+type specOperation struct {
+	Path    string
+	Method  string
+	Pointer string
+	Item    map[string]interface{}
+	Op      map[string]interface{}
+}
+
+// operations lists the operations of the document's paths in order
+func (c *specCheck) operations() []specOperation {
+	paths, _ := c.doc["paths"].(map[string]interface{})
+	var ops []specOperation
+	for _, path := range sortedKeys(paths) {
+		if !strings.HasPrefix(path, "/") {
+			continue
+		}
+		item, _ := resolveRef(c.spec, paths[path]).(map[string]interface{})
+		for _, method := range specMethods {
+			if op, ok := item[method].(map[string]interface{}); ok {
+				ops = append(ops, specOperation{
+					Path:    path,
+					Method:  strings.ToUpper(method),
+					Pointer: pointerTo(pointerTo("/paths", path), method),
+					Item:    item,
+					Op:      op,
+				})
+			}
+		}
+	}
+	return ops
+}
+
+// checkRefs reports references that do not resolve within the document.
+// Example values, defaults, enums and extensions are data and not searched.
+func checkRefs(c *specCheck) {
+	var walk func(v interface{}, pointer, key string)
+	walk = func(v interface{}, pointer, key string) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if ref, ok := v["$ref"].(string); ok {
+				c.checkRef(pointerTo(pointer, "$ref"), ref)
+			}
+			for _, k := range sortedKeys(v) {
+				data := k == "example" || k == "default" || k == "enum" || k == "const" || strings.HasPrefix(k, "x-")
+				if data && key != "properties" {
+					continue
+				}
+				if k == "examples" && key != "properties" {
+					// Example Objects, which may be references, or a list of
+					// values in 3.1 schemas
+					examples, _ := v[k].(map[string]interface{})
+					for _, name := range sortedKeys(examples) {
+						if example, ok := examples[name].(map[string]interface{}); ok {
+							if ref, ok := example["$ref"].(string); ok {
+								c.checkRef(pointerTo(pointerTo(pointerTo(pointer, k), name), "$ref"), ref)
+							}
+						}
+					}
+					continue
+				}
+				walk(v[k], pointerTo(pointer, k), k)
+			}
+		case []interface{}:
+			for i, item := range v {
+				walk(item, fmt.Sprintf("%s/%d", pointer, i), "")
+			}
+		}
+	}
+	walk(c.doc, "", "")
+}
+
+// checkRef reports a reference that is not a JSON pointer into the document
+// or does not resolve
+func (c *specCheck) checkRef(pointer, ref string) {
+	switch {
+	case !strings.HasPrefix(ref, "#"):
+		c.report(pointer, "external reference %s is not supported, only references within the document are resolved", ref)
+	case ref != "#" && !strings.HasPrefix(ref, "#/"):
+		c.report(pointer, "reference %s is not a JSON pointer, anchors are not supported", ref)
+	default:
+		if _, ok := lookupPointer(c.doc, strings.TrimPrefix(ref, "#")); !ok {
+			c.report(pointer, "reference %s does not resolve", ref)
+		}
+	}
+}
+
+// checkOperationIDs reports operationIds used by more than one operation
+func checkOperationIDs(c *specCheck) {
+	seen := map[string]string{}
+	for _, op := range c.operations() {
+		id, ok := op.Op["operationId"].(string)
+		if !ok {
+			continue
+		}
+		if first, dup := seen[id]; dup {
+			c.report(pointerTo(op.Pointer, "operationId"), "operationId %q is also used by %s", id, first)
+			continue
+		}
+		seen[id] = op.Method + " " + op.Path
+	}
+}
+
+// checkSecuritySchemes reports security requirements, of the document or of
+// an operation, naming a scheme components/securitySchemes does not declare
+func checkSecuritySchemes(c *specCheck) {
+	components, _ := c.doc["components"].(map[string]interface{})
+	schemes, _ := components["securitySchemes"].(map[string]interface{})
+	check := func(pointer string, security interface{}) {
+		reqs, _ := security.([]interface{})
+		for i, r := range reqs {
+			req, _ := r.(map[string]interface{})
+			for _, name := range sortedKeys(req) {
+				if _, ok := schemes[name]; !ok {
+					c.report(pointerTo(fmt.Sprintf("%s/%d", pointer, i), name), "security scheme %q is not declared in components/securitySchemes", name)
+				}
+			}
+		}
+	}
+	check("/security", c.doc["security"])
+	for _, op := range c.operations() {
+		check(pointerTo(op.Pointer, "security"), op.Op["security"])
+	}
+}
+
+// pathTemplate matches the parameters of a path template
+var pathTemplate = regexp.MustCompile(`\{([^{}]+)\}`)
+
+// checkPathParameters reports path template parameters an operation does
+// not declare, and path parameters that are not in the template or not
+// required
+func checkPathParameters(c *specCheck) {
+	repeated := map[string]bool{}
+	for _, op := range c.operations() {
+		inTemplate := map[string]bool{}
+		for _, m := range pathTemplate.FindAllStringSubmatch(op.Path, -1) {
+			if inTemplate[m[1]] && !repeated[op.Path] {
+				repeated[op.Path] = true
+				c.report(pointerTo("/paths", op.Path), "path parameter %q appears more than once in %s", m[1], op.Path)
+			}
+			inTemplate[m[1]] = true
+		}
+
+		// Operation parameters override the path item's of the same name
+		type declared struct {
+			pointer string
+			param   map[string]interface{}
+		}
+		params := map[string]declared{}
+		var names []string
+		for _, level := range []struct {
+			pointer string
+			list    interface{}
+		}{
+			{pointerTo(pointerTo("/paths", op.Path), "parameters"), op.Item["parameters"]},
+			{pointerTo(op.Pointer, "parameters"), op.Op["parameters"]},
+		} {
+			list, _ := level.list.([]interface{})
+			for i, p := range list {
+				param, ok := resolveRef(c.spec, p).(map[string]interface{})
+				if !ok || param["in"] != "path" {
+					continue
+				}
+				name, _ := param["name"].(string)
+				if _, ok := params[name]; !ok {
+					names = append(names, name)
+				}
+				params[name] = declared{fmt.Sprintf("%s/%d", level.pointer, i), param}
+			}
+		}
+
+		for _, m := range pathTemplate.FindAllStringSubmatch(op.Path, -1) {
+			if _, ok := params[m[1]]; !ok {
+				c.report(op.Pointer, "path parameter %q of %s is not declared", m[1], op.Path)
+				params[m[1]] = declared{} // once per operation
+			}
+		}
+		for _, name := range names {
+			d := params[name]
+			if !inTemplate[name] {
+				c.report(d.pointer, "path parameter %q is not in the path %s", name, op.Path)
+			} else if required, _ := d.param["required"].(bool); !required {
+				c.report(d.pointer, "path parameter %q must be required", name)
+			}
+		}
+	}
+}
+
+// checkResponses reports operations that declare no response
+func checkResponses(c *specCheck) {
+	for _, op := range c.operations() {
+		responses, ok := op.Op["responses"].(map[string]interface{})
+		if !ok {
+			if _, present := op.Op["responses"]; !present {
+				c.report(op.Pointer, "operation declares no responses")
+			}
+			continue
+		}
+		codes := 0
+		for code := range responses {
+			if !strings.HasPrefix(code, "x-") {
+				codes++
+			}
+		}
+		if codes == 0 {
+			c.report(pointerTo(op.Pointer, "responses"), "operation declares no responses")
+		}
+	}
+}
+
+// checkGeneratorSupport reports what the meta-schema allows but the code
+// generator cannot handle: schemas it cannot read and x-rate-limit
+// extensions it cannot parse
+func checkGeneratorSupport(c *specCheck) {
+	spec := &OpenAPISpec{Raw: c.doc}
+	spec.Paths, _ = c.doc["paths"].(map[string]interface{})
+	spec.Components, _ = c.doc["components"].(map[string]interface{})
+	var se *schemaError
+	if _, err := collectSchemas(spec); errors.As(err, &se) {
+		c.report(se.Pointer, "schema cannot be generated: %v", se.Err)
+	} else if err != nil {
+		c.report("/components/schemas", "schemas cannot be generated: %v", err)
+	}
+
+	if raw, ok := c.doc["x-rate-limit"]; ok {
+		if _, err := parseRateLimit(raw); err != nil {
+			c.report("/x-rate-limit", "x-rate-limit: %v", err)
+		}
+	}
+	checkLimit := func(pointer string, raw interface{}, present bool) {
+		if exempt, ok := raw.(bool); !present || (ok && !exempt) {
+			return
+		}
+		if _, err := parseRateLimit(raw); err != nil {
+			c.report(pointer, "x-rate-limit: %v", err)
+		}
+	}
+	checkedItems := map[string]bool{}
+	for _, op := range c.operations() {
+		raw, present := op.Op["x-rate-limit"]
+		checkLimit(pointerTo(op.Pointer, "x-rate-limit"), raw, present)
+		if !checkedItems[op.Path] {
+			checkedItems[op.Path] = true
+			raw, present := op.Item["x-rate-limit"]
+			checkLimit(pointerTo(pointerTo("/paths", op.Path), "x-rate-limit"), raw, present)
+		}
+	}
+}
@@ -0,0 +1,392 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// validateDoc runs the rules against a JSON document
+func validateDoc(t *testing.T, src string, rules ruleset) []diagnostic {
+	t.Helper()
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(src), &doc); err != nil {
+		t.Fatal(err)
+	}
+	return validateSpec(doc, rules)
+}
+
+// findDiagnostic returns the diagnostic of a rule at a pointer
+func findDiagnostic(diagnostics []diagnostic, rule, pointer string) (diagnostic, bool) {
+	for _, d := range diagnostics {
+		if d.Rule == rule && d.Pointer == pointer {
+			return d, true
+		}
+	}
+	return diagnostic{}, false
+}
+
+// allRules returns the default ruleset with every rule that is off turned
+// into a warning
+func allRules() ruleset {
+	rules := defaultRuleset()
+	for name, setting := range rules {
+		if setting.Severity == "off" {
+			setting.Severity = "warning"
+			rules[name] = setting
+		}
+	}
+	return rules
+}
+
+func writeTemp(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const invalidSpecJSON = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "Broken"
+  },
+  "paths": {
+    "/pets": {
+      "get": {
+        "operationId": "listPets",
+        "parameters": [
+          {"name": "limit", "in": "body", "schema": {"type": "integer"}}
+        ],
+        "responses": {"200": {"description": "ok"}}
+      }
+    }
+  }
+}
+`
+
+const invalidSpecYAML = `openapi: 3.0.3
+info:
+  title: Broken
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      parameters:
+        - name: limit
+          in: body
+          schema:
+            type: integer
+      responses:
+        "200":
+          description: ok
+`
+
+func TestLoadSpecLocatesMetaSchemaErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		info    [2]int
+		in      [2]int
+	}{
+		{"json", "spec.json", invalidSpecJSON, [2]int{3, 3}, [2]int{11, 29}},
+		{"yaml", "spec.yaml", invalidSpecYAML, [2]int{2, 1}, [2]int{10, 11}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, diagnostics, err := loadSpec(writeTemp(t, tt.file, tt.content), defaultRuleset())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if spec != nil {
+				t.Error("a spec with errors should not be returned")
+			}
+			info, ok := findDiagnostic(diagnostics, "meta-schema", "/info")
+			if !ok || info.Message != `"version" is required` || [2]int{info.Line, info.Column} != tt.info {
+				t.Errorf("info diagnostic = %+v, want at %v", info, tt.info)
+			}
+			in, ok := findDiagnostic(diagnostics, "meta-schema", "/paths/~1pets/get/parameters/0/in")
+			if !ok || !strings.HasPrefix(in.Message, "must be one of") || [2]int{in.Line, in.Column} != tt.in {
+				t.Errorf("in diagnostic = %+v, want at %v", in, tt.in)
+			}
+		})
+	}
+}
+
+func TestLoadSpecSyntaxError(t *testing.T) {
+	_, _, err := loadSpec(writeTemp(t, "spec.json", "{\n  \"openapi\": \"3.0.3\",\n  \"info\" {}\n}\n"), defaultRuleset())
+	if err == nil || !strings.Contains(err.Error(), "line 3, column 11") {
+		t.Errorf("err = %v, want the line and column of the syntax error", err)
+	}
+}
+
+func TestReadOpenAPISpecRejectsInvalidSpec(t *testing.T) {
+	path := writeTemp(t, "spec.json", invalidSpecJSON)
+	_, err := readOpenAPISpec(path)
+	var invalid *invalidSpecError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("err = %v, want an invalidSpecError", err)
+	}
+	if countSeverity(invalid.Diagnostics, "error") != 2 {
+		t.Errorf("diagnostics = %+v, want 2 errors", invalid.Diagnostics)
+	}
+	if !strings.Contains(err.Error(), path+":3:3: error: #/info") {
+		t.Errorf("error does not locate the problem:\n%v", err)
+	}
+}
+
+func TestMockServesYAMLSpec(t *testing.T) {
+	spec, err := readOpenAPISpec(writeTemp(t, "api.yaml", `openapi: 3.0.3
+info: {title: Pets, version: 1.0.0}
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      responses:
+        "200":
+          description: The pets
+          content:
+            application/json:
+              example: [{name: Rex}]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler, err := newMockServer(spec, false, func(string) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/pets", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"name": "Rex"`) {
+		t.Errorf("GET /pets = %d %s, want the example", rec.Code, rec.Body.String())
+	}
+}
+
+func TestSemanticRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		paths   string
+		rule    string
+		pointer string
+		message string
+	}{
+		{
+			name:    "unresolved ref",
+			paths:   `{"/pets": {"get": {"operationId": "listPets", "responses": {"200": {"$ref": "#/components/responses/Missing"}}}}}`,
+			rule:    "unresolved-ref",
+			pointer: "/paths/~1pets/get/responses/200/$ref",
+		},
+		{
+			name: "duplicate operationId",
+			paths: `{"/pets": {"get": {"operationId": "listPets", "responses": {"200": {"description": "ok"}}}},
+			         "/cats": {"get": {"operationId": "listPets", "responses": {"200": {"description": "ok"}}}}}`,
+			rule:    "duplicate-operation-id",
+			pointer: "/paths/~1pets/get/operationId",
+			message: `operationId "listPets" is also used by GET /cats`,
+		},
+		{
+			name:    "undeclared path parameter",
+			paths:   `{"/pets/{petId}": {"get": {"operationId": "getPet", "responses": {"200": {"description": "ok"}}}}}`,
+			rule:    "path-parameters",
+			pointer: "/paths/~1pets~1{petId}/get",
+		},
+		{
+			name: "path parameter not in the path",
+			paths: `{"/pets": {"get": {"operationId": "getPet", "responses": {"200": {"description": "ok"}},
+			         "parameters": [{"name": "petId", "in": "path", "required": true, "schema": {"type": "string"}}]}}}`,
+			rule:    "path-parameters",
+			pointer: "/paths/~1pets/get/parameters/0",
+		},
+		{
+			name: "undeclared security scheme",
+			paths: `{"/pets": {"get": {"operationId": "listPets", "security": [{"api_key": []}],
+			         "responses": {"200": {"description": "ok"}}}}}`,
+			rule:    "security-schemes",
+			pointer: "/paths/~1pets/get/security/0/api_key",
+			message: `security scheme "api_key" is not declared in components/securitySchemes`,
+		},
+		{
+			name:    "no responses",
+			paths:   `{"/pets": {"get": {"operationId": "listPets"}}}`,
+			rule:    "operation-responses",
+			pointer: "/paths/~1pets/get",
+			message: "operation declares no responses",
+		},
+		{
+			name: "schema the generator cannot read",
+			paths: `{"/pets": {"get": {"operationId": "listPets", "responses": {"200": {"description": "ok",
+			         "content": {"application/json": {"schema": {"type": "object", "required": "name"}}}}}}}}`,
+			rule:    "generator-support",
+			pointer: "/paths/~1pets/get/responses/200/content/application~1json/schema",
+		},
+		{
+			name: "invalid rate limit",
+			paths: `{"/pets": {"get": {"operationId": "listPets", "x-rate-limit": {"requests": 0},
+			         "responses": {"200": {"description": "ok"}}}}}`,
+			rule:    "generator-support",
+			pointer: "/paths/~1pets/get/x-rate-limit",
+			message: "x-rate-limit: requests must be a positive integer",
+		},
+		{
+			name:    "operationId casing",
+			paths:   `{"/pets": {"get": {"operationId": "list_pets", "responses": {"200": {"description": "ok"}}}}}`,
+			rule:    "operation-id-casing",
+			pointer: "/paths/~1pets/get/operationId",
+			message: `operationId "list_pets" is not camelCase`,
+		},
+		{
+			name:    "path casing",
+			paths:   `{"/petOwners": {"get": {"operationId": "listOwners", "responses": {"200": {"description": "ok"}}}}}`,
+			rule:    "path-casing",
+			pointer: "/paths/~1petOwners",
+		},
+		{
+			name:    "operation description",
+			paths:   `{"/pets": {"get": {"operationId": "listPets", "responses": {"200": {"description": "ok"}}}}}`,
+			rule:    "operation-description",
+			pointer: "/paths/~1pets/get",
+		},
+		{
+			name: "inline schema",
+			paths: `{"/pets": {"get": {"operationId": "listPets", "summary": "List pets", "responses": {"200": {"description": "ok",
+			         "content": {"application/json": {"schema": {"type": "object", "properties": {"name": {"type": "string"}}}}}}}}}}`,
+			rule:    "no-inline-schemas",
+			pointer: "/paths/~1pets/get/responses/200/content/application~1json/schema",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := `{"openapi": "3.0.3", "info": {"title": "Pets", "version": "1"}, "paths": ` + tt.paths + `}`
+			diagnostics := validateDoc(t, src, allRules())
+			d, ok := findDiagnostic(diagnostics, tt.rule, tt.pointer)
+			if !ok {
+				t.Fatalf("no %s diagnostic at %s in %+v", tt.rule, tt.pointer, diagnostics)
+			}
+			if tt.message != "" && d.Message != tt.message {
+				t.Errorf("message = %q, want %q", d.Message, tt.message)
+			}
+		})
+	}
+}
+
+func TestSchemaRules(t *testing.T) {
+	src := `{"openapi": "3.0.3", "info": {"title": "Pets", "version": "1"}, "paths": {},
+	  "components": {"schemas": {"pet_owner": {"type": "object", "properties": {"first_name": {"type": "string"}}}}}}`
+	diagnostics := validateDoc(t, src, allRules())
+	for _, want := range [][2]string{
+		{"schema-casing", "/components/schemas/pet_owner"},
+		{"schema-description", "/components/schemas/pet_owner"},
+		{"property-casing", "/components/schemas/pet_owner/properties/first_name"},
+	} {
+		if _, ok := findDiagnostic(diagnostics, want[0], want[1]); !ok {
+			t.Errorf("no %s diagnostic at %s in %+v", want[0], want[1], diagnostics)
+		}
+	}
+
+	// The default ruleset leaves the description rules off
+	for _, d := range validateDoc(t, src, defaultRuleset()) {
+		if d.Rule == "schema-description" {
+			t.Errorf("schema-description should be off by default: %+v", d)
+		}
+	}
+}
+
+func TestSpecVersions(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		errors int
+	}{
+		{"3.1 boolean schema and webhooks", `{"openapi": "3.1.0", "info": {"title": "Hooks", "version": "1", "summary": "Webhooks only"},
+		  "webhooks": {"newPet": {"post": {"responses": {"200": {"description": "ok"}}}}},
+		  "components": {"schemas": {"Anything": true, "Nothing": false, "List": {"type": ["array", "null"], "items": true}}}}`, 0},
+		{"3.0 without paths", `{"openapi": "3.0.3", "info": {"title": "Pets", "version": "1"}}`, 1},
+		{"3.0 info summary", `{"openapi": "3.0.3", "info": {"title": "Pets", "version": "1", "summary": "Pets"}, "paths": {}}`, 1},
+		{"swagger", `{"swagger": "2.0", "info": {"title": "Pets", "version": "1"}, "paths": {}}`, 1},
+		{"unsupported version", `{"openapi": "4.0.0", "info": {"title": "Pets", "version": "1"}, "paths": {}}`, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diagnostics := validateDoc(t, tt.src, defaultRuleset())
+			if got := countSeverity(diagnostics, "error"); got != tt.errors {
+				t.Errorf("got %d errors, want %d: %+v", got, tt.errors, diagnostics)
+			}
+		})
+	}
+}
+
+func TestLoadRuleset(t *testing.T) {
+	path := writeTemp(t, "rules.yaml", `rules:
+  operation-description: off
+  schema-description: warn
+  property-casing:
+    severity: error
+    case: snake_case
+`)
+	rules, err := loadRuleset(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rules["operation-description"].Severity != "off" || rules["schema-description"].Severity != "warning" {
+		t.Errorf("rules = %+v", rules)
+	}
+	if got := rules["property-casing"]; got != (ruleSetting{Severity: "error", Case: "snake_case"}) {
+		t.Errorf("property-casing = %+v", got)
+	}
+	if got := rules["schema-casing"]; got != (ruleSetting{Severity: "warning", Case: "PascalCase"}) {
+		t.Errorf("schema-casing should keep its default, got %+v", got)
+	}
+}
+
+func TestLoadRulesetErrors(t *testing.T) {
+	tests := map[string]string{
+		"unknown rule":       "rules:\n  no-such-rule: error\n",
+		"bad severity":       "rules:\n  operation-description: fatal\n",
+		"case without names": "rules:\n  operation-description:\n    case: camelCase\n",
+		"unknown case":       "rules:\n  path-casing:\n    case: Train-Case\n",
+		"unknown field":      "rule:\n  path-casing: off\n",
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := loadRuleset(writeTemp(t, "rules.yaml", content)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+	if _, err := loadRuleset(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("a missing ruleset file should fail")
+	}
+}
+
+func TestSampleSpecIsClean(t *testing.T) {
+	if diagnostics := validateDoc(t, generateSampleOpenAPIJSON(), allRules()); len(diagnostics) != 0 {
+		t.Errorf("sample spec has problems with every rule enabled: %+v", diagnostics)
+	}
+}
+
+func TestCorpusSpecsHaveNoErrors(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "specs", "*.json"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no specs found: %v", err)
+	}
+	for _, file := range files {
+		_, diagnostics, err := loadSpec(file, defaultRuleset())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, d := range diagnostics {
+			if d.Severity == "error" {
+				t.Errorf("%s", d.format(file))
+			}
+		}
+	}
+}
@@ -0,0 +1,426 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// verifier checks a running server against a spec: every operation is sent
+// a valid request and its response compared with what the spec declares
+type verifier struct {
+	spec    *OpenAPISpec
+	ops     []operation
+	schemas map[string]Schema
+	baseURL string
+	header  http.Header // sent with every request, e.g. credentials
+	query   url.Values  // added to every request's query
+	client  *http.Client
+}
+
+// verifyResult is the outcome of one operation
+type verifyResult struct {
+	OperationID string        `json:"operationId"`
+	Method      string        `json:"method"`
+	Path        string        `json:"path"`
+	Tag         string        `json:"tag,omitempty"`
+	URL         string        `json:"url,omitempty"`
+	Status      int           `json:"status,omitempty"`
+	Setup       []string      `json:"setup,omitempty"`    // requests creating the resources the path refers to
+	Problems    []string      `json:"problems,omitempty"` // ways the response breaks the contract
+	Error       string        `json:"error,omitempty"`    // the operation could not be checked
+	Duration    time.Duration `json:"-"`
+	DurationMS  float64       `json:"durationMs"`
+}
+
+// Passed reports whether the operation honours the spec
+func (r verifyResult) Passed() bool {
+	return r.Error == "" && len(r.Problems) == 0
+}
+
+// verifyReport holds the results of a run
+type verifyReport struct {
+	Spec     string         `json:"spec"`
+	Title    string         `json:"title"`
+	BaseURL  string         `json:"baseUrl"`
+	Started  time.Time      `json:"started"`
+	Duration float64        `json:"durationMs"`
+	Passed   int            `json:"passed"`
+	Failed   int            `json:"failed"`
+	Results  []verifyResult `json:"results"`
+}
+
+func newVerifier(spec *OpenAPISpec, baseURL string, header http.Header, query url.Values, timeout time.Duration) (*verifier, error) {
+	schemas, err := collectSchemas(spec)
+	if err != nil {
+		return nil, err
+	}
+	return &verifier{
+		spec:    spec,
+		ops:     collectOperations(spec.Paths),
+		schemas: schemas,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		header:  header,
+		query:   query,
+		client: &http.Client{
+			Timeout: timeout,
+			// Redirects are checked as responses of the operation
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+	}, nil
+}
+
+// run checks the operations in spec order, calling progress after each
+func (v *verifier) run(progress func(verifyResult)) verifyReport {
+	title, _ := v.spec.Info["title"].(string)
+	report := verifyReport{Title: title, BaseURL: v.baseURL, Started: time.Now()}
+	for _, op := range v.ops {
+		result := v.verify(op)
+		if result.Passed() {
+			report.Passed++
+		} else {
+			report.Failed++
+		}
+		report.Results = append(report.Results, result)
+		if progress != nil {
+			progress(result)
+		}
+	}
+	report.Duration = float64(time.Since(report.Started).Microseconds()) / 1000
+	return report
+}
+
+// verify sends an operation its example request, after creating the
+// resources its path parameters refer to
+func (v *verifier) verify(op operation) verifyResult {
+	result := verifyResult{OperationID: op.OperationID, Method: op.Method, Path: op.Path}
+	if tags := routeTags(op); len(tags) > 0 {
+		result.Tag = tags[0]
+	}
+	req := operationRequest(v.spec, op)
+	target, complete, err := v.fillPath(op, req, &result.Setup)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	start := time.Now()
+	resp, body, err := v.send(op, req, target)
+	result.Duration = time.Since(start)
+	result.DurationMS = float64(result.Duration.Microseconds()) / 1000
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.URL = resp.Request.URL.String()
+	result.Status = resp.StatusCode
+	result.Problems = v.checkResponse(op, resp, body, complete)
+	return result
+}
+
+// fillPath substitutes the path parameters of an operation. Each member the
+// path selects is created first through its collection's POST, where the spec
+// has one; complete is false when an example value had to be used instead.
+// POSTs to an item path pick their own ID.
+func (v *verifier) fillPath(op operation, req exampleRequest, setup *[]string) (string, bool, error) {
+	complete := true
+	segments := strings.Split(op.Path, "/")
+	templates := append([]string(nil), segments...)
+	for i, segment := range segments {
+		if !strings.HasPrefix(segment, "{") || !strings.HasSuffix(segment, "}") {
+			continue
+		}
+		name := strings.Trim(segment, "{}")
+		segments[i] = url.PathEscape(req.Params[name])
+		if i == len(segments)-1 && op.Method == "POST" {
+			continue
+		}
+		create, ok := v.createOperation(strings.Join(templates[:i], "/"))
+		if !ok {
+			complete = false
+			continue
+		}
+		collection := strings.Join(segments[:i], "/")
+		resp, body, err := v.send(create, operationRequest(v.spec, create), collection)
+		if err != nil {
+			return "", false, fmt.Errorf("creating the %s with %s: %v", name, create.OperationID, err)
+		}
+		id := v.createdID(create, name, resp, body)
+		*setup = append(*setup, fmt.Sprintf("%s %s: %d, %s=%s", create.Method, collection, resp.StatusCode, name, id))
+		if resp.StatusCode/100 != 2 || id == "" {
+			return "", false, fmt.Errorf("creating the %s with %s: status %d without an ID: %s", name, create.OperationID, resp.StatusCode, snippet(body))
+		}
+		segments[i] = url.PathEscape(id)
+	}
+	return strings.Join(segments, "/"), complete, nil
+}
+
+// createOperation finds the POST creating members of a collection path
+func (v *verifier) createOperation(collection string) (operation, bool) {
+	for _, op := range v.ops {
+		if op.Method == "POST" && op.Path == collection {
+			return op, true
+		}
+	}
+	return operation{}, false
+}
+
+// createdID reads the ID of a created resource from the Location header, or
+// from the ID field of the returned document: the property the generator
+// would store it in, one named like the path parameter, or id
+func (v *verifier) createdID(create operation, param string, resp *http.Response, body []byte) string {
+	if location := resp.Header.Get("Location"); location != "" {
+		if u, err := url.Parse(location); err == nil && u.Path != "" {
+			if id, err := url.PathUnescape(path.Base(u.Path)); err == nil {
+				return id
+			}
+		}
+	}
+	var doc map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if decoder.Decode(&doc) != nil {
+		return ""
+	}
+	fields := []string{resourceIDFor(v.ops, create, v.schemas, operationSuccess(create)).Field, param, "id"}
+	for _, field := range fields {
+		switch id := doc[field].(type) {
+		case string:
+			if field != "" && id != "" {
+				return id
+			}
+		case json.Number:
+			return id.String()
+		}
+	}
+	return ""
+}
+
+// send performs the request of an operation on a concrete path
+func (v *verifier) send(op operation, req exampleRequest, target string) (*http.Response, []byte, error) {
+	var body io.Reader
+	if req.ContentType != "" {
+		body = strings.NewReader(req.Body)
+	}
+	r, err := http.NewRequest(op.Method, v.baseURL+target, body)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(req.Query) > 0 || len(v.query) > 0 {
+		query := r.URL.Query()
+		for name, value := range req.Query {
+			query.Set(name, value)
+		}
+		for name, values := range v.query {
+			query[name] = values
+		}
+		r.URL.RawQuery = query.Encode()
+	}
+	for name, value := range req.Header {
+		r.Header.Set(name, value)
+	}
+	if req.ContentType != "" {
+		r.Header.Set("Content-Type", req.ContentType)
+	}
+	for name, values := range v.header {
+		// Cookies add to those of the operation's parameters
+		if name == "Cookie" && r.Header.Get("Cookie") != "" {
+			r.Header.Set("Cookie", r.Header.Get("Cookie")+"; "+strings.Join(values, "; "))
+			continue
+		}
+		r.Header[name] = values
+	}
+	resp, err := v.client.Do(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading response: %v", err)
+	}
+	return resp, data, nil
+}
+
+// checkResponse compares a response with its declaration: the status must
+// be declared, and a success when the request used real IDs; declared
+// headers must be present and well-typed; the body must have a declared
+// content type and, for JSON, match its schema
+func (v *verifier) checkResponse(op operation, resp *http.Response, body []byte, complete bool) []string {
+	var problems []string
+	status := strconv.Itoa(resp.StatusCode)
+	declared, code := declaredResponse(v.spec, op, status)
+	if declared == nil {
+		problems = append(problems, fmt.Sprintf("status %d is not declared", resp.StatusCode))
+	}
+	if complete && resp.StatusCode/100 != 2 {
+		problems = append(problems, fmt.Sprintf("status %d, want a success: %s", resp.StatusCode, snippet(body)))
+	}
+	if declared == nil {
+		return problems
+	}
+
+	headers, _ := declared["headers"].(map[string]interface{})
+	for _, name := range sortedKeys(headers) {
+		header, _ := resolveRef(v.spec, headers[name]).(map[string]interface{})
+		value := resp.Header.Get(name)
+		if value == "" {
+			if required, _ := header["required"].(bool); required {
+				problems = append(problems, "header "+name+" is missing")
+			}
+			continue
+		}
+		schema := resolveSchema(v.spec, mapValue(header, "schema"))
+		problems = append(problems, validateValue(v.spec, schema, scalarParam(schema, value), "header "+name, false)...)
+	}
+
+	content, _ := declared["content"].(map[string]interface{})
+	if len(body) == 0 || len(content) == 0 {
+		return problems
+	}
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return append(problems, fmt.Sprintf("Content-Type %q is not a media type", resp.Header.Get("Content-Type")))
+	}
+	var media map[string]interface{}
+	for _, declaredType := range sortedKeys(content) {
+		if declaredType == mediaType || (media == nil && matchMediaRange(declaredType, mediaType)) {
+			media, _ = content[declaredType].(map[string]interface{})
+		}
+	}
+	if media == nil {
+		return append(problems, fmt.Sprintf("Content-Type %s is not declared for %s", mediaType, code))
+	}
+	if mediaCategory(mediaType) != "json" {
+		return problems
+	}
+	var doc interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		return append(problems, fmt.Sprintf("body is not JSON: %v", err))
+	}
+	return append(problems, validateValue(v.spec, mapValue(media, "schema"), doc, "body", false)...)
+}
+
+// declaredResponse finds the response an operation declares for a status:
+// the exact code, its range such as 2XX, or default
+func declaredResponse(spec *OpenAPISpec, op operation, status string) (map[string]interface{}, string) {
+	responses, _ := op.Endpoint["responses"].(map[string]interface{})
+	for _, code := range []string{status, status[:1] + "XX", status[:1] + "xx", "default"} {
+		if raw, ok := responses[code]; ok {
+			resp, _ := resolveRef(spec, raw).(map[string]interface{})
+			return resp, code
+		}
+	}
+	return nil, ""
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// snippet shortens a body for a message
+func snippet(body []byte) string {
+	s := strings.TrimSpace(string(body))
+	if len(s) > 200 {
+		s = s[:200] + "..."
+	}
+	return s
+}
+
+// JUnit XML, as read by CI servers
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name      string      `xml:"name,attr"`
+	Tests     int         `xml:"tests,attr"`
+	Failures  int         `xml:"failures,attr"`
+	Errors    int         `xml:"errors,attr"`
+	Time      string      `xml:"time,attr"`
+	Timestamp string      `xml:"timestamp,attr"`
+	Cases     []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeJUnit writes the report as a test suite with a test case per
+// operation, classed by its first tag
+func writeJUnit(report verifyReport, file string) error {
+	suite := junitSuite{
+		Name:      report.Title,
+		Tests:     len(report.Results),
+		Time:      fmt.Sprintf("%.3f", report.Duration/1000),
+		Timestamp: report.Started.Format("2006-01-02T15:04:05"),
+	}
+	for _, r := range report.Results {
+		c := junitCase{
+			Name:      r.OperationID,
+			Classname: r.Tag,
+			Time:      fmt.Sprintf("%.3f", r.Duration.Seconds()),
+		}
+		if c.Classname == "" {
+			c.Classname = r.Path
+		}
+		out := append([]string(nil), r.Setup...)
+		if r.URL != "" {
+			out = append(out, fmt.Sprintf("%s %s: %d", r.Method, r.URL, r.Status))
+		}
+		c.SystemOut = strings.Join(out, "\n")
+		switch {
+		case r.Error != "":
+			suite.Errors++
+			c.Error = &junitMessage{Message: r.Error, Text: r.Error}
+		case len(r.Problems) > 0:
+			suite.Failures++
+			c.Failure = &junitMessage{Message: r.Problems[0], Text: strings.Join(r.Problems, "\n")}
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+	data, err := xml.MarshalIndent(junitSuites{Suites: []junitSuite{suite}}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, append([]byte(xml.Header), append(data, '\n')...), 0644)
+}
+
+// writeJSONReport writes the report as indented JSON
+func writeJSONReport(report verifyReport, file string) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, append(data, '\n'), 0644)
+}